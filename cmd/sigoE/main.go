@@ -27,16 +27,9 @@ import (
 func providerForModelCLI(model string) string {
 	id := sigoengine.ResolveModelName(model)
 	if m, ok := sigoengine.GetModelByID(id); ok {
-		switch {
-		case strings.HasPrefix(m.APIKeyEnv, "MAMMOUTH"):
-			return "mammouth"
-		case strings.HasPrefix(m.APIKeyEnv, "MOONSHOT"):
-			return "moonshot"
-		case strings.HasPrefix(m.APIKeyEnv, "ZAI"):
-			return "zai"
-		}
+		return sigoengine.ProviderForModel(m.Endpoint, m.APIKeyEnv, id)
 	}
-	return "mammouth"
+	return sigoengine.ProviderForModel("", "", id)
 }
 
 func main() {
@@ -0,0 +1,192 @@
+//**********************************************************************
+//      sigoREST/auto_models.go
+//**********************************************************************
+//  Beschreibung: Automatische Modellwahl für model "auto" bzw.
+//  "auto:vision", "auto:cheap", "auto:long". Kandidaten sind alle
+//  Chat-Modelle, die die Features des Requests können (Bilder, Tools,
+//  JSON-Mode, ...), deren Kontextfenster den Prompt fasst, die unter
+//  max_cost liegen und einen nutzbaren Kanal haben (aktiv, nicht auf der
+//  Bank, Circuit Breaker nicht offen). Die Rangfolge hängt vom Profil ab;
+//  serveChat arbeitet sie wie die Kette eines virtuellen Modells ab, das
+//  antwortende Modell steht in "model" und im Header X-Sigo-Model.
+//**********************************************************************
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"sigorest/sigoengine"
+)
+
+// Profile der automatischen Modellwahl ("auto:<profil>").
+const (
+	autoModelName = "auto"
+	autoDefault   = ""       // schnellster gesunder Kanal, dann günstigster Preis
+	autoVision    = "vision" // wie auto, nur Modelle mit ausgewiesener Bild-Eingabe
+	autoCheap     = "cheap"  // günstigster Preis zuerst
+	autoLong      = "long"   // größtes Kontextfenster zuerst
+)
+
+// autoProfiles sind die gültigen Profile (für Fehlermeldungen und /v1/models).
+var autoProfiles = []string{autoVision, autoCheap, autoLong}
+
+// Grenzen der automatischen Modellwahl.
+const (
+	autoMaxTargets    = 5    // Länge der Fallback-Kette
+	autoOutputReserve = 1024 // Platz für die Antwort im Kontextfenster
+)
+
+// autoCandidate ist ein Modell mit den Werten für die Rangfolge.
+type autoCandidate struct {
+	target chatTarget
+	known  bool    // Capabilities ausgewiesen (nicht unbekannt)
+	cost   float64 // Mittel aus Input- und Output-Preis ($/1M Tokens)
+	score  float64 // bester Kanal nach RoutingScore (kleiner ist besser)
+}
+
+// parseAutoModel erkennt "auto" und "auto:<profil>" (case-insensitiv).
+// isAuto ist false für alle anderen Namen; err meldet unbekannte Profile.
+func parseAutoModel(name string) (profile string, isAuto bool, err error) {
+	lower := strings.ToLower(strings.TrimSpace(name))
+	if lower == autoModelName {
+		return autoDefault, true, nil
+	}
+	rest, ok := strings.CutPrefix(lower, autoModelName+":")
+	if !ok {
+		return "", false, nil
+	}
+	for _, p := range autoProfiles {
+		if rest == p {
+			return p, true, nil
+		}
+	}
+	return "", true, fmt.Errorf("Unbekanntes Auto-Profil '%s' (erlaubt: auto, auto:%s)", rest, strings.Join(autoProfiles, ", auto:"))
+}
+
+// autoTargets wählt die Modelle für einen Auto-Request in Rangfolge
+// (höchstens autoMaxTargets). Aufrufer muss s.mu (RLock) halten.
+func (s *Server) autoTargets(profile string, req *ChatRequest) []chatTarget {
+	required := requestCapabilities(req)
+	if profile == autoVision {
+		required = appendMissing(required, sigoengine.CapVision)
+	}
+
+	// Prompt grob mit dem Default-Encoding zählen; das exakte Pre-Flight
+	// je Modell macht runChatTarget.
+	tok := sigoengine.TokenizerForModel("")
+	var messages []map[string]interface{}
+	for _, msg := range req.Messages {
+		messages = append(messages, msg.toEngine().ToMap())
+	}
+	needTokens := sigoengine.EstimatePromptTokens(tok, messages) + autoOutputReserve
+
+	var candidates []autoCandidate
+	for id, info := range s.models {
+		if info.Kind == sigoengine.ModelKindEmbedding || info.Kind == modelKindVirtual {
+			continue
+		}
+		if len(sigoengine.MissingCapabilities(info.Capabilities, required)) > 0 {
+			continue
+		}
+		// auto:vision nur mit ausgewiesener Bild-Eingabe (unbekannt reicht nicht)
+		if profile == autoVision && info.Capabilities == nil {
+			continue
+		}
+		if info.MaxInputTokens > 0 && info.MaxInputTokens < needTokens {
+			continue
+		}
+		cost := (info.InputCost + info.OutputCost) / 2
+		if req.MaxCost > 0 && cost > req.MaxCost {
+			continue
+		}
+		score, ok := s.autoChannelScore(info, id)
+		if !ok {
+			continue
+		}
+		candidates = append(candidates, autoCandidate{
+			target: chatTarget{info: info, id: id, name: id},
+			known:  info.Capabilities != nil,
+			cost:   cost,
+			score:  score,
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		// Ausgewiesene Features vor unbekannten, wenn der Request welche nutzt
+		if len(required) > 0 && a.known != b.known {
+			return a.known
+		}
+		switch profile {
+		case autoCheap:
+			if a.cost != b.cost {
+				return a.cost < b.cost
+			}
+		case autoLong:
+			if a.target.info.MaxInputTokens != b.target.info.MaxInputTokens {
+				return a.target.info.MaxInputTokens > b.target.info.MaxInputTokens
+			}
+		}
+		if a.score != b.score {
+			return a.score < b.score
+		}
+		if a.cost != b.cost {
+			return a.cost < b.cost
+		}
+		return a.target.id < b.target.id
+	})
+
+	if len(candidates) > autoMaxTargets {
+		candidates = candidates[:autoMaxTargets]
+	}
+	targets := make([]chatTarget, len(candidates))
+	ids := make([]string, len(candidates))
+	for i, c := range candidates {
+		targets[i] = c.target
+		ids[i] = c.target.id
+	}
+	sigoengine.LogDebug("Auto-Modellwahl", map[string]interface{}{
+		"model":      req.Model,
+		"required":   strings.Join(required, ","),
+		"tokens":     needTokens,
+		"candidates": strings.Join(ids, ","),
+	})
+	return targets
+}
+
+// autoChannelScore bewertet die Kanäle eines Modells: nutzbar sind aktive
+// Kanäle, die das Modell bedienen, nicht auf der Bank sitzen und deren
+// Circuit Breaker nicht offen ist. Ergebnis ist der beste RoutingScore;
+// ok ist false ohne nutzbaren Kanal. Aufrufer muss s.mu (RLock) halten.
+func (s *Server) autoChannelScore(info ModelInfo, id string) (float64, bool) {
+	provider := sigoengine.ProviderForModel(info.Endpoint, info.APIKey, id)
+	registry := s.channelManager.Registry()
+	best, ok := math.Inf(1), false
+	for _, ch := range registry.Channels(provider) {
+		if !ch.Active || !sigoengine.ChannelServesModel(ch, id) || s.benchedUntil(ch.FullName()) != nil {
+			continue
+		}
+		if cb := s.breakers[id+"#"+ch.FullName()]; cb != nil && cb.IsOpen() {
+			continue
+		}
+		if score := registry.RoutingScore(id, ch); score < best {
+			best = score
+		}
+		ok = true
+	}
+	return best, ok
+}
+
+// appendMissing hängt c an, falls noch nicht enthalten.
+func appendMissing(caps []string, c string) []string {
+	for _, have := range caps {
+		if have == c {
+			return caps
+		}
+	}
+	return append(caps, c)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sigorest/sigoengine"
+)
+
+func TestParseAutoModel(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		isAuto  bool
+		err     bool
+	}{
+		{"auto", autoDefault, true, false},
+		{"AUTO:Cheap", autoCheap, true, false},
+		{"auto:vision", autoVision, true, false},
+		{"auto:long", autoLong, true, false},
+		{"auto:fast", "", true, true},
+		{"automatic", "", false, false},
+		{"cl-s", "", false, false},
+	}
+	for _, tt := range tests {
+		profile, isAuto, err := parseAutoModel(tt.name)
+		if profile != tt.profile || isAuto != tt.isAuto || (err != nil) != tt.err {
+			t.Errorf("parseAutoModel(%q) = %q, %v, %v", tt.name, profile, isAuto, err)
+		}
+	}
+}
+
+func TestChatCompletionsAutoModel(t *testing.T) {
+	var calls []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		calls = append(calls, body["model"].(string))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer upstream.Close()
+
+	srv, _ := newTestServer(t)
+	srv.channelManager.Registry().AddChannel(&sigoengine.Channel{
+		Provider: "moonshot", Name: "default", APIKey: "moon-key", Active: true, Healthy: true,
+	})
+	addMockModel(srv, upstream)
+	mock := srv.models["mock-model"]
+	mock.Capabilities = []string{sigoengine.CapTools, sigoengine.CapStreaming}
+	mock.InputCost, mock.OutputCost, mock.MaxInputTokens = 1, 3, 100000
+	srv.models["mock-model"] = mock
+	srv.models["kimi-test"] = ModelInfo{
+		ID:             "kimi-test",
+		Shortcode:      "kt",
+		Endpoint:       upstream.URL + "/v1/chat/completions",
+		APIKey:         "MOONSHOT_API_KEY",
+		MaxTemperature: 1.0,
+		InputCost:      4,
+		OutputCost:     16,
+		MaxInputTokens: 200000,
+		Capabilities:   []string{sigoengine.CapVision, sigoengine.CapTools, sigoengine.CapStreaming},
+	}
+	// Günstigstes Modell, aber Kontextfenster zu klein für Prompt plus Antwort
+	srv.models["tiny-model"] = ModelInfo{
+		ID:             "tiny-model",
+		Shortcode:      "tiny",
+		Endpoint:       upstream.URL + "/v1/chat/completions",
+		MaxTemperature: 1.0,
+		InputCost:      0.1,
+		OutputCost:     0.1,
+		MaxInputTokens: 50,
+	}
+
+	image := `[{"type":"text","text":"Was ist das?"},{"type":"image_url","image_url":{"url":"data:image/png;base64,AAAA"}}]`
+	chosen := []struct {
+		body  string
+		model string
+	}{
+		{`{"model":"auto","messages":[{"role":"user","content":` + image + `}]}`, "kimi-test"},
+		{`{"model":"auto:cheap","messages":[{"role":"user","content":"Hi"}]}`, "mock-model"},
+		{`{"model":"auto:long","messages":[{"role":"user","content":"Hi"}]}`, "kimi-test"},
+		{`{"model":"auto:vision","messages":[{"role":"user","content":"Hi"}]}`, "kimi-test"},
+		{`{"model":"auto","max_cost":5,"messages":[{"role":"user","content":"Hi"}]}`, "mock-model"},
+	}
+	for _, tt := range chosen {
+		calls = nil
+		rr := httptest.NewRecorder()
+		srv.handleChatCompletions(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(tt.body)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", tt.body, rr.Code, rr.Body.String())
+		}
+		var resp ChatResponse
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		if resp.Model != tt.model || rr.Header().Get("X-Sigo-Model") != tt.model || len(calls) != 1 || calls[0] != tt.model {
+			t.Fatalf("%s: expected %s, got model=%q header=%q calls=%v", tt.body, tt.model, resp.Model, rr.Header().Get("X-Sigo-Model"), calls)
+		}
+	}
+
+	// Kein Kandidat: Preisgrenze, unbekanntes Profil, Kanal deaktiviert
+	srv.channelManager.Registry().SetActive("moonshot", "default", false)
+	calls = nil
+	for _, body := range []string{
+		`{"model":"auto","max_cost":1,"messages":[{"role":"user","content":"Hi"}]}`,
+		`{"model":"auto:fast","messages":[{"role":"user","content":"Hi"}]}`,
+		`{"model":"auto:vision","messages":[{"role":"user","content":"Hi"}]}`,
+	} {
+		rr := httptest.NewRecorder()
+		srv.handleChatCompletions(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+		var errResp ErrorResponse
+		json.Unmarshal(rr.Body.Bytes(), &errResp)
+		if rr.Code != http.StatusBadRequest || errResp.Error.Code != "model_not_found" {
+			t.Fatalf("%s: expected 400 model_not_found, got %d: %s", body, rr.Code, rr.Body.String())
+		}
+	}
+	if len(calls) != 0 {
+		t.Fatalf("expected no upstream call, got %v", calls)
+	}
+
+	// /v1/models listet die Auto-Profile
+	rr := httptest.NewRecorder()
+	srv.handleModels(rr, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	if !strings.Contains(rr.Body.String(), `"id":"auto:cheap"`) {
+		t.Errorf("auto profiles missing in /v1/models: %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,108 @@
+//**********************************************************************
+//      sigoREST/capabilities.go
+//**********************************************************************
+//  Beschreibung: Prüfung der Request-Features gegen die Capabilities
+//  des Modells (sigoengine/capabilities.go). Nutzt ein Request Bilder,
+//  Tools, JSON-Mode, Reasoning oder Streaming, die das Modell nicht
+//  kann, kommt HTTP 400 unsupported_feature ohne Provider-Call
+//  (virtuelle Modelle: nächstes Modell der Kette).
+//**********************************************************************
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"sigorest/sigoengine"
+)
+
+// capabilityLabels beschreiben die Features in Fehlermeldungen.
+var capabilityLabels = map[string]string{
+	sigoengine.CapVision:    "Bilder in messages",
+	sigoengine.CapTools:     "tools",
+	sigoengine.CapJSONMode:  "response_format (JSON-Mode)",
+	sigoengine.CapReasoning: "Reasoning (reasoning_effort/thinking)",
+	sigoengine.CapStreaming: "stream",
+}
+
+// requestCapabilities liefert die Features, die ein Request nutzt.
+func requestCapabilities(req *ChatRequest) []string {
+	var caps []string
+	for _, msg := range req.Messages {
+		if contentHasImage(msg.Content) {
+			caps = append(caps, sigoengine.CapVision)
+			break
+		}
+	}
+	pass := req.Passthrough()
+	if nonEmptyJSON(pass["tools"]) || nonEmptyJSON(pass["functions"]) {
+		caps = append(caps, sigoengine.CapTools)
+	}
+	if rf, ok := pass["response_format"]; ok {
+		var format struct {
+			Type string `json:"type"`
+		}
+		json.Unmarshal(rf, &format)
+		if format.Type == "json_object" || format.Type == "json_schema" {
+			caps = append(caps, sigoengine.CapJSONMode)
+		}
+	}
+	if nonEmptyJSON(pass["reasoning_effort"]) || nonEmptyJSON(pass["reasoning"]) || thinkingEnabled(pass["thinking"]) {
+		caps = append(caps, sigoengine.CapReasoning)
+	}
+	if req.Stream {
+		caps = append(caps, sigoengine.CapStreaming)
+	}
+	return caps
+}
+
+// contentHasImage meldet Bild-Parts (image_url/image) in einem Inhalt.
+func contentHasImage(content json.RawMessage) bool {
+	var parts []struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(content, &parts); err != nil {
+		return false
+	}
+	for _, p := range parts {
+		if p.Type == "image_url" || p.Type == "image" {
+			return true
+		}
+	}
+	return false
+}
+
+// nonEmptyJSON meldet einen gesetzten Wert (nicht null, "", [] oder {}).
+func nonEmptyJSON(v json.RawMessage) bool {
+	switch strings.TrimSpace(string(v)) {
+	case "", "null", `""`, "[]", "{}":
+		return false
+	}
+	return true
+}
+
+// thinkingEnabled wertet "thinking" aus ({"type":"enabled"} bzw. Objekt
+// ohne type; {"type":"disabled"} zählt nicht).
+func thinkingEnabled(v json.RawMessage) bool {
+	if !nonEmptyJSON(v) {
+		return false
+	}
+	var thinking struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(v, &thinking); err != nil {
+		return string(v) == "true"
+	}
+	return thinking.Type != "disabled"
+}
+
+// capabilityError beschreibt die fehlenden Features eines Modells.
+func capabilityError(model string, missing []string) error {
+	labels := make([]string, len(missing))
+	for i, c := range missing {
+		labels[i] = capabilityLabels[c]
+	}
+	return fmt.Errorf("Modell '%s' unterstützt nicht: %s", model, strings.Join(labels, ", "))
+}
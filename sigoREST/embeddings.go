@@ -0,0 +1,263 @@
+//**********************************************************************
+//      sigoREST/embeddings.go
+//**********************************************************************
+//  Beschreibung: OpenAI-kompatibler Endpunkt POST /v1/embeddings.
+//  Nutzt dieselben Bausteine wie serveChat: Modell-Lookup, Kanal-
+//  Failover, Rate-Limiter, Circuit Breaker und Usage-Zählung.
+//  Backends: OpenAI-kompatible /embeddings-Endpoints und Ollama
+//  /api/embed (siehe sigoengine/embeddings.go).
+//**********************************************************************
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"sigorest/sigoengine"
+)
+
+// **********************************************************************
+// Request/Response Typen (OpenAI Embeddings API)
+
+type EmbeddingRequest struct {
+	Model          string          `json:"model"`
+	Input          json.RawMessage `json:"input"` // String, []String, []int oder [][]int
+	EncodingFormat string          `json:"encoding_format,omitempty"`
+	Dimensions     int             `json:"dimensions,omitempty"`
+	User           string          `json:"user,omitempty"`
+	// sigoREST-Erweiterungen wie bei /v1/chat/completions
+	Timeout int    `json:"timeout"`
+	Retries int    `json:"retries"`
+	Channel string `json:"channel"`
+}
+
+type EmbeddingData struct {
+	Object    string          `json:"object"`
+	Index     int             `json:"index"`
+	Embedding json.RawMessage `json:"embedding"`
+}
+
+type EmbeddingUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+type EmbeddingResponse struct {
+	Object string          `json:"object"`
+	Data   []EmbeddingData `json:"data"`
+	Model  string          `json:"model"`
+	Usage  EmbeddingUsage  `json:"usage"`
+}
+
+// embeddingInputText liefert den Text aller Inputs (für die Usage-
+// Schätzung), die Zahl der Tokens bei Token-Arrays (sonst 0, der Text
+// wird gezählt) und die Anzahl der Inputs.
+func embeddingInputText(raw json.RawMessage) (string, int, int, error) {
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return single, 0, 1, nil
+	}
+	var texts []string
+	if err := json.Unmarshal(raw, &texts); err == nil && len(texts) > 0 {
+		return strings.Join(texts, "\n"), 0, len(texts), nil
+	}
+	var tokens []int
+	if err := json.Unmarshal(raw, &tokens); err == nil && len(tokens) > 0 {
+		return "", len(tokens), 1, nil
+	}
+	var batches [][]int
+	if err := json.Unmarshal(raw, &batches); err == nil && len(batches) > 0 {
+		n := 0
+		for _, b := range batches {
+			n += len(b)
+		}
+		return "", n, len(batches), nil
+	}
+	return "", 0, 0, fmt.Errorf("input muss ein String, ein String-Array oder ein Token-Array sein")
+}
+
+// **********************************************************************
+// POST /v1/embeddings
+
+func (s *Server) handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	out := openAIFormat{}
+	if r.Method != http.MethodPost {
+		out.writeError(w, "Method not allowed", "invalid_request", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req EmbeddingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		out.writeError(w, "Invalid JSON: "+err.Error(), "invalid_request", http.StatusBadRequest)
+		return
+	}
+	inputText, inputTokens, inputCount, err := embeddingInputText(req.Input)
+	if err != nil {
+		out.writeError(w, err.Error(), "invalid_request", http.StatusBadRequest)
+		return
+	}
+	if req.EncodingFormat != "" && req.EncodingFormat != "float" && req.EncodingFormat != "base64" {
+		out.writeError(w, "encoding_format muss 'float' oder 'base64' sein", "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.RLock()
+	modelInfo, modelID, exists := s.lookupModel(req.Model)
+	s.mu.RUnlock()
+	if !exists {
+		out.writeError(w, fmt.Sprintf("Model '%s' nicht gefunden", req.Model), "model_not_found", http.StatusBadRequest)
+		return
+	}
+	if modelInfo.Kind != sigoengine.ModelKindEmbedding {
+		out.writeError(w, fmt.Sprintf("Model '%s' ist kein Embedding-Modell", req.Model), "invalid_request", http.StatusBadRequest)
+		return
+	}
+	if inputTokens == 0 {
+		inputTokens = modelTokenizer(modelInfo, modelID).Count(inputText)
+	}
+
+	provider := s.providerForModel(modelID)
+	ch, err := s.resolveChannel(provider, modelID, req.Channel)
+	if err != nil {
+		apiErr := sigoengine.ClassifyError(err)
+		httpStatus := http.StatusBadRequest
+		if apiErr.Type == sigoengine.ErrConfigNotFound {
+			httpStatus = http.StatusNotFound
+		}
+		out.writeError(w, err.Error(), apiErr.Type, httpStatus)
+		return
+	}
+
+	if req.Timeout == 0 {
+		req.Timeout = sigoengine.DEFAULT_TIMEOUT
+	}
+	if req.Retries == 0 {
+		req.Retries = 3
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(req.Timeout)*time.Second)
+	defer cancel()
+
+	// Scheduler wie bei Chat: Platz in der Queue des Providers
+	leave, err := s.enterQueue(ctx, r, provider)
+	if err != nil {
+		s.writeUpstreamError(w, out, modelID, err)
+		return
+	}
+	defer leave()
+
+	retryConfig := sigoengine.DefaultRetryConfig()
+	retryConfig.MaxRetries = req.Retries
+
+	var result *sigoengine.EmbeddingResult
+	var successfulCh *sigoengine.Channel
+	var lastErr, unreachable error
+	pinged := make(map[string]error)
+	for _, currentCh := range s.failoverChain(provider, ch) {
+		// Config, Ping und Rate-Limiter wie bei Chat (TPM-Schätzung: nur
+		// Input, Embeddings haben keinen Output)
+		cfg, release, down, err := s.prepareChannel(ctx, pinged, modelID, modelInfo.Endpoint, currentCh, inputTokens)
+		if err != nil {
+			lastErr = err
+			if down {
+				unreachable = err
+			}
+			// ctx abgebrochen: kein weiterer Kanal
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		apiRequest := map[string]interface{}{
+			"model": cfg.Model,
+			"input": req.Input,
+		}
+		if req.EncodingFormat != "" {
+			apiRequest["encoding_format"] = req.EncodingFormat
+		}
+		if req.Dimensions > 0 {
+			apiRequest["dimensions"] = req.Dimensions
+		}
+		if req.User != "" {
+			apiRequest["user"] = req.User
+		}
+
+		breaker := s.breakerFor(modelID, currentCh)
+		done := s.channelManager.BeginRequest(currentCh)
+		lastErr = sigoengine.RetryWithBackoff(ctx, retryConfig, func() error {
+			return s.benchedError(currentCh, breaker.Do(func() error {
+				start := time.Now()
+				res, e := sigoengine.CallEmbeddings(ctx, cfg, apiRequest, req.Timeout)
+				s.recordChannelCall(ctx, currentCh, modelID, time.Since(start), e)
+				if e != nil {
+					s.disableOnAuthError(currentCh, e)
+					return e
+				}
+				result = res
+				return nil
+			}))
+		})
+		done()
+		if lastErr == nil {
+			release(result.Usage)
+		} else {
+			release(nil)
+		}
+
+		if lastErr == nil {
+			successfulCh = currentCh
+			s.channelManager.Registry().MarkChannelHealth(currentCh.Provider, currentCh.Name, true, "")
+			break
+		}
+
+		s.channelManager.Registry().MarkChannelHealth(currentCh.Provider, currentCh.Name, false, lastErr.Error())
+
+		apiErr := sigoengine.ClassifyError(lastErr)
+		if apiErr.Type == sigoengine.ErrClientError {
+			break
+		}
+		sigoengine.LogWarn("Failing over to next channel", map[string]interface{}{
+			"model":      modelID,
+			"channel":    currentCh.FullName(),
+			"error_type": apiErr.Type,
+		})
+	}
+
+	if lastErr != nil && lastErr == unreachable {
+		out.writeError(w, "Provider nicht erreichbar: "+lastErr.Error(), "provider_unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if lastErr != nil {
+		s.writeUpstreamError(w, out, modelID, lastErr)
+		return
+	}
+	if len(result.Embeddings) != inputCount {
+		out.writeError(w, fmt.Sprintf("Provider lieferte %d Embeddings für %d Inputs", len(result.Embeddings), inputCount), "api_error", http.StatusBadGateway)
+		return
+	}
+
+	// Usage schätzen falls Provider keine liefert (Embeddings: nur Input)
+	usage := result.Usage
+	if usage == nil {
+		usage = &sigoengine.UsageData{InputTokens: inputTokens, TotalTokens: inputTokens}
+	}
+	s.recordUsage(modelID, successfulCh, usage)
+
+	resp := EmbeddingResponse{
+		Object: "list",
+		Data:   make([]EmbeddingData, len(result.Embeddings)),
+		Model:  req.Model,
+		Usage:  EmbeddingUsage{PromptTokens: usage.InputTokens, TotalTokens: usage.TotalTokens},
+	}
+	for i, emb := range result.Embeddings {
+		resp.Data[i] = EmbeddingData{Object: "embedding", Index: i, Embedding: emb}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
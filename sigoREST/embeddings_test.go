@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sigorest/sigoengine"
+)
+
+func addMockEmbeddingModel(srv *Server, upstream *httptest.Server) {
+	srv.models["mock-embed"] = ModelInfo{
+		ID:        "mock-embed",
+		Shortcode: "memb",
+		Endpoint:  upstream.URL + "/v1/embeddings",
+		Kind:      sigoengine.ModelKindEmbedding,
+	}
+}
+
+func TestEmbeddingsFailoverAndUsage(t *testing.T) {
+	var keys []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		keys = append(keys, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") == "Bearer default-key" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":[{"index":0,"embedding":[0.1]},{"index":1,"embedding":[0.2]}],"usage":{"prompt_tokens":5,"total_tokens":5}}`))
+	}))
+	defer upstream.Close()
+
+	srv, _ := newTestServer(t)
+	srv.channelManager.Registry().SetActive("mammouth", "0", true)
+	addMockEmbeddingModel(srv, upstream)
+
+	body := `{"model":"memb","input":["eins","zwei"],"retries":1}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	srv.handleEmbeddings(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if keys[len(keys)-1] != "Bearer key-0" {
+		t.Fatalf("expected failover to channel 0, got %v", keys)
+	}
+
+	var resp EmbeddingResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if resp.Object != "list" || len(resp.Data) != 2 || resp.Data[1].Index != 1 || string(resp.Data[1].Embedding) != "[0.2]" {
+		t.Fatalf("unexpected response: %s", rr.Body.String())
+	}
+	if resp.Usage.PromptTokens != 5 {
+		t.Fatalf("unexpected usage: %+v", resp.Usage)
+	}
+
+	if u := srv.usage["mock-embed"]; u == nil || u.InputTokens != 5 || u.Requests != 1 {
+		t.Fatalf("usage not recorded: %+v", u)
+	}
+	if u := srv.usageByChannel["mock-embed#mammouth-0"]; u == nil || u.TotalTokens != 5 {
+		t.Fatalf("usage by channel not recorded: %+v", srv.usageByChannel)
+	}
+}
+
+// Provider aus providers.json: wie bei Chat ohne "<name>/"-Präfix beim
+// Provider, Kanal über prepareChannel (Rate-Limiter zählt den Call).
+func TestEmbeddingsConfiguredProvider(t *testing.T) {
+	var models []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		models = append(models, body["model"].(string))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":[{"index":0,"embedding":[0.1]}],"usage":{"prompt_tokens":2,"total_tokens":2}}`))
+	}))
+	defer upstream.Close()
+
+	path := filepath.Join(t.TempDir(), "providers.json")
+	os.WriteFile(path, []byte(`{"providers":[{"name":"embedgpu","base_url":"`+upstream.URL+`/embedgpu/v1"}]}`), 0644)
+	if _, err := sigoengine.LoadProviderConfig(path); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, _ := newTestServer(t)
+	srv.channelManager.Registry().AddChannel(&sigoengine.Channel{
+		Provider: "embedgpu", Name: "default", APIKey: "gpu-key", Active: true, Healthy: true, MaxConcurrent: 1,
+	})
+	srv.models["embedgpu/bge-m3"] = ModelInfo{
+		ID:        "embedgpu/bge-m3",
+		Shortcode: "bge",
+		Endpoint:  upstream.URL + "/embedgpu/v1/embeddings",
+		APIKey:    "EMBEDGPU_API_KEY",
+		Kind:      sigoengine.ModelKindEmbedding,
+	}
+
+	body := `{"model":"bge","input":"hallo"}`
+	rr := httptest.NewRecorder()
+	srv.handleEmbeddings(rr, httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if strings.Join(models, ",") != "bge-m3" {
+		t.Fatalf("expected upstream model without prefix, got %v", models)
+	}
+	if st := srv.requestQueue.Stats(); len(st) != 1 || st[0].Admitted != 1 {
+		t.Errorf("expected embedding call in the queue, got %+v", st)
+	}
+}
+
+func TestEmbeddingsRejectsChatModel(t *testing.T) {
+	srv, _ := newTestServer(t)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+	addMockModel(srv, upstream)
+	addMockEmbeddingModel(srv, upstream)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(`{"model":"mock","input":"x"}`))
+	rr := httptest.NewRecorder()
+	srv.handleEmbeddings(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for chat model, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+		strings.NewReader(`{"model":"memb","messages":[{"role":"user","content":"Hi"}]}`))
+	rr = httptest.NewRecorder()
+	srv.handleChatCompletions(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for embedding model on chat endpoint, got %d", rr.Code)
+	}
+}
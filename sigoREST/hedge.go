@@ -0,0 +1,150 @@
+//**********************************************************************
+//      sigoREST/hedge.go
+//**********************************************************************
+//  Beschreibung: Hedged Requests für latenzkritische Non-Streaming-Calls
+//  (hedge_after_ms im Request oder model_settings.json). Antwortet der
+//  Primärkanal nicht rechtzeitig, geht derselbe Request zusätzlich an den
+//  nächsten aktiven Kanal; die erste erfolgreiche Antwort gewinnt, der
+//  andere Call wird abgebrochen. Beide Calls laufen über den Rate-Limiter
+//  und werden getrennt in der Usage gezählt; der zweite Call belegt einen
+//  eigenen Platz in der Provider-Queue.
+//**********************************************************************
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"sigorest/sigoengine"
+)
+
+// channelCall führt einen Non-Streaming-Chat-Call auf einem Kanal aus.
+type channelCall func(ctx context.Context, ch *sigoengine.Channel, cfg *sigoengine.ProviderConfig) (*sigoengine.ChatResult, error)
+
+// channelPrepare baut die Config eines Kanals und prüft Ping und
+// Rate-Limiter (wie die Failover-Schleife); release gibt den Slot frei und
+// korrigiert die TPM-Schätzung (siehe acquireChannel).
+type channelPrepare func(ctx context.Context, ch *sigoengine.Channel) (cfg *sigoengine.ProviderConfig, release func(*sigoengine.UsageData), err error)
+
+// queueAdmit belegt ohne Warten einen Platz in der Provider-Queue
+// (ok false: kein Platz frei, kein Hedge).
+type queueAdmit func() (leave func(), ok bool)
+
+// hedgeAttempt ist das Ergebnis eines Calls im Hedge.
+type hedgeAttempt struct {
+	ch       *sigoengine.Channel // nil: kein Backup-Kanal verfügbar
+	res      *sigoengine.ChatResult
+	err      error
+	backup   bool
+	consumed int // Backup: Anzahl verbrauchter Kanäle aus backups
+}
+
+// hedgeOutcome fasst den Hedge für die Failover-Schleife zusammen.
+type hedgeOutcome struct {
+	winner   *hedgeAttempt  // erster erfolgreicher Call, nil wenn keiner
+	failed   []hedgeAttempt // fehlgeschlagene Calls in Ankunftsreihenfolge
+	consumed int            // Kanäle aus backups, die die Schleife überspringt
+}
+
+// hedgedCall startet call auf dem Primärkanal. Ist nach after keine
+// Antwort da, läuft derselbe Call zusätzlich auf dem ersten Backup-Kanal,
+// den prepare freigibt, sofern admit einen Queue-Platz vergibt. Der
+// erste Erfolg gewinnt; der Verlierer wird abgebrochen und im Hintergrund
+// an onLoser übergeben (Usage). Scheitert der Primärkanal vor after, gibt
+// es keinen Hedge (normaler Failover). Die Rate-Limiter-Slots
+// (primaryRelease bzw. aus prepare) und der Queue-Platz des zweiten Calls
+// werden frei, sobald der jeweilige Call endet.
+func (s *Server) hedgedCall(ctx context.Context, after time.Duration, primary *sigoengine.Channel,
+	primaryCfg *sigoengine.ProviderConfig, primaryRelease func(*sigoengine.UsageData), backups []*sigoengine.Channel,
+	admit queueAdmit, prepare channelPrepare, call channelCall, onLoser func(hedgeAttempt)) hedgeOutcome {
+
+	results := make(chan hedgeAttempt, 2)
+	primaryCtx, cancelPrimary := context.WithCancel(ctx)
+	backupCtx, cancelBackup := context.WithCancel(ctx)
+	go func() {
+		res, err := call(primaryCtx, primary, primaryCfg)
+		primaryRelease(resultUsage(res))
+		results <- hedgeAttempt{ch: primary, res: res, err: err}
+	}()
+
+	timer := time.NewTimer(after)
+	defer timer.Stop()
+	hedgeTimer := timer.C
+
+	var out hedgeOutcome
+	pending := 1
+	for pending > 0 {
+		select {
+		case <-hedgeTimer:
+			hedgeTimer = nil
+			pending++
+			go func() {
+				// Zweiter Call zählt in der Queue wie ein eigener Request
+				leave, ok := admit()
+				if !ok {
+					sigoengine.LogDebug("Hedge: kein freier Queue-Platz", map[string]interface{}{
+						"primary": primary.FullName(),
+					})
+					results <- hedgeAttempt{backup: true}
+					return
+				}
+				defer leave()
+				// Vorbereitung im Hintergrund: wartet der Rate-Limiter des
+				// Backup-Kanals, soll eine Primärantwort nicht mitwarten.
+				for i, ch := range backups {
+					cfg, release, err := prepare(backupCtx, ch)
+					if err != nil {
+						if backupCtx.Err() != nil {
+							break
+						}
+						continue
+					}
+					sigoengine.LogInfo("Hedge: Primärkanal antwortet nicht, zweiter Kanal gestartet", map[string]interface{}{
+						"primary":  primary.FullName(),
+						"channel":  ch.FullName(),
+						"after_ms": after.Milliseconds(),
+					})
+					res, err := call(backupCtx, ch, cfg)
+					release(resultUsage(res))
+					results <- hedgeAttempt{ch: ch, res: res, err: err, backup: true, consumed: i + 1}
+					return
+				}
+				results <- hedgeAttempt{backup: true}
+			}()
+
+		case a := <-results:
+			pending--
+			if a.backup {
+				out.consumed = a.consumed
+				if a.ch == nil {
+					continue
+				}
+			}
+			if a.err == nil {
+				winner := a
+				out.winner = &winner
+				cancelPrimary()
+				cancelBackup()
+				if pending > 0 {
+					go func() {
+						if loser := <-results; loser.ch != nil {
+							onLoser(loser)
+						}
+					}()
+				}
+				return out
+			}
+			out.failed = append(out.failed, a)
+			if !a.backup && hedgeTimer != nil {
+				// Primärkanal vor dem Hedge gescheitert: normaler Failover
+				cancelPrimary()
+				cancelBackup()
+				return out
+			}
+		}
+	}
+	cancelPrimary()
+	cancelBackup()
+	return out
+}
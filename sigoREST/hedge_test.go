@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sigorest/sigoengine"
+)
+
+// hedgeUpstream antwortet auf default-key langsam und auf key-0 sofort.
+func hedgeUpstream(t *testing.T, slow time.Duration) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var calls []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		key := r.Header.Get("Authorization")
+		mu.Lock()
+		calls = append(calls, key)
+		mu.Unlock()
+		if key == "Bearer default-key" {
+			select {
+			case <-time.After(slow):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"` + key + `"}}],` +
+			`"usage":{"prompt_tokens":10,"completion_tokens":2,"total_tokens":12}}`))
+	}))
+	t.Cleanup(upstream.Close)
+	return upstream, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), calls...)
+	}
+}
+
+func TestChatCompletionsHedgedRequest(t *testing.T) {
+	upstream, calls := hedgeUpstream(t, 2*time.Second)
+	srv, _ := newTestServer(t)
+	srv.channelManager.Registry().SetActive("mammouth", "0", true)
+	addMockModel(srv, upstream)
+
+	body := `{"model":"mock","hedge_after_ms":50,"messages":[{"role":"user","content":"hi"}]}`
+	rr := httptest.NewRecorder()
+	start := time.Now()
+	srv.handleChatCompletions(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("hedge did not shortcut the slow channel: %v", elapsed)
+	}
+	var resp ChatResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if got := string(resp.Choices[0].Message.Content); got != `"Bearer key-0"` {
+		t.Fatalf("expected answer from hedge channel, got %s", got)
+	}
+	if got := calls(); len(got) != 2 {
+		t.Fatalf("expected primary + hedge call, got %v", got)
+	}
+
+	// Beide Versuche getrennt gezählt (Verlierer im Hintergrund, geschätzt)
+	deadline := time.Now().Add(time.Second)
+	for {
+		srv.usageMu.RLock()
+		primary := srv.usageByChannel["mock-model#mammouth-default"]
+		hedge := srv.usageByChannel["mock-model#mammouth-0"]
+		srv.usageMu.RUnlock()
+		if primary != nil && hedge != nil {
+			if hedge.TotalTokens != 12 || primary.Requests != 1 {
+				t.Errorf("usage primary=%+v hedge=%+v", primary, hedge)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("usage of both attempts not recorded: %v", srv.usageByChannel)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestChatCompletionsHedgeConfiguredProvider: Hedge über einen Provider
+// aus providers.json (entfernt das Modell-Präfix) – beide Calls mit
+// eigenem Request (go test -race) und eigenem Queue-Platz.
+func TestChatCompletionsHedgeConfiguredProvider(t *testing.T) {
+	var mu sync.Mutex
+	var models []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		models = append(models, body["model"].(string))
+		mu.Unlock()
+		if r.Header.Get("Authorization") == "Bearer gpu-slow" {
+			select {
+			case <-time.After(2 * time.Second):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer upstream.Close()
+
+	path := filepath.Join(t.TempDir(), "providers.json")
+	os.WriteFile(path, []byte(`{"providers":[{"name":"hedgegpu","base_url":"`+upstream.URL+`/hedgegpu/v1"}]}`), 0644)
+	if _, err := sigoengine.LoadProviderConfig(path); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, _ := newTestServer(t)
+	registry := srv.channelManager.Registry()
+	registry.AddChannel(&sigoengine.Channel{Provider: "hedgegpu", Name: "default", APIKey: "gpu-slow", Active: true, Healthy: true, MaxConcurrent: 1})
+	registry.AddChannel(&sigoengine.Channel{Provider: "hedgegpu", Name: "0", APIKey: "gpu-fast", Active: true, Healthy: true, Order: 1, MaxConcurrent: 1})
+	srv.models["hedgegpu/qwen"] = ModelInfo{
+		ID:             "hedgegpu/qwen",
+		Shortcode:      "hq",
+		Endpoint:       upstream.URL + "/hedgegpu/v1/chat/completions",
+		APIKey:         "HEDGEGPU_API_KEY",
+		MaxTemperature: 2.0,
+	}
+
+	body := `{"model":"hq","hedge_after_ms":50,"messages":[{"role":"user","content":"hi"}]}`
+	rr := httptest.NewRecorder()
+	srv.handleChatCompletions(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	mu.Lock()
+	got := strings.Join(models, ",")
+	mu.Unlock()
+	if got != "qwen,qwen" {
+		t.Fatalf("expected two calls without prefix, got %q", got)
+	}
+	if st := srv.requestQueue.Stats(); len(st) != 1 || st[0].Admitted != 2 {
+		t.Errorf("expected request and hedge in the queue, got %+v", st)
+	}
+}
+
+func TestChatCompletionsHedgeModelDefault(t *testing.T) {
+	upstream, calls := hedgeUpstream(t, 20*time.Millisecond)
+	srv, _ := newTestServer(t)
+	srv.channelManager.Registry().SetActive("mammouth", "0", true)
+	addMockModel(srv, upstream)
+	srv.modelSettings = map[string]ModelSettings{"mock": {HedgeAfterMs: 500}}
+
+	// Primärkanal antwortet vor hedge_after_ms → kein zweiter Call
+	body := `{"model":"mock","messages":[{"role":"user","content":"hi"}]}`
+	rr := httptest.NewRecorder()
+	srv.handleChatCompletions(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	if rr.Code != http.StatusOK || len(calls()) != 1 {
+		t.Fatalf("expected single call, got %d, calls %v", rr.Code, calls())
+	}
+}
+
+func TestLoadModelSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model_settings.json")
+	if settings, err := loadModelSettings(path); err != nil || len(settings) != 0 {
+		t.Fatalf("fehlende Datei: %v, %v", settings, err)
+	}
+	os.WriteFile(path, []byte(`{"models":{"CL-S":{"hedge_after_ms":2500}}}`), 0644)
+	settings, err := loadModelSettings(path)
+	if err != nil || settings["cl-s"].HedgeAfterMs != 2500 {
+		t.Fatalf("loadModelSettings = %v, %v", settings, err)
+	}
+	os.WriteFile(path, []byte(`{"models":{"x":{"hedge_after_ms":-1}}}`), 0644)
+	if _, err := loadModelSettings(path); err == nil {
+		t.Error("expected error for negative hedge_after_ms")
+	}
+}
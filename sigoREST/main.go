@@ -24,9 +24,11 @@ import (
 	"crypto/x509/pkix"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"maps"
 	"math/big"
 	"net"
 	"net/http"
@@ -43,23 +45,47 @@ import (
 )
 
 // streamProviderResponse leitet einen OpenAI-kompatiblen SSE-Stream vom Provider
-// an den Client durch und sammelt den Assistant-Text für Sessions.
-// Gibt den akkumulierten Text oder einen Fehler zurück.
-func (s *Server) streamProviderResponse(w http.ResponseWriter, stream io.ReadCloser, model string) (string, error) {
+// an den Client durch und sammelt Assistant-Text und tool_calls für Sessions.
+// enc formt die data:-Payloads ins Client-Format um (OpenAI oder Anthropic).
+//
+// Header und Chunks werden erst geschrieben, wenn der erste Chunk mit Inhalt
+// (Text, tool_calls) da ist oder der Stream regulär endet. Bis dahin ist
+// committed false und ein Fehler kann vom Aufrufer per Retry/Failover
+// behandelt werden. Nach dem Commit schreibt streamProviderResponse bei
+// Fehlern selbst ein Fehler-Event in den Stream (statt ihn stumm abzuschneiden).
+func (s *Server) streamProviderResponse(w http.ResponseWriter, stream io.ReadCloser, enc streamEncoder) (res *sigoengine.ChatResult, committed bool, err error) {
 	defer stream.Close()
 
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("X-Accel-Buffering", "no")
-	w.WriteHeader(http.StatusOK)
-
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		return "", fmt.Errorf("response writer does not support flushing")
+		return nil, false, fmt.Errorf("response writer does not support flushing")
+	}
+
+	var pending []string // data:-Payloads vor dem Commit
+	commit := func() error {
+		committed = true
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		for _, data := range pending {
+			if _, err := io.WriteString(w, enc.encode(data)); err != nil {
+				return err
+			}
+		}
+		pending = nil
+		return nil
+	}
+	// fail beendet einen bereits begonnenen Stream mit einem Fehler-Event.
+	fail := func(e error) error {
+		apiErr := sigoengine.ClassifyError(e)
+		io.WriteString(w, enc.fail(apiErr.Message, apiErr.Type))
+		flusher.Flush()
+		return e
 	}
 
-	var responseText strings.Builder
+	acc := sigoengine.NewStreamAccumulator()
 	scanner := bufio.NewScanner(stream)
 	// Große Chunks unterstützen (z.B. lange JSON-Zeilen)
 	const maxScanTokenSize = 1024 * 1024
@@ -68,43 +94,60 @@ func (s *Server) streamProviderResponse(w http.ResponseWriter, stream io.ReadClo
 
 	for scanner.Scan() {
 		line := scanner.Text()
-		if _, err := fmt.Fprintln(w, line); err != nil {
-			return responseText.String(), err
+		// Nur data:-Zeilen tragen Inhalt; Leerzeilen/Kommentare erzeugt der Encoder selbst
+		if !strings.HasPrefix(line, "data:") {
+			continue
 		}
-		flusher.Flush()
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
 
-		// Text aus data:-Zeilen akkumulieren
-		if strings.HasPrefix(line, "data: ") {
-			dataStr := strings.TrimPrefix(line, "data: ")
-			if dataStr == "" || dataStr == "[DONE]" {
-				continue
+		// Fehler-Objekt im Stream (statt HTTP-Status)
+		if e := sigoengine.StreamChunkError(data); e != nil {
+			if !committed {
+				return acc.Result(), false, e
 			}
-			var chunk map[string]interface{}
-			if err := json.Unmarshal([]byte(dataStr), &chunk); err != nil {
+			return acc.Result(), true, fail(e)
+		}
+
+		// Text und tool_calls akkumulieren
+		acc.AddData(data)
+		if !committed {
+			pending = append(pending, data)
+			if !acc.HasOutput() && data != "[DONE]" {
 				continue
 			}
-			if choices, ok := chunk["choices"].([]interface{}); ok && len(choices) > 0 {
-				if choice, ok := choices[0].(map[string]interface{}); ok {
-					if delta, ok := choice["delta"].(map[string]interface{}); ok {
-						if content, ok := delta["content"].(string); ok {
-							responseText.WriteString(content)
-						}
-					}
-				}
+			if err := commit(); err != nil {
+				return acc.Result(), true, err
 			}
+		} else if _, err := io.WriteString(w, enc.encode(data)); err != nil {
+			return acc.Result(), true, err
 		}
+		flusher.Flush()
 	}
 
 	if err := scanner.Err(); err != nil {
-		return responseText.String(), err
+		// Abbruch vor dem ersten Inhalt: wie ein Serverfehler behandeln (Retry/Failover)
+		if !committed {
+			return acc.Result(), false, &sigoengine.APIError{
+				Type:    sigoengine.ErrServerError,
+				Message: "Provider-Stream abgebrochen: " + err.Error(),
+				Err:     err,
+			}
+		}
+		return acc.Result(), true, fail(err)
 	}
 
-	// Sicherstellen, dass [DONE] gesendet wird
-	fmt.Fprintln(w, "data: [DONE]")
-	fmt.Fprintln(w)
+	// Leere Antwort ohne [DONE]: jetzt committen
+	if !committed {
+		if err := commit(); err != nil {
+			return acc.Result(), true, err
+		}
+	}
+
+	// Sicherstellen, dass der Stream ordentlich abgeschlossen wird ([DONE] bzw. message_stop)
+	io.WriteString(w, enc.finish())
 	flusher.Flush()
 
-	return responseText.String(), nil
+	return acc.Result(), true, nil
 }
 
 // **********************************************************************
@@ -116,17 +159,21 @@ var defaultMemoryJSON string
 // **********************************************************************
 // ModelInfo - Modell-Informationen aus CSV
 type ModelInfo struct {
-	ID                       string  `json:"id"`
-	Shortcode                string  `json:"shortcode"`
-	Endpoint                 string  `json:"endpoint"`
-	APIKey                   string  `json:"apikey"`
-	MaxInputTokens           int     `json:"max_input_tokens"`
-	MaxOutputTokens          int     `json:"max_output_tokens"`
-	InputCost                float64 `json:"input_cost"`  // $/1M tokens
-	OutputCost               float64 `json:"output_cost"` // $/1M tokens
-	MinTemperature           float64 `json:"min_temperature"`
-	MaxTemperature           float64 `json:"max_temperature"`
-	RequiresCompletionTokens bool    `json:"requires_completion_tokens"`
+	ID                       string   `json:"id"`
+	Shortcode                string   `json:"shortcode"`
+	Endpoint                 string   `json:"endpoint"`
+	APIKey                   string   `json:"apikey"`
+	MaxInputTokens           int      `json:"max_input_tokens"`
+	MaxOutputTokens          int      `json:"max_output_tokens"`
+	InputCost                float64  `json:"input_cost"`  // $/1M tokens
+	OutputCost               float64  `json:"output_cost"` // $/1M tokens
+	MinTemperature           float64  `json:"min_temperature"`
+	MaxTemperature           float64  `json:"max_temperature"`
+	RequiresCompletionTokens bool     `json:"requires_completion_tokens"`
+	Kind                     string   `json:"kind,omitempty"`         // "embedding", "virtual" oder leer (Chat)
+	Chain                    []string `json:"chain,omitempty"`        // Fallback-Kette (nur virtuelle Modelle)
+	Tokenizer                string   `json:"tokenizer,omitempty"`    // BPE-Encoding (leer = nach Modellfamilie)
+	Capabilities             []string `json:"capabilities,omitempty"` // vision, tools, json_mode, reasoning, streaming (fehlt = unbekannt)
 }
 
 // ModelUsageStats kumulierter Token-Verbrauch pro Modell
@@ -143,6 +190,8 @@ type Server struct {
 	mu              sync.RWMutex
 	memory          sigoengine.MemoryBlock
 	models          map[string]ModelInfo                          // id → ModelInfo
+	virtualModels   map[string]VirtualModel                       // Name (lowercase) → Fallback-Kette
+	modelSettings   map[string]ModelSettings                      // ID/Shortcode (lowercase) → Defaults
 	breakers        map[string]*sigoengine.EnhancedCircuitBreaker // Modell → Enhanced Circuit Breaker
 	systemPrompt    string                                        // globaler Default-Prompt (leer = kein Prompt)
 	usageMu         sync.RWMutex
@@ -152,6 +201,9 @@ type Server struct {
 	rateLimiter     *sigoengine.RateLimiter
 	rateMinInterval time.Duration
 	rateMaxWait     time.Duration
+	requestQueue    *sigoengine.RequestQueue  // Scheduler vor den Kanälen (Priorität, fair je Client)
+	clients         map[string]ClientSettings // IP bzw. API-Key → Client-Konfiguration
+	refreshMu       sync.Mutex                // serialisiert refreshModels (Ticker, Endpoint)
 	baseDir         string
 }
 
@@ -170,6 +222,11 @@ var (
 	channelHealthInterval = flag.Duration("channel-health-interval", 30*time.Second, "Intervall für Kanal-Health-Checks")
 	rateMinInterval       = flag.Duration("rate-min-interval", 500*time.Millisecond, "Default Mindest-Abstand zwischen Calls pro Kanal (0=deaktiviert)")
 	rateMaxWait           = flag.Duration("rate-max-wait", 1000*time.Millisecond, "Default max Queue-Wartezeit bis HTTP 429 pro Kanal")
+	queueSize             = flag.Int("queue-size", 256, "Max wartende Requests pro Provider (0=unbegrenzt)")
+	queueMaxWait          = flag.Duration("queue-max-wait", 30*time.Second, "Max Wartezeit in der Provider-Queue bis HTTP 429")
+	ollamaHosts           = flag.String("ollama-hosts", sigoengine.DefaultOllamaHost, "Ollama-Hosts, kommagetrennt (je Host ein Kanal, leer=aus)")
+	ollamaRefresh         = flag.Duration("ollama-refresh", 5*time.Minute, "Intervall für Ollama-Modell-Discovery (0=nur beim Start)")
+	modelsRefresh         = flag.Duration("models-refresh", time.Hour, "Intervall für den Abruf der Provider-Modell-Listen (0=nur beim Start)")
 )
 
 // **********************************************************************
@@ -322,8 +379,14 @@ func ensureTLSCert(certPath, keyPath string) error {
 // **********************************************************************
 // Modelle von Provider-APIs laden
 
-// modelInfoFromEngine konvertiert sigoengine.Model → ModelInfo
+// modelInfoFromEngine konvertiert sigoengine.Model → ModelInfo.
+// Capabilities aus models.csv/models.json (Registry) haben Vorrang vor
+// den Angaben des Providers.
 func modelInfoFromEngine(m sigoengine.Model) ModelInfo {
+	caps := m.Capabilities
+	if reg, ok := sigoengine.GetModelByID(m.ID); ok && reg.Capabilities != nil {
+		caps = reg.Capabilities
+	}
 	return ModelInfo{
 		ID:                       m.ID,
 		Shortcode:                m.Shortcode,
@@ -336,6 +399,9 @@ func modelInfoFromEngine(m sigoengine.Model) ModelInfo {
 		MinTemperature:           m.MinTemperature,
 		MaxTemperature:           m.MaxTemperature,
 		RequiresCompletionTokens: m.RequiresCompletionTokens,
+		Kind:                     m.Kind,
+		Tokenizer:                m.Tokenizer,
+		Capabilities:             caps,
 	}
 }
 
@@ -343,42 +409,86 @@ func modelInfoFromEngine(m sigoengine.Model) ModelInfo {
 // Fehler bei einzelnen Providern werden geloggt; der Server startet
 // trotzdem mit den verfügbaren Modellen.
 func loadModelsFromProviders() map[string]ModelInfo {
-	models := make(map[string]ModelInfo)
-
 	// Retry-Parameter: 4 Versuche mit 2s/4s/8s Backoff. Fängt den Fall ab,
 	// dass beim Systemstart DNS noch nicht verfügbar ist (siehe FetchWithRetry).
-	const fetchAttempts = 4
-	const fetchBackoff = 2 * time.Second
+	fetch := fetchProviderModels(4, 2*time.Second)
+	sigoengine.LogInfo("Provider-Modelle geladen", map[string]interface{}{"count": len(fetch.models)})
+	return fetch.models
+}
 
-	// 1. Mammouth (kein API-Key nötig)
-	if ms, err := sigoengine.FetchWithRetry("mammouth", fetchAttempts, fetchBackoff, sigoengine.FetchMammouthModels); err != nil {
-		sigoengine.LogWarn("Mammouth-Modelle nicht geladen", map[string]interface{}{"error": err.Error()})
-	} else {
-		for _, m := range ms {
-			models[m.ID] = modelInfoFromEngine(m)
-		}
+// providerFetch ist das Ergebnis eines Abrufs aller Provider.
+type providerFetch struct {
+	models map[string]ModelInfo // ID → Modell (erfolgreiche Provider + Embeddings)
+	failed map[string]string    // Provider → Fehler
+}
+
+// fetchProviderModels ruft die Modelle aller registrierten Provider ab
+// (Start und Refresh). Fehlgeschlagene Provider stehen in failed.
+func fetchProviderModels(attempts int, backoff time.Duration) providerFetch {
+	fetch := providerFetch{
+		models: make(map[string]ModelInfo),
+		failed: make(map[string]string),
 	}
 
-	// 2. Moonshot
-	if ms, err := sigoengine.FetchWithRetry("moonshot", fetchAttempts, fetchBackoff, sigoengine.FetchMoonshotModels); err != nil {
-		sigoengine.LogWarn("Moonshot-Modelle nicht geladen", map[string]interface{}{"error": err.Error()})
-	} else {
+	// 1. Chat-Modelle aller registrierten Provider (Anthropic/Gemini nur mit
+	// ANTHROPIC_API_KEY/GEMINI_API_KEY, ZAI fällt intern auf statische Liste zurück)
+	for _, p := range sigoengine.Providers() {
+		ms, err := sigoengine.FetchWithRetry(p.Name(), attempts, backoff, p.FetchModels)
+		if err != nil {
+			sigoengine.LogWarn("Provider-Modelle nicht geladen", map[string]interface{}{
+				"provider": p.Name(),
+				"error":    err.Error(),
+			})
+			fetch.failed[p.Name()] = err.Error()
+			continue
+		}
 		for _, m := range ms {
-			models[m.ID] = modelInfoFromEngine(m)
+			fetch.models[m.ID] = modelInfoFromEngine(m)
 		}
 	}
 
-	// 3. ZAI (fällt intern auf statische Liste zurück)
-	if ms, err := sigoengine.FetchWithRetry("zai", fetchAttempts, fetchBackoff, sigoengine.FetchZAIModels); err != nil {
-		sigoengine.LogWarn("ZAI-Modelle nicht geladen", map[string]interface{}{"error": err.Error()})
-	} else {
-		for _, m := range ms {
-			models[m.ID] = modelInfoFromEngine(m)
+	// 2. Embedding-Modelle (Provider-Tabelle + models.csv/json mit kind "embedding")
+	for _, m := range sigoengine.FetchEmbeddingModels() {
+		if _, exists := fetch.models[m.ID]; !exists {
+			fetch.models[m.ID] = modelInfoFromEngine(m)
 		}
 	}
+	return fetch
+}
 
-	sigoengine.LogInfo("Provider-Modelle geladen", map[string]interface{}{"count": len(models)})
-	return models
+// syncOllamaModels lädt die Modelle aller Ollama-Hosts neu und ersetzt die
+// "ollama-*"-Einträge in s.models (neue Modelle erscheinen, gelöschte
+// verschwinden). Endpoints zeigen auf den ersten Host mit dem Modell; der
+// Kanal setzt beim Call seinen eigenen Host ein (ChannelEndpoint).
+func (s *Server) syncOllamaModels() {
+	sigoengine.DiscoverOllamaModels(s.channelManager.Registry())
+	ollamaModels := sigoengine.GetOllamaModels()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id := range s.models {
+		if _, still := ollamaModels[id]; !still && strings.HasPrefix(id, "ollama-") {
+			delete(s.models, id)
+		}
+	}
+	for sc, info := range ollamaModels {
+		mi := ModelInfo{
+			ID:              sc,
+			Shortcode:       sc,
+			Endpoint:        info.Host + "/v1/chat/completions",
+			APIKey:          "",
+			MaxInputTokens:  info.ContextLength,
+			MaxOutputTokens: 0,
+			MinTemperature:  0.0,
+			MaxTemperature:  2.0,
+		}
+		// Embedding-Modelle über die native Ollama-API
+		if info.Kind == sigoengine.ModelKindEmbedding {
+			mi.Endpoint = info.Host + "/api/embed"
+			mi.Kind = sigoengine.ModelKindEmbedding
+		}
+		s.models[sc] = mi
+	}
 }
 
 // **********************************************************************
@@ -420,8 +530,22 @@ func loadSystemPrompt(dataDir string) string {
 // Request/Response Typen (OpenAI-kompatibel)
 
 type ChatMessage struct {
-	Role    string          `json:"role"`
-	Content json.RawMessage `json:"content"`
+	Role       string          `json:"role"`
+	Content    json.RawMessage `json:"content"`
+	ToolCalls  json.RawMessage `json:"tool_calls,omitempty"`   // Assistant-Tool-Aufrufe
+	ToolCallID string          `json:"tool_call_id,omitempty"` // bei role:"tool"
+	Name       string          `json:"name,omitempty"`
+}
+
+// toEngine konvertiert die Request-Nachricht in eine Session-Nachricht
+func (m ChatMessage) toEngine() sigoengine.Message {
+	return sigoengine.Message{
+		Role:       m.Role,
+		Content:    m.Content,
+		ToolCalls:  m.ToolCalls,
+		ToolCallID: m.ToolCallID,
+		Name:       m.Name,
+	}
 }
 
 type ChatRequest struct {
@@ -429,12 +553,68 @@ type ChatRequest struct {
 	Messages     []ChatMessage `json:"messages"`
 	Temp         float64       `json:"temperature"`
 	MaxTokens    int           `json:"max_tokens"`
-	SessionID    string        `json:"session_id"`    // sigoREST-Erweiterung
-	Timeout      int           `json:"timeout"`       // sigoREST-Erweiterung
-	Retries      int           `json:"retries"`       // sigoREST-Erweiterung
-	SystemPrompt string        `json:"system_prompt"` // per-Request Override
-	Channel      string        `json:"channel"`       // optionaler Kanal, z.B. "mammouth-0"
-	Stream       bool          `json:"stream"`        // OpenAI streaming flag (new)
+	SessionID    string        `json:"session_id"`     // sigoREST-Erweiterung
+	Timeout      int           `json:"timeout"`        // sigoREST-Erweiterung
+	Retries      int           `json:"retries"`        // sigoREST-Erweiterung
+	SystemPrompt string        `json:"system_prompt"`  // per-Request Override
+	Channel      string        `json:"channel"`        // optionaler Kanal, z.B. "mammouth-0"
+	HedgeAfterMs int           `json:"hedge_after_ms"` // sigoREST-Erweiterung: Hedged Request
+	MaxCost      float64       `json:"max_cost"`       // sigoREST-Erweiterung: Preisgrenze für model "auto" ($/1M Tokens)
+	Stream       bool          `json:"stream"`         // OpenAI streaming flag (new)
+
+	// Raw hält alle Felder des Request-JSON; Felder, die sigoREST nicht
+	// selbst auswertet, gehen per Passthrough() an den Provider.
+	Raw map[string]json.RawMessage `json:"-"`
+}
+
+// sigoOwnedFields sind Request-Felder, die sigoREST selbst auswertet bzw.
+// neu setzt. Alle anderen Felder werden an den Provider durchgereicht.
+var sigoOwnedFields = map[string]bool{
+	"model":          true,
+	"messages":       true,
+	"temperature":    true,
+	"max_tokens":     true,
+	"stream":         true,
+	"stream_options": true, // setzt runChatTarget selbst (nur bei stream)
+	"session_id":     true,
+	"timeout":        true,
+	"retries":        true,
+	"system_prompt":  true,
+	"channel":        true,
+	"hedge_after_ms": true,
+	"max_cost":       true,
+}
+
+// UnmarshalJSON dekodiert den Request und behält zusätzlich alle Rohfelder.
+func (r *ChatRequest) UnmarshalJSON(data []byte) error {
+	type plain ChatRequest
+	if err := json.Unmarshal(data, (*plain)(r)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &r.Raw)
+}
+
+// wantsStreamUsage prüft, ob der Client stream_options.include_usage gesetzt
+// hat und den abschließenden Usage-Chunk selbst sehen will.
+func (r *ChatRequest) wantsStreamUsage() bool {
+	var opts struct {
+		IncludeUsage bool `json:"include_usage"`
+	}
+	raw, ok := r.Raw["stream_options"]
+	return ok && json.Unmarshal(raw, &opts) == nil && opts.IncludeUsage
+}
+
+// Passthrough liefert alle Request-Felder, die sigoREST nicht besitzt
+// (top_p, stop, seed, response_format, tools, ...). null-Werte entfallen.
+func (r *ChatRequest) Passthrough() map[string]json.RawMessage {
+	result := make(map[string]json.RawMessage)
+	for k, v := range r.Raw {
+		if sigoOwnedFields[k] || string(v) == "null" {
+			continue
+		}
+		result[k] = v
+	}
+	return result
 }
 
 type ChatChoice struct {
@@ -463,10 +643,14 @@ type ErrorResponse struct {
 		Message string `json:"message"`
 		Type    string `json:"type"`
 		Code    string `json:"code"`
+		// Details strukturierter Fehler (z.B. prompt_tokens und
+		// max_input_tokens bei context_length_exceeded)
+		Details map[string]interface{} `json:"details,omitempty"`
 	} `json:"error"`
 }
 
-// lookupModel sucht case-insensitiv nach ID (Map-Key) oder Shortcode.
+// lookupModel sucht case-insensitiv nach ID (Map-Key), Shortcode oder
+// virtuellem Modell (Kind "virtual", Kette in Chain).
 // Aufrufer muss s.mu (RLock) halten. Liefert ModelInfo + kanonische ID.
 // Provider-Modell-IDs sind überwiegend lowercase; Sigil/CLI können aber
 // andere Casing mitschicken (z.B. "GLM-4.5"), die ansonsten am exakten
@@ -483,6 +667,10 @@ func (s *Server) lookupModel(query string) (ModelInfo, string, bool) {
 			return info, info.ID, true
 		}
 	}
+	// Virtuelle Modelle zuletzt: echte Modelle gleichen Namens gewinnen
+	if vm, ok := s.virtualModels[q]; ok {
+		return vm.info(), vm.Name, true
+	}
 	return ModelInfo{}, "", false
 }
 
@@ -492,25 +680,9 @@ func (s *Server) providerForModel(modelID string) string {
 	info, _, ok := s.lookupModel(modelID)
 	s.mu.RUnlock()
 	if ok {
-		switch {
-		case strings.Contains(info.Endpoint, "mammouth"):
-			return "mammouth"
-		case strings.Contains(info.Endpoint, "moonshot"):
-			return "moonshot"
-		case strings.Contains(info.Endpoint, "z.ai"):
-			return "zai"
-		}
-	}
-	// Fallback by model name heuristics (case-insensitiv)
-	lower := strings.ToLower(modelID)
-	switch {
-	case strings.Contains(lower, "kimi"):
-		return "moonshot"
-	case strings.Contains(lower, "glm"):
-		return "zai"
-	default:
-		return "mammouth"
+		return sigoengine.ProviderForModel(info.Endpoint, info.APIKey, modelID)
 	}
+	return sigoengine.ProviderForModel("", "", modelID)
 }
 
 // **********************************************************************
@@ -528,57 +700,223 @@ func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Modell-Validierung (ID oder Shortcode, case-insensitiv)
-	modelID := req.Model
+	s.serveChat(w, r, &req, openAIFormat{includeUsage: req.wantsStreamUsage()})
+}
 
-	// Case-insensitiv nach ID oder Shortcode suchen
+// serveChat ist der gemeinsame Kern von /v1/chat/completions und /v1/messages:
+// Modell-Lookup, Kanal-Auflösung und Failover, Rate-Limiter, Memory-Block,
+// System-Prompt und Sessions. req liegt immer im OpenAI-Format vor; out
+// schreibt Fehler, Antworten und Streams im Wire-Format des Clients.
+// Virtuelle Modelle probieren ihre Kette der Reihe nach (runChatTarget je
+// Modell, mit Kanal-Failover innerhalb des Providers).
+func (s *Server) serveChat(w http.ResponseWriter, r *http.Request, req *ChatRequest, out apiFormat) {
+	// Modell-Validierung (ID, Shortcode oder virtuelles Modell, case-insensitiv)
+	// "auto[:profil]" wählt die Modelle selbst (sofern kein echtes oder
+	// virtuelles Modell so heißt).
 	s.mu.RLock()
-	modelInfo, resolvedID, exists := s.lookupModel(modelID)
-	if exists {
-		modelID = resolvedID
+	modelInfo, modelID, exists := s.lookupModel(req.Model)
+	autoProfile, isAuto, autoErr := parseAutoModel(req.Model)
+	if !exists && !isAuto {
+		s.mu.RUnlock()
+		out.writeError(w, fmt.Sprintf("Model '%s' nicht gefunden", req.Model), "model_not_found", http.StatusBadRequest)
+		return
+	}
+	if !exists && autoErr != nil {
+		s.mu.RUnlock()
+		out.writeError(w, autoErr.Error(), "model_not_found", http.StatusBadRequest)
+		return
 	}
-	if !exists {
+	if modelInfo.Kind == sigoengine.ModelKindEmbedding {
 		s.mu.RUnlock()
-		writeError(w, fmt.Sprintf("Model '%s' nicht gefunden", req.Model), "model_not_found", http.StatusBadRequest)
+		out.writeError(w, fmt.Sprintf("Model '%s' ist ein Embedding-Modell, bitte /v1/embeddings nutzen", req.Model), "invalid_request", http.StatusBadRequest)
 		return
 	}
+	var targets []chatTarget
+	if exists {
+		targets = s.chatTargets(modelInfo, modelID, req.Model)
+	} else {
+		targets = s.autoTargets(autoProfile, req)
+	}
 	mem := s.memory
 	globalSystemPrompt := s.systemPrompt
 	s.mu.RUnlock()
+	if len(targets) == 0 && !exists {
+		out.writeError(w, fmt.Sprintf("Model '%s': kein verfügbares Modell erfüllt die Anforderungen des Requests (Features, Kontextfenster, max_cost, Kanäle)", req.Model), "model_not_found", http.StatusBadRequest)
+		return
+	}
+	if len(targets) == 0 {
+		out.writeError(w, fmt.Sprintf("Virtuelles Modell '%s': kein Modell der Kette verfügbar", req.Model), "model_not_found", http.StatusBadRequest)
+		return
+	}
+
+	if req.Timeout == 0 {
+		req.Timeout = sigoengine.DEFAULT_TIMEOUT
+	}
+	if req.Retries == 0 {
+		req.Retries = 3
+	}
+
+	// Kette abarbeiten: nächstes Modell, wenn alle Kanäle eines Modells
+	// scheitern. Kein Fallback nach dem ersten gestreamten Chunk und bei
+	// Client-Fehlern (der Request selbst ist ungültig).
+	var att *chatAttempt
+	for i, target := range targets {
+		att = s.runChatTarget(w, r, *req, out, target, mem, globalSystemPrompt)
+		if att.err == nil || att.streamed || i == len(targets)-1 {
+			break
+		}
+		if att.setupStatus == 0 && sigoengine.ClassifyError(att.err).Type == sigoengine.ErrClientError {
+			break
+		}
+		sigoengine.LogWarn("Fallback auf nächstes Modell der Kette", map[string]interface{}{
+			"model":  req.Model,
+			"failed": target.id,
+			"next":   targets[i+1].id,
+			"error":  att.err.Error(),
+		})
+	}
+	target, lastErr, successfulCh := att.target, att.err, att.ch
+
+	if att.setupStatus != 0 {
+		// Strukturierte Fehler (SigoError): Meldung ohne Code, Fields als details
+		msg, details := lastErr.Error(), map[string]interface{}(nil)
+		var sigoErr *sigoengine.SigoError
+		if errors.As(lastErr, &sigoErr) {
+			msg, details = sigoErr.Message, sigoErr.Fields
+		}
+		out.writeErrorDetails(w, msg, att.setupType, att.setupStatus, details)
+		return
+	}
+	if lastErr != nil && att.unreachable {
+		out.writeError(w, "Provider nicht erreichbar: "+lastErr.Error(), "provider_unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if lastErr != nil && !att.streamed {
+		s.writeUpstreamError(w, out, target.name, lastErr)
+		return
+	}
+	responseUsage := att.res.Usage
+	if lastErr != nil {
+		// Abbruch mitten im Stream: Client hat das Fehler-Event bekommen.
+		// Verbrauch trotzdem zählen, Session nicht speichern (Antwort unvollständig).
+		sigoengine.LogWarn("Stream nach erstem Chunk abgebrochen", map[string]interface{}{
+			"model":   target.name,
+			"channel": successfulCh.FullName(),
+			"error":   lastErr.Error(),
+		})
+		if responseUsage == nil {
+			responseUsage = modelTokenizer(target.info, target.id).EstimateUsage(att.inputText, att.res.Content)
+		}
+		s.recordUsage(target.id, successfulCh, responseUsage)
+		return
+	}
+
+	// Usage schätzen falls Provider keine liefert
+	if responseUsage == nil {
+		sigoengine.LogDebug("Provider lieferte keine Usage, schätze", map[string]interface{}{
+			"model":  target.name,
+			"stream": att.streamed,
+		})
+		responseUsage = modelTokenizer(target.info, target.id).EstimateUsage(att.inputText, att.res.Content)
+	}
+
+	// Session speichern (unter dem Kanal, der tatsächlich geantwortet hat)
+	if req.SessionID != "" && len(att.turnMessages) > 0 && successfulCh != nil {
+		for _, m := range att.turnMessages {
+			att.session.AddRawMessage(m)
+		}
+		att.session.AddRawMessage(sigoengine.Message{
+			Role:      "assistant",
+			Content:   assistantContent(att.res.Content, att.res.ToolCalls),
+			ToolCalls: att.res.ToolCalls,
+		})
+		att.session.SaveForChannel(s.baseDir, successfulCh.Provider, successfulCh.Name, req.SessionID, req.Model)
+	}
+
+	// Usage akkumulieren
+	chatUsage := &ChatUsage{
+		PromptTokens:     responseUsage.InputTokens,
+		CompletionTokens: responseUsage.OutputTokens,
+		TotalTokens:      responseUsage.TotalTokens,
+	}
+	s.recordUsage(target.id, successfulCh, responseUsage)
+
+	// Bei echtem Streaming wurde die Antwort bereits geschrieben.
+	if att.streamed {
+		return
+	}
+
+	// JSON-Antwort im Client-Format (non-streaming)
+	out.writeResult(w, target.name, &sigoengine.ChatResult{
+		Content:      att.res.Content,
+		ToolCalls:    att.res.ToolCalls,
+		FinishReason: att.res.FinishReason,
+	}, chatUsage)
+}
+
+// chatTarget ist ein konkretes Modell, das serveChat probiert.
+type chatTarget struct {
+	info ModelInfo
+	id   string // kanonische Modell-ID
+	// name erscheint in Antwort, Logs und Circuit-Breaker-Key: der
+	// Request-Name, bei virtuellen Modellen die konkrete ID.
+	name string
+}
+
+// chatAttempt ist das Ergebnis von runChatTarget für ein Modell.
+type chatAttempt struct {
+	target       chatTarget
+	res          sigoengine.ChatResult
+	ch           *sigoengine.Channel // Kanal, der geantwortet hat
+	session      *sigoengine.Session
+	turnMessages []sigoengine.Message
+	inputText    string
+	streamed     bool
+	err          error // letzter Fehler, nil bei Erfolg
+	unreachable  bool  // err: Provider-Ping fehlgeschlagen
+	// Fehler vor dem ersten Upstream-Call (Kanal, Config): Typ und HTTP-Status
+	setupType   string
+	setupStatus int
+}
+
+// runChatTarget führt den Chat-Request für ein konkretes Modell aus:
+// Kanal auflösen, Messages aufbauen (Memory, System-Prompt, Session),
+// dann Retry und Kanal-Failover. req ist eine Kopie; Defaults (max_tokens,
+// Temperatur) gelten nur für dieses Modell.
+func (s *Server) runChatTarget(w http.ResponseWriter, r *http.Request, req ChatRequest, out apiFormat,
+	target chatTarget, mem sigoengine.MemoryBlock, globalSystemPrompt string) *chatAttempt {
+
+	modelID, modelInfo := target.id, target.info
+	att := &chatAttempt{target: target}
 
 	// Streaming-Modus erkennen (OpenAI-Standard)
 	isStreaming := req.Stream
 
+	// Features des Requests, die das Modell nicht kann → HTTP 400
+	if missing := sigoengine.MissingCapabilities(modelInfo.Capabilities, requestCapabilities(&req)); len(missing) > 0 {
+		att.err = capabilityError(modelID, missing)
+		att.setupType, att.setupStatus = "unsupported_feature", http.StatusBadRequest
+		return att
+	}
+
 	// Provider und Kanal bestimmen
 	provider := s.providerForModel(modelID)
-	ch, err := s.channelManager.Resolve(provider, req.Channel)
+	ch, err := s.resolveChannel(provider, modelID, req.Channel)
 	if err != nil {
 		apiErr := sigoengine.ClassifyError(err)
 		httpStatus := http.StatusBadRequest
 		if apiErr.Type == sigoengine.ErrConfigNotFound {
 			httpStatus = http.StatusNotFound
 		}
-		writeError(w, err.Error(), apiErr.Type, httpStatus)
-		return
+		att.err, att.setupType, att.setupStatus = err, apiErr.Type, httpStatus
+		return att
 	}
 
 	// Config mit Kanal-Key aufbauen
 	cfg, err := sigoengine.LoadConfigWithChannel(modelID, ch)
 	if err != nil {
-		writeError(w, err.Error(), "config_error", http.StatusInternalServerError)
-		return
-	}
-	cfg.Endpoint = modelInfo.Endpoint
-
-	// Provider-Ping: scheitert → sofortiger Fehler, kein API-Call
-	if err := sigoengine.PingProvider(modelInfo.Endpoint); err != nil {
-		sigoengine.LogWarn("Provider nicht erreichbar", map[string]interface{}{
-			"model":    modelID,
-			"endpoint": modelInfo.Endpoint,
-			"error":    err.Error(),
-		})
-		writeError(w, "Provider nicht erreichbar: "+err.Error(), "provider_unavailable", http.StatusServiceUnavailable)
-		return
+		att.err, att.setupType, att.setupStatus = err, "config_error", http.StatusInternalServerError
+		return att
 	}
 
 	// Defaults setzen
@@ -601,12 +939,6 @@ func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 			req.Temp = modelInfo.MaxTemperature
 		}
 	}
-	if req.Timeout == 0 {
-		req.Timeout = sigoengine.DEFAULT_TIMEOUT
-	}
-	if req.Retries == 0 {
-		req.Retries = 3
-	}
 
 	// Messages aufbauen: Memory zuerst, dann user-Messages
 	messages := []map[string]interface{}{}
@@ -656,19 +988,20 @@ func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 	if req.SessionID != "" {
 		session = sigoengine.LoadSessionForChannel(s.baseDir, ch.Provider, ch.Name, req.SessionID, req.Model)
 		for _, m := range session.History {
-			messages = append(messages, map[string]interface{}{
-				"role": m.Role, "content": m.Content,
-			})
+			messages = append(messages, m.ToMap())
 		}
 	}
 
-	// User-Messages aus Request
-	var userPrompt string
-	for _, msg := range req.Messages {
-		var contentValue interface{}
-		if err := json.Unmarshal(msg.Content, &contentValue); err != nil {
-			contentValue = string(msg.Content)
-		}
+	// User-Messages aus Request. turnMessages sammelt, was nach Erfolg in die
+	// Session geht: die Messages nach dem letzten Assistant-Turn, den die
+	// Session schon enthält (Clients schicken oft den ganzen Verlauf), in
+	// Request-Reihenfolge; User-Prompts nur als Text.
+	var turnMessages []sigoengine.Message
+	turnStart := 0
+	if session != nil {
+		turnStart = newTurnStart(session.History, req.Messages)
+	}
+	for i, msg := range req.Messages {
 		if msg.Role == "system" {
 			if req.SystemPrompt != "" {
 				sigoengine.LogWarn("Ignoriere role:system in Messages, da system_prompt im Request gesetzt ist", map[string]interface{}{
@@ -676,17 +1009,48 @@ func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 				})
 				continue
 			}
-			messages = append(messages, map[string]interface{}{
-				"role": "system", "content": contentValue,
-			})
-		} else {
-			messages = append(messages, map[string]interface{}{
-				"role": msg.Role, "content": contentValue,
-			})
-			if msg.Role == "user" {
-				userPrompt = sigoengine.ExtractTextFromContent(msg.Content)
-			}
+			messages = append(messages, msg.toEngine().ToMap())
+			continue
 		}
+		messages = append(messages, msg.toEngine().ToMap())
+		if i < turnStart {
+			continue
+		}
+		switch msg.Role {
+		case "user":
+			text := sigoengine.ExtractTextFromContent(msg.Content)
+			turnMessages = append(turnMessages, sigoengine.Message{Role: "user", Content: json.RawMessage(`"` + jsonEscapeString(text) + `"`)})
+		case "assistant", "tool":
+			turnMessages = append(turnMessages, msg.toEngine())
+		}
+	}
+
+	// Pre-Flight gegen das Kontextfenster: Prompt (inkl. Memory, System-
+	// Prompt, Session, Tool-Definitionen) schätzen und max_tokens kürzen,
+	// damit Prompt plus Output passen. Passt der Prompt nie → HTTP 400
+	// context_length_exceeded ohne Provider-Call (virtuelle Modelle: das
+	// nächste Modell der Kette). Ohne Vokabular (Näherung) erst jenseits
+	// der Toleranz von FitMaxTokens.
+	tok := modelTokenizer(modelInfo, modelID)
+	promptTokens := sigoengine.EstimatePromptTokens(tok, messages)
+	if tools, ok := req.Passthrough()["tools"]; ok {
+		data, _ := json.Marshal(tools)
+		promptTokens += tok.Count(string(data))
+	}
+	fitted, err := sigoengine.FitMaxTokens(promptTokens, modelInfo.MaxInputTokens, req.MaxTokens, tok.Exact())
+	if err != nil {
+		att.err = err
+		att.setupType, att.setupStatus = "context_length_exceeded", http.StatusBadRequest
+		return att
+	}
+	if fitted != req.MaxTokens {
+		sigoengine.LogInfo("max_tokens an Kontextfenster angepasst", map[string]interface{}{
+			"model":         modelID,
+			"prompt_tokens": promptTokens,
+			"max_tokens":    req.MaxTokens,
+			"fitted":        fitted,
+		})
+		req.MaxTokens = fitted
 	}
 
 	// API-Request aufbauen
@@ -704,11 +1068,41 @@ func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 		delete(apiRequest, "max_tokens")
 		apiRequest["max_completion_tokens"] = req.MaxTokens
 	}
+	// Nicht von sigoREST ausgewertete Felder (top_p, stop, seed, tools,
+	// response_format, ...) durchreichen. Die Provider-Policy entfernt Felder,
+	// die der Provider ablehnt; von sigoREST gesetzte Felder haben Vorrang.
+	passthrough, dropped := sigoengine.FilterPassthroughParams(provider, req.Passthrough())
+	for k, v := range passthrough {
+		if _, exists := apiRequest[k]; !exists {
+			apiRequest[k] = v
+		}
+	}
+	if len(dropped) > 0 {
+		sigoengine.LogDebug("Parameter vom Provider nicht unterstützt, entfernt", map[string]interface{}{
+			"provider": provider,
+			"params":   strings.Join(dropped, ","),
+		})
+	}
+	// Streaming: echte Usage im letzten Chunk anfordern (statt Schätzung).
+	// Ob der Client den Chunk sieht, entscheidet das Format (openAIStream).
+	if isStreaming && sigoengine.ParamAllowed(provider, "stream_options") {
+		apiRequest["stream_options"] = map[string]interface{}{"include_usage": true}
+	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(req.Timeout)*time.Second)
 	defer cancel()
 
+	// Scheduler: Platz in der Queue des Providers (bis Ende von Failover
+	// und Stream); volle Queue bzw. zu lange Wartezeit → HTTP 429
+	leave, err := s.enterQueue(ctx, r, provider)
+	if err != nil {
+		att.err = err
+		return att
+	}
+	defer leave()
+
 	var responseText string
+	var responseToolCalls json.RawMessage
 	var responseUsage *sigoengine.UsageData
 	var responseFinishReason string
 	var successfulCh *sigoengine.Channel
@@ -720,114 +1114,162 @@ func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 	}
 	inputText := inputBuilder.String()
 
+	// Token-Schätzung für das TPM-Limit (Prompt + maximaler Output);
+	// nach dem Call korrigiert der Rate-Limiter auf die echte Usage.
+	tokenEstimate := promptTokens + req.MaxTokens
+
 	// Liste der zu probierenden Kanäle aufbauen (initial + Failover)
-	channelsToTry := []*sigoengine.Channel{ch}
-	current := ch
-	for {
-		next, ok := s.channelManager.NextActive(provider, current)
-		if !ok {
-			break
-		}
-		channelsToTry = append(channelsToTry, next)
-		current = next
-	}
+	channelsToTry := s.failoverChain(provider, ch)
+
+	// Konkretes Modell melden (bei virtuellen Modellen das Glied der Kette);
+	// vor dem Stream-Commit gesetzt, damit der Header mitgeht.
+	w.Header().Set("X-Sigo-Model", modelID)
 
 	// Exponential Backoff Retry
 	retryConfig := sigoengine.DefaultRetryConfig()
 	retryConfig.MaxRetries = req.Retries
 
-	var lastErr error
+	// Hedging (nur Non-Streaming): Request-Wert vor Modell-Default
+	hedgeAfter := req.HedgeAfterMs
+	if hedgeAfter == 0 {
+		hedgeAfter = s.modelSettingsFor(modelInfo).HedgeAfterMs
+	}
+
+	// Non-Streaming-Call auf einem Kanal: Retry, Circuit Breaker, Statistik.
+	// Jeder Call bekommt eine eigene flache Kopie des Requests (beim Hedge
+	// laufen zwei Calls gleichzeitig).
+	callChat := func(ctx context.Context, currentCh *sigoengine.Channel, cfg *sigoengine.ProviderConfig) (*sigoengine.ChatResult, error) {
+		breaker := s.breakerFor(target.name, currentCh)
+		done := s.channelManager.BeginRequest(currentCh)
+		defer done()
+		request := maps.Clone(apiRequest)
+		var result *sigoengine.ChatResult
+		err := sigoengine.RetryWithBackoff(ctx, retryConfig, func() error {
+			return s.benchedError(currentCh, breaker.Do(func() error {
+				start := time.Now()
+				res, e := sigoengine.CallAPIResult(ctx, cfg, request, req.Timeout)
+				s.recordChannelCall(ctx, currentCh, modelID, time.Since(start), e)
+				if e != nil {
+					s.disableOnAuthError(currentCh, e)
+					return e
+				}
+				result = res
+				return nil
+			}))
+		})
+		return result, err
+	}
+
+	var lastErr, unreachable error
 	var streamed bool
-	for _, currentCh := range channelsToTry {
-		cfg, err := sigoengine.LoadConfigWithChannel(modelID, currentCh)
+	pinged := make(map[string]error)
+	for i := 0; i < len(channelsToTry); i++ {
+		currentCh := channelsToTry[i]
+		cfg, release, down, err := s.prepareChannel(ctx, pinged, modelID, modelInfo.Endpoint, currentCh, tokenEstimate)
 		if err != nil {
 			lastErr = err
-			continue
-		}
-		cfg.Endpoint = modelInfo.Endpoint
-
-		// Rate-Limiter pro Kanal (hybrid): wartet bis minInterval seit
-		// letztem Call vergangen, spätestens nach maxWait → ErrRateLimited
-		// → Failover auf nächsten Kanal (oder HTTP 429 am Ende).
-		minInt := s.rateMinInterval
-		if currentCh.MinInterval > 0 {
-			minInt = time.Duration(currentCh.MinInterval) * time.Millisecond
-		}
-		maxW := s.rateMaxWait
-		if currentCh.MaxWait > 0 {
-			maxW = time.Duration(currentCh.MaxWait) * time.Millisecond
-		}
-		if minInt > 0 {
-			if err := s.rateLimiter.Acquire(ctx, currentCh.FullName(), minInt, maxW); err != nil {
-				if err == sigoengine.ErrRateLimited {
-					sigoengine.LogWarn("Rate-Limit: Kanal überlastet, Failover", map[string]interface{}{
-						"channel":     currentCh.FullName(),
-						"max_wait_ms": maxW.Milliseconds(),
-					})
-					lastErr = err
-					continue
-				}
-				// ctx abgebrochen oder anderer Fehler
-				lastErr = err
+			if down {
+				unreachable = err
+			}
+			// ctx abgebrochen: kein weiterer Kanal
+			if ctx.Err() != nil {
 				break
 			}
-			s.rateLimiter.Release(currentCh.FullName())
+			continue
 		}
 
-		// Circuit Breaker pro Kanal (Key: model#channel)
-		cbKey := fmt.Sprintf("%s#%s", req.Model, currentCh.FullName())
-		s.mu.Lock()
-		if _, exists := s.breakers[cbKey]; !exists {
-			config := &sigoengine.CircuitBreakerConfig{
-				Threshold:   5,
-				Window:      60 * time.Second,
-				Cooldown:    10 * time.Second,
-				HalfOpenMax: 3,
+		// Hedge: nächste Kanäle als Backup, falls der Primärkanal trödelt
+		if !isStreaming && hedgeAfter > 0 && i+1 < len(channelsToTry) {
+			backupPinged := make(map[string]error)
+			h := s.hedgedCall(ctx, time.Duration(hedgeAfter)*time.Millisecond, currentCh, cfg, release, channelsToTry[i+1:],
+				func() (func(), bool) { return s.tryEnterQueue(provider) },
+				func(ctx context.Context, ch *sigoengine.Channel) (*sigoengine.ProviderConfig, func(*sigoengine.UsageData), error) {
+					cfg, release, _, err := s.prepareChannel(ctx, backupPinged, modelID, modelInfo.Endpoint, ch, tokenEstimate)
+					return cfg, release, err
+				},
+				callChat,
+				func(loser hedgeAttempt) {
+					usage := tok.EstimateUsage(inputText, "")
+					if loser.err == nil && loser.res.Usage != nil {
+						usage = loser.res.Usage
+					}
+					s.recordUsage(modelID, loser.ch, usage)
+				})
+			i += h.consumed
+			for _, f := range h.failed {
+				s.channelManager.Registry().MarkChannelHealth(f.ch.Provider, f.ch.Name, false, f.err.Error())
+				currentCh, lastErr = f.ch, f.err
 			}
-			s.breakers[cbKey] = sigoengine.NewEnhancedCircuitBreaker(config)
+			if h.winner != nil {
+				res := h.winner.res
+				currentCh, lastErr = h.winner.ch, nil
+				responseText, responseToolCalls = res.Content, res.ToolCalls
+				responseUsage, responseFinishReason = res.Usage, res.FinishReason
+				successfulCh = currentCh
+				s.channelManager.Registry().MarkChannelHealth(currentCh.Provider, currentCh.Name, true, "")
+				break
+			}
+			apiErr := sigoengine.ClassifyError(lastErr)
+			if apiErr.Type == sigoengine.ErrClientError {
+				break
+			}
+			sigoengine.LogWarn("Failing over to next channel", map[string]interface{}{
+				"model":      target.name,
+				"channel":    currentCh.FullName(),
+				"error_type": apiErr.Type,
+			})
+			continue
 		}
-		breaker := s.breakers[cbKey]
-		s.mu.Unlock()
 
-		// Echtes Streaming nur für OpenAI-kompatible Provider.
-		// Anthropic wird als normale JSON-Antwort behandelt (kein Fake-Streaming).
-		if isStreaming && cfg.Type != "anthropic" {
-			lastErr = breaker.Do(func() error {
-				stream, e := sigoengine.CallAPIStream(ctx, cfg, apiRequest)
-				if e != nil {
-					return e
-				}
-				text, e := s.streamProviderResponse(w, stream, req.Model)
-				if e != nil {
-					return e
-				}
-				responseText = text
-				streamed = true
-				return nil
-			})
-		} else {
+		// Echtes Streaming für alle Provider: CallAPIStream liefert immer
+		// OpenAI-SSE (native Formate übersetzt sigoengine).
+		// Retry und Failover greifen bis zum ersten gesendeten Chunk; danach
+		// endet der Stream mit einem Fehler-Event (streamProviderResponse).
+		if isStreaming {
+			breaker := s.breakerFor(target.name, currentCh)
+			done := s.channelManager.BeginRequest(currentCh)
 			lastErr = sigoengine.RetryWithBackoff(ctx, retryConfig, func() error {
-				return breaker.Do(func() error {
-					text, u, fr, e := sigoengine.CallAPI(ctx, cfg, apiRequest, req.Timeout)
+				err := breaker.Do(func() error {
+					start := time.Now()
+					stream, e := sigoengine.CallAPIStream(ctx, cfg, apiRequest)
+					latency := time.Since(start)
 					if e != nil {
-						apiErr := sigoengine.ClassifyError(e)
-						if apiErr.Type == sigoengine.ErrAuthFailed {
-							if err := s.channelManager.Registry().SetActive(currentCh.Provider, currentCh.Name, false); err != nil {
-								sigoengine.LogWarn("Konnte Kanal nach Auth-Fehler nicht deaktivieren", map[string]interface{}{
-									"provider": currentCh.Provider,
-									"channel":  currentCh.Name,
-									"error":    err.Error(),
-								})
-							}
-						}
+						s.recordChannelCall(ctx, currentCh, modelID, latency, e)
+						s.disableOnAuthError(currentCh, e)
 						return e
 					}
-					responseText = text
-					responseUsage = u
-					responseFinishReason = fr
-					return nil
+					res, committed, e := s.streamProviderResponse(w, stream, out.newStream(target.name))
+					s.recordChannelCall(ctx, currentCh, modelID, latency, e)
+					if committed {
+						streamed = true
+						responseText = res.Content
+						responseToolCalls = res.ToolCalls
+						responseUsage = res.Usage
+						responseFinishReason = res.FinishReason
+					}
+					if e != nil && committed {
+						// Nicht retrybar: der Client hat bereits Daten erhalten
+						return &sigoengine.APIError{Type: sigoengine.ErrAPIFailed, Message: e.Error(), Err: e}
+					}
+					return e
 				})
+				if streamed {
+					return err
+				}
+				return s.benchedError(currentCh, err)
 			})
+			done()
+			release(responseUsage)
+		} else {
+			var res *sigoengine.ChatResult
+			res, lastErr = callChat(ctx, currentCh, cfg)
+			release(resultUsage(res))
+			if lastErr == nil {
+				responseText = res.Content
+				responseToolCalls = res.ToolCalls
+				responseUsage = res.Usage
+				responseFinishReason = res.FinishReason
+			}
 		}
 
 		if lastErr == nil {
@@ -840,138 +1282,350 @@ func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 		// Lazy Health: fehlgeschlagener User-Request → Kanal unhealthy
 		s.channelManager.Registry().MarkChannelHealth(currentCh.Provider, currentCh.Name, false, lastErr.Error())
 
+		// Stream bereits beim Client: kein Failover mehr möglich
+		if streamed {
+			successfulCh = currentCh
+			break
+		}
+
 		apiErr := sigoengine.ClassifyError(lastErr)
 		if apiErr.Type == sigoengine.ErrClientError {
 			break
 		}
 		sigoengine.LogWarn("Failing over to next channel", map[string]interface{}{
-			"model":      req.Model,
+			"model":      target.name,
 			"channel":    currentCh.FullName(),
 			"error_type": apiErr.Type,
 		})
 	}
 
-	if lastErr != nil {
-		// Eigener Rate-Limiter-Fehler (sentinel, kein APIError):
-		// alle Kanäle waren innerhalb maxWait nicht frei → HTTP 429.
-		if lastErr == sigoengine.ErrRateLimited {
-			retryAfter := s.rateMaxWait.Seconds()
-			if retryAfter < 1 {
-				retryAfter = 1
-			}
-			sigoengine.LogWarn("Alle Kanäle rate-limitiert", map[string]interface{}{
-				"model":       req.Model,
-				"retry_after": retryAfter,
-			})
-			w.Header().Set("Retry-After", fmt.Sprintf("%.0f", retryAfter))
-			writeError(w, "rate limit exceeded: all channels throttled", "rate_limit", http.StatusTooManyRequests)
-			return
+	att.ch, att.err, att.streamed = successfulCh, lastErr, streamed
+	att.unreachable = lastErr != nil && lastErr == unreachable
+	att.res = sigoengine.ChatResult{
+		Content:      responseText,
+		ToolCalls:    responseToolCalls,
+		Usage:        responseUsage,
+		FinishReason: responseFinishReason,
+	}
+	att.session, att.turnMessages, att.inputText = session, turnMessages, inputText
+	return att
+}
+
+// **********************************************************************
+// Gemeinsame Upstream-Bausteine (Chat, Messages, Embeddings)
+
+// resolveChannel löst den Kanal wie ChannelManager.Resolve auf; ohne
+// expliziten Kanal wird der erste aktive genommen, der das Modell bedient
+// (Ollama: Modell auf dem Host installiert).
+func (s *Server) resolveChannel(provider, modelID, requested string) (*sigoengine.Channel, error) {
+	ch, err := s.channelManager.ResolveModel(provider, modelID, requested)
+	if err != nil || requested != "" {
+		return ch, err
+	}
+	for _, c := range s.failoverChain(provider, ch) {
+		if sigoengine.ChannelServesModel(c, modelID) {
+			return c, nil
 		}
+	}
+	return ch, nil
+}
 
-		// Fehler klassifizieren für typisierte Antwort
-		apiErr := sigoengine.ClassifyError(lastErr)
+// prepareChannel baut die Config eines Kanals für modelID (Endpoint des
+// Kanals, sonst modelEndpoint) und prüft Ping und Rate-Limiter. down
+// meldet einen nicht erreichbaren Provider; ErrRateLimited heißt "nächster
+// Kanal", ein abgebrochener ctx beendet den Failover. tokens ist die
+// Schätzung für das TPM-Limit; release gibt den Rate-Limiter-Slot nach dem
+// Call frei (siehe acquireChannel).
+func (s *Server) prepareChannel(ctx context.Context, pinged map[string]error, modelID, modelEndpoint string,
+	ch *sigoengine.Channel, tokens int) (cfg *sigoengine.ProviderConfig, release func(*sigoengine.UsageData), down bool, err error) {
+	cfg, err = sigoengine.LoadConfigWithChannel(modelID, ch)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	cfg.Endpoint = sigoengine.ChannelEndpoint(ch, modelEndpoint)
+	s.watchRateLimits(cfg, ch)
 
-		sigoengine.LogError("API-Call fehlgeschlagen", lastErr, map[string]interface{}{
-			"model":       req.Model,
-			"error_type":  apiErr.Type,
-			"status_code": apiErr.StatusCode,
+	// Provider-Ping: nicht erreichbar → kein API-Call, nächster Kanal
+	// (anderer Host, z.B. Ollama); sonst HTTP 503
+	if err := s.pingChannel(pinged, modelID, ch, cfg.Endpoint); err != nil {
+		return nil, nil, true, err
+	}
+
+	// Rate-Limiter pro Kanal: ErrRateLimited → Failover auf nächsten Kanal
+	release, err = s.acquireChannel(ctx, ch, tokens)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return cfg, release, false, nil
+}
+
+// pingChannel prüft per PingProvider, ob der Endpoint eines Kanals
+// erreichbar ist. pinged merkt das Ergebnis je Endpoint, damit Kanäle
+// desselben Hosts nur einmal pro Request gepingt werden.
+func (s *Server) pingChannel(pinged map[string]error, model string, ch *sigoengine.Channel, endpoint string) error {
+	err, done := pinged[endpoint]
+	if !done {
+		err = sigoengine.PingProvider(endpoint)
+		pinged[endpoint] = err
+	}
+	if err != nil {
+		sigoengine.LogWarn("Provider nicht erreichbar", map[string]interface{}{
+			"model":    model,
+			"channel":  ch.FullName(),
+			"endpoint": endpoint,
+			"error":    err.Error(),
 		})
+		s.channelManager.Registry().MarkChannelHealth(ch.Provider, ch.Name, false, err.Error())
+	}
+	return err
+}
+
+// failoverChain liefert den Startkanal gefolgt von allen weiteren aktiven
+// Kanälen des Providers (Failover-Reihenfolge, siehe
+// ChannelManager.FailoverChain).
+func (s *Server) failoverChain(provider string, ch *sigoengine.Channel) []*sigoengine.Channel {
+	return s.channelManager.FailoverChain(provider, ch)
+}
 
-		// HTTP-Status und Error-Type basierend auf Fehlerklasse
-		httpStatus := http.StatusBadGateway
-		errType := "api_error"
-
-		switch apiErr.Type {
-		case sigoengine.ErrRateLimit:
-			httpStatus = http.StatusTooManyRequests // 429
-			errType = "rate_limit"
-			// Retry-After Header setzen
-			if apiErr.RetryAfter > 0 {
-				w.Header().Set("Retry-After", fmt.Sprintf("%.0f", apiErr.RetryAfter.Seconds()))
+// channelLimits liefert die Rate-Limits eines Kanals: Server-Default,
+// darüber der Provider-Default (providers.json rate_limit), darüber die
+// Kanal-Config.
+func (s *Server) channelLimits(ch *sigoengine.Channel) sigoengine.Limits {
+	minInt := s.rateMinInterval
+	maxW := s.rateMaxWait
+	if p, ok := sigoengine.GetProvider(ch.Provider); ok {
+		if rl, ok := p.(sigoengine.RateLimitProvider); ok {
+			if pMin, pWait, set := rl.DefaultRateLimits(); set {
+				minInt = pMin
+				if pWait > 0 {
+					maxW = pWait
+				}
 			}
-		case sigoengine.ErrAuthFailed:
-			httpStatus = http.StatusUnauthorized // 401
-			errType = "auth_failed"
-		case sigoengine.ErrTimeout:
-			httpStatus = http.StatusGatewayTimeout // 504
-			errType = "timeout"
-		case sigoengine.ErrServerError:
-			httpStatus = http.StatusServiceUnavailable // 503
-			errType = "server_error"
-		case sigoengine.ErrClientError:
-			httpStatus = http.StatusBadRequest // 400
-			errType = "client_error"
-		case sigoengine.ErrCircuitOpen:
-			httpStatus = http.StatusServiceUnavailable // 503
-			errType = "circuit_open"
-		}
-
-		writeError(w, apiErr.Message, errType, httpStatus)
-		return
+		}
+	}
+	if ch.MinInterval > 0 {
+		minInt = time.Duration(ch.MinInterval) * time.Millisecond
 	}
+	if ch.MaxWait > 0 {
+		maxW = time.Duration(ch.MaxWait) * time.Millisecond
+	}
+	return sigoengine.Limits{
+		MinInterval:   minInt,
+		MaxWait:       maxW,
+		MaxConcurrent: ch.MaxConcurrent,
+		RPM:           ch.RPM,
+		TPM:           ch.TPM,
+		Burst:         ch.Burst,
+	}
+}
 
-	// Usage schätzen falls Provider keine liefert
-	if responseUsage == nil {
-		responseUsage = sigoengine.EstimateUsage(inputText, responseText)
+// acquireChannel wendet den Rate-Limiter des Kanals an (hybrid): wartet bis
+// minInterval seit dem letzten Call vergangen, (max_concurrent) ein Slot
+// frei ist und die RPM/TPM-Buckets reichen, spätestens nach maxWait →
+// ErrRateLimited (Aufrufer macht Failover bzw. HTTP 429). tokens ist die
+// Schätzung des Calls für das TPM-Limit. release gibt den Slot nach dem
+// Call frei und bucht die Abbuchung (Schätzung, höchstens TPM) auf die
+// echte Usage um (nil: keine Usage bekannt, Abbuchung bleibt stehen).
+func (s *Server) acquireChannel(ctx context.Context, ch *sigoengine.Channel, tokens int) (release func(usage *sigoengine.UsageData), err error) {
+	limits := s.channelLimits(ch)
+	key := ch.FullName()
+	if limits.MinInterval <= 0 && limits.MaxConcurrent <= 0 && limits.RPM <= 0 && limits.TPM <= 0 &&
+		s.rateLimiter.BenchedUntil(key).IsZero() {
+		return func(*sigoengine.UsageData) {}, nil
+	}
+	limits.Tokens = tokens
+	charged, err := s.rateLimiter.AcquireLimits(ctx, key, limits)
+	if err != nil {
+		if err == sigoengine.ErrRateLimited {
+			sigoengine.LogWarn("Rate-Limit: Kanal überlastet, Failover", map[string]interface{}{
+				"channel":        key,
+				"max_wait_ms":    limits.MaxWait.Milliseconds(),
+				"max_concurrent": limits.MaxConcurrent,
+				"rpm":            limits.RPM,
+				"tpm":            limits.TPM,
+			})
+		}
+		return nil, err
 	}
+	return func(usage *sigoengine.UsageData) {
+		s.rateLimiter.Release(key)
+		if usage != nil && limits.TPM > 0 {
+			s.rateLimiter.CorrectTokens(key, charged, usage.TotalTokens)
+		}
+	}, nil
+}
 
-	// Session speichern (unter dem Kanal, der tatsächlich geantwortet hat)
-	if req.SessionID != "" && userPrompt != "" && successfulCh != nil {
-		session.AddMessage("user", userPrompt)
-		session.AddMessage("assistant", responseText)
-		session.SaveForChannel(s.baseDir, successfulCh.Provider, successfulCh.Name, req.SessionID, req.Model)
+// watchRateLimits verbindet die Rate-Limit-Header der Provider-Antworten
+// (Retry-After, x-ratelimit-*) mit dem Rate-Limiter: meldet der Provider
+// den Kanal als erschöpft, pausiert er bis zum Reset und andere Requests
+// gehen sofort an den nächsten Kanal.
+func (s *Server) watchRateLimits(cfg *sigoengine.ProviderConfig, ch *sigoengine.Channel) {
+	key := ch.FullName()
+	cfg.OnRateLimit = func(until time.Time) {
+		if s.rateLimiter.Bench(key, until) {
+			sigoengine.LogWarn("Rate-Limit laut Provider erschöpft, Kanal pausiert", map[string]interface{}{
+				"channel":  key,
+				"reset_ms": time.Until(until).Milliseconds(),
+			})
+		}
 	}
+}
 
-	// Usage akkumulieren
-	chatUsage := &ChatUsage{
-		PromptTokens:     responseUsage.InputTokens,
-		CompletionTokens: responseUsage.OutputTokens,
-		TotalTokens:      responseUsage.TotalTokens,
+// benchedError ersetzt den Fehler eines Calls durch ErrRateLimited, wenn
+// der Kanal inzwischen pausiert (z.B. 429 mit Retry-After): kein Retry
+// auf demselben Kanal, sondern sofort Failover.
+func (s *Server) benchedError(ch *sigoengine.Channel, err error) error {
+	if err != nil && !s.rateLimiter.BenchedUntil(ch.FullName()).IsZero() {
+		return sigoengine.ErrRateLimited
+	}
+	return err
+}
+
+// resultUsage liefert die Usage eines Chat-Ergebnisses (nil ohne Ergebnis).
+func resultUsage(res *sigoengine.ChatResult) *sigoengine.UsageData {
+	if res == nil {
+		return nil
+	}
+	return res.Usage
+}
+
+// recordChannelCall erfasst Latenz und Ergebnis eines Upstream-Calls in der
+// Kanal-Statistik (Strategie "fastest", /api/channels). Client-Fehler und
+// abgebrochene Requests sagen nichts über den Kanal aus und zählen nicht.
+// Bei Streams ist latency die Zeit bis zur Antwort des Providers, err
+// enthält auch Abbrüche im Stream.
+func (s *Server) recordChannelCall(ctx context.Context, ch *sigoengine.Channel, model string, latency time.Duration, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil && sigoengine.ClassifyError(err).Type == sigoengine.ErrClientError {
+		return
+	}
+	s.channelManager.Registry().RecordCall(ch, model, latency, err != nil)
+}
+
+// breakerFor liefert den Circuit Breaker pro Kanal (Key: model#channel).
+func (s *Server) breakerFor(model string, ch *sigoengine.Channel) *sigoengine.EnhancedCircuitBreaker {
+	cbKey := fmt.Sprintf("%s#%s", model, ch.FullName())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.breakers[cbKey]; !exists {
+		config := &sigoengine.CircuitBreakerConfig{
+			Threshold:   5,
+			Window:      60 * time.Second,
+			Cooldown:    10 * time.Second,
+			HalfOpenMax: 3,
+		}
+		s.breakers[cbKey] = sigoengine.NewEnhancedCircuitBreaker(config)
+	}
+	return s.breakers[cbKey]
+}
+
+// disableOnAuthError deaktiviert einen Kanal persistent, wenn der Provider
+// den Key abgelehnt hat (401/403).
+func (s *Server) disableOnAuthError(ch *sigoengine.Channel, err error) {
+	if sigoengine.ClassifyError(err).Type != sigoengine.ErrAuthFailed {
+		return
+	}
+	if e := s.channelManager.Registry().SetActive(ch.Provider, ch.Name, false); e != nil {
+		sigoengine.LogWarn("Konnte Kanal nach Auth-Fehler nicht deaktivieren", map[string]interface{}{
+			"provider": ch.Provider,
+			"channel":  ch.Name,
+			"error":    e.Error(),
+		})
 	}
+}
+
+// recordUsage akkumuliert Token-Verbrauch pro Modell und pro Modell#Kanal.
+func (s *Server) recordUsage(modelID string, ch *sigoengine.Channel, usage *sigoengine.UsageData) {
 	s.usageMu.Lock()
+	defer s.usageMu.Unlock()
+
 	stats, ok := s.usage[modelID]
 	if !ok {
 		stats = &ModelUsageStats{}
 		s.usage[modelID] = stats
 	}
-	stats.InputTokens += int64(responseUsage.InputTokens)
-	stats.OutputTokens += int64(responseUsage.OutputTokens)
-	stats.TotalTokens += int64(responseUsage.TotalTokens)
+	stats.InputTokens += int64(usage.InputTokens)
+	stats.OutputTokens += int64(usage.OutputTokens)
+	stats.TotalTokens += int64(usage.TotalTokens)
 	stats.Requests++
 
-	channelKey := fmt.Sprintf("%s#%s", modelID, successfulCh.FullName())
+	channelKey := fmt.Sprintf("%s#%s", modelID, ch.FullName())
 	channelStats, ok := s.usageByChannel[channelKey]
 	if !ok {
 		channelStats = &ModelUsageStats{}
 		s.usageByChannel[channelKey] = channelStats
 	}
-	channelStats.InputTokens += int64(responseUsage.InputTokens)
-	channelStats.OutputTokens += int64(responseUsage.OutputTokens)
-	channelStats.TotalTokens += int64(responseUsage.TotalTokens)
+	channelStats.InputTokens += int64(usage.InputTokens)
+	channelStats.OutputTokens += int64(usage.OutputTokens)
+	channelStats.TotalTokens += int64(usage.TotalTokens)
 	channelStats.Requests++
-	s.usageMu.Unlock()
+}
 
-	// Bei echtem Streaming wurde die Antwort bereits geschrieben.
-	if streamed {
+// writeUpstreamError übersetzt den letzten Upstream-Fehler nach allen
+// Kanälen in eine typisierte HTTP-Antwort im Client-Format.
+func (s *Server) writeUpstreamError(w http.ResponseWriter, out apiFormat, model string, lastErr error) {
+	// Eigener Rate-Limiter-Fehler (sentinel, kein APIError):
+	// alle Kanäle waren innerhalb maxWait nicht frei → HTTP 429.
+	if lastErr == sigoengine.ErrQueueFull {
+		w.Header().Set("Retry-After", "1")
+		out.writeError(w, "rate limit exceeded: request queue full", "rate_limit", http.StatusTooManyRequests)
 		return
 	}
-
-	// OpenAI-kompatible JSON-Antwort (non-streaming oder Anthropic-streaming)
-	resp := ChatResponse{
-		ID:      fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano()),
-		Object:  "chat.completion",
-		Created: time.Now().Unix(),
-		Model:   req.Model,
-		Choices: []ChatChoice{{
-			Index:        0,
-			Message:      ChatMessage{Role: "assistant", Content: json.RawMessage(`"` + jsonEscapeString(responseText) + `"`)},
-			FinishReason: responseFinishReason,
-		}},
-		Usage: chatUsage,
+	if lastErr == sigoengine.ErrRateLimited {
+		retryAfter := s.rateMaxWait.Seconds()
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		sigoengine.LogWarn("Alle Kanäle rate-limitiert", map[string]interface{}{
+			"model":       model,
+			"retry_after": retryAfter,
+		})
+		w.Header().Set("Retry-After", fmt.Sprintf("%.0f", retryAfter))
+		out.writeError(w, "rate limit exceeded: all channels throttled", "rate_limit", http.StatusTooManyRequests)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	// Fehler klassifizieren für typisierte Antwort
+	apiErr := sigoengine.ClassifyError(lastErr)
+
+	sigoengine.LogError("API-Call fehlgeschlagen", lastErr, map[string]interface{}{
+		"model":       model,
+		"error_type":  apiErr.Type,
+		"status_code": apiErr.StatusCode,
+	})
+
+	// HTTP-Status und Error-Type basierend auf Fehlerklasse
+	httpStatus := http.StatusBadGateway
+	errType := "api_error"
+
+	switch apiErr.Type {
+	case sigoengine.ErrRateLimit:
+		httpStatus = http.StatusTooManyRequests // 429
+		errType = "rate_limit"
+		// Retry-After Header setzen
+		if apiErr.RetryAfter > 0 {
+			w.Header().Set("Retry-After", fmt.Sprintf("%.0f", apiErr.RetryAfter.Seconds()))
+		}
+	case sigoengine.ErrAuthFailed:
+		httpStatus = http.StatusUnauthorized // 401
+		errType = "auth_failed"
+	case sigoengine.ErrTimeout:
+		httpStatus = http.StatusGatewayTimeout // 504
+		errType = "timeout"
+	case sigoengine.ErrServerError:
+		httpStatus = http.StatusServiceUnavailable // 503
+		errType = "server_error"
+	case sigoengine.ErrClientError:
+		httpStatus = http.StatusBadRequest // 400
+		errType = "client_error"
+	case sigoengine.ErrCircuitOpen:
+		httpStatus = http.StatusServiceUnavailable // 503
+		errType = "circuit_open"
+	}
+
+	out.writeError(w, apiErr.Message, errType, httpStatus)
 }
 
 // **********************************************************************
@@ -986,30 +1640,56 @@ func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
 	defer s.mu.RUnlock()
 
 	type ModelData struct {
-		ID      string `json:"id"`
-		Object  string `json:"object"`
-		Created int64  `json:"created"`
-		OwnedBy string `json:"owned_by"`
+		ID           string   `json:"id"`
+		Object       string   `json:"object"`
+		Created      int64    `json:"created"`
+		OwnedBy      string   `json:"owned_by"`
+		Capabilities []string `json:"capabilities,omitempty"`
 	}
 
 	var models []ModelData
 	for id, info := range s.models {
 		// ID und Shortcode hinzufügen
 		models = append(models, ModelData{
-			ID:      id,
-			Object:  "model",
-			Created: time.Now().Unix(),
-			OwnedBy: "sigorest",
+			ID:           id,
+			Object:       "model",
+			Created:      time.Now().Unix(),
+			OwnedBy:      "sigorest",
+			Capabilities: info.Capabilities,
 		})
 		if info.Shortcode != id {
 			models = append(models, ModelData{
-				ID:      info.Shortcode,
-				Object:  "model",
-				Created: time.Now().Unix(),
-				OwnedBy: "sigorest",
+				ID:           info.Shortcode,
+				Object:       "model",
+				Created:      time.Now().Unix(),
+				OwnedBy:      "sigorest",
+				Capabilities: info.Capabilities,
 			})
 		}
 	}
+	for _, vm := range s.virtualModels {
+		models = append(models, ModelData{
+			ID:      vm.Name,
+			Object:  "model",
+			Created: time.Now().Unix(),
+			OwnedBy: "sigorest",
+		})
+	}
+	// Automatische Modellwahl (verdeckt durch gleichnamige Modelle)
+	for _, name := range append([]string{autoModelName}, autoProfiles...) {
+		if name != autoModelName {
+			name = autoModelName + ":" + name
+		}
+		if _, _, exists := s.lookupModel(name); exists {
+			continue
+		}
+		models = append(models, ModelData{
+			ID:      name,
+			Object:  "model",
+			Created: time.Now().Unix(),
+			OwnedBy: "sigorest",
+		})
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -1031,19 +1711,11 @@ func (s *Server) handleAPIModels(w http.ResponseWriter, r *http.Request) {
 
 	var models []ModelInfo
 	for id, info := range s.models {
-		models = append(models, ModelInfo{
-			ID:                       id,
-			Shortcode:                info.Shortcode,
-			Endpoint:                 info.Endpoint,
-			APIKey:                   info.APIKey,
-			MaxInputTokens:           info.MaxInputTokens,
-			MaxOutputTokens:          info.MaxOutputTokens,
-			InputCost:                info.InputCost,
-			OutputCost:               info.OutputCost,
-			MinTemperature:           info.MinTemperature,
-			MaxTemperature:           info.MaxTemperature,
-			RequiresCompletionTokens: info.RequiresCompletionTokens,
-		})
+		info.ID = id
+		models = append(models, info)
+	}
+	for _, vm := range s.virtualModels {
+		models = append(models, vm.info())
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -1170,8 +1842,22 @@ func (s *Server) handleChannels(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	status := s.channelManager.AllChannelStatus()
+	for _, ch := range status {
+		ch["benched_until"] = s.benchedUntil(ch["full_name"].(string))
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(s.channelManager.AllChannelStatus())
+	json.NewEncoder(w).Encode(status)
+}
+
+// benchedUntil liefert das Ende der Provider-Pause eines Kanals für die
+// Status-Ausgabe (nil, wenn der Kanal nicht pausiert).
+func (s *Server) benchedUntil(fullName string) *time.Time {
+	until := s.rateLimiter.BenchedUntil(fullName)
+	if until.IsZero() {
+		return nil
+	}
+	return &until
 }
 
 // GET /api/channels/:provider/:name - Einzelkanal
@@ -1191,6 +1877,9 @@ func (s *Server) handleChannelDetail(w http.ResponseWriter, r *http.Request, pro
 		"last_health_check":  ch.LastHealthCheck,
 		"last_error":         ch.LastError,
 		"consecutive_errors": ch.ConsecutiveErrors,
+		"benched_until":      s.benchedUntil(ch.FullName()),
+		"stats":              s.channelManager.Registry().Stats(ch),
+		"model_stats":        s.channelManager.Registry().ModelStatsByChannel(ch),
 	})
 }
 
@@ -1308,6 +1997,7 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 		"timestamp":        time.Now().Unix(),
 		"available_models": len(s.models),
 		"circuit_breakers": breakers,
+		"queues":           s.requestQueue.Stats(),
 		"memory_set":       s.memory.Content != "",
 	})
 }
@@ -1402,24 +2092,61 @@ func (s *Server) handleHelp(w http.ResponseWriter, r *http.Request) {
 				"method":      "POST",
 				"description": "OpenAI-kompatible Chat-Completion API",
 				"parameters": map[string]string{
-					"model":       "Modell-ID oder Shortcode (z.B. 'claude-h', 'gpt41')",
-					"messages":    "Array von {role, content} Objekten",
-					"temperature": "Optional: 0.0-2.0 (default: Modell-Mittelwert)",
-					"max_tokens":  "Optional: Max. Ausgabe-Tokens",
-					"session_id":  "Optional: Session-ID für Gesprächsverlauf",
-					"timeout":     "Optional: Timeout in Sekunden (default: 180)",
-					"retries":     "Optional: Anzahl Retries (default: 3)",
-					"channel":     "Optional: Kanal-FullName z.B. 'mammouth-0'",
-					"stream":      "Optional: true für Server-Sent Events Streaming (OpenAI-kompatibel)",
+					"model":           "Modell-ID oder Shortcode (z.B. 'claude-h', 'gpt41'), virtuelles Modell oder 'auto', 'auto:vision', 'auto:cheap', 'auto:long' (automatische Wahl)",
+					"max_cost":        "Optional: bei 'auto' nur Modelle bis zu diesem Preis ($/1M Tokens, Mittel aus Input und Output)",
+					"messages":        "Array von {role, content} Objekten",
+					"temperature":     "Optional: 0.0-2.0 (default: Modell-Mittelwert)",
+					"max_tokens":      "Optional: Max. Ausgabe-Tokens",
+					"session_id":      "Optional: Session-ID für Gesprächsverlauf",
+					"timeout":         "Optional: Timeout in Sekunden (default: 180)",
+					"retries":         "Optional: Anzahl Retries (default: 3)",
+					"channel":         "Optional: Kanal-FullName z.B. 'mammouth-0'",
+					"hedge_after_ms":  "Optional: nach dieser Zeit denselben Request zusätzlich auf dem nächsten Kanal starten (nur ohne stream)",
+					"X-Sigo-Priority": "Optional (Header): high|normal|low, Klasse in der Request-Queue des Providers",
+					"stream":          "Optional: true für Server-Sent Events Streaming (OpenAI-kompatibel)",
+					"tools":           "Optional: OpenAI Tool-Definitionen (Function-Calling), tool_choice, parallel_tool_calls",
+					"...":             "Weitere OpenAI-Parameter (top_p, stop, seed, response_format, ...) werden durchgereicht; Provider-Policy entfernt nicht unterstützte",
 				},
 				"example": `curl -s http://localhost:9080/v1/chat/completions \
   -H "Content-Type: application/json" \
   -d '{"model":"claude-h","messages":[{"role":"user","content":"Hallo"}]}'`,
+			},
+			{
+				"path":        "/v1/messages",
+				"method":      "POST",
+				"description": "Anthropic-kompatible Messages API (gleiche Modelle, Kanäle, Memory und Sessions wie /v1/chat/completions)",
+				"parameters": map[string]string{
+					"model":      "Modell-ID oder Shortcode (jedes Backend, nicht nur Claude)",
+					"messages":   "Anthropic-Messages (Text, Bilder, tool_use, tool_result)",
+					"system":     "Optional: System-Prompt (String oder Text-Blöcke)",
+					"max_tokens": "Max. Ausgabe-Tokens",
+					"stream":     "Optional: true für Anthropic-SSE (message_start, content_block_delta, ...)",
+					"tools":      "Optional: Anthropic Tool-Definitionen, tool_choice",
+					"...":        "sigoREST-Erweiterungen wie bei /v1/chat/completions (session_id, channel, timeout, retries, system_prompt)",
+				},
+				"example": `curl -s http://localhost:9080/v1/messages \
+  -H "Content-Type: application/json" \
+  -d '{"model":"claude-h","max_tokens":1024,"messages":[{"role":"user","content":"Hallo"}]}'`,
+			},
+			{
+				"path":        "/v1/embeddings",
+				"method":      "POST",
+				"description": "OpenAI-kompatible Embeddings (Provider und Ollama /api/embed, mit Kanal-Failover und Usage-Zählung)",
+				"parameters": map[string]string{
+					"model":           "Embedding-Modell (ID oder Shortcode, kind \"embedding\" in /api/models)",
+					"input":           "String, String-Array oder Token-Array(s)",
+					"encoding_format": "Optional: float (default) oder base64",
+					"dimensions":      "Optional: Ziel-Dimension (falls vom Modell unterstützt)",
+					"...":             "sigoREST-Erweiterungen: channel, timeout, retries",
+				},
+				"example": `curl -s http://localhost:9080/v1/embeddings \
+  -H "Content-Type: application/json" \
+  -d '{"model":"zemb3","input":["Hallo Welt","Guten Morgen"]}'`,
 			},
 			{
 				"path":        "/v1/models",
 				"method":      "GET",
-				"description": "Liste aller verfügbaren Modelle (OpenAI-kompatibel)",
+				"description": "Liste aller verfügbaren Modelle inkl. virtueller Modelle (OpenAI-kompatibel)",
 				"example":     "curl -s http://localhost:9080/v1/models",
 			},
 			{
@@ -1428,6 +2155,12 @@ func (s *Server) handleHelp(w http.ResponseWriter, r *http.Request) {
 				"description": "Detaillierte Modell-Informationen (Preise, Limits)",
 				"example":     "curl -s http://localhost:9080/api/models",
 			},
+			{
+				"path":        "/api/models/refresh",
+				"method":      "POST",
+				"description": "Provider-Modell-Listen neu laden (neue/entfallene Modelle; Provider mit Fehler behalten ihre Modelle)",
+				"example":     "curl -s -X POST http://localhost:9080/api/models/refresh | jq",
+			},
 			{
 				"path":        "/api/health",
 				"method":      "GET",
@@ -1498,6 +2231,20 @@ func (s *Server) handleHelp(w http.ResponseWriter, r *http.Request) {
 				"description": "Token-Verbrauch pro Modell und pro Kanal",
 				"example":     "curl -s http://localhost:9080/api/usage | jq",
 			},
+			{
+				"path":        "/api/tokenize",
+				"method":      "POST",
+				"description": "Tokens lokal zählen (Encoding des Modells, ohne Provider-Call), inkl. Kontextfenster und Input-Kosten",
+				"parameters": map[string]string{
+					"model":    "Modell-ID oder Shortcode",
+					"text":     "Optional: Text",
+					"messages": "Optional: Chat-Messages (OpenAI-Format, Zählung wie im Pre-Flight)",
+					"tools":    "Optional: Tool-Definitionen",
+				},
+				"example": `curl -s http://localhost:9080/api/tokenize \
+  -H "Content-Type: application/json" \
+  -d '{"model":"gpt41","messages":[{"role":"user","content":"Hallo"}]}'`,
+			},
 			{
 				"path":        "/api/system-prompt",
 				"method":      "GET/PUT",
@@ -1518,19 +2265,20 @@ func (s *Server) handleHelp(w http.ResponseWriter, r *http.Request) {
 			"retry":                  "Exponential Backoff: 500ms → 1s → 2s → max 5s",
 			"session_management":     "JSON-basierte Sessions pro Kanal",
 			"ip_access_control":      "HTTP: localhost, HTTPS: privates Netz",
-			"ollama_discovery":       "Auto-Discovery lokaler Ollama-Modelle",
+			"ollama_discovery":       "Periodische Discovery der Ollama-Modelle, mehrere Hosts als Kanäle",
 			"memory_block":           "Globaler + kanal-spezifischer Memory",
 			"system_prompt":          "Globaler + kanal-spezifischer + per-Request System-Prompt",
 			"multi_channel":          "Mehrere API-Key-Kanäle pro Provider mit Failover",
 			"channel_health_monitor": "Automatische Health-Checks und Reserve-Zuschaltung",
 		},
 		"error_types": map[string]string{
-			"rate_limit":   "HTTP 429 - Zu viele Anfragen, Retry-After Header gesetzt",
-			"auth_failed":  "HTTP 401 - Ungültiger API-Key",
-			"timeout":      "HTTP 504 - Request Timeout",
-			"server_error": "HTTP 503 - Upstream Server-Fehler",
-			"client_error": "HTTP 400 - Ungültige Anfrage",
-			"circuit_open": "HTTP 503 - Circuit Breaker geöffnet",
+			"rate_limit":          "HTTP 429 - Zu viele Anfragen, Retry-After Header gesetzt",
+			"auth_failed":         "HTTP 401 - Ungültiger API-Key",
+			"timeout":             "HTTP 504 - Request Timeout",
+			"server_error":        "HTTP 503 - Upstream Server-Fehler",
+			"client_error":        "HTTP 400 - Ungültige Anfrage",
+			"circuit_open":        "HTTP 503 - Circuit Breaker geöffnet",
+			"unsupported_feature": "HTTP 400 - Modell unterstützt ein Feature des Requests nicht (capabilities)",
 		},
 		"environment_variables": map[string]string{
 			"MAMMOUTH_API_KEY": "Für GPT, Claude, Gemini, Grok, DeepSeek, ...",
@@ -1584,21 +2332,211 @@ func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
 // **********************************************************************
 // Hilfsfunktion für Fehler-Antworten
 func writeError(w http.ResponseWriter, msg, errType string, status int) {
+	writeErrorDetails(w, msg, errType, status, nil)
+}
+
+// writeErrorDetails schreibt eine Fehler-Antwort mit error.details.
+func writeErrorDetails(w http.ResponseWriter, msg, errType string, status int, details map[string]interface{}) {
 	var resp ErrorResponse
 	resp.Error.Message = msg
 	resp.Error.Type = errType
 	resp.Error.Code = errType
+	resp.Error.Details = details
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(resp)
 }
 
+// **********************************************************************
+// Client-Wire-Formate
+
+// apiFormat kapselt das Wire-Format, in dem ein Client mit sigoREST spricht.
+// serveChat arbeitet intern mit OpenAI-Strukturen; das Format übersetzt nur
+// Fehler, fertige Antworten und Stream-Events.
+type apiFormat interface {
+	writeError(w http.ResponseWriter, msg, errType string, status int)
+	writeErrorDetails(w http.ResponseWriter, msg, errType string, status int, details map[string]interface{})
+	writeResult(w http.ResponseWriter, model string, res *sigoengine.ChatResult, usage *ChatUsage)
+	newStream(model string) streamEncoder
+}
+
+// streamEncoder übersetzt OpenAI-data-Payloads (chat.completion.chunk-JSON
+// oder "[DONE]") in SSE-Frames des Client-Formats. fail liefert das
+// Fehler-Event für einen Abbruch nach bereits gesendeten Chunks.
+type streamEncoder interface {
+	encode(data string) string
+	finish() string
+	fail(msg, errType string) string
+}
+
+// openAIFormat: /v1/chat/completions. includeUsage: Client hat
+// stream_options.include_usage gesetzt und bekommt den Usage-Chunk.
+type openAIFormat struct {
+	includeUsage bool
+}
+
+func (openAIFormat) writeError(w http.ResponseWriter, msg, errType string, status int) {
+	writeError(w, msg, errType, status)
+}
+
+func (openAIFormat) writeErrorDetails(w http.ResponseWriter, msg, errType string, status int, details map[string]interface{}) {
+	writeErrorDetails(w, msg, errType, status, details)
+}
+
+func (openAIFormat) writeResult(w http.ResponseWriter, model string, res *sigoengine.ChatResult, usage *ChatUsage) {
+	resp := ChatResponse{
+		ID:      fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano()),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []ChatChoice{{
+			Index: 0,
+			Message: ChatMessage{
+				Role:      "assistant",
+				Content:   assistantContent(res.Content, res.ToolCalls),
+				ToolCalls: res.ToolCalls,
+			},
+			FinishReason: res.FinishReason,
+		}},
+		Usage: usage,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (f openAIFormat) newStream(model string) streamEncoder {
+	return &openAIStream{includeUsage: f.includeUsage}
+}
+
+// openAIStream reicht die Provider-Chunks unverändert durch. Die Usage, die
+// sigoREST selbst angefordert hat, bekommt der Client nur, wenn er sie auch
+// angefordert hat: der Usage-Chunk (leere choices) entfällt, manche Provider
+// hängen "usage" aber an den letzten Chunk mit choices – dort wird sie
+// entfernt.
+type openAIStream struct {
+	includeUsage bool
+	done         bool
+}
+
+func (e *openAIStream) encode(data string) string {
+	if data == "[DONE]" {
+		e.done = true
+	}
+	if !e.includeUsage {
+		var ok bool
+		if data, ok = stripStreamUsage(data); !ok {
+			return ""
+		}
+	}
+	return "data: " + data + "\n\n"
+}
+
+// stripStreamUsage entfernt die Top-Level-"usage" aus einem Chunk. ok ist
+// false für den reinen Usage-Chunk (leere choices), der ganz entfällt.
+// Chunks ohne usage bleiben unverändert.
+func stripStreamUsage(data string) (string, bool) {
+	if !strings.Contains(data, `"usage"`) {
+		return data, true
+	}
+	var chunk map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return data, true
+	}
+	usage, has := chunk["usage"]
+	if !has || string(usage) == "null" {
+		return data, true
+	}
+	var choices []json.RawMessage
+	json.Unmarshal(chunk["choices"], &choices)
+	if len(choices) == 0 {
+		return "", false
+	}
+	delete(chunk, "usage")
+	out, err := json.Marshal(chunk)
+	if err != nil {
+		return data, true
+	}
+	return string(out), true
+}
+
+func (e *openAIStream) finish() string {
+	if e.done {
+		return ""
+	}
+	e.done = true
+	return "data: [DONE]\n\n"
+}
+
+// fail: Fehler-Objekt wie bei der OpenAI-API mitten im Stream; kein [DONE],
+// damit Clients den Abbruch nicht als reguläres Ende lesen.
+func (e *openAIStream) fail(msg, errType string) string {
+	e.done = true
+	var resp ErrorResponse
+	resp.Error.Message = msg
+	resp.Error.Type = errType
+	resp.Error.Code = errType
+	data, _ := json.Marshal(resp)
+	return "data: " + string(data) + "\n\n"
+}
+
 // jsonEscapeString escaped a string for JSON embedding
 func jsonEscapeString(s string) string {
 	b, _ := json.Marshal(s)
 	return string(b[1 : len(b)-1])
 }
 
+// newTurnStart liefert den Index der ersten Request-Message, die die
+// Session noch nicht enthält: nach dem letzten Assistant-Turn der Session,
+// falls der Client ihn mitschickt, sonst 0.
+func newTurnStart(history []sigoengine.Message, msgs []ChatMessage) int {
+	var last *sigoengine.Message
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == "assistant" {
+			last = &history[i]
+			break
+		}
+	}
+	if last == nil {
+		return 0
+	}
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == "assistant" && sameAssistantTurn(msgs[i].toEngine(), *last) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// sameAssistantTurn vergleicht Assistant-Messages nach Text und
+// Tool-Call-IDs (Clients serialisieren tool_calls oft neu).
+func sameAssistantTurn(a, b sigoengine.Message) bool {
+	return sigoengine.ExtractTextFromContent(a.Content) == sigoengine.ExtractTextFromContent(b.Content) &&
+		toolCallIDs(a.ToolCalls) == toolCallIDs(b.ToolCalls)
+}
+
+// toolCallIDs liefert die IDs der Tool-Calls einer Message (kommagetrennt).
+func toolCallIDs(raw json.RawMessage) string {
+	var calls []struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(raw, &calls)
+	ids := make([]string, len(calls))
+	for i, c := range calls {
+		ids[i] = c.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+// assistantContent liefert den Content einer Assistant-Antwort als JSON.
+// Reine Tool-Call-Antworten ohne Text bekommen content:null (OpenAI-Format).
+func assistantContent(text string, toolCalls json.RawMessage) json.RawMessage {
+	if text == "" && len(toolCalls) > 0 {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(`"` + jsonEscapeString(text) + `"`)
+}
+
 // **********************************************************************
 // main
 func main() {
@@ -1630,9 +2568,59 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Provider aus providers.json registrieren (vor Modell-Laden und Kanal-Discovery)
+	if n, err := sigoengine.LoadProviderConfig(filepath.Join(*dataDir, "providers.json")); err != nil {
+		sigoengine.LogError("providers.json Fehler", err, nil)
+		os.Exit(1)
+	} else if n > 0 {
+		sigoengine.LogInfo("Konfigurierte Provider geladen", map[string]interface{}{"count": n})
+	}
+
+	// Virtuelle Modelle (Fallback-Ketten über Provider hinweg)
+	virtualModels, err := loadVirtualModels(filepath.Join(*dataDir, "virtual_models.json"))
+	if err != nil {
+		sigoengine.LogError("virtual_models.json Fehler", err, nil)
+		os.Exit(1)
+	}
+
+	// Per-Modell-Defaults (hedge_after_ms, ...)
+	modelSettings, err := loadModelSettings(filepath.Join(*dataDir, "model_settings.json"))
+	if err != nil {
+		sigoengine.LogError("model_settings.json Fehler", err, nil)
+		os.Exit(1)
+	}
+
+	// Tokenizer-Vokabulare (exakte Token-Zählung statt Näherung)
+	if loaded, err := sigoengine.LoadTokenizerVocab(filepath.Join(*dataDir, "tokenizers")); err != nil {
+		sigoengine.LogWarn("Tokenizer-Vokabular nicht geladen", map[string]interface{}{"error": err.Error()})
+	} else if len(loaded) > 0 {
+		sigoengine.LogInfo("Tokenizer-Vokabulare geladen", map[string]interface{}{"tokenizers": loaded})
+	}
+	var approx []string
+	for _, name := range []string{sigoengine.TokenizerCL100K, sigoengine.TokenizerO200K} {
+		if tok, ok := sigoengine.GetTokenizer(name); ok && !tok.Exact() {
+			approx = append(approx, name)
+		}
+	}
+	if len(approx) > 0 {
+		sigoengine.LogWarn("Tokenizer ohne Vokabular: Token-Zahlen sind nur Schätzungen (<encoding>.tiktoken nach <data-dir>/tokenizers/ legen)", map[string]interface{}{
+			"tokenizers": strings.Join(approx, ","),
+		})
+	}
+
+	// Client-Konfiguration (Priorität für die Request-Queue)
+	clients, err := loadClients(filepath.Join(*dataDir, "clients.json"))
+	if err != nil {
+		sigoengine.LogError("clients.json Fehler", err, nil)
+		os.Exit(1)
+	}
+
 	// Server-State initialisieren
 	srv := &Server{
 		models:         loadModelsFromProviders(),
+		virtualModels:  virtualModels,
+		modelSettings:  modelSettings,
+		clients:        clients,
 		memory:         loadMemory(*dataDir),
 		breakers:       make(map[string]*sigoengine.EnhancedCircuitBreaker),
 		systemPrompt:   loadSystemPrompt(*dataDir),
@@ -1651,6 +2639,9 @@ func main() {
 	// Kanal-Registry initialisieren
 	registry := sigoengine.NewChannelRegistry(filepath.Join(srv.baseDir, "channels.json"))
 	registry.DiscoverFromEnv()
+	if *ollamaHosts != "" {
+		registry.AddOllamaHosts(strings.Split(*ollamaHosts, ","))
+	}
 	if err := registry.LoadState(); err != nil {
 		sigoengine.LogWarn("Kanal-Status konnte nicht geladen werden", map[string]interface{}{"error": err.Error()})
 	}
@@ -1662,34 +2653,29 @@ func main() {
 		"min_interval_ms": srv.rateMinInterval.Milliseconds(),
 		"max_wait_ms":     srv.rateMaxWait.Milliseconds(),
 	})
+	srv.requestQueue = sigoengine.NewRequestQueue(*queueSize, *queueMaxWait)
 
 	// Health-Monitor starten. Health-Status aktiver Kanäle wird lazy aus
 	// echten User-Requests gesetzt (handleChatCompletions → MarkChannelHealth).
 	// Der Ticker aktiviert nur noch Reserven per kostenlosem /models-Probe.
 	sigoengine.StartHealthMonitor(context.Background(), srv.channelManager, *channelHealthInterval)
 
-	// Ollama Auto-Discovery
-	ollamaEndpoint := "http://localhost:11434"
-	if n := sigoengine.DiscoverOllamaModels(ollamaEndpoint); n > 0 {
-		srv.mu.Lock()
-		ollamaModels := sigoengine.GetOllamaModels()
-		for sc, info := range ollamaModels {
-			srv.models[sc] = ModelInfo{
-				ID:              sc,
-				Shortcode:       sc,
-				Endpoint:        "http://localhost:11434/v1/chat/completions",
-				APIKey:          "",
-				MaxInputTokens:  info.ContextLength,
-				MaxOutputTokens: 0,
-				MinTemperature:  0.0,
-				MaxTemperature:  2.0,
-			}
-		}
-		srv.mu.Unlock()
+	// Ollama: je Host ein Kanal, Modelle periodisch neu laden ("ollama pull"
+	// ohne Neustart sichtbar)
+	if *ollamaHosts != "" {
+		srv.syncOllamaModels()
+		runEvery(*ollamaRefresh, srv.syncOllamaModels)
 	}
 
+	// Provider-Modelle periodisch neu laden (neue Modelle, Provider, die
+	// beim Start nicht erreichbar waren)
+	runEvery(*modelsRefresh, func() { srv.refreshModels() })
+
+	srv.checkVirtualModels()
+
 	sigoengine.LogInfo("Konfiguration geladen", map[string]interface{}{
 		"available_models": len(srv.models),
+		"virtual_models":   len(srv.virtualModels),
 		"memory_cache":     srv.memory.Cache,
 	})
 
@@ -1698,8 +2684,11 @@ func main() {
 	mux.HandleFunc("/ping", srv.handlePing)
 	mux.HandleFunc("/api/version", srv.handleVersion)
 	mux.HandleFunc("/v1/chat/completions", srv.handleChatCompletions)
+	mux.HandleFunc("/v1/messages", srv.handleMessages)
+	mux.HandleFunc("/v1/embeddings", srv.handleEmbeddings)
 	mux.HandleFunc("/v1/models", srv.handleModels)
 	mux.HandleFunc("/api/models", srv.handleAPIModels)
+	mux.HandleFunc("/api/models/refresh", srv.handleModelsRefresh)
 	mux.HandleFunc("/api/shortcodes", srv.handleShortcodes)
 	mux.HandleFunc("/api/channels/", srv.handleChannelRouter)
 	mux.HandleFunc("/api/channels", srv.handleChannels)
@@ -1707,6 +2696,7 @@ func main() {
 	mux.HandleFunc("/api/memory", srv.handleMemory)
 	mux.HandleFunc("/api/system-prompt", srv.handleSystemPrompt)
 	mux.HandleFunc("/api/usage", srv.handleUsage)
+	mux.HandleFunc("/api/tokenize", srv.handleTokenize)
 	mux.HandleFunc("/api/help", srv.handleHelp)
 
 	// HTTP-Server (nur localhost)
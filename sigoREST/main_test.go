@@ -2,13 +2,17 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"sigorest/sigoengine"
 )
@@ -42,6 +46,8 @@ func newTestServer(t *testing.T) (*Server, string) {
 		usage:          make(map[string]*ModelUsageStats),
 		usageByChannel: make(map[string]*ModelUsageStats),
 		channelManager: sigoengine.NewChannelManager(registry),
+		rateLimiter:    sigoengine.NewRateLimiter(),
+		requestQueue:   sigoengine.NewRequestQueue(0, 0),
 		baseDir:        dir,
 	}, dir
 }
@@ -202,3 +208,694 @@ func TestHandleUsage(t *testing.T) {
 		t.Fatal("missing by_channel")
 	}
 }
+
+// addMockModel registriert ein Modell, dessen Endpoint auf einen lokalen
+// httptest-Upstream zeigt (Provider-Heuristik → mammouth).
+func addMockModel(srv *Server, upstream *httptest.Server) {
+	srv.models["mock-model"] = ModelInfo{
+		ID:             "mock-model",
+		Shortcode:      "mock",
+		Endpoint:       upstream.URL + "/v1/chat/completions",
+		MinTemperature: 0.0,
+		MaxTemperature: 2.0,
+	}
+}
+
+func TestChatCompletionsToolCalls(t *testing.T) {
+	var upstreamReq map[string]interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&upstreamReq)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","content":null,
+			"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Berlin\"}"}}]}}],
+			"usage":{"prompt_tokens":12,"completion_tokens":7,"total_tokens":19}}`))
+	}))
+	defer upstream.Close()
+
+	srv, dir := newTestServer(t)
+	addMockModel(srv, upstream)
+
+	body := `{"model":"mock","session_id":"s1",
+		"messages":[{"role":"user","content":"Wetter in Berlin?"}],
+		"tools":[{"type":"function","function":{"name":"get_weather","parameters":{"type":"object"}}}],
+		"tool_choice":"auto","parallel_tool_calls":false}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	srv.handleChatCompletions(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, ok := upstreamReq["tools"]; !ok {
+		t.Fatalf("tools not forwarded: %v", upstreamReq)
+	}
+	if upstreamReq["tool_choice"] != "auto" || upstreamReq["parallel_tool_calls"] != false {
+		t.Fatalf("tool_choice/parallel_tool_calls not forwarded: %v", upstreamReq)
+	}
+
+	var resp ChatResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	msg := resp.Choices[0].Message
+	if string(msg.Content) != "null" || !strings.Contains(string(msg.ToolCalls), "call_1") {
+		t.Fatalf("expected tool_calls with null content, got %+v", msg)
+	}
+	if resp.Choices[0].FinishReason != "tool_calls" {
+		t.Fatalf("expected finish_reason tool_calls, got %q", resp.Choices[0].FinishReason)
+	}
+
+	// Zweiter Turn: Tool-Ergebnis mit tool_call_id
+	body = `{"model":"mock","session_id":"s1",
+		"messages":[{"role":"tool","tool_call_id":"call_1","content":"{\"temp\":21}"}]}`
+	req = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	rr = httptest.NewRecorder()
+	srv.handleChatCompletions(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	sent, _ := upstreamReq["messages"].([]interface{})
+	if len(sent) != 3 {
+		t.Fatalf("expected user + assistant(tool_calls) + tool in upstream request, got %v", sent)
+	}
+	if m, _ := sent[2].(map[string]interface{}); m["role"] != "tool" || m["tool_call_id"] != "call_1" {
+		t.Fatalf("tool message not forwarded correctly: %v", sent[2])
+	}
+
+	session := sigoengine.LoadSessionForChannel(dir, "mammouth", "default", "s1", "mock")
+	if len(session.History) != 4 {
+		t.Fatalf("expected 4 persisted messages, got %d", len(session.History))
+	}
+	if session.History[1].Role != "assistant" || len(session.History[1].ToolCalls) == 0 {
+		t.Fatalf("assistant tool_calls not persisted: %+v", session.History[1])
+	}
+	if session.History[2].Role != "tool" || session.History[2].ToolCallID != "call_1" {
+		t.Fatalf("tool result not persisted: %+v", session.History[2])
+	}
+}
+
+// TestChatCompletionsSessionFullHistory: Clients, die mit session_id den
+// ganzen Verlauf erneut schicken, erzeugen keine Duplikate in der Session.
+func TestChatCompletionsSessionFullHistory(t *testing.T) {
+	var calls int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok-%d"}}]}`, calls)
+	}))
+	defer upstream.Close()
+
+	srv, dir := newTestServer(t)
+	addMockModel(srv, upstream)
+
+	for _, messages := range []string{
+		`[{"role":"user","content":"A"}]`,
+		`[{"role":"user","content":"A"},{"role":"assistant","content":"ok-1"},{"role":"user","content":"B"}]`,
+		`[{"role":"system","content":"Kurz."},{"role":"user","content":"A"},{"role":"assistant","content":"ok-1"},
+		  {"role":"user","content":"B"},{"role":"assistant","content":"ok-2"},{"role":"user","content":"C1"},{"role":"user","content":"C2"}]`,
+	} {
+		body := `{"model":"mock","session_id":"full","messages":` + messages + `}`
+		rr := httptest.NewRecorder()
+		srv.handleChatCompletions(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+	}
+
+	session := sigoengine.LoadSessionForChannel(dir, "mammouth", "default", "full", "mock")
+	var got []string
+	for _, m := range session.History {
+		got = append(got, m.Role+":"+sigoengine.ExtractTextFromContent(m.Content))
+	}
+	want := "user:A,assistant:ok-1,user:B,assistant:ok-2,user:C1,user:C2,assistant:ok-3"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("session history = %v, want %s", got, want)
+	}
+}
+
+func TestChatCompletionsPassthroughParams(t *testing.T) {
+	var upstreamReq map[string]interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&upstreamReq)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer upstream.Close()
+
+	srv, _ := newTestServer(t)
+	addMockModel(srv, upstream)
+
+	body := `{"model":"mock","messages":[{"role":"user","content":"hi"}],
+		"top_p":0.5,"stop":["\n"],"seed":7,"user":"u-1","response_format":{"type":"json_object"},
+		"session_id":"x","channel":"mammouth-default","timeout":30,"stream_options":{"include_usage":true}}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	srv.handleChatCompletions(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	for _, k := range []string{"top_p", "stop", "seed", "user", "response_format"} {
+		if _, ok := upstreamReq[k]; !ok {
+			t.Errorf("%s not forwarded: %v", k, upstreamReq)
+		}
+	}
+	for _, k := range []string{"session_id", "channel", "timeout", "stream_options"} {
+		if _, ok := upstreamReq[k]; ok {
+			t.Errorf("sigoREST field %s must not reach the provider", k)
+		}
+	}
+}
+
+func TestChatCompletionsStreamUsage(t *testing.T) {
+	var upstreamReq map[string]interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&upstreamReq)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hallo\"}}]}\n\n" +
+			"data: {\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n" +
+			"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":42,\"completion_tokens\":3,\"total_tokens\":45}}\n\n" +
+			"data: [DONE]\n\n"))
+	}))
+	defer upstream.Close()
+
+	for _, clientWantsUsage := range []bool{false, true} {
+		srv, _ := newTestServer(t)
+		addMockModel(srv, upstream)
+
+		body := `{"model":"mock","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+		if clientWantsUsage {
+			body = `{"model":"mock","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"hi"}]}`
+		}
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		srv.handleChatCompletions(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		opts, _ := upstreamReq["stream_options"].(map[string]interface{})
+		if opts["include_usage"] != true {
+			t.Fatalf("include_usage not requested from provider: %v", upstreamReq["stream_options"])
+		}
+		if got := strings.Contains(rr.Body.String(), `"prompt_tokens":42`); got != clientWantsUsage {
+			t.Fatalf("usage chunk forwarded=%v, client asked=%v:\n%s", got, clientWantsUsage, rr.Body.String())
+		}
+		if u := srv.usage["mock-model"]; u == nil || u.InputTokens != 42 || u.OutputTokens != 3 || u.TotalTokens != 45 {
+			t.Fatalf("streamed usage not recorded from provider: %+v", u)
+		}
+	}
+}
+
+// Provider mit usage im letzten Chunk mit choices (statt eigenem
+// Usage-Chunk): ohne include_usage entfernt, Chunk selbst bleibt.
+func TestChatCompletionsStreamUsageInFinishChunk(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hallo\"}}]}\n\n" +
+			"data: {\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}],\"usage\":{\"prompt_tokens\":42,\"completion_tokens\":3,\"total_tokens\":45}}\n\n" +
+			"data: [DONE]\n\n"))
+	}))
+	defer upstream.Close()
+
+	for _, clientWantsUsage := range []bool{false, true} {
+		srv, _ := newTestServer(t)
+		addMockModel(srv, upstream)
+
+		body := `{"model":"mock","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+		if clientWantsUsage {
+			body = `{"model":"mock","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"hi"}]}`
+		}
+		rr := httptest.NewRecorder()
+		srv.handleChatCompletions(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		out := rr.Body.String()
+		if got := strings.Contains(out, `"usage"`); got != clientWantsUsage {
+			t.Fatalf("usage forwarded=%v, client asked=%v:\n%s", got, clientWantsUsage, out)
+		}
+		if !strings.Contains(out, `"finish_reason":"stop"`) || !strings.HasSuffix(out, "data: [DONE]\n\n") {
+			t.Fatalf("finish chunk or [DONE] missing:\n%s", out)
+		}
+		if u := srv.usage["mock-model"]; u == nil || u.InputTokens != 42 || u.TotalTokens != 45 {
+			t.Fatalf("streamed usage not recorded from provider: %+v", u)
+		}
+	}
+}
+
+func TestChatCompletionsStreamFailoverBeforeFirstChunk(t *testing.T) {
+	var calls []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		key := r.Header.Get("Authorization")
+		calls = append(calls, key)
+		if key == "Bearer default-key" {
+			// Fehler im Stream nach dem Rollen-Chunk, noch vor dem ersten Inhalt
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\"}}]}\n\n" +
+				"data: {\"error\":{\"message\":\"overloaded\",\"type\":\"server_error\"}}\n\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"ok\"}}]}\n\n" +
+			"data: [DONE]\n\n"))
+	}))
+	defer upstream.Close()
+
+	srv, _ := newTestServer(t)
+	srv.channelManager.Registry().SetActive("mammouth", "0", true)
+	addMockModel(srv, upstream)
+
+	body := `{"model":"mock","stream":true,"retries":1,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	srv.handleChatCompletions(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	// 2 Versuche auf default (1 Retry), dann Failover auf Kanal 0
+	if len(calls) != 3 || calls[2] != "Bearer key-0" {
+		t.Fatalf("expected retry then failover, got %v", calls)
+	}
+	out := rr.Body.String()
+	if strings.Contains(out, "overloaded") || strings.Count(out, `"role":"assistant"`) != 1 {
+		t.Fatalf("failed attempt leaked into client stream:\n%s", out)
+	}
+	if !strings.Contains(out, `"content":"ok"`) || !strings.HasSuffix(out, "data: [DONE]\n\n") {
+		t.Fatalf("unexpected stream:\n%s", out)
+	}
+
+	// Kanal-Statistik: Stream-Abbrüche zählen als Fehler des Kanals
+	registry := srv.channelManager.Registry()
+	def, _ := registry.GetChannel("mammouth", "default")
+	ch0, _ := registry.GetChannel("mammouth", "0")
+	if st := registry.Stats(def); st.Requests != 2 || st.Errors != 2 {
+		t.Errorf("default stats = %+v, want 2 errors", st)
+	}
+	if st := registry.ModelStats("mock-model", ch0); st.Requests != 1 || st.Errors != 0 {
+		t.Errorf("mock-model#mammouth-0 stats = %+v", st)
+	}
+}
+
+func TestChatCompletionsStreamErrorAfterFirstChunk(t *testing.T) {
+	calls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		calls++
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hal\"}}]}\n\n" +
+			"data: {\"error\":{\"message\":\"upstream reset\",\"type\":\"server_error\"}}\n\n"))
+	}))
+	defer upstream.Close()
+
+	srv, _ := newTestServer(t)
+	addMockModel(srv, upstream)
+
+	body := `{"model":"mock","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	srv.handleChatCompletions(rr, req)
+
+	if calls != 1 {
+		t.Fatalf("expected no retry after first chunk, got %d calls", calls)
+	}
+	out := rr.Body.String()
+	if rr.Code != http.StatusOK || !strings.Contains(out, `"content":"Hal"`) {
+		t.Fatalf("expected partial stream, got %d:\n%s", rr.Code, out)
+	}
+	if !strings.Contains(out, `"error":{"message":"upstream reset"`) || strings.Contains(out, "[DONE]") {
+		t.Fatalf("expected in-stream error event without [DONE]:\n%s", out)
+	}
+	if u := srv.usage["mock-model"]; u == nil || u.Requests != 1 {
+		t.Fatalf("usage of interrupted stream not recorded: %+v", u)
+	}
+}
+
+func TestChatCompletionsOllamaHostFailover(t *testing.T) {
+	var chatHosts []string
+	tags := `{"models":[{"name":"llama3:latest"}]}`
+	newHost := func(name string, status int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			switch {
+			case r.URL.Path == "/api/tags":
+				w.Write([]byte(tags))
+			case r.URL.Path == "/api/show":
+				w.Write([]byte(`{"capabilities":["completion"]}`))
+			case r.URL.Path == "/v1/chat/completions" && r.Method == http.MethodPost:
+				chatHosts = append(chatHosts, name)
+				w.WriteHeader(status)
+				w.Write([]byte(`{"choices":[{"finish_reason":"stop","message":{"role":"assistant","content":"von ` + name + `"}}]}`))
+			}
+		}))
+	}
+	hostA := newHost("a", http.StatusInternalServerError)
+	defer hostA.Close()
+	hostB := newHost("b", http.StatusOK)
+	defer hostB.Close()
+
+	srv, _ := newTestServer(t)
+	srv.channelManager.Registry().AddOllamaHosts([]string{hostA.URL, hostB.URL})
+	srv.syncOllamaModels()
+	defer func() {
+		// Globale Ollama-Registry und Provider-Hosts zurücksetzen
+		empty := sigoengine.NewChannelRegistry("")
+		empty.AddOllamaHosts([]string{sigoengine.DefaultOllamaHost})
+		sigoengine.DiscoverOllamaModels(sigoengine.NewChannelRegistry(""))
+	}()
+
+	if got := srv.providerForModel("ollama-llama3"); got != "ollama" {
+		t.Fatalf("providerForModel = %q, want ollama", got)
+	}
+
+	body := `{"model":"ollama-llama3","retries":1,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	srv.handleChatCompletions(rr, req)
+
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "von b") {
+		t.Fatalf("expected failover to host b, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(chatHosts) != 3 || chatHosts[2] != "b" {
+		t.Fatalf("expected retry on a then failover to b, got %v", chatHosts)
+	}
+	if ch, _ := srv.channelManager.Registry().GetChannel("ollama", "default"); ch.Healthy {
+		t.Error("host a should be marked unhealthy")
+	}
+
+	// Modell verschwindet nach erneuter Discovery, wenn kein Host es mehr hat
+	tags = `{"models":[]}`
+	srv.syncOllamaModels()
+	if _, ok := srv.models["ollama-llama3"]; ok {
+		t.Error("ollama-llama3 should be removed after rediscovery")
+	}
+}
+
+func TestChatCompletionsMaxConcurrentFailover(t *testing.T) {
+	var mu sync.Mutex
+	keys := map[string]int{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		mu.Lock()
+		keys[r.Header.Get("Authorization")]++
+		mu.Unlock()
+		time.Sleep(200 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer upstream.Close()
+
+	srv, _ := newTestServer(t)
+	srv.rateMaxWait = 20 * time.Millisecond
+	registry := srv.channelManager.Registry()
+	registry.SetActive("mammouth", "0", true)
+	def, _ := registry.GetChannel("mammouth", "default")
+	def.MaxConcurrent = 1
+	addMockModel(srv, upstream)
+
+	// Zwei parallele Requests: der zweite findet default voll und weicht aus
+	var wg sync.WaitGroup
+	codes := make([]int, 2)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := `{"model":"mock","messages":[{"role":"user","content":"hi"}]}`
+			rr := httptest.NewRecorder()
+			srv.handleChatCompletions(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+			codes[i] = rr.Code
+		}(i)
+		time.Sleep(20 * time.Millisecond)
+	}
+	wg.Wait()
+
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK {
+		t.Fatalf("expected both requests to succeed, got %v", codes)
+	}
+	if keys["Bearer default-key"] != 1 || keys["Bearer key-0"] != 1 {
+		t.Fatalf("expected one call per channel, got %v", keys)
+	}
+	if n := srv.rateLimiter.InFlight("mammouth-default"); n != 0 {
+		t.Errorf("slot not released: in-flight %d", n)
+	}
+
+	// Nur ein Kanal und der ist voll → HTTP 429
+	registry.SetActive("mammouth", "0", false)
+	release, err := srv.acquireChannel(context.Background(), def, 0)
+	if err != nil {
+		t.Fatalf("acquireChannel: %v", err)
+	}
+	defer release(nil)
+	body := `{"model":"mock","retries":1,"messages":[{"role":"user","content":"hi"}]}`
+	rr := httptest.NewRecorder()
+	srv.handleChatCompletions(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 on full channel, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestChatCompletionsRateLimitHeadersBenchChannel(t *testing.T) {
+	var mu sync.Mutex
+	keys := map[string]int{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		key := r.Header.Get("Authorization")
+		mu.Lock()
+		keys[key]++
+		mu.Unlock()
+		if key == "Bearer default-key" {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error":{"message":"rate limited"}}`))
+			return
+		}
+		// key-0 ist danach ebenfalls erschöpft (remaining 0, Reset in 20s)
+		w.Header().Set("x-ratelimit-remaining-requests", "0")
+		w.Header().Set("x-ratelimit-reset-requests", "20s")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer upstream.Close()
+
+	srv, _ := newTestServer(t)
+	srv.rateMaxWait = 20 * time.Millisecond
+	srv.channelManager.Registry().SetActive("mammouth", "0", true)
+	addMockModel(srv, upstream)
+
+	// 429 mit Retry-After: kein Retry auf default, sofort Failover
+	body := `{"model":"mock","messages":[{"role":"user","content":"hi"}]}`
+	start := time.Now()
+	rr := httptest.NewRecorder()
+	srv.handleChatCompletions(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected failover to key-0, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("retried the benched channel: %v", elapsed)
+	}
+	if until := srv.rateLimiter.BenchedUntil("mammouth-default"); time.Until(until) < 25*time.Second {
+		t.Fatalf("default not benched for Retry-After: %v", until)
+	}
+	if srv.rateLimiter.BenchedUntil("mammouth-0").IsZero() {
+		t.Fatal("key-0 not benched after remaining 0")
+	}
+
+	// Beide Kanäle pausiert: HTTP 429 ohne weiteren Upstream-Call
+	rr = httptest.NewRecorder()
+	srv.handleChatCompletions(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 with all channels benched, got %d: %s", rr.Code, rr.Body.String())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if keys["Bearer default-key"] != 1 || keys["Bearer key-0"] != 1 {
+		t.Fatalf("expected one call per channel, got %v", keys)
+	}
+}
+
+func TestChatCompletionsContextWindowPreflight(t *testing.T) {
+	var calls int
+	var upstreamReq map[string]interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		calls++
+		json.NewDecoder(r.Body).Decode(&upstreamReq)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer upstream.Close()
+
+	srv, _ := newTestServer(t)
+	addMockModel(srv, upstream)
+	info := srv.models["mock-model"]
+	info.MaxInputTokens = 1000
+	info.MaxOutputTokens = 800
+	srv.models["mock-model"] = info
+
+	// ~600 Prompt-Tokens: max_tokens wird auf den Rest des Fensters gekürzt
+	prompt := strings.Repeat(" word", 600)
+	body := `{"model":"mock","messages":[{"role":"user","content":"` + prompt + `"}]}`
+	rr := httptest.NewRecorder()
+	srv.handleChatCompletions(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	maxTokens, _ := upstreamReq["max_tokens"].(float64)
+	if maxTokens <= 0 || maxTokens >= 800 || int(maxTokens)+600 > 1000 {
+		t.Fatalf("max_tokens not fitted to context window: %v", upstreamReq["max_tokens"])
+	}
+
+	// Geschätzt etwas größer als das Fenster (Näherung ohne Vokabular):
+	// innerhalb der Toleranz entscheidet der Provider, max_tokens auf das
+	// Minimum der Näherung (256) gekürzt
+	prompt = strings.Repeat(" word", 1100)
+	body = `{"model":"mock","messages":[{"role":"user","content":"` + prompt + `"}]}`
+	rr = httptest.NewRecorder()
+	srv.handleChatCompletions(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	if rr.Code != http.StatusOK || calls != 2 {
+		t.Fatalf("expected provider call within tolerance, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if upstreamReq["max_tokens"] != float64(256) {
+		t.Fatalf("max_tokens within tolerance: expected 256, got %v", upstreamReq["max_tokens"])
+	}
+
+	// Prompt deutlich größer als das Fenster: 400 ohne Provider-Call
+	prompt = strings.Repeat(" word", 1400)
+	body = `{"model":"mock","messages":[{"role":"user","content":"` + prompt + `"}]}`
+	rr = httptest.NewRecorder()
+	srv.handleChatCompletions(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var errResp ErrorResponse
+	json.Unmarshal(rr.Body.Bytes(), &errResp)
+	if errResp.Error.Code != "context_length_exceeded" || calls != 2 {
+		t.Fatalf("expected context_length_exceeded without upstream call, got %s (calls %d)", rr.Body.String(), calls)
+	}
+	if errResp.Error.Details["max_input_tokens"] != float64(1000) || errResp.Error.Details["prompt_tokens"] == nil ||
+		strings.HasPrefix(errResp.Error.Message, "CONTEXT_LENGTH_EXCEEDED") {
+		t.Fatalf("expected message and details of the pre-flight error, got %s", rr.Body.String())
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	srv, _ := newTestServer(t)
+	srv.models["gpt-4o-mini"] = ModelInfo{ID: "gpt-4o-mini", Shortcode: "g4om", MaxInputTokens: 1000, InputCost: 2.0}
+
+	body := `{"model":"g4om","messages":[{"role":"system","content":"Kurz."},{"role":"user","content":"Hallo Welt"}]}`
+	rr := httptest.NewRecorder()
+	srv.handleTokenize(rr, httptest.NewRequest(http.MethodPost, "/api/tokenize", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp TokenizeResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	tok, _ := sigoengine.GetTokenizer(sigoengine.TokenizerO200K)
+	want := sigoengine.EstimatePromptTokens(tok, []map[string]interface{}{
+		{"role": "system", "content": "Kurz."},
+		{"role": "user", "content": "Hallo Welt"},
+	})
+	if resp.Model != "gpt-4o-mini" || resp.Tokenizer != sigoengine.TokenizerO200K || resp.Tokens != want ||
+		len(resp.MessageTokens) != 2 || !resp.Fits || resp.MaxInputTokens != 1000 {
+		t.Fatalf("unexpected response: %+v (want %d tokens)", resp, want)
+	}
+	if resp.InputCost != float64(want)*2.0/1e6 {
+		t.Fatalf("input_cost = %v", resp.InputCost)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.handleTokenize(rr, httptest.NewRequest(http.MethodPost, "/api/tokenize", strings.NewReader(`{"model":"nope","text":"x"}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("unknown model: expected 400, got %d", rr.Code)
+	}
+}
+
+func TestChatCompletionsUnsupportedFeature(t *testing.T) {
+	var calls int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer upstream.Close()
+
+	srv, _ := newTestServer(t)
+	addMockModel(srv, upstream)
+	info := srv.models["mock-model"]
+	info.Capabilities = []string{sigoengine.CapTools, sigoengine.CapStreaming}
+	srv.models["mock-model"] = info
+
+	rejected := []string{
+		`{"model":"mock","messages":[{"role":"user","content":[{"type":"text","text":"Was ist das?"},{"type":"image_url","image_url":{"url":"data:image/png;base64,AAAA"}}]}]}`,
+		`{"model":"mock","messages":[{"role":"user","content":"Hi"}],"response_format":{"type":"json_object"}}`,
+		`{"model":"mock","messages":[{"role":"user","content":"Hi"}],"reasoning_effort":"high"}`,
+	}
+	for _, body := range rejected {
+		rr := httptest.NewRecorder()
+		srv.handleChatCompletions(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+		var errResp ErrorResponse
+		json.Unmarshal(rr.Body.Bytes(), &errResp)
+		if rr.Code != http.StatusBadRequest || errResp.Error.Code != "unsupported_feature" {
+			t.Fatalf("%s: expected 400 unsupported_feature, got %d: %s", body, rr.Code, rr.Body.String())
+		}
+	}
+	if calls != 0 {
+		t.Fatalf("expected no upstream call, got %d", calls)
+	}
+
+	body := `{"model":"mock","messages":[{"role":"user","content":"Hi"}],"tools":[{"type":"function","function":{"name":"f","parameters":{}}}],"response_format":{"type":"text"}}`
+	rr := httptest.NewRecorder()
+	srv.handleChatCompletions(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	if rr.Code != http.StatusOK || calls != 1 {
+		t.Fatalf("supported features: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// /api/models und /v1/models zeigen die Capabilities
+	rr = httptest.NewRecorder()
+	srv.handleAPIModels(rr, httptest.NewRequest(http.MethodGet, "/api/models", nil))
+	var models []ModelInfo
+	json.Unmarshal(rr.Body.Bytes(), &models)
+	found := false
+	for _, m := range models {
+		found = found || (m.ID == "mock-model" && len(m.Capabilities) == 2)
+	}
+	if !found {
+		t.Fatalf("/api/models ohne Capabilities: %s", rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	srv.handleModels(rr, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	if !strings.Contains(rr.Body.String(), `"capabilities":["tools","streaming"]`) {
+		t.Fatalf("/v1/models ohne Capabilities: %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,617 @@
+//**********************************************************************
+//      sigoREST/messages.go
+//**********************************************************************
+//  Beschreibung: Anthropic-kompatibler Endpunkt POST /v1/messages.
+//  Übersetzt Messages-Requests ins interne OpenAI-Format und nutzt
+//  denselben Kern (serveChat) wie /v1/chat/completions: Modell-Lookup,
+//  Kanal-Failover, Rate-Limiter, Memory und Sessions. Antworten und
+//  Streams gehen im Anthropic-Format (message_start, content_block_*,
+//  message_delta, message_stop) zurück.
+//**********************************************************************
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"sigorest/sigoengine"
+)
+
+// **********************************************************************
+// Request-Typen (Anthropic Messages API)
+
+type AnthropicContentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	Source    json.RawMessage `json:"source,omitempty"`      // image
+	ID        string          `json:"id,omitempty"`          // tool_use
+	Name      string          `json:"name,omitempty"`        // tool_use
+	Input     json.RawMessage `json:"input,omitempty"`       // tool_use
+	ToolUseID string          `json:"tool_use_id,omitempty"` // tool_result
+	Content   json.RawMessage `json:"content,omitempty"`     // tool_result (String oder Blöcke)
+}
+
+type AnthropicMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"` // String oder []AnthropicContentBlock
+}
+
+type AnthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type AnthropicToolChoice struct {
+	Type                   string `json:"type"` // auto, any, tool, none
+	Name                   string `json:"name,omitempty"`
+	DisableParallelToolUse bool   `json:"disable_parallel_tool_use,omitempty"`
+}
+
+type AnthropicRequest struct {
+	Model         string               `json:"model"`
+	System        json.RawMessage      `json:"system,omitempty"` // String oder Text-Blöcke
+	Messages      []AnthropicMessage   `json:"messages"`
+	MaxTokens     int                  `json:"max_tokens"`
+	Temperature   float64              `json:"temperature"`
+	TopP          *float64             `json:"top_p,omitempty"`
+	TopK          *int                 `json:"top_k,omitempty"`
+	StopSequences []string             `json:"stop_sequences,omitempty"`
+	Stream        bool                 `json:"stream"`
+	Tools         []AnthropicTool      `json:"tools,omitempty"`
+	ToolChoice    *AnthropicToolChoice `json:"tool_choice,omitempty"`
+	Metadata      struct {
+		UserID string `json:"user_id,omitempty"`
+	} `json:"metadata"`
+	// sigoREST-Erweiterungen wie bei /v1/chat/completions
+	SessionID    string `json:"session_id"`
+	Timeout      int    `json:"timeout"`
+	Retries      int    `json:"retries"`
+	SystemPrompt string `json:"system_prompt"`
+	Channel      string `json:"channel"`
+	HedgeAfterMs int    `json:"hedge_after_ms"`
+}
+
+// **********************************************************************
+// POST /v1/messages
+
+func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request) {
+	out := anthropicFormat{}
+	if r.Method != http.MethodPost {
+		out.writeError(w, "Method not allowed", "invalid_request", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var areq AnthropicRequest
+	if err := json.NewDecoder(r.Body).Decode(&areq); err != nil {
+		out.writeError(w, "Invalid JSON: "+err.Error(), "invalid_request", http.StatusBadRequest)
+		return
+	}
+	req, err := areq.toChatRequest()
+	if err != nil {
+		out.writeError(w, err.Error(), "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	s.serveChat(w, r, req, out)
+}
+
+// toChatRequest übersetzt einen Messages-Request ins interne OpenAI-Format:
+// system → role:"system", tool_use → tool_calls, tool_result → role:"tool",
+// Bild-Blöcke → image_url-Parts.
+func (a *AnthropicRequest) toChatRequest() (*ChatRequest, error) {
+	req := &ChatRequest{
+		Model:        a.Model,
+		Temp:         a.Temperature,
+		MaxTokens:    a.MaxTokens,
+		Stream:       a.Stream,
+		SessionID:    a.SessionID,
+		Timeout:      a.Timeout,
+		Retries:      a.Retries,
+		SystemPrompt: a.SystemPrompt,
+		Channel:      a.Channel,
+		HedgeAfterMs: a.HedgeAfterMs,
+		Raw:          make(map[string]json.RawMessage),
+	}
+
+	if system := anthropicText(a.System); system != "" {
+		req.Messages = append(req.Messages, ChatMessage{Role: "system", Content: jsonString(system)})
+	}
+
+	for _, m := range a.Messages {
+		msgs, err := anthropicToChatMessages(m)
+		if err != nil {
+			return nil, err
+		}
+		req.Messages = append(req.Messages, msgs...)
+	}
+
+	if len(a.Tools) > 0 {
+		tools := make([]map[string]interface{}, 0, len(a.Tools))
+		for _, t := range a.Tools {
+			fn := map[string]interface{}{"name": t.Name, "parameters": t.InputSchema}
+			if t.Description != "" {
+				fn["description"] = t.Description
+			}
+			tools = append(tools, map[string]interface{}{"type": "function", "function": fn})
+		}
+		req.Raw["tools"], _ = json.Marshal(tools)
+	}
+	if tc := a.ToolChoice; tc != nil {
+		var choice interface{}
+		switch tc.Type {
+		case "auto":
+			choice = "auto"
+		case "any":
+			choice = "required"
+		case "none":
+			choice = "none"
+		case "tool":
+			choice = map[string]interface{}{"type": "function", "function": map[string]string{"name": tc.Name}}
+		}
+		if choice != nil {
+			req.Raw["tool_choice"], _ = json.Marshal(choice)
+		}
+		if tc.DisableParallelToolUse {
+			req.Raw["parallel_tool_calls"] = json.RawMessage("false")
+		}
+	}
+
+	// Weitere Parameter über den Passthrough-Pfad (Provider-Policy greift)
+	if a.TopP != nil {
+		req.Raw["top_p"], _ = json.Marshal(*a.TopP)
+	}
+	if a.TopK != nil {
+		req.Raw["top_k"], _ = json.Marshal(*a.TopK)
+	}
+	if len(a.StopSequences) > 0 {
+		req.Raw["stop"], _ = json.Marshal(a.StopSequences)
+	}
+	if a.Metadata.UserID != "" {
+		req.Raw["user"] = jsonString(a.Metadata.UserID)
+	}
+	return req, nil
+}
+
+// anthropicToChatMessages übersetzt eine Anthropic-Nachricht in eine oder
+// mehrere OpenAI-Nachrichten. tool_result-Blöcke werden zu eigenen
+// role:"tool"-Nachrichten vor dem restlichen User-Inhalt.
+func anthropicToChatMessages(m AnthropicMessage) ([]ChatMessage, error) {
+	var text string
+	if err := json.Unmarshal(m.Content, &text); err == nil {
+		return []ChatMessage{{Role: m.Role, Content: jsonString(text)}}, nil
+	}
+	var blocks []AnthropicContentBlock
+	if err := json.Unmarshal(m.Content, &blocks); err != nil {
+		return nil, fmt.Errorf("messages: content muss String oder Block-Array sein (role %q)", m.Role)
+	}
+
+	var result []ChatMessage
+	var parts []map[string]interface{}
+	var toolCalls []map[string]interface{}
+	for _, b := range blocks {
+		switch b.Type {
+		case "text":
+			parts = append(parts, map[string]interface{}{"type": "text", "text": b.Text})
+		case "image":
+			if url := anthropicImageURL(b.Source); url != "" {
+				parts = append(parts, map[string]interface{}{
+					"type":      "image_url",
+					"image_url": map[string]string{"url": url},
+				})
+			}
+		case "tool_use":
+			args := string(b.Input)
+			if args == "" || args == "null" {
+				args = "{}"
+			}
+			toolCalls = append(toolCalls, map[string]interface{}{
+				"id":   b.ID,
+				"type": "function",
+				"function": map[string]string{
+					"name":      b.Name,
+					"arguments": args,
+				},
+			})
+		case "tool_result":
+			result = append(result, ChatMessage{
+				Role:       "tool",
+				ToolCallID: b.ToolUseID,
+				Content:    jsonString(anthropicText(b.Content)),
+			})
+		}
+		// thinking/redacted_thinking: kein OpenAI-Gegenstück
+	}
+
+	msg := ChatMessage{Role: m.Role}
+	switch {
+	case m.Role == "assistant":
+		// Assistant: nur Text (Bilder gibt es hier nicht)
+		var sb strings.Builder
+		for _, p := range parts {
+			if t, ok := p["text"].(string); ok {
+				sb.WriteString(t)
+			}
+		}
+		if len(toolCalls) > 0 {
+			msg.ToolCalls, _ = json.Marshal(toolCalls)
+		}
+		msg.Content = assistantContent(sb.String(), msg.ToolCalls)
+	case len(parts) == 0:
+		return result, nil
+	case len(parts) == 1 && parts[0]["type"] == "text":
+		msg.Content = jsonString(parts[0]["text"].(string))
+	default:
+		msg.Content, _ = json.Marshal(parts)
+	}
+	return append(result, msg), nil
+}
+
+// anthropicText liefert den Text aus einem String- oder Block-Array-Feld.
+func anthropicText(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var blocks []AnthropicContentBlock
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return ""
+	}
+	var texts []string
+	for _, b := range blocks {
+		if b.Type == "text" {
+			texts = append(texts, b.Text)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// anthropicImageURL wandelt eine Anthropic image.source in eine image_url um
+// (base64 → data:-URI).
+func anthropicImageURL(raw json.RawMessage) string {
+	var src struct {
+		Type      string `json:"type"`
+		MediaType string `json:"media_type"`
+		Data      string `json:"data"`
+		URL       string `json:"url"`
+	}
+	if err := json.Unmarshal(raw, &src); err != nil {
+		return ""
+	}
+	if src.Type == "base64" {
+		return "data:" + src.MediaType + ";base64," + src.Data
+	}
+	return src.URL
+}
+
+// jsonString kodiert einen String als JSON-Wert
+func jsonString(s string) json.RawMessage {
+	b, _ := json.Marshal(s)
+	return b
+}
+
+// **********************************************************************
+// Antwort-Format (Anthropic)
+
+// anthropicFormat: /v1/messages
+type anthropicFormat struct{}
+
+// anthropicErrorType bildet den HTTP-Status auf Anthropic error.type ab.
+func anthropicErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest, http.StatusMethodNotAllowed:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusServiceUnavailable:
+		return "overloaded_error"
+	case http.StatusGatewayTimeout:
+		return "timeout_error"
+	default:
+		return "api_error"
+	}
+}
+
+func (f anthropicFormat) writeError(w http.ResponseWriter, msg, errType string, status int) {
+	f.writeErrorDetails(w, msg, errType, status, nil)
+}
+
+func (anthropicFormat) writeErrorDetails(w http.ResponseWriter, msg, errType string, status int, details map[string]interface{}) {
+	errObj := map[string]interface{}{
+		"type":    anthropicErrorType(status),
+		"message": msg,
+	}
+	if details != nil {
+		errObj["details"] = details
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"type":  "error",
+		"error": errObj,
+	})
+}
+
+// anthropicStopReason bildet OpenAI finish_reason auf Anthropic stop_reason ab.
+func anthropicStopReason(finishReason string) string {
+	switch finishReason {
+	case "", "stop":
+		return "end_turn"
+	case "length":
+		return "max_tokens"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	case "content_filter":
+		return "refusal"
+	default:
+		return finishReason
+	}
+}
+
+func (anthropicFormat) writeResult(w http.ResponseWriter, model string, res *sigoengine.ChatResult, usage *ChatUsage) {
+	content := []map[string]interface{}{}
+	if res.Content != "" {
+		content = append(content, map[string]interface{}{"type": "text", "text": res.Content})
+	}
+	if res.HasToolCalls() {
+		var calls []struct {
+			ID       string `json:"id"`
+			Function struct {
+				Name      string `json:"name"`
+				Arguments string `json:"arguments"`
+			} `json:"function"`
+		}
+		json.Unmarshal(res.ToolCalls, &calls)
+		for _, c := range calls {
+			input := json.RawMessage(c.Function.Arguments)
+			if !json.Valid(input) {
+				input = json.RawMessage("{}")
+			}
+			content = append(content, map[string]interface{}{
+				"type":  "tool_use",
+				"id":    c.ID,
+				"name":  c.Function.Name,
+				"input": input,
+			})
+		}
+	}
+
+	resp := map[string]interface{}{
+		"id":            fmt.Sprintf("msg_%d", time.Now().UnixNano()),
+		"type":          "message",
+		"role":          "assistant",
+		"model":         model,
+		"content":       content,
+		"stop_reason":   anthropicStopReason(res.FinishReason),
+		"stop_sequence": nil,
+		"usage": map[string]int{
+			"input_tokens":  usage.PromptTokens,
+			"output_tokens": usage.CompletionTokens,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (anthropicFormat) newStream(model string) streamEncoder {
+	return &anthropicStream{
+		model:      model,
+		id:         fmt.Sprintf("msg_%d", time.Now().UnixNano()),
+		textBlock:  -1,
+		toolBlocks: make(map[int]int),
+	}
+}
+
+// **********************************************************************
+// Stream: OpenAI chat.completion.chunk → Anthropic-Events
+
+// anthropicStream übersetzt OpenAI-Chunks in die Anthropic-Eventfolge
+// message_start, content_block_start/delta/stop, message_delta, message_stop.
+type anthropicStream struct {
+	model        string
+	id           string
+	started      bool
+	finished     bool
+	blocks       int         // Anzahl gestarteter Content-Blocks
+	textBlock    int         // Index des offenen Text-Blocks (-1 = keiner)
+	toolBlocks   map[int]int // OpenAI tool_calls-Index → Block-Index
+	openTools    []int       // offene tool_use-Blocks in Startreihenfolge
+	stopReason   string
+	inputTokens  int
+	outputTokens int
+}
+
+// event formatiert ein SSE-Event im Anthropic-Stil (event: + data:).
+func anthropicEvent(eventType string, payload map[string]interface{}) string {
+	payload["type"] = eventType
+	data, _ := json.Marshal(payload)
+	return "event: " + eventType + "\ndata: " + string(data) + "\n\n"
+}
+
+func (e *anthropicStream) start() string {
+	if e.started {
+		return ""
+	}
+	e.started = true
+	return anthropicEvent("message_start", map[string]interface{}{
+		"message": map[string]interface{}{
+			"id":            e.id,
+			"type":          "message",
+			"role":          "assistant",
+			"model":         e.model,
+			"content":       []interface{}{},
+			"stop_reason":   nil,
+			"stop_sequence": nil,
+			"usage":         map[string]int{"input_tokens": 0, "output_tokens": 0},
+		},
+	})
+}
+
+// openBlock schließt einen offenen Text-Block und startet einen neuen Block.
+// tool_use-Blocks bleiben bis finish offen: Provider mit parallelen
+// Tool-Calls verschachteln die Argument-Deltas mehrerer Indizes, und jedes
+// input_json_delta muss an einen noch offenen Block gehen.
+func (e *anthropicStream) openBlock(blockType string, block map[string]interface{}) (string, int) {
+	out := e.closeText()
+	idx := e.blocks
+	e.blocks++
+	if blockType == "text" {
+		e.textBlock = idx
+	} else {
+		e.openTools = append(e.openTools, idx)
+	}
+	block["type"] = blockType
+	return out + anthropicEvent("content_block_start", map[string]interface{}{
+		"index":         idx,
+		"content_block": block,
+	}), idx
+}
+
+func (e *anthropicStream) closeText() string {
+	if e.textBlock < 0 {
+		return ""
+	}
+	idx := e.textBlock
+	e.textBlock = -1
+	return anthropicEvent("content_block_stop", map[string]interface{}{"index": idx})
+}
+
+// closeBlocks schließt alle offenen Blocks in aufsteigender Reihenfolge.
+func (e *anthropicStream) closeBlocks() string {
+	open := e.openTools
+	if e.textBlock >= 0 {
+		open = append(open, e.textBlock)
+	}
+	sort.Ints(open)
+	var sb strings.Builder
+	for _, idx := range open {
+		sb.WriteString(anthropicEvent("content_block_stop", map[string]interface{}{"index": idx}))
+	}
+	e.openTools, e.textBlock = nil, -1
+	return sb.String()
+}
+
+func (e *anthropicStream) encode(data string) string {
+	if e.finished {
+		return ""
+	}
+	if data == "[DONE]" {
+		return e.finish()
+	}
+	var chunk struct {
+		Choices []struct {
+			Delta struct {
+				Content   string `json:"content"`
+				ToolCalls []struct {
+					Index    int    `json:"index"`
+					ID       string `json:"id"`
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"delta"`
+			FinishReason *string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage *ChatUsage `json:"usage"`
+	}
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(e.start())
+	if chunk.Usage != nil {
+		e.inputTokens = chunk.Usage.PromptTokens
+		e.outputTokens = chunk.Usage.CompletionTokens
+	}
+	if len(chunk.Choices) == 0 {
+		return sb.String()
+	}
+	choice := chunk.Choices[0]
+
+	if choice.Delta.Content != "" {
+		if e.textBlock < 0 {
+			out, _ := e.openBlock("text", map[string]interface{}{"text": ""})
+			sb.WriteString(out)
+		}
+		sb.WriteString(anthropicEvent("content_block_delta", map[string]interface{}{
+			"index": e.textBlock,
+			"delta": map[string]string{"type": "text_delta", "text": choice.Delta.Content},
+		}))
+	}
+	for _, tc := range choice.Delta.ToolCalls {
+		idx, known := e.toolBlocks[tc.Index]
+		if !known {
+			var out string
+			out, idx = e.openBlock("tool_use", map[string]interface{}{
+				"id":    tc.ID,
+				"name":  tc.Function.Name,
+				"input": map[string]interface{}{},
+			})
+			sb.WriteString(out)
+			e.toolBlocks[tc.Index] = idx
+		}
+		if tc.Function.Arguments != "" {
+			sb.WriteString(anthropicEvent("content_block_delta", map[string]interface{}{
+				"index": idx,
+				"delta": map[string]string{"type": "input_json_delta", "partial_json": tc.Function.Arguments},
+			}))
+		}
+	}
+	if choice.FinishReason != nil && *choice.FinishReason != "" {
+		e.stopReason = anthropicStopReason(*choice.FinishReason)
+	}
+	return sb.String()
+}
+
+func (e *anthropicStream) finish() string {
+	if e.finished {
+		return ""
+	}
+	e.finished = true
+	stopReason := e.stopReason
+	if stopReason == "" {
+		stopReason = "end_turn"
+	}
+	return e.start() + e.closeBlocks() +
+		anthropicEvent("message_delta", map[string]interface{}{
+			"delta": map[string]interface{}{"stop_reason": stopReason, "stop_sequence": nil},
+			"usage": map[string]int{"input_tokens": e.inputTokens, "output_tokens": e.outputTokens},
+		}) +
+		anthropicEvent("message_stop", map[string]interface{}{})
+}
+
+// fail: Anthropic sendet Fehler mitten im Stream als event: error.
+func (e *anthropicStream) fail(msg, errType string) string {
+	e.finished = true
+	status := http.StatusInternalServerError
+	switch errType {
+	case sigoengine.ErrRateLimit:
+		status = http.StatusTooManyRequests
+	case sigoengine.ErrServerError:
+		status = http.StatusServiceUnavailable
+	case sigoengine.ErrTimeout:
+		status = http.StatusGatewayTimeout
+	}
+	return anthropicEvent("error", map[string]interface{}{
+		"error": map[string]string{"type": anthropicErrorType(status), "message": msg},
+	})
+}
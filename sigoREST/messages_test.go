@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMessagesToolUse(t *testing.T) {
+	var upstreamReq map[string]interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&upstreamReq)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","content":"Moment.",
+			"tool_calls":[{"id":"call_2","type":"function","function":{"name":"get_time","arguments":"{\"tz\":\"CET\"}"}}]}}],
+			"usage":{"prompt_tokens":30,"completion_tokens":9,"total_tokens":39}}`))
+	}))
+	defer upstream.Close()
+
+	srv, _ := newTestServer(t)
+	addMockModel(srv, upstream)
+
+	body := `{"model":"mock","max_tokens":256,"system":[{"type":"text","text":"Sei knapp."}],
+		"messages":[
+			{"role":"user","content":"Wetter?"},
+			{"role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Berlin"}}]},
+			{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"21 Grad"},{"type":"text","text":"Und die Uhrzeit?"}]}
+		],
+		"tools":[{"name":"get_time","input_schema":{"type":"object"}}],
+		"tool_choice":{"type":"any"}}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	srv.handleMessages(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	sent, _ := upstreamReq["messages"].([]interface{})
+	roles := make([]string, 0, len(sent))
+	for _, m := range sent {
+		roles = append(roles, m.(map[string]interface{})["role"].(string))
+	}
+	if strings.Join(roles, ",") != "system,user,assistant,tool,user" {
+		t.Fatalf("unexpected upstream roles: %v", roles)
+	}
+	if upstreamReq["tool_choice"] != "required" {
+		t.Fatalf("tool_choice not translated: %v", upstreamReq["tool_choice"])
+	}
+	tools, _ := upstreamReq["tools"].([]interface{})
+	if len(tools) != 1 || tools[0].(map[string]interface{})["type"] != "function" {
+		t.Fatalf("tools not translated: %v", upstreamReq["tools"])
+	}
+
+	var resp struct {
+		Type       string `json:"type"`
+		StopReason string `json:"stop_reason"`
+		Content    []struct {
+			Type  string                 `json:"type"`
+			Text  string                 `json:"text"`
+			ID    string                 `json:"id"`
+			Input map[string]interface{} `json:"input"`
+		} `json:"content"`
+		Usage struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if resp.Type != "message" || resp.StopReason != "tool_use" {
+		t.Fatalf("unexpected message: %s", rr.Body.String())
+	}
+	if len(resp.Content) != 2 || resp.Content[0].Text != "Moment." || resp.Content[1].ID != "call_2" || resp.Content[1].Input["tz"] != "CET" {
+		t.Fatalf("unexpected content blocks: %+v", resp.Content)
+	}
+	if resp.Usage.InputTokens != 30 || resp.Usage.OutputTokens != 9 {
+		t.Fatalf("unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestMessagesStreaming(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Hal\"}}]}\n\n" +
+			"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"lo\"},\"finish_reason\":\"stop\"}]}\n\n" +
+			"data: [DONE]\n\n"))
+	}))
+	defer upstream.Close()
+
+	srv, _ := newTestServer(t)
+	addMockModel(srv, upstream)
+
+	body := `{"model":"mock","max_tokens":64,"stream":true,"messages":[{"role":"user","content":"Hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	srv.handleMessages(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var events []string
+	var text strings.Builder
+	for _, line := range strings.Split(rr.Body.String(), "\n") {
+		if strings.HasPrefix(line, "event: ") {
+			events = append(events, strings.TrimPrefix(line, "event: "))
+		}
+		if strings.HasPrefix(line, "data: ") {
+			var ev struct {
+				Delta struct {
+					Text       string `json:"text"`
+					StopReason string `json:"stop_reason"`
+				} `json:"delta"`
+			}
+			json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev)
+			text.WriteString(ev.Delta.Text)
+		}
+	}
+	want := "message_start,content_block_start,content_block_delta,content_block_delta,content_block_stop,message_delta,message_stop"
+	if strings.Join(events, ",") != want {
+		t.Fatalf("unexpected event sequence:\n got %v\nwant %s", events, want)
+	}
+	if text.String() != "Hallo" {
+		t.Fatalf("expected Hallo, got %q", text.String())
+	}
+	if !strings.Contains(rr.Body.String(), `"stop_reason":"end_turn"`) {
+		t.Fatalf("missing stop_reason end_turn: %s", rr.Body.String())
+	}
+}
+
+// Parallele Tool-Calls mit verschachtelten Argument-Deltas: jedes
+// input_json_delta geht an einen offenen Block, die Argumente bleiben je
+// Block getrennt, jeder Block wird genau einmal gestoppt.
+func TestMessagesStreamingInterleavedToolCalls(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Moment.\"}}]}\n\n" +
+			"data: {\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"id\":\"call_a\",\"function\":{\"name\":\"time\",\"arguments\":\"{\\\"tz\\\":\"}}]}}]}\n\n" +
+			"data: {\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":1,\"id\":\"call_b\",\"function\":{\"name\":\"weather\",\"arguments\":\"{\\\"city\\\":\"}}]}}]}\n\n" +
+			"data: {\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"function\":{\"arguments\":\"\\\"CET\\\"}\"}}]}}]}\n\n" +
+			"data: {\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":1,\"function\":{\"arguments\":\"\\\"Bonn\\\"}\"}}]},\"finish_reason\":\"tool_calls\"}]}\n\n" +
+			"data: [DONE]\n\n"))
+	}))
+	defer upstream.Close()
+
+	srv, _ := newTestServer(t)
+	addMockModel(srv, upstream)
+
+	body := `{"model":"mock","max_tokens":64,"stream":true,"messages":[{"role":"user","content":"Hi"}]}`
+	rr := httptest.NewRecorder()
+	srv.handleMessages(rr, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	open := map[int]bool{}
+	stopped := map[int]int{}
+	args := map[int]string{}
+	for _, line := range strings.Split(rr.Body.String(), "\n") {
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var ev struct {
+			Type  string `json:"type"`
+			Index int    `json:"index"`
+			Delta struct {
+				Type        string `json:"type"`
+				PartialJSON string `json:"partial_json"`
+			} `json:"delta"`
+		}
+		json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev)
+		switch ev.Type {
+		case "content_block_start":
+			open[ev.Index] = true
+		case "content_block_delta":
+			if !open[ev.Index] {
+				t.Fatalf("delta for closed block %d:\n%s", ev.Index, rr.Body.String())
+			}
+			if ev.Delta.Type == "input_json_delta" {
+				args[ev.Index] += ev.Delta.PartialJSON
+			}
+		case "content_block_stop":
+			open[ev.Index] = false
+			stopped[ev.Index]++
+		}
+	}
+	if args[1] != `{"tz":"CET"}` || args[2] != `{"city":"Bonn"}` {
+		t.Fatalf("unexpected tool arguments: %v", args)
+	}
+	if len(stopped) != 3 || stopped[0] != 1 || stopped[1] != 1 || stopped[2] != 1 {
+		t.Fatalf("expected each block stopped once, got %v", stopped)
+	}
+	if !strings.Contains(rr.Body.String(), `"stop_reason":"tool_use"`) {
+		t.Fatalf("missing stop_reason tool_use: %s", rr.Body.String())
+	}
+}
+
+func TestMessagesErrorFormat(t *testing.T) {
+	srv, _ := newTestServer(t)
+	body := `{"model":"gibt-es-nicht","max_tokens":16,"messages":[{"role":"user","content":"Hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	srv.handleMessages(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+	var resp struct {
+		Type  string `json:"type"`
+		Error struct {
+			Type string `json:"type"`
+		} `json:"error"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Type != "error" || resp.Error.Type != "invalid_request_error" {
+		t.Fatalf("expected Anthropic error shape, got %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,168 @@
+//**********************************************************************
+//      sigoREST/model_refresh.go
+//**********************************************************************
+//  Beschreibung: Modell-Liste zur Laufzeit aktualisieren (ohne
+//  Neustart). Ein Hintergrund-Refresh (-models-refresh) und
+//  POST /api/models/refresh rufen alle Provider erneut ab, vergleichen
+//  mit der aktuellen Liste und melden neue und entfallene Modelle.
+//  Provider mit Fehler behalten ihre bisherigen Modelle; bekannte
+//  Modelle behalten ihren Shortcode, neue weichen Kollisionen aus.
+//  Ollama-Modelle pflegt weiterhin syncOllamaModels.
+//**********************************************************************
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"sigorest/sigoengine"
+)
+
+// Abruf beim Refresh: ein Retry mit kurzem Backoff (beim Start 4 Versuche,
+// siehe loadModelsFromProviders). Der nächste Refresh versucht es erneut.
+const (
+	refreshFetchAttempts = 2
+	refreshFetchBackoff  = time.Second
+)
+
+// ModelRefreshResult ist das Ergebnis von POST /api/models/refresh.
+type ModelRefreshResult struct {
+	Added   []string          `json:"added"`
+	Removed []string          `json:"removed"`
+	Failed  map[string]string `json:"failed,omitempty"` // Provider → Fehler (bisherige Modelle bleiben)
+	Count   int               `json:"count"`            // Modelle nach dem Refresh
+}
+
+// refreshModels ruft alle Provider ab und ersetzt s.models durch die
+// zusammengeführte Liste. Parallele Aufrufe (Ticker, Endpoint) laufen
+// nacheinander; der Abruf selbst hält s.mu nicht.
+func (s *Server) refreshModels() ModelRefreshResult {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	fetch := fetchProviderModels(refreshFetchAttempts, refreshFetchBackoff)
+
+	s.mu.Lock()
+	next, result := mergeModelSnapshot(s.models, fetch)
+	s.models = next
+	s.mu.Unlock()
+
+	fields := map[string]interface{}{
+		"count":   result.Count,
+		"added":   strings.Join(result.Added, ","),
+		"removed": strings.Join(result.Removed, ","),
+	}
+	if len(result.Failed) > 0 {
+		failed := make([]string, 0, len(result.Failed))
+		for provider := range result.Failed {
+			failed = append(failed, provider)
+		}
+		sort.Strings(failed)
+		fields["failed"] = strings.Join(failed, ",")
+	}
+	if len(result.Added) > 0 || len(result.Removed) > 0 {
+		sigoengine.LogInfo("Modell-Liste aktualisiert", fields)
+		s.checkVirtualModels()
+	} else {
+		sigoengine.LogDebug("Modell-Liste unverändert", fields)
+	}
+	return result
+}
+
+// mergeModelSnapshot baut die neue Modell-Liste aus dem Abruf:
+//   - Modelle erfolgreicher Provider ersetzen die bisherigen,
+//   - Provider mit Fehler behalten ihre bisherigen Modelle,
+//   - "ollama-*"-Modelle bleiben unverändert (syncOllamaModels),
+//   - bekannte Modelle behalten ihren Shortcode; neue bekommen bei
+//     Kollision einen freien (GenerateShortcode mit Suffix).
+func mergeModelSnapshot(prev map[string]ModelInfo, fetch providerFetch) (map[string]ModelInfo, ModelRefreshResult) {
+	next := make(map[string]ModelInfo, len(fetch.models))
+	for id, info := range fetch.models {
+		next[id] = info
+	}
+	for id, info := range prev {
+		if _, exists := next[id]; exists {
+			continue
+		}
+		if strings.HasPrefix(id, "ollama-") {
+			next[id] = info
+			continue
+		}
+		if _, failed := fetch.failed[sigoengine.ProviderForModel(info.Endpoint, info.APIKey, id)]; failed {
+			next[id] = info
+		}
+	}
+
+	// Shortcodes: zuerst die bekannten Modelle, dann die neuen (sortiert,
+	// damit die Vergabe deterministisch ist)
+	used := make(map[string]bool, len(next))
+	var added []string
+	for id, info := range next {
+		old, known := prev[id]
+		if !known {
+			added = append(added, id)
+			continue
+		}
+		info.Shortcode = old.Shortcode
+		next[id] = info
+		used[strings.ToLower(old.Shortcode)] = true
+	}
+	sort.Strings(added)
+	for _, id := range added {
+		info := next[id]
+		if info.Shortcode == "" || used[strings.ToLower(info.Shortcode)] {
+			info.Shortcode = sigoengine.GenerateShortcode(id, used)
+			next[id] = info
+		}
+		used[strings.ToLower(info.Shortcode)] = true
+	}
+
+	var removed []string
+	for id := range prev {
+		if _, still := next[id]; !still {
+			removed = append(removed, id)
+		}
+	}
+	sort.Strings(removed)
+
+	result := ModelRefreshResult{
+		Added:   append([]string{}, added...),
+		Removed: append([]string{}, removed...),
+		Count:   len(next),
+	}
+	if len(fetch.failed) > 0 {
+		result.Failed = fetch.failed
+	}
+	return next, result
+}
+
+// runEvery startet fn periodisch in einer Goroutine (interval <= 0: nie).
+func runEvery(interval time.Duration, fn func()) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			fn()
+		}
+	}()
+}
+
+// **********************************************************************
+// POST /api/models/refresh - Modell-Liste neu laden
+
+func (s *Server) handleModelsRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", "invalid_request", http.StatusMethodNotAllowed)
+		return
+	}
+	result := s.refreshModels()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
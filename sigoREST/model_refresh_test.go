@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMergeModelSnapshot(t *testing.T) {
+	const mammouth = "https://api.mammouth.ai/v1/chat/completions"
+	prev := map[string]ModelInfo{
+		"gpt-5":         {ID: "gpt-5", Shortcode: "gpt5", Endpoint: mammouth, APIKey: "MAMMOUTH_API_KEY"},
+		"old-model":     {ID: "old-model", Shortcode: "old", Endpoint: mammouth, APIKey: "MAMMOUTH_API_KEY"},
+		"kimi-k2":       {ID: "kimi-k2", Shortcode: "kimi2", APIKey: "MOONSHOT_API_KEY"},
+		"ollama-llama3": {ID: "ollama-llama3", Shortcode: "ollama-llama3", Endpoint: "http://localhost:11434/v1/chat/completions"},
+	}
+	fetch := providerFetch{
+		models: map[string]ModelInfo{
+			// Neue Sortierung beim Provider verschiebt die Shortcodes
+			"gpt-5":     {ID: "gpt-5", Shortcode: "gpt5-2", Endpoint: mammouth, APIKey: "MAMMOUTH_API_KEY", InputCost: 2},
+			"gpt-5-pro": {ID: "gpt-5-pro", Shortcode: "gpt5", Endpoint: mammouth, APIKey: "MAMMOUTH_API_KEY"},
+		},
+		failed: map[string]string{"moonshot": "timeout"},
+	}
+
+	next, result := mergeModelSnapshot(prev, fetch)
+
+	if next["gpt-5"].Shortcode != "gpt5" || next["gpt-5"].InputCost != 2 {
+		t.Errorf("known model: expected stable shortcode and new data, got %+v", next["gpt-5"])
+	}
+	if sc := next["gpt-5-pro"].Shortcode; sc == "" || sc == "gpt5" {
+		t.Errorf("new model: expected collision-free shortcode, got %q", sc)
+	}
+	if _, ok := next["kimi-k2"]; !ok {
+		t.Error("failed provider: previous model dropped")
+	}
+	if _, ok := next["ollama-llama3"]; !ok {
+		t.Error("ollama model dropped")
+	}
+	if _, ok := next["old-model"]; ok {
+		t.Error("removed model still present")
+	}
+	if strings.Join(result.Added, ",") != "gpt-5-pro" || strings.Join(result.Removed, ",") != "old-model" {
+		t.Errorf("diff: added=%v removed=%v", result.Added, result.Removed)
+	}
+	if result.Failed["moonshot"] != "timeout" || result.Count != len(next) || len(next) != 4 {
+		t.Errorf("result = %+v, next = %v", result, next)
+	}
+
+	// Unveränderter Abruf: keine Änderungen
+	again := providerFetch{models: next, failed: map[string]string{}}
+	_, result = mergeModelSnapshot(next, again)
+	if len(result.Added) != 0 || len(result.Removed) != 0 || result.Failed != nil {
+		t.Errorf("expected no changes, got %+v", result)
+	}
+}
+
+func TestHandleModelsRefreshMethod(t *testing.T) {
+	srv, _ := newTestServer(t)
+	rr := httptest.NewRecorder()
+	srv.handleModelsRefresh(rr, httptest.NewRequest(http.MethodGet, "/api/models/refresh", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,59 @@
+//**********************************************************************
+//      sigoREST/model_settings.go
+//**********************************************************************
+//  Beschreibung: Per-Modell-Defaults für sigoREST-Erweiterungen aus
+//  <data-dir>/model_settings.json, z.B. hedge_after_ms. Keys sind
+//  Modell-IDs oder Shortcodes; Request-Werte haben Vorrang.
+//**********************************************************************
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ModelSettings sind die Defaults eines Modells.
+type ModelSettings struct {
+	HedgeAfterMs int `json:"hedge_after_ms,omitempty"` // 0 → kein Hedging
+}
+
+// modelSettingsFile ist das Format von model_settings.json.
+type modelSettingsFile struct {
+	Models map[string]ModelSettings `json:"models"`
+}
+
+// loadModelSettings liest model_settings.json. Fehlt die Datei, gibt es
+// keine Defaults. Key der Map ist ID bzw. Shortcode in Kleinbuchstaben.
+func loadModelSettings(path string) (map[string]ModelSettings, error) {
+	result := make(map[string]ModelSettings)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return nil, fmt.Errorf("model_settings.json lesen: %w", err)
+	}
+	var file modelSettingsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("model_settings.json parsen: %w", err)
+	}
+	for name, settings := range file.Models {
+		if settings.HedgeAfterMs < 0 {
+			return nil, fmt.Errorf("model_settings.json %q: hedge_after_ms ist negativ", name)
+		}
+		result[strings.ToLower(strings.TrimSpace(name))] = settings
+	}
+	return result, nil
+}
+
+// modelSettingsFor liefert die Defaults eines Modells (über ID, sonst
+// Shortcode). Die Map wird nur beim Start gesetzt und danach nur gelesen.
+func (s *Server) modelSettingsFor(info ModelInfo) ModelSettings {
+	if settings, ok := s.modelSettings[strings.ToLower(info.ID)]; ok {
+		return settings
+	}
+	return s.modelSettings[strings.ToLower(info.Shortcode)]
+}
@@ -0,0 +1,156 @@
+//**********************************************************************
+//      sigoREST/scheduler.go
+//**********************************************************************
+//  Beschreibung: Anbindung der Request-Queue (sigoengine.RequestQueue).
+//  Client-Identität (Bearer-Token, sonst IP), Priorität aus dem Header
+//  X-Sigo-Priority oder <data-dir>/clients.json, Kapazität eines
+//  Providers aus den max_concurrent seiner aktiven Kanäle. Die Queue ist
+//  opt-in: ohne max_concurrent ist der Provider unbegrenzt, Requests
+//  gehen direkt an den Rate-Limiter der Kanäle.
+//**********************************************************************
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"sigorest/sigoengine"
+)
+
+// ClientSettings ist die Konfiguration eines Clients.
+type ClientSettings struct {
+	Priority string `json:"priority,omitempty"` // Default-Klasse (high|normal|low)
+}
+
+// clientsFile ist das Format von clients.json.
+type clientsFile struct {
+	Clients map[string]ClientSettings `json:"clients"`
+}
+
+// clientKeyPrefix kennzeichnet in clients.json den SHA-256 eines API-Keys
+// (Bearer-Token); Keys selbst stehen nicht in der Datei.
+const clientKeyPrefix = "sha256:"
+
+// clientKeyHash liefert den clients.json-Key eines API-Keys.
+func clientKeyHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return clientKeyPrefix + hex.EncodeToString(sum[:])
+}
+
+// loadClients liest clients.json. Keys sind Client-IPs oder
+// "sha256:<hex>" eines API-Keys (Bearer-Token). Fehlt die Datei, gelten
+// für alle Clients die Defaults.
+func loadClients(path string) (map[string]ClientSettings, error) {
+	result := make(map[string]ClientSettings)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return nil, fmt.Errorf("clients.json lesen: %w", err)
+	}
+	var file clientsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("clients.json parsen: %w", err)
+	}
+	for key, settings := range file.Clients {
+		key = strings.TrimSpace(key)
+		if hash, ok := strings.CutPrefix(key, clientKeyPrefix); ok {
+			if _, err := hex.DecodeString(hash); err != nil || len(hash) != 2*sha256.Size {
+				return nil, fmt.Errorf("clients.json %q: ungültiger SHA-256", key)
+			}
+			key = strings.ToLower(key)
+		} else if net.ParseIP(key) == nil {
+			return nil, fmt.Errorf("clients.json %q: Key muss eine Client-IP oder \"sha256:<hex>\" des API-Keys sein", key)
+		}
+		if settings.Priority != "" {
+			if _, ok := sigoengine.ParsePriority(settings.Priority); !ok {
+				return nil, fmt.Errorf("clients.json %q: unbekannte priority %q", key, settings.Priority)
+			}
+		}
+		result[key] = settings
+	}
+	return result, nil
+}
+
+// bearerToken liefert den API-Key des Clients (leer ohne Authorization).
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// clientIdentity liefert die Identität eines Clients für die faire
+// Verteilung (API-Key als Hash, sonst IP) und seine Konfiguration.
+func (s *Server) clientIdentity(r *http.Request) (string, ClientSettings) {
+	ip := r.RemoteAddr
+	if parsed := extractIP(r.RemoteAddr); parsed != nil {
+		ip = parsed.String()
+	}
+	if token := bearerToken(r); token != "" {
+		hash := clientKeyHash(token)
+		settings, ok := s.clients[hash]
+		if !ok {
+			settings = s.clients[ip]
+		}
+		return "key:" + hash[len(clientKeyPrefix):len(clientKeyPrefix)+12], settings
+	}
+	return "ip:" + ip, s.clients[ip]
+}
+
+// requestPriority bestimmt die Klasse eines Requests: X-Sigo-Priority vor
+// clients.json, sonst normal. Unbekannte Header-Werte werden ignoriert.
+func requestPriority(r *http.Request, settings ClientSettings) sigoengine.Priority {
+	if h := r.Header.Get("X-Sigo-Priority"); h != "" {
+		if prio, ok := sigoengine.ParsePriority(h); ok {
+			return prio
+		}
+		sigoengine.LogWarn("Unbekannte X-Sigo-Priority ignoriert", map[string]interface{}{"priority": h})
+	}
+	prio, _ := sigoengine.ParsePriority(settings.Priority)
+	return prio
+}
+
+// providerCapacity liefert die Zahl paralleler Requests eines Providers:
+// Summe der max_concurrent seiner aktiven Kanäle. Hat ein aktiver Kanal
+// kein Limit, ist der Provider unbegrenzt (0) und die Queue lässt alle
+// Requests sofort durch (opt-in über max_concurrent).
+func (s *Server) providerCapacity(provider string) int {
+	capacity := 0
+	for _, ch := range s.channelManager.Registry().Channels(provider) {
+		if !ch.Active {
+			continue
+		}
+		if ch.MaxConcurrent <= 0 {
+			return 0
+		}
+		capacity += ch.MaxConcurrent
+	}
+	return capacity
+}
+
+// enterQueue reiht einen Request in die Queue des Providers ein und wartet
+// auf seinen Platz. leave gibt ihn nach dem Request (inkl. Failover und
+// Stream) frei. ErrQueueFull bzw. ErrRateLimited (zu lange gewartet)
+// werden als HTTP 429 gemeldet.
+func (s *Server) enterQueue(ctx context.Context, r *http.Request, provider string) (leave func(), err error) {
+	client, settings := s.clientIdentity(r)
+	prio := requestPriority(r, settings)
+	return s.requestQueue.Enter(ctx, provider, client, prio, s.providerCapacity(provider))
+}
+
+// tryEnterQueue belegt einen weiteren Platz für den zweiten Call eines
+// Hedge, ohne zu warten (false: Provider voll oder Requests warten).
+func (s *Server) tryEnterQueue(provider string) (leave func(), ok bool) {
+	return s.requestQueue.TryEnter(provider, s.providerCapacity(provider))
+}
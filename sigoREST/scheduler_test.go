@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sigorest/sigoengine"
+)
+
+func TestLoadClients(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clients.json")
+	if clients, err := loadClients(path); err != nil || len(clients) != 0 {
+		t.Fatalf("fehlende Datei: %v, %v", clients, err)
+	}
+	hash := clientKeyHash("sk-batch")
+	os.WriteFile(path, []byte(`{"clients":{"192.168.1.50":{"priority":"low"},"`+clientKeyPrefix+strings.ToUpper(hash[len(clientKeyPrefix):])+`":{"priority":"LOW"}}}`), 0644)
+	clients, err := loadClients(path)
+	if err != nil || clients["192.168.1.50"].Priority != "low" || clients[hash].Priority != "LOW" || len(clients) != 2 {
+		t.Fatalf("loadClients = %v, %v", clients, err)
+	}
+	for _, bad := range []string{
+		`{"clients":{"10.0.0.1":{"priority":"urgent"}}}`,
+		`{"clients":{"sk-batch":{"priority":"low"}}}`, // API-Key im Klartext
+		`{"clients":{"sha256:abc":{"priority":"low"}}}`,
+	} {
+		os.WriteFile(path, []byte(bad), 0644)
+		if _, err := loadClients(path); err == nil {
+			t.Errorf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestRequestPriorityAndClientIdentity(t *testing.T) {
+	srv, _ := newTestServer(t)
+	srv.clients = map[string]ClientSettings{
+		"192.168.1.50":            {Priority: "low"},
+		clientKeyHash("sk-batch"): {Priority: "low"},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.RemoteAddr = "192.168.1.50:4711"
+	client, settings := srv.clientIdentity(req)
+	if client != "ip:192.168.1.50" || requestPriority(req, settings) != sigoengine.PriorityLow {
+		t.Fatalf("IP-Client: %s %v", client, settings)
+	}
+
+	// Header vor clients.json, unbekannte Werte ignoriert
+	req.Header.Set("X-Sigo-Priority", "high")
+	if p := requestPriority(req, settings); p != sigoengine.PriorityHigh {
+		t.Errorf("header priority = %v", p)
+	}
+	req.Header.Set("X-Sigo-Priority", "urgent")
+	if p := requestPriority(req, settings); p != sigoengine.PriorityLow {
+		t.Errorf("invalid header should fall back to client config, got %v", p)
+	}
+
+	// API-Key: eigene Identität (gehasht), Konfiguration per Key
+	req = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.RemoteAddr = "10.0.0.7:4711"
+	req.Header.Set("Authorization", "Bearer sk-batch")
+	client, settings = srv.clientIdentity(req)
+	if client == "" || client == "ip:10.0.0.7" || settings.Priority != "low" {
+		t.Fatalf("Key-Client: %s %v", client, settings)
+	}
+}
+
+func TestProviderCapacityAndHealthQueues(t *testing.T) {
+	srv, _ := newTestServer(t)
+	registry := srv.channelManager.Registry()
+	def, _ := registry.GetChannel("mammouth", "default")
+	if n := srv.providerCapacity("mammouth"); n != 0 {
+		t.Fatalf("Kanal ohne max_concurrent: Kapazität %d, want 0", n)
+	}
+	def.MaxConcurrent = 2
+	registry.SetActive("mammouth", "0", true)
+	ch0, _ := registry.GetChannel("mammouth", "0")
+	ch0.MaxConcurrent = 3
+	if n := srv.providerCapacity("mammouth"); n != 5 {
+		t.Fatalf("Kapazität %d, want 5", n)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	leave, err := srv.enterQueue(req.Context(), req, "mammouth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	srv.handleHealth(rr, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+	leave()
+	var health struct {
+		Queues []sigoengine.QueueStats `json:"queues"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &health)
+	if len(health.Queues) != 1 || health.Queues[0].Capacity != 5 || health.Queues[0].Running != 1 {
+		t.Fatalf("queues in /api/health: %s", rr.Body.String())
+	}
+}
+
+// TestChatCompletionsQueueFairness: mit max_concurrent (opt-in) wartet ein
+// interaktiver Client nicht hinter den Requests eines Batch-Clients.
+func TestChatCompletionsQueueFairness(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	started := make(chan struct{}, 10)
+	unblock := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		var body struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		order = append(order, body.Messages[len(body.Messages)-1].Content)
+		mu.Unlock()
+		started <- struct{}{}
+		<-unblock
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer upstream.Close()
+
+	srv, _ := newTestServer(t)
+	addMockModel(srv, upstream)
+	def, _ := srv.channelManager.Registry().GetChannel("mammouth", "default")
+	def.MaxConcurrent = 1
+
+	var wg sync.WaitGroup
+	send := func(key, content string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+				strings.NewReader(`{"model":"mock","messages":[{"role":"user","content":"`+content+`"}]}`))
+			req.Header.Set("Authorization", "Bearer "+key)
+			rr := httptest.NewRecorder()
+			srv.handleChatCompletions(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Errorf("%s: expected 200, got %d: %s", content, rr.Code, rr.Body.String())
+			}
+		}()
+	}
+	waitDepth := func(n int) {
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			if st := srv.requestQueue.Stats(); len(st) == 1 && st[0].Depth == n {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("queue depth %d not reached: %+v", n, srv.requestQueue.Stats())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	send("sk-batch", "batch-0")
+	<-started
+	for i, content := range []string{"batch-1", "batch-2", "batch-3"} {
+		send("sk-batch", content)
+		waitDepth(i + 1)
+	}
+	send("sk-user", "user")
+	waitDepth(4)
+	for i := 0; i < 5; i++ {
+		unblock <- struct{}{}
+		if i < 4 {
+			<-started
+		}
+	}
+	wg.Wait()
+
+	want := "batch-0,batch-1,user,batch-2,batch-3"
+	if got := strings.Join(order, ","); got != want {
+		t.Fatalf("upstream order = %s, want %s", got, want)
+	}
+}
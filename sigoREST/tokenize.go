@@ -0,0 +1,107 @@
+//**********************************************************************
+//      sigoREST/tokenize.go
+//**********************************************************************
+//  Beschreibung: Lokale Token-Zählung (sigoengine.Tokenizer).
+//  POST /api/tokenize zählt Text oder Chat-Messages mit dem Encoding
+//  des Modells, ohne Provider-Call: Prompt-Tokens wie im Pre-Flight,
+//  Tokens je Message, Kontextfenster und geschätzte Input-Kosten.
+//**********************************************************************
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"sigorest/sigoengine"
+)
+
+// TokenizeRequest ist der Body von POST /api/tokenize.
+type TokenizeRequest struct {
+	Model    string                   `json:"model"`
+	Text     string                   `json:"text,omitempty"`
+	Messages []map[string]interface{} `json:"messages,omitempty"`
+	Tools    json.RawMessage          `json:"tools,omitempty"`
+}
+
+// TokenizeResponse ist das Ergebnis von POST /api/tokenize.
+type TokenizeResponse struct {
+	Model          string  `json:"model"`
+	Tokenizer      string  `json:"tokenizer"`
+	Exact          bool    `json:"exact"` // false = Näherung ohne Vokabular
+	Tokens         int     `json:"tokens"`
+	MessageTokens  []int   `json:"message_tokens,omitempty"`
+	MaxInputTokens int     `json:"max_input_tokens,omitempty"`
+	Fits           bool    `json:"fits"`
+	InputCost      float64 `json:"input_cost,omitempty"` // $ für die Tokens (InputCost des Modells)
+}
+
+// modelTokenizer liefert den Tokenizer eines Modells: tokenizer aus der
+// Modell-Liste, sonst nach Modellfamilie.
+func modelTokenizer(info ModelInfo, modelID string) *sigoengine.Tokenizer {
+	if info.Tokenizer != "" {
+		if tok, ok := sigoengine.GetTokenizer(info.Tokenizer); ok {
+			return tok
+		}
+	}
+	return sigoengine.TokenizerForModel(modelID)
+}
+
+// **********************************************************************
+// POST /api/tokenize - Tokens zählen
+
+func (s *Server) handleTokenize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", "invalid_request", http.StatusMethodNotAllowed)
+		return
+	}
+	var req TokenizeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "Invalid JSON: "+err.Error(), "invalid_request", http.StatusBadRequest)
+		return
+	}
+	if req.Text == "" && len(req.Messages) == 0 {
+		writeError(w, "text oder messages erforderlich", "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	// Virtuelle Modelle: erstes verfügbares Modell der Kette
+	s.mu.RLock()
+	info, modelID, exists := s.lookupModel(req.Model)
+	if exists {
+		if targets := s.chatTargets(info, modelID, req.Model); len(targets) > 0 {
+			info, modelID = targets[0].info, targets[0].id
+		}
+	}
+	s.mu.RUnlock()
+	if !exists {
+		writeError(w, fmt.Sprintf("Model '%s' nicht gefunden", req.Model), "model_not_found", http.StatusBadRequest)
+		return
+	}
+
+	tok := modelTokenizer(info, modelID)
+	resp := TokenizeResponse{
+		Model:          modelID,
+		Tokenizer:      tok.Name(),
+		Exact:          tok.Exact(),
+		MaxInputTokens: info.MaxInputTokens,
+	}
+	if len(req.Messages) > 0 {
+		resp.Tokens = sigoengine.EstimatePromptTokens(tok, req.Messages)
+		for _, msg := range req.Messages {
+			resp.MessageTokens = append(resp.MessageTokens, sigoengine.MessageTokens(tok, msg))
+		}
+	}
+	if req.Text != "" {
+		resp.Tokens += tok.Count(req.Text)
+	}
+	if len(req.Tools) > 0 {
+		resp.Tokens += tok.Count(string(req.Tools))
+	}
+	resp.Fits = info.MaxInputTokens <= 0 || resp.Tokens < info.MaxInputTokens
+	resp.InputCost = float64(resp.Tokens) * info.InputCost / 1e6
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,124 @@
+//**********************************************************************
+//      sigoREST/virtual_models.go
+//**********************************************************************
+//  Beschreibung: Virtuelle Modelle mit providerübergreifender Fallback-
+//  Kette aus <data-dir>/virtual_models.json, z.B. "smart" →
+//  [claude-sonnet-4-6, kimi-k2.5, glm-4.6]. serveChat probiert die
+//  Modelle der Reihe nach, jedes mit eigenem Kanal-Failover; das
+//  antwortende Modell steht in "model" und im Header X-Sigo-Model.
+//**********************************************************************
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"sigorest/sigoengine"
+)
+
+// modelKindVirtual kennzeichnet virtuelle Modelle in ModelInfo.Kind.
+const modelKindVirtual = "virtual"
+
+// VirtualModel ist ein Eintrag in virtual_models.json.
+type VirtualModel struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Models      []string `json:"models"` // IDs oder Shortcodes in Fallback-Reihenfolge
+}
+
+// virtualModelsFile ist das Format von virtual_models.json.
+type virtualModelsFile struct {
+	VirtualModels []VirtualModel `json:"virtual_models"`
+}
+
+// info liefert das virtuelle Modell als ModelInfo (für Lookup und /api/models).
+func (vm VirtualModel) info() ModelInfo {
+	return ModelInfo{
+		ID:        vm.Name,
+		Shortcode: vm.Name,
+		Kind:      modelKindVirtual,
+		Chain:     vm.Models,
+	}
+}
+
+// loadVirtualModels liest virtual_models.json. Fehlt die Datei, gibt es
+// keine virtuellen Modelle. Key der Map ist der Name in Kleinbuchstaben
+// (Lookup ist case-insensitiv wie bei IDs und Shortcodes).
+func loadVirtualModels(path string) (map[string]VirtualModel, error) {
+	result := make(map[string]VirtualModel)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return nil, fmt.Errorf("virtual_models.json lesen: %w", err)
+	}
+	var file virtualModelsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("virtual_models.json parsen: %w", err)
+	}
+	for i, vm := range file.VirtualModels {
+		vm.Name = strings.TrimSpace(vm.Name)
+		if vm.Name == "" {
+			return nil, fmt.Errorf("virtual_models.json Eintrag %d: name fehlt", i)
+		}
+		if len(vm.Models) == 0 {
+			return nil, fmt.Errorf("virtual_models.json %q: models ist leer", vm.Name)
+		}
+		key := strings.ToLower(vm.Name)
+		if _, dup := result[key]; dup {
+			return nil, fmt.Errorf("virtual_models.json: %q doppelt definiert", vm.Name)
+		}
+		result[key] = vm
+	}
+	return result, nil
+}
+
+// checkVirtualModels warnt vor Namen, die ein echtes Modell verdecken
+// würde, und vor unbekannten Modellen in Ketten. Unbekannte Modelle sind
+// kein Fehler: sie können später erscheinen (z.B. nach "ollama pull").
+func (s *Server) checkVirtualModels() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, vm := range s.virtualModels {
+		if info, _, ok := s.lookupModel(vm.Name); ok && info.Kind != modelKindVirtual {
+			sigoengine.LogWarn("Virtuelles Modell wird von echtem Modell verdeckt", map[string]interface{}{
+				"name": vm.Name, "model": info.ID,
+			})
+		}
+		for _, entry := range vm.Models {
+			if _, _, ok := s.lookupModel(entry); !ok {
+				sigoengine.LogWarn("Virtuelles Modell: unbekanntes Modell in der Kette", map[string]interface{}{
+					"name": vm.Name, "model": entry,
+				})
+			}
+		}
+	}
+}
+
+// chatTargets liefert die konkreten Modelle für einen Chat-Request: das
+// Modell selbst oder die Kette eines virtuellen Modells. Unbekannte,
+// Embedding- und (verschachtelte) virtuelle Modelle in der Kette werden
+// übersprungen, ebenso Duplikate. Aufrufer muss s.mu (RLock) halten.
+func (s *Server) chatTargets(info ModelInfo, id, requested string) []chatTarget {
+	if info.Kind != modelKindVirtual {
+		return []chatTarget{{info: info, id: id, name: requested}}
+	}
+	var targets []chatTarget
+	seen := make(map[string]bool)
+	for _, entry := range info.Chain {
+		target, targetID, ok := s.lookupModel(entry)
+		if !ok || target.Kind == modelKindVirtual || target.Kind == sigoengine.ModelKindEmbedding || seen[targetID] {
+			sigoengine.LogDebug("Modell der Kette übersprungen", map[string]interface{}{
+				"model": requested, "entry": entry, "found": ok,
+			})
+			continue
+		}
+		seen[targetID] = true
+		targets = append(targets, chatTarget{info: target, id: targetID, name: targetID})
+	}
+	return targets
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sigorest/sigoengine"
+)
+
+func TestLoadVirtualModels(t *testing.T) {
+	dir := t.TempDir()
+	if vms, err := loadVirtualModels(filepath.Join(dir, "fehlt.json")); err != nil || len(vms) != 0 {
+		t.Fatalf("fehlende Datei: %v, %v", vms, err)
+	}
+
+	path := filepath.Join(dir, "virtual_models.json")
+	os.WriteFile(path, []byte(`{"virtual_models":[{"name":"Smart","models":["cl-s","kimi"]}]}`), 0644)
+	vms, err := loadVirtualModels(path)
+	if err != nil || len(vms["smart"].Models) != 2 {
+		t.Fatalf("loadVirtualModels = %v, %v", vms, err)
+	}
+
+	for _, content := range []string{
+		`{"virtual_models":[{"name":"","models":["a"]}]}`,
+		`{"virtual_models":[{"name":"x","models":[]}]}`,
+		`{"virtual_models":[{"name":"x","models":["a"]},{"name":"X","models":["b"]}]}`,
+		`{"virtual_models":`,
+	} {
+		os.WriteFile(path, []byte(content), 0644)
+		if _, err := loadVirtualModels(path); err == nil {
+			t.Errorf("%s: Fehler erwartet", content)
+		}
+	}
+}
+
+func TestChatCompletionsVirtualModelFallback(t *testing.T) {
+	var calls []string
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		calls = append(calls, "mock-model")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":{"message":"down"}}`))
+	}))
+	defer failing.Close()
+	working := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		calls = append(calls, body["model"].(string)+" "+r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer working.Close()
+
+	srv, _ := newTestServer(t)
+	srv.channelManager.Registry().AddChannel(&sigoengine.Channel{
+		Provider: "moonshot", Name: "default", APIKey: "moon-key", Active: true, Healthy: true,
+	})
+	addMockModel(srv, failing)
+	srv.models["kimi-test"] = ModelInfo{
+		ID:             "kimi-test",
+		Shortcode:      "kt",
+		Endpoint:       working.URL + "/v1/chat/completions",
+		APIKey:         "MOONSHOT_API_KEY",
+		MaxTemperature: 1.0,
+	}
+	srv.virtualModels = map[string]VirtualModel{
+		"smart": {Name: "smart", Models: []string{"mock", "gibt-es-nicht", "kt"}},
+	}
+
+	body := `{"model":"SMART","retries":1,"messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	srv.handleChatCompletions(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	// mock-model: 2 Versuche (1 Retry) auf dem einzigen aktiven Kanal, dann kimi-test über moonshot
+	if len(calls) != 3 || calls[2] != "kimi-test Bearer moon-key" {
+		t.Fatalf("expected fallback to kimi-test, got %v", calls)
+	}
+	var resp ChatResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Model != "kimi-test" || rr.Header().Get("X-Sigo-Model") != "kimi-test" {
+		t.Fatalf("answering model not reported: model=%q header=%q", resp.Model, rr.Header().Get("X-Sigo-Model"))
+	}
+	if srv.usage["kimi-test"] == nil || srv.usage["kimi-test"].Requests != 1 {
+		t.Errorf("usage not recorded for concrete model: %v", srv.usage)
+	}
+
+	// /v1/models listet das virtuelle Modell
+	rr = httptest.NewRecorder()
+	srv.handleModels(rr, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	if !strings.Contains(rr.Body.String(), `"id":"smart"`) {
+		t.Errorf("virtual model missing in /v1/models: %s", rr.Body.String())
+	}
+}
+
+func TestChatCompletionsVirtualModelNoFallbackOnClientError(t *testing.T) {
+	var calls int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			return
+		}
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"message":"invalid messages"}}`))
+	}))
+	defer upstream.Close()
+
+	srv, _ := newTestServer(t)
+	addMockModel(srv, upstream)
+	srv.models["mock-2"] = ModelInfo{ID: "mock-2", Shortcode: "mock2", Endpoint: upstream.URL + "/v1/chat/completions", MaxTemperature: 1.0}
+	srv.virtualModels = map[string]VirtualModel{"smart": {Name: "smart", Models: []string{"mock", "mock2"}}}
+
+	body := `{"model":"smart","messages":[{"role":"user","content":"hi"}]}`
+	rr := httptest.NewRecorder()
+	srv.handleChatCompletions(rr, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	if rr.Code != http.StatusBadRequest || calls != 1 {
+		t.Fatalf("expected 400 without fallback, got %d after %d calls", rr.Code, calls)
+	}
+}
@@ -0,0 +1,601 @@
+//**********************************************************************
+//      sigoengine/anthropic.go
+//**********************************************************************
+//  Beschreibung: Adapter für die native Anthropic Messages API.
+//  Übersetzt OpenAI-Chat-Requests (System-Hoisting, Bild-Parts,
+//  tool_calls/tool-Ergebnisse) ins Anthropic-Format, Antworten und
+//  stop_reason/usage zurück, sowie Anthropic-SSE-Events in OpenAI
+//  chat.completion.chunk-Events.
+//**********************************************************************
+
+package sigoengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	anthropicVersion = "2023-06-01"
+	// Direkte Anthropic-Modelle tragen dieses ID-Präfix, damit sie nicht mit
+	// den gleichnamigen Mammouth-Modellen (z.B. claude-sonnet-4-6) kollidieren.
+	anthropicModelPrefix = "anthropic/"
+	// Anthropic verlangt max_tokens; greift wenn der Request keinen Wert setzt.
+	anthropicDefaultMaxTokens = 4096
+)
+
+// AnthropicModelName liefert den Modellnamen, den die Anthropic API erwartet
+// (ohne "anthropic/"-Präfix der Registry).
+func AnthropicModelName(id string) string {
+	return strings.TrimPrefix(id, anthropicModelPrefix)
+}
+
+// anthropicProvider: Messages API statt /chat/completions, x-api-key-Auth
+// und eigenes SSE-Format. Übrige Methoden vom OpenAICompatProvider.
+type anthropicProvider struct {
+	OpenAICompatProvider
+}
+
+func init() {
+	RegisterProvider(&anthropicProvider{OpenAICompatProvider{
+		ProviderName:   "anthropic",
+		KeyEnvPrefix:   "ANTHROPIC",
+		Hosts:          []string{"anthropic.com"},
+		ModelsURL:      anthropicModelsEndpoint,
+		ModelsNeedAuth: true,
+	}})
+}
+
+func (p *anthropicProvider) Type() string { return "anthropic" }
+
+// FetchModels lädt Modelle nur mit ANTHROPIC_API_KEY (IDs mit "anthropic/"-Präfix).
+func (p *anthropicProvider) FetchModels() ([]Model, error) {
+	if GetEnvWithFile("ANTHROPIC_API_KEY") == "" {
+		return nil, nil
+	}
+	return FetchAnthropicModels()
+}
+
+// SetAuth: Anthropic nutzt x-api-key + anthropic-version statt Bearer.
+func (p *anthropicProvider) SetAuth(req *http.Request, apiKey string) {
+	req.Header.Set("x-api-key", apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+}
+
+func (p *anthropicProvider) BuildChatRequest(request map[string]interface{}) (map[string]interface{}, error) {
+	return ToAnthropicRequest(request)
+}
+
+func (p *anthropicProvider) ParseChatResponse(result map[string]interface{}) (*ChatResult, error) {
+	return FromAnthropicResponse(result)
+}
+
+func (p *anthropicProvider) NewStreamTranslator(model string) StreamTranslator {
+	return NewAnthropicStreamTranslator(model)
+}
+
+// **********************************************************************
+// OpenAI → Anthropic Request
+
+// openAIChatRequest deckt die Felder ab, die der Adapter übersetzt.
+// Alles andere hat in der Messages API keine Entsprechung und entfällt.
+type openAIChatRequest struct {
+	Model               string              `json:"model"`
+	Messages            []openAIChatMessage `json:"messages"`
+	MaxTokens           int                 `json:"max_tokens"`
+	MaxCompletionTokens int                 `json:"max_completion_tokens"`
+	Temperature         *float64            `json:"temperature"`
+	TopP                *float64            `json:"top_p"`
+	TopK                *int                `json:"top_k"`
+	Stop                json.RawMessage     `json:"stop"`
+	Stream              bool                `json:"stream"`
+	Tools               []openAITool        `json:"tools"`
+	ToolChoice          json.RawMessage     `json:"tool_choice"`
+	ParallelToolCalls   *bool               `json:"parallel_tool_calls"`
+	User                string              `json:"user"`
+	Metadata            json.RawMessage     `json:"metadata"`
+}
+
+type openAIChatMessage struct {
+	Role       string           `json:"role"`
+	Content    json.RawMessage  `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls"`
+	ToolCallID string           `json:"tool_call_id"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAITool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Parameters  json.RawMessage `json:"parameters"`
+	} `json:"function"`
+}
+
+// openAIContentPart ist ein Element eines Vision-Content-Arrays.
+type openAIContentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	ImageURL struct {
+		URL string `json:"url"`
+	} `json:"image_url"`
+}
+
+// ToAnthropicRequest wandelt einen OpenAI-Chat-Request in einen Anthropic
+// Messages-Request um:
+//   - role:"system" wird in das Top-Level-Feld "system" gehoben
+//   - image_url-Parts werden zu image-Blöcken (base64 oder url)
+//   - Assistant-tool_calls werden zu tool_use-, role:"tool" zu tool_result-Blöcken
+//   - aufeinanderfolgende Nachrichten gleicher Rolle werden zusammengeführt
+func ToAnthropicRequest(request map[string]interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, NewError(ErrInvalidInput, "Request nicht serialisierbar", err, nil)
+	}
+	var in openAIChatRequest
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, NewError(ErrInvalidInput, "Request nicht im OpenAI-Format", err, nil)
+	}
+
+	var systemParts []string
+	var messages []map[string]interface{}
+	appendBlocks := func(role string, blocks []map[string]interface{}) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(messages); n > 0 && messages[n-1]["role"] == role {
+			prev := messages[n-1]["content"].([]map[string]interface{})
+			messages[n-1]["content"] = append(prev, blocks...)
+			return
+		}
+		messages = append(messages, map[string]interface{}{"role": role, "content": blocks})
+	}
+
+	for _, m := range in.Messages {
+		switch m.Role {
+		case "system", "developer":
+			if text := ExtractTextFromContent(m.Content); text != "" && string(m.Content) != "null" {
+				systemParts = append(systemParts, text)
+			}
+		case "tool":
+			appendBlocks("user", []map[string]interface{}{{
+				"type":        "tool_result",
+				"tool_use_id": m.ToolCallID,
+				"content":     ExtractTextFromContent(m.Content),
+			}})
+		case "assistant":
+			blocks := anthropicContentBlocks(m.Content)
+			for _, tc := range m.ToolCalls {
+				input := json.RawMessage(tc.Function.Arguments)
+				if len(strings.TrimSpace(tc.Function.Arguments)) == 0 || !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, map[string]interface{}{
+					"type":  "tool_use",
+					"id":    tc.ID,
+					"name":  tc.Function.Name,
+					"input": input,
+				})
+			}
+			appendBlocks("assistant", blocks)
+		default:
+			appendBlocks("user", anthropicContentBlocks(m.Content))
+		}
+	}
+
+	maxTokens := in.MaxTokens
+	if in.MaxCompletionTokens > 0 {
+		maxTokens = in.MaxCompletionTokens
+	}
+	if maxTokens <= 0 {
+		maxTokens = anthropicDefaultMaxTokens
+	}
+
+	out := map[string]interface{}{
+		"model":      AnthropicModelName(in.Model),
+		"messages":   messages,
+		"max_tokens": maxTokens,
+	}
+	if len(systemParts) > 0 {
+		out["system"] = strings.Join(systemParts, "\n\n")
+	}
+	if in.Temperature != nil {
+		out["temperature"] = *in.Temperature
+	}
+	if in.TopP != nil {
+		out["top_p"] = *in.TopP
+	}
+	if in.TopK != nil {
+		out["top_k"] = *in.TopK
+	}
+	if in.Stream {
+		out["stream"] = true
+	}
+	if stops := anthropicStopSequences(in.Stop); len(stops) > 0 {
+		out["stop_sequences"] = stops
+	}
+	if in.User != "" {
+		out["metadata"] = map[string]interface{}{"user_id": in.User}
+	}
+
+	if len(in.Tools) > 0 {
+		tools := make([]map[string]interface{}, 0, len(in.Tools))
+		for _, t := range in.Tools {
+			schema := t.Function.Parameters
+			if len(schema) == 0 || string(schema) == "null" {
+				schema = json.RawMessage(`{"type":"object","properties":{}}`)
+			}
+			tool := map[string]interface{}{
+				"name":         t.Function.Name,
+				"input_schema": schema,
+			}
+			if t.Function.Description != "" {
+				tool["description"] = t.Function.Description
+			}
+			tools = append(tools, tool)
+		}
+		out["tools"] = tools
+	}
+	// tool_choice ohne tools lehnt Anthropic ab
+	if choice := anthropicToolChoice(in.ToolChoice, in.ParallelToolCalls); choice != nil && len(in.Tools) > 0 {
+		out["tool_choice"] = choice
+	}
+
+	return out, nil
+}
+
+// anthropicContentBlocks wandelt OpenAI-Content (String oder Vision-Array)
+// in Anthropic-Content-Blöcke um.
+func anthropicContentBlocks(raw json.RawMessage) []map[string]interface{} {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		if s == "" {
+			return nil
+		}
+		return []map[string]interface{}{{"type": "text", "text": s}}
+	}
+	var parts []openAIContentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return []map[string]interface{}{{"type": "text", "text": string(raw)}}
+	}
+	var blocks []map[string]interface{}
+	for _, p := range parts {
+		switch p.Type {
+		case "text":
+			if p.Text != "" {
+				blocks = append(blocks, map[string]interface{}{"type": "text", "text": p.Text})
+			}
+		case "image_url":
+			if src := anthropicImageSource(p.ImageURL.URL); src != nil {
+				blocks = append(blocks, map[string]interface{}{"type": "image", "source": src})
+			}
+		}
+	}
+	return blocks
+}
+
+// anthropicImageSource wandelt eine image_url (data:-URI oder http(s)-URL)
+// in eine Anthropic image.source um.
+func anthropicImageSource(url string) map[string]interface{} {
+	if strings.HasPrefix(url, "data:") {
+		// data:image/png;base64,<daten>
+		meta, payload, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+		if !ok {
+			return nil
+		}
+		mediaType := strings.TrimSuffix(meta, ";base64")
+		return map[string]interface{}{
+			"type":       "base64",
+			"media_type": mediaType,
+			"data":       payload,
+		}
+	}
+	if url == "" {
+		return nil
+	}
+	return map[string]interface{}{"type": "url", "url": url}
+}
+
+// anthropicStopSequences akzeptiert OpenAI "stop" als String oder Array.
+func anthropicStopSequences(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var one string
+	if err := json.Unmarshal(raw, &one); err == nil {
+		if one == "" {
+			return nil
+		}
+		return []string{one}
+	}
+	var many []string
+	json.Unmarshal(raw, &many)
+	return many
+}
+
+// anthropicToolChoice übersetzt OpenAI tool_choice ("auto", "none",
+// "required" oder {"type":"function","function":{"name":...}}).
+// parallel_tool_calls:false wird zu disable_parallel_tool_use.
+func anthropicToolChoice(raw json.RawMessage, parallel *bool) map[string]interface{} {
+	var choice map[string]interface{}
+	var mode string
+	if len(raw) > 0 && json.Unmarshal(raw, &mode) == nil {
+		switch mode {
+		case "auto":
+			choice = map[string]interface{}{"type": "auto"}
+		case "none":
+			choice = map[string]interface{}{"type": "none"}
+		case "required":
+			choice = map[string]interface{}{"type": "any"}
+		}
+	} else if len(raw) > 0 {
+		var named struct {
+			Function struct {
+				Name string `json:"name"`
+			} `json:"function"`
+		}
+		if json.Unmarshal(raw, &named) == nil && named.Function.Name != "" {
+			choice = map[string]interface{}{"type": "tool", "name": named.Function.Name}
+		}
+	}
+	if parallel != nil && !*parallel {
+		if choice == nil {
+			choice = map[string]interface{}{"type": "auto"}
+		}
+		if choice["type"] != "none" {
+			choice["disable_parallel_tool_use"] = true
+		}
+	}
+	return choice
+}
+
+// **********************************************************************
+// Anthropic → OpenAI Antwort
+
+// anthropicFinishReason bildet Anthropic stop_reason auf OpenAI finish_reason ab.
+func anthropicFinishReason(stopReason string) string {
+	switch stopReason {
+	case "end_turn", "stop_sequence", "pause_turn":
+		return "stop"
+	case "max_tokens", "model_context_window_exceeded":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	case "refusal":
+		return "content_filter"
+	default:
+		return stopReason
+	}
+}
+
+// FromAnthropicResponse wandelt eine Anthropic Messages-Antwort in ein
+// ChatResult um: Text-Blöcke werden verkettet, tool_use-Blöcke zu
+// OpenAI tool_calls (arguments als JSON-String).
+func FromAnthropicResponse(result map[string]interface{}) (*ChatResult, error) {
+	content, ok := result["content"].([]interface{})
+	if !ok {
+		return nil, NewError(ErrUnexpectedFormat, "Anthropic-Antwort ohne content", nil, nil)
+	}
+	res := &ChatResult{Usage: extractUsage(result, "anthropic")}
+	if sr, ok := result["stop_reason"].(string); ok {
+		res.FinishReason = anthropicFinishReason(sr)
+	}
+
+	var text strings.Builder
+	var calls []map[string]interface{}
+	for _, raw := range content {
+		block, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch block["type"] {
+		case "text":
+			if t, ok := block["text"].(string); ok {
+				text.WriteString(t)
+			}
+		case "tool_use":
+			args, _ := json.Marshal(block["input"])
+			if block["input"] == nil {
+				args = []byte("{}")
+			}
+			calls = append(calls, map[string]interface{}{
+				"id":   block["id"],
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":      block["name"],
+					"arguments": string(args),
+				},
+			})
+		}
+	}
+	res.Content = text.String()
+	if len(calls) > 0 {
+		res.ToolCalls, _ = json.Marshal(calls)
+	}
+	return res, nil
+}
+
+// anthropicErrorType bildet Anthropic error.type auf die Fehlerklassen ab.
+func anthropicErrorType(errType string) (string, int) {
+	switch errType {
+	case "rate_limit_error":
+		return ErrRateLimit, 429
+	case "authentication_error":
+		return ErrAuthFailed, 401
+	case "permission_error":
+		return ErrAuthFailed, 403
+	case "overloaded_error":
+		return ErrServerError, 529
+	case "api_error":
+		return ErrServerError, 500
+	case "timeout_error":
+		return ErrTimeout, 504
+	default:
+		return ErrClientError, 400
+	}
+}
+
+// **********************************************************************
+// Anthropic SSE → OpenAI chat.completion.chunk
+
+// AnthropicStreamTranslator übersetzt Anthropic-SSE-Events (message_start,
+// content_block_*, message_delta, message_stop) in OpenAI-Chunks.
+// Nicht thread-safe; ein Translator pro Stream.
+type AnthropicStreamTranslator struct {
+	model        string
+	id           string
+	created      int64
+	toolIndex    map[int]int // Content-Block-Index → tool_calls-Index
+	nextTool     int
+	inputTokens  int
+	outputTokens int
+}
+
+// NewAnthropicStreamTranslator erzeugt einen Translator; model erscheint
+// im "model"-Feld der erzeugten Chunks.
+func NewAnthropicStreamTranslator(model string) *AnthropicStreamTranslator {
+	return &AnthropicStreamTranslator{
+		model:     model,
+		id:        fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano()),
+		created:   time.Now().Unix(),
+		toolIndex: make(map[int]int),
+	}
+}
+
+// Translate verarbeitet den Inhalt einer "data: "-Zeile und liefert die
+// entsprechenden OpenAI-data-Payloads (JSON oder "[DONE]"). Ein
+// Anthropic-"error"-Event wird als APIError zurückgegeben.
+func (t *AnthropicStreamTranslator) Translate(data string) ([]string, error) {
+	var ev map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &ev); err != nil {
+		return nil, nil
+	}
+	switch ev["type"] {
+	case "message_start":
+		if msg, ok := ev["message"].(map[string]interface{}); ok {
+			if id, ok := msg["id"].(string); ok && id != "" {
+				t.id = id
+			}
+			if u := extractUsage(msg, "anthropic"); u != nil {
+				t.inputTokens = u.InputTokens
+				t.outputTokens = u.OutputTokens
+			}
+		}
+		return t.chunk(map[string]interface{}{"role": "assistant", "content": ""}, nil, nil), nil
+
+	case "content_block_start":
+		block, _ := ev["content_block"].(map[string]interface{})
+		if block["type"] != "tool_use" {
+			if text, ok := block["text"].(string); ok && text != "" {
+				return t.chunk(map[string]interface{}{"content": text}, nil, nil), nil
+			}
+			return nil, nil
+		}
+		idx := t.nextTool
+		t.nextTool++
+		t.toolIndex[intField(ev, "index")] = idx
+		return t.chunk(map[string]interface{}{"tool_calls": []map[string]interface{}{{
+			"index": idx,
+			"id":    block["id"],
+			"type":  "function",
+			"function": map[string]interface{}{
+				"name":      block["name"],
+				"arguments": "",
+			},
+		}}}, nil, nil), nil
+
+	case "content_block_delta":
+		delta, _ := ev["delta"].(map[string]interface{})
+		switch delta["type"] {
+		case "text_delta":
+			text, _ := delta["text"].(string)
+			return t.chunk(map[string]interface{}{"content": text}, nil, nil), nil
+		case "input_json_delta":
+			idx, ok := t.toolIndex[intField(ev, "index")]
+			if !ok {
+				return nil, nil
+			}
+			partial, _ := delta["partial_json"].(string)
+			return t.chunk(map[string]interface{}{"tool_calls": []map[string]interface{}{{
+				"index":    idx,
+				"function": map[string]interface{}{"arguments": partial},
+			}}}, nil, nil), nil
+		}
+		// thinking_delta, signature_delta: kein OpenAI-Gegenstück
+		return nil, nil
+
+	case "message_delta":
+		if u, ok := ev["usage"].(map[string]interface{}); ok {
+			if n := intField(u, "output_tokens"); n > 0 {
+				t.outputTokens = n
+			}
+		}
+		delta, _ := ev["delta"].(map[string]interface{})
+		sr, _ := delta["stop_reason"].(string)
+		if sr == "" {
+			return nil, nil
+		}
+		finish := anthropicFinishReason(sr)
+		usage := map[string]interface{}{
+			"prompt_tokens":     t.inputTokens,
+			"completion_tokens": t.outputTokens,
+			"total_tokens":      t.inputTokens + t.outputTokens,
+		}
+		return t.chunk(map[string]interface{}{}, &finish, usage), nil
+
+	case "message_stop":
+		return []string{"[DONE]"}, nil
+
+	case "error":
+		errObj, _ := ev["error"].(map[string]interface{})
+		errType, _ := errObj["type"].(string)
+		msg, _ := errObj["message"].(string)
+		code, status := anthropicErrorType(errType)
+		return nil, &APIError{Type: code, StatusCode: status, Message: msg}
+	}
+	// ping, content_block_stop
+	return nil, nil
+}
+
+// chunk baut ein chat.completion.chunk-Payload.
+func (t *AnthropicStreamTranslator) chunk(delta map[string]interface{}, finish *string, usage map[string]interface{}) []string {
+	choice := map[string]interface{}{"index": 0, "delta": delta, "finish_reason": nil}
+	if finish != nil {
+		choice["finish_reason"] = *finish
+	}
+	c := map[string]interface{}{
+		"id":      t.id,
+		"object":  "chat.completion.chunk",
+		"created": t.created,
+		"model":   t.model,
+		"choices": []interface{}{choice},
+	}
+	if usage != nil {
+		c["usage"] = usage
+	}
+	data, _ := json.Marshal(c)
+	return []string{string(data)}
+}
+
+// intField liest ein JSON-Zahlenfeld als int (0 wenn nicht vorhanden).
+func intField(m map[string]interface{}, key string) int {
+	if f, ok := m[key].(float64); ok {
+		return int(f)
+	}
+	return 0
+}
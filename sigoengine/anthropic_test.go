@@ -0,0 +1,276 @@
+package sigoengine
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestToAnthropicRequest(t *testing.T) {
+	request := map[string]interface{}{
+		"model": "anthropic/claude-sonnet-4-6",
+		"messages": []map[string]interface{}{
+			{"role": "system", "content": "Memory"},
+			{"role": "system", "content": "Prompt"},
+			{"role": "user", "content": []interface{}{
+				map[string]interface{}{"type": "text", "text": "Was ist das?"},
+				map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "data:image/png;base64,iVBORw0"}},
+			}},
+			Message{Role: "assistant", ToolCalls: json.RawMessage(`[{"id":"c1","type":"function","function":{"name":"a","arguments":"{\"x\":1}"}},{"id":"c2","type":"function","function":{"name":"b","arguments":""}}]`)}.ToMap(),
+			{"role": "tool", "tool_call_id": "c1", "content": "eins"},
+			{"role": "tool", "tool_call_id": "c2", "content": "zwei"},
+		},
+		"temperature":         0.5,
+		"stop":                "ENDE",
+		"tools":               json.RawMessage(`[{"type":"function","function":{"name":"a","description":"A","parameters":{"type":"object"}}}]`),
+		"tool_choice":         "required",
+		"parallel_tool_calls": false,
+	}
+	out, err := ToAnthropicRequest(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(out)
+	var got struct {
+		Model     string   `json:"model"`
+		System    string   `json:"system"`
+		MaxTokens int      `json:"max_tokens"`
+		Temp      float64  `json:"temperature"`
+		Stop      []string `json:"stop_sequences"`
+		Messages  []struct {
+			Role    string                   `json:"role"`
+			Content []map[string]interface{} `json:"content"`
+		} `json:"messages"`
+		Tools      []map[string]interface{} `json:"tools"`
+		ToolChoice map[string]interface{}   `json:"tool_choice"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Model != "claude-sonnet-4-6" {
+		t.Fatalf("prefix not stripped: %q", got.Model)
+	}
+	if got.System != "Memory\n\nPrompt" {
+		t.Fatalf("unexpected system: %q", got.System)
+	}
+	if got.MaxTokens != anthropicDefaultMaxTokens || got.Temp != 0.5 {
+		t.Fatalf("unexpected max_tokens/temperature: %d %v", got.MaxTokens, got.Temp)
+	}
+	if len(got.Stop) != 1 || got.Stop[0] != "ENDE" {
+		t.Fatalf("unexpected stop_sequences: %v", got.Stop)
+	}
+	if len(got.Messages) != 3 {
+		t.Fatalf("expected user/assistant/user, got %d messages: %s", len(got.Messages), data)
+	}
+	img := got.Messages[0].Content[1]
+	src, _ := img["source"].(map[string]interface{})
+	if img["type"] != "image" || src["media_type"] != "image/png" || src["data"] != "iVBORw0" {
+		t.Fatalf("unexpected image block: %v", img)
+	}
+	asst := got.Messages[1]
+	if asst.Role != "assistant" || len(asst.Content) != 2 || asst.Content[0]["type"] != "tool_use" {
+		t.Fatalf("unexpected assistant message: %+v", asst)
+	}
+	if in, _ := asst.Content[0]["input"].(map[string]interface{}); in["x"] != float64(1) {
+		t.Fatalf("unexpected tool_use input: %v", asst.Content[0]["input"])
+	}
+	results := got.Messages[2]
+	if results.Role != "user" || len(results.Content) != 2 || results.Content[1]["tool_use_id"] != "c2" {
+		t.Fatalf("tool results not merged into one user message: %+v", results)
+	}
+	if len(got.Tools) != 1 || got.Tools[0]["name"] != "a" || got.Tools[0]["input_schema"] == nil {
+		t.Fatalf("unexpected tools: %v", got.Tools)
+	}
+	if got.ToolChoice["type"] != "any" || got.ToolChoice["disable_parallel_tool_use"] != true {
+		t.Fatalf("unexpected tool_choice: %v", got.ToolChoice)
+	}
+}
+
+func TestFromAnthropicResponse(t *testing.T) {
+	body := `{
+		"id": "msg_1", "type": "message", "role": "assistant",
+		"content": [
+			{"type": "text", "text": "Ich schaue nach."},
+			{"type": "tool_use", "id": "toolu_1", "name": "get_weather", "input": {"city": "Berlin"}}
+		],
+		"stop_reason": "tool_use",
+		"usage": {"input_tokens": 10, "cache_read_input_tokens": 5, "output_tokens": 7}
+	}`
+	var result map[string]interface{}
+	json.Unmarshal([]byte(body), &result)
+
+	res, err := FromAnthropicResponse(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Content != "Ich schaue nach." || res.FinishReason != "tool_calls" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if res.Usage == nil || res.Usage.InputTokens != 15 || res.Usage.OutputTokens != 7 || res.Usage.TotalTokens != 22 {
+		t.Fatalf("unexpected usage: %+v", res.Usage)
+	}
+	var calls []openAIToolCall
+	if err := json.Unmarshal(res.ToolCalls, &calls); err != nil || len(calls) != 1 {
+		t.Fatalf("invalid tool_calls: %s", res.ToolCalls)
+	}
+	if calls[0].ID != "toolu_1" || calls[0].Function.Arguments != `{"city":"Berlin"}` {
+		t.Fatalf("unexpected tool call: %+v", calls[0])
+	}
+}
+
+func TestAnthropicStreamTranslator(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":12,"output_tokens":1}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"ping"}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hal"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"lo"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Berlin\"}"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":20}}`,
+		`{"type":"message_stop"}`,
+	}
+	tr := NewAnthropicStreamTranslator("a-cl46-s")
+	acc := NewStreamAccumulator()
+	var last []string
+	for _, ev := range events {
+		out, err := tr.Translate(ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, data := range out {
+			acc.AddData(data)
+		}
+		if len(out) > 0 {
+			last = out
+		}
+	}
+	if len(last) != 1 || last[0] != "[DONE]" {
+		t.Fatalf("expected [DONE] at end, got %v", last)
+	}
+	res := acc.Result()
+	if res.Content != "Hallo" {
+		t.Fatalf("expected Hallo, got %q", res.Content)
+	}
+	var calls []openAIToolCall
+	if err := json.Unmarshal(res.ToolCalls, &calls); err != nil || len(calls) != 1 {
+		t.Fatalf("invalid tool_calls: %s", res.ToolCalls)
+	}
+	if calls[0].ID != "toolu_1" || calls[0].Function.Arguments != `{"city":"Berlin"}` {
+		t.Fatalf("unexpected tool call: %+v", calls[0])
+	}
+}
+
+func TestAnthropicStreamTranslatorError(t *testing.T) {
+	tr := NewAnthropicStreamTranslator("m")
+	_, err := tr.Translate(`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.Type != ErrServerError || !apiErr.IsRetryable() {
+		t.Fatalf("expected retryable server error, got %v", err)
+	}
+}
+
+func TestCallAPIResultAnthropic(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "sk-ant" || r.Header.Get("anthropic-version") == "" {
+			t.Errorf("missing anthropic auth headers: %v", r.Header)
+		}
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("unexpected bearer auth for anthropic")
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["system"] != "Sei knapp." || body["model"] != "claude-haiku-4-5" {
+			t.Errorf("unexpected request body: %v", body)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"content":[{"type":"text","text":"Hallo"}],"stop_reason":"end_turn","usage":{"input_tokens":3,"output_tokens":2}}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &ProviderConfig{Endpoint: upstream.URL, Model: "anthropic/claude-haiku-4-5", APIKey: "sk-ant", Type: "anthropic"}
+	res, err := CallAPIResult(context.Background(), cfg, map[string]interface{}{
+		"model": cfg.Model,
+		"messages": []map[string]interface{}{
+			{"role": "system", "content": "Sei knapp."},
+			{"role": "user", "content": "Hi"},
+		},
+	}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Content != "Hallo" || res.FinishReason != "stop" || res.Usage.TotalTokens != 5 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+}
+
+func TestCallAPIStreamAnthropic(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["stream"] != true || body["system"] != "Sei knapp." {
+			t.Errorf("unexpected request body: %v", body)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: message_start\n" +
+			`data: {"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":9,"output_tokens":1}}}` + "\n\n" +
+			"event: content_block_delta\n" +
+			`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hallo"}}` + "\n\n" +
+			"event: message_delta\n" +
+			`data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":2}}` + "\n\n" +
+			"event: message_stop\n" +
+			`data: {"type":"message_stop"}` + "\n\n"))
+	}))
+	defer upstream.Close()
+
+	cfg := &ProviderConfig{Endpoint: upstream.URL, Model: "anthropic/claude-haiku-4-5", APIKey: "sk-ant", Type: "anthropic"}
+	stream, err := CallAPIStream(context.Background(), cfg, map[string]interface{}{
+		"model": cfg.Model,
+		"messages": []map[string]interface{}{
+			{"role": "system", "content": "Sei knapp."},
+			{"role": "user", "content": "Hi"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	raw, _ := io.ReadAll(stream)
+
+	acc := NewStreamAccumulator()
+	var last string
+	for _, line := range strings.Split(string(raw), "\n") {
+		if strings.HasPrefix(line, "event:") {
+			t.Fatalf("native event line leaked: %q", line)
+		}
+		if strings.HasPrefix(line, "data: ") {
+			last = strings.TrimPrefix(line, "data: ")
+			acc.AddData(last)
+		}
+	}
+	res := acc.Result()
+	if res.Content != "Hallo" || res.FinishReason != "stop" || last != "[DONE]" {
+		t.Fatalf("unexpected translated stream: %+v\n%s", res, raw)
+	}
+	if res.Usage == nil || res.Usage.InputTokens != 9 || res.Usage.OutputTokens != 2 {
+		t.Fatalf("unexpected usage: %+v", res.Usage)
+	}
+}
+
+func TestTranslateStreamError(t *testing.T) {
+	src := io.NopCloser(strings.NewReader("event: error\n" +
+		`data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}` + "\n\n"))
+	raw, _ := io.ReadAll(TranslateStream(src, NewAnthropicStreamTranslator("m")))
+	data := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(raw)), "data:"))
+	apiErr, ok := StreamChunkError(data).(*APIError)
+	if !ok || apiErr.Type != ErrServerError || apiErr.Message != "Overloaded" {
+		t.Fatalf("expected retryable error chunk, got %q", raw)
+	}
+}
@@ -0,0 +1,160 @@
+//**********************************************************************
+//      sigoengine/capabilities.go
+//**********************************************************************
+//  Beschreibung: Capability-Flags der Modelle (Bilder, Tools, JSON-Mode,
+//  Reasoning, Streaming). Quellen: Mammouth /public/models, statische
+//  Tabellen (Moonshot, ZAI), models.csv/models.json. nil = unbekannt
+//  (keine Prüfung), sonst die vollständige Liste der Features.
+//**********************************************************************
+
+package sigoengine
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Capability-Namen (models.csv, /api/models, /v1/models).
+const (
+	CapVision    = "vision"    // Bilder in Messages
+	CapTools     = "tools"     // Function-Calling
+	CapJSONMode  = "json_mode" // response_format json_object/json_schema
+	CapReasoning = "reasoning" // reasoning_effort, thinking
+	CapStreaming = "streaming" // stream: true
+)
+
+// AllCapabilities sind alle bekannten Capabilities.
+var AllCapabilities = []string{CapVision, CapTools, CapJSONMode, CapReasoning, CapStreaming}
+
+// capabilityAliases bildet Schreibweisen der Provider auf die Namen ab.
+var capabilityAliases = map[string]string{
+	"vision":             CapVision,
+	"image":              CapVision,
+	"images":             CapVision,
+	"image_input":        CapVision,
+	"tools":              CapTools,
+	"tool_use":           CapTools,
+	"tool_calling":       CapTools,
+	"function_calling":   CapTools,
+	"json_mode":          CapJSONMode,
+	"json":               CapJSONMode,
+	"response_format":    CapJSONMode,
+	"response_schema":    CapJSONMode,
+	"structured_outputs": CapJSONMode,
+	"reasoning":          CapReasoning,
+	"thinking":           CapReasoning,
+	"streaming":          CapStreaming,
+	"stream":             CapStreaming,
+}
+
+// NormalizeCapability liefert den Capability-Namen einer Schreibweise.
+func NormalizeCapability(name string) (string, bool) {
+	c, ok := capabilityAliases[strings.ToLower(strings.TrimSpace(name))]
+	return c, ok
+}
+
+// ParseCapabilities liest eine Liste wie "vision,tools,streaming"
+// (Trenner "," oder "|"). "none" ergibt eine leere Liste, unbekannte
+// Namen landen in unknown.
+func ParseCapabilities(s string) (caps []string, unknown []string) {
+	caps = []string{}
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '|' }) {
+		field = strings.TrimSpace(field)
+		if field == "" || strings.EqualFold(field, "none") {
+			continue
+		}
+		if c, ok := NormalizeCapability(field); ok {
+			caps = addCapability(caps, c)
+		} else {
+			unknown = append(unknown, field)
+		}
+	}
+	return caps, unknown
+}
+
+// Supports meldet, ob das Modell ein Feature kann (unbekannt = ja).
+func (m Model) Supports(capability string) bool {
+	return HasCapability(m.Capabilities, capability)
+}
+
+// HasCapability meldet, ob caps ein Feature enthält (nil = unbekannt = ja).
+func HasCapability(caps []string, capability string) bool {
+	if caps == nil {
+		return true
+	}
+	for _, c := range caps {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// MissingCapabilities liefert die Features aus required, die caps fehlen.
+func MissingCapabilities(caps, required []string) []string {
+	var missing []string
+	for _, c := range required {
+		if !HasCapability(caps, c) {
+			missing = append(missing, c)
+		}
+	}
+	return missing
+}
+
+// addCapability hängt c an, falls noch nicht enthalten.
+func addCapability(caps []string, c string) []string {
+	for _, have := range caps {
+		if have == c {
+			return caps
+		}
+	}
+	return append(caps, c)
+}
+
+// capabilitiesFromFlags baut die Liste aus expliziten Provider-Angaben.
+// Ohne jede Angabe bleibt sie nil (unbekannt); Streaming gilt bei
+// Chat-Modellen als vorhanden, solange es nicht ausdrücklich fehlt.
+func capabilitiesFromFlags(flags map[string]bool) []string {
+	if len(flags) == 0 {
+		return nil
+	}
+	if _, ok := flags[CapStreaming]; !ok {
+		flags[CapStreaming] = true
+	}
+	caps := []string{}
+	for _, c := range AllCapabilities {
+		if flags[c] {
+			caps = append(caps, c)
+		}
+	}
+	return caps
+}
+
+// parseCapabilityValue liest ein "capabilities"-Feld im Provider-Format:
+// Liste von Namen (["vision","tools"]) oder Objekt ({"vision":true}).
+func parseCapabilityValue(raw json.RawMessage, flags map[string]bool) {
+	if len(raw) == 0 {
+		return
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		for _, name := range list {
+			if c, ok := NormalizeCapability(name); ok {
+				flags[c] = true
+			}
+		}
+		return
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(raw, &obj); err == nil {
+		for name, v := range obj {
+			c, ok := NormalizeCapability(strings.TrimPrefix(name, "supports_"))
+			if !ok {
+				continue
+			}
+			if b, isBool := v.(bool); isBool {
+				flags[c] = flags[c] || b
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package sigoengine
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseCapabilities(t *testing.T) {
+	caps, unknown := ParseCapabilities("Vision, function_calling|json|teleport")
+	if !reflect.DeepEqual(caps, []string{CapVision, CapTools, CapJSONMode}) || !reflect.DeepEqual(unknown, []string{"teleport"}) {
+		t.Fatalf("ParseCapabilities = %v, %v", caps, unknown)
+	}
+	if caps, _ := ParseCapabilities("none"); caps == nil || len(caps) != 0 {
+		t.Fatalf("none: erwartet leere Liste, got %#v", caps)
+	}
+}
+
+func TestMissingCapabilities(t *testing.T) {
+	required := []string{CapVision, CapStreaming}
+	if missing := MissingCapabilities(nil, required); missing != nil {
+		t.Errorf("unbekannte Capabilities: erwartet keine Prüfung, got %v", missing)
+	}
+	if missing := MissingCapabilities([]string{CapStreaming}, required); !reflect.DeepEqual(missing, []string{CapVision}) {
+		t.Errorf("MissingCapabilities = %v", missing)
+	}
+	if (Model{Capabilities: []string{}}).Supports(CapStreaming) {
+		t.Error("leere Liste: Streaming darf nicht unterstützt sein")
+	}
+}
+
+func TestMammouthCapabilities(t *testing.T) {
+	raw := json.RawMessage(`[
+		{"id":"gpt-4.1","capabilities":["vision","function_calling","json"]},
+		{"id":"deepseek-r1","supports_reasoning":true,"supports_function_calling":false},
+		{"id":"mistral-ocr","input_modalities":["text","image"],"capabilities":{"supports_streaming":false}},
+		{"id":"plain"}
+	]`)
+	models, err := parseMammouthResponse(raw)
+	if err != nil || len(models) != 4 {
+		t.Fatalf("parseMammouthResponse: %v, %d Modelle", err, len(models))
+	}
+	want := [][]string{
+		{CapVision, CapTools, CapJSONMode, CapStreaming},
+		{CapReasoning, CapStreaming},
+		{CapVision},
+		nil,
+	}
+	for i, m := range models {
+		if !reflect.DeepEqual(m.Capabilities, want[i]) {
+			t.Errorf("%s: Capabilities = %#v, want %#v", m.ID, m.Capabilities, want[i])
+		}
+	}
+}
+
+func TestParseCSVRecordCapabilities(t *testing.T) {
+	m, err := parseCSVRecord([]string{"m", "m", "http://x", "", "", "", "", "", "", "", "", "", "", "tools,streaming"})
+	if err != nil || !reflect.DeepEqual(m.Capabilities, []string{CapTools, CapStreaming}) {
+		t.Fatalf("parseCSVRecord: %v, %v", m.Capabilities, err)
+	}
+	m, _ = parseCSVRecord([]string{"m", "m", "http://x"})
+	if m.Capabilities != nil {
+		t.Fatalf("ohne Spalte: erwartet nil, got %v", m.Capabilities)
+	}
+}
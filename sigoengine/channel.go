@@ -21,7 +21,8 @@ import (
 type Channel struct {
 	Provider          string    `json:"provider"`
 	Name              string    `json:"name"`
-	APIKey            string    `json:"-"` // nie serialisieren
+	APIKey            string    `json:"-"`                  // nie serialisieren
+	BaseURL           string    `json:"base_url,omitempty"` // Host des Kanals (Ollama), leer → Modell-Endpoint
 	Active            bool      `json:"active"`
 	Order             int       `json:"order"`
 	Healthy           bool      `json:"healthy"`
@@ -29,8 +30,13 @@ type Channel struct {
 	LastError         string    `json:"last_error,omitempty"`
 	ConsecutiveErrors int       `json:"consecutive_errors"`
 	// Rate-Limit-Config pro Kanal (0 → Server-Default greift).
-	MinInterval int `json:"min_interval_ms,omitempty"` // Mindest-Abstand zwischen Calls (ms)
-	MaxWait     int `json:"max_wait_ms,omitempty"`     // max Queue-Wartezeit bis 429 (ms)
+	MinInterval   int `json:"min_interval_ms,omitempty"` // Mindest-Abstand zwischen Calls (ms)
+	MaxWait       int `json:"max_wait_ms,omitempty"`     // max Queue-Wartezeit bis 429 (ms)
+	MaxConcurrent int `json:"max_concurrent,omitempty"`  // max parallele Calls (0 → unbegrenzt)
+	RPM           int `json:"rpm,omitempty"`             // Requests pro Minute, Token-Bucket (0 → unbegrenzt)
+	TPM           int `json:"tpm,omitempty"`             // Tokens pro Minute, Token-Bucket (0 → unbegrenzt)
+	Burst         int `json:"burst,omitempty"`           // Kapazität des RPM-Buckets (0 → rpm)
+	Weight        int `json:"weight,omitempty"`          // Gewicht für Strategie "weighted" (0 → 1)
 }
 
 // FullName returns the canonical channel identifier, e.g. "mammouth-0".
@@ -40,16 +46,20 @@ func (c *Channel) FullName() string {
 
 // ChannelRegistry hält alle bekannten Kanäle pro Provider.
 type ChannelRegistry struct {
-	mu        sync.RWMutex
-	channels  map[string][]*Channel // provider → sorted channels
-	statePath string                // path to channels.json
+	mu         sync.RWMutex
+	channels   map[string][]*Channel // provider → sorted channels
+	selections map[string]string     // provider → Auswahl-Strategie (leer → ordered)
+	stats      *channelStatsStore    // rollierende Latenz/Fehler (channel_stats.go)
+	statePath  string                // path to channels.json
 }
 
 // NewChannelRegistry creates an empty registry.
 func NewChannelRegistry(statePath string) *ChannelRegistry {
 	return &ChannelRegistry{
-		channels:  make(map[string][]*Channel),
-		statePath: statePath,
+		channels:   make(map[string][]*Channel),
+		selections: make(map[string]string),
+		stats:      newChannelStatsStore(),
+		statePath:  statePath,
 	}
 }
 
@@ -165,25 +175,21 @@ func (r *ChannelRegistry) MarkChannelHealth(provider, name string, healthy bool,
 	}
 }
 
-// knownProviders maps the base API-key env var names to provider names.
-var knownProviders = []struct {
-	EnvVar   string
-	Provider string
-}{
-	{"MAMMOUTH_API_KEY", "mammouth"},
-	{"MOONSHOT_API_KEY", "moonshot"},
-	{"ZAI_API_KEY", "zai"},
-}
-
 // DiscoverFromEnv scans environment variables for provider API keys.
 // It always registers the default channel (unindexed key) and any indexed
-// channels (PROVIDER_API_KEY_0, _1, ...) that are set.
+// channels (PROVIDER_API_KEY_0, _1, ...) that are set. Providers and their
+// env prefixes come from the provider registry (see provider.go).
 func (r *ChannelRegistry) DiscoverFromEnv() {
-	for _, p := range knownProviders {
-		// Default channel
-		if key := GetEnvWithFile(p.EnvVar); key != "" {
+	for _, p := range Providers() {
+		// Providers without a key prefix bring their own channels (Ollama hosts)
+		if p.EnvPrefix() == "" {
+			continue
+		}
+		envVar := p.EnvPrefix() + "_API_KEY"
+		// Default channel (keyless providers get one without a key)
+		if key := GetEnvWithFile(envVar); key != "" || keyOptional(p.Name()) {
 			r.AddChannel(&Channel{
-				Provider: p.Provider,
+				Provider: p.Name(),
 				Name:     "default",
 				APIKey:   key,
 				Active:   true,
@@ -196,14 +202,14 @@ func (r *ChannelRegistry) DiscoverFromEnv() {
 		// is set, so gaps like _0 + _2 without _1 still work.
 		const maxIndexedChannels = 100
 		for i := 0; i < maxIndexedChannels; i++ {
-			envName := fmt.Sprintf("%s_%d", p.EnvVar, i)
+			envName := fmt.Sprintf("%s_%d", envVar, i)
 			key := GetEnvWithFile(envName)
 			if key == "" {
 				continue
 			}
 			name := fmt.Sprintf("%d", i)
 			r.AddChannel(&Channel{
-				Provider: p.Provider,
+				Provider: p.Name(),
 				Name:     name,
 				APIKey:   key,
 				Active:   false,
@@ -214,13 +220,22 @@ func (r *ChannelRegistry) DiscoverFromEnv() {
 	}
 }
 
+// persistedChannel is the on-disk shape of one channel in channels.json.
+type persistedChannel struct {
+	Active        bool `json:"active"`
+	MinInterval   int  `json:"min_interval_ms,omitempty"`
+	MaxWait       int  `json:"max_wait_ms,omitempty"`
+	MaxConcurrent int  `json:"max_concurrent,omitempty"`
+	RPM           int  `json:"rpm,omitempty"`
+	TPM           int  `json:"tpm,omitempty"`
+	Burst         int  `json:"burst,omitempty"`
+	Weight        int  `json:"weight,omitempty"`
+}
+
 // persistedState is the on-disk shape of channels.json.
 type persistedState struct {
-	Providers map[string]map[string]struct {
-		Active      bool `json:"active"`
-		MinInterval int  `json:"min_interval_ms,omitempty"`
-		MaxWait     int  `json:"max_wait_ms,omitempty"`
-	} `json:"providers"`
+	Providers map[string]map[string]persistedChannel `json:"providers"`
+	Selection map[string]string                      `json:"selection,omitempty"` // provider → Strategie
 }
 
 // LoadState reads channels.json and applies saved active flags.
@@ -253,6 +268,11 @@ func (r *ChannelRegistry) LoadState() error {
 					ch.Active = cfg.Active
 					ch.MinInterval = cfg.MinInterval
 					ch.MaxWait = cfg.MaxWait
+					ch.MaxConcurrent = cfg.MaxConcurrent
+					ch.RPM = cfg.RPM
+					ch.TPM = cfg.TPM
+					ch.Burst = cfg.Burst
+					ch.Weight = cfg.Weight
 					if !ch.Active {
 						ch.Healthy = false
 					}
@@ -260,6 +280,15 @@ func (r *ChannelRegistry) LoadState() error {
 			}
 		}
 	}
+	for provider, strategy := range state.Selection {
+		if !validSelection(strategy) {
+			LogWarn("Unbekannte Kanal-Strategie, nutze ordered", map[string]interface{}{
+				"provider": provider, "selection": strategy,
+			})
+			continue
+		}
+		r.selections[provider] = strategy
+	}
 	return nil
 }
 
@@ -274,26 +303,29 @@ func (r *ChannelRegistry) saveStateLocked() error {
 	if r.statePath == "" {
 		return nil
 	}
-	state := persistedState{Providers: make(map[string]map[string]struct {
-		Active      bool `json:"active"`
-		MinInterval int  `json:"min_interval_ms,omitempty"`
-		MaxWait     int  `json:"max_wait_ms,omitempty"`
-	})}
+	state := persistedState{
+		Providers: make(map[string]map[string]persistedChannel),
+		Selection: make(map[string]string),
+	}
 	for provider, list := range r.channels {
-		m := make(map[string]struct {
-			Active      bool `json:"active"`
-			MinInterval int  `json:"min_interval_ms,omitempty"`
-			MaxWait     int  `json:"max_wait_ms,omitempty"`
-		})
+		m := make(map[string]persistedChannel)
 		for _, ch := range list {
-			m[ch.Name] = struct {
-				Active      bool `json:"active"`
-				MinInterval int  `json:"min_interval_ms,omitempty"`
-				MaxWait     int  `json:"max_wait_ms,omitempty"`
-			}{Active: ch.Active, MinInterval: ch.MinInterval, MaxWait: ch.MaxWait}
+			m[ch.Name] = persistedChannel{
+				Active:        ch.Active,
+				MinInterval:   ch.MinInterval,
+				MaxWait:       ch.MaxWait,
+				MaxConcurrent: ch.MaxConcurrent,
+				RPM:           ch.RPM,
+				TPM:           ch.TPM,
+				Burst:         ch.Burst,
+				Weight:        ch.Weight,
+			}
 		}
 		state.Providers[provider] = m
 	}
+	for provider, strategy := range r.selections {
+		state.Selection[provider] = strategy
+	}
 
 	data, err := json.MarshalIndent(state, "", "  ")
 	if err != nil {
@@ -89,7 +89,7 @@ func checkChannel(ch *Channel, registry *ChannelRegistry) bool {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	health := ProbeProviderModelList(ctx, ch.Provider, ch.APIKey)
+	health := ProbeChannel(ctx, ch)
 	registry.MarkChannelHealth(ch.Provider, ch.Name, health.Status == "available", health.Error)
 
 	if health.Status == "auth_failed" {
@@ -6,17 +6,35 @@
 
 package sigoengine
 
+import (
+	"sync"
+	"time"
+)
+
 // ErrChannelInactive signals that a specifically requested channel is not active.
 const ErrChannelInactive = "CHANNEL_INACTIVE"
 
 // ChannelManager wraps a registry and provides resolution/failover helpers.
 type ChannelManager struct {
 	registry *ChannelRegistry
+
+	// Laufzeit-Zustand der Auswahl-Strategien (siehe channel_selection.go)
+	mu       sync.Mutex
+	rrNext   map[string]int       // provider → nächste Round-Robin-Position
+	wrrScore map[string]int       // FullName → aktueller Smooth-WRR-Wert
+	inFlight map[string]int       // FullName → laufende Upstream-Requests
+	lastUsed map[string]time.Time // FullName → letzte Auswahl/Nutzung
 }
 
 // NewChannelManager creates a manager for the given registry.
 func NewChannelManager(registry *ChannelRegistry) *ChannelManager {
-	return &ChannelManager{registry: registry}
+	return &ChannelManager{
+		registry: registry,
+		rrNext:   make(map[string]int),
+		wrrScore: make(map[string]int),
+		inFlight: make(map[string]int),
+		lastUsed: make(map[string]time.Time),
+	}
 }
 
 // Registry returns the underlying registry.
@@ -25,10 +43,17 @@ func (m *ChannelManager) Registry() *ChannelRegistry {
 }
 
 // Resolve picks a channel for a provider.
-// If requested is empty, returns the first active channel in order.
+// If requested is empty, the provider's selection strategy picks one of the
+// active channels (default "ordered": the first active channel in order).
 // If requested is a full name like "mammouth-0", resolves via FullName.
 // Otherwise treats requested as the channel name within the provider.
 func (m *ChannelManager) Resolve(provider, requested string) (*Channel, error) {
+	return m.ResolveModel(provider, "", requested)
+}
+
+// ResolveModel works like Resolve; model (optional) lets the "fastest"
+// strategy use the model#channel statistics instead of the channel's.
+func (m *ChannelManager) ResolveModel(provider, model, requested string) (*Channel, error) {
 	if requested != "" {
 		// Try full name first, e.g. "mammouth-0"
 		if ch, ok := m.registry.GetChannelByFullName(requested); ok {
@@ -55,10 +80,8 @@ func (m *ChannelManager) Resolve(provider, requested string) (*Channel, error) {
 		return ch, nil
 	}
 
-	for _, ch := range m.registry.Channels(provider) {
-		if ch.Active {
-			return ch, nil
-		}
+	if ch := m.pick(provider, model); ch != nil {
+		return ch, nil
 	}
 	return nil, NewError(ErrConfigNotFound, "no active channel for provider", nil,
 		map[string]interface{}{"provider": provider})
@@ -84,10 +107,39 @@ func (m *ChannelManager) NextActive(provider string, after *Channel) (*Channel,
 	return nil, false
 }
 
+// FailoverChain returns start followed by the other active channels in
+// order. With the "ordered" strategy only channels after start follow (as
+// NextActive); other strategies wrap around, so a channel picked in the
+// middle still fails over to every active channel.
+func (m *ChannelManager) FailoverChain(provider string, start *Channel) []*Channel {
+	chain := []*Channel{start}
+	var before []*Channel
+	passed := false
+	for _, ch := range m.registry.Channels(provider) {
+		if ch.Name == start.Name {
+			passed = true
+			continue
+		}
+		if !ch.Active {
+			continue
+		}
+		if passed {
+			chain = append(chain, ch)
+		} else {
+			before = append(before, ch)
+		}
+	}
+	if m.registry.Selection(provider) != SelectionOrdered {
+		chain = append(chain, before...)
+	}
+	return chain
+}
+
 // AllChannelStatus returns a snapshot of every known channel.
 func (m *ChannelManager) AllChannelStatus() []map[string]interface{} {
 	var result []map[string]interface{}
 	for _, provider := range m.registry.AllProviders() {
+		selection := m.registry.Selection(provider)
 		for _, ch := range m.registry.Channels(provider) {
 			result = append(result, map[string]interface{}{
 				"provider":           ch.Provider,
@@ -100,6 +152,16 @@ func (m *ChannelManager) AllChannelStatus() []map[string]interface{} {
 				"consecutive_errors": ch.ConsecutiveErrors,
 				"min_interval_ms":    ch.MinInterval,
 				"max_wait_ms":        ch.MaxWait,
+				"max_concurrent":     ch.MaxConcurrent,
+				"rpm":                ch.RPM,
+				"tpm":                ch.TPM,
+				"burst":              ch.Burst,
+				"base_url":           ch.BaseURL,
+				"selection":          selection,
+				"weight":             ch.Weight,
+				"in_flight":          m.InFlight(ch),
+				"stats":              m.registry.Stats(ch),
+				"model_stats":        m.registry.ModelStatsByChannel(ch),
 			})
 		}
 	}
@@ -0,0 +1,182 @@
+//**********************************************************************
+//      sigoengine/channel_selection.go
+//**********************************************************************
+//  Beschreibung: Auswahl-Strategien für Kanäle ohne expliziten Kanal im
+//  Request. Pro Provider in channels.json ("selection") konfigurierbar:
+//  ordered (Default, erster aktiver Kanal), round_robin, weighted
+//  (Smooth Weighted Round-Robin über Channel.Weight),
+//  least_recently_used, least_in_flight und fastest (p50-Latenz und
+//  Fehlerquote aus channel_stats.go). Die Strategie wählt nur den
+//  Startkanal; der Failover läuft danach über FailoverChain.
+//**********************************************************************
+
+package sigoengine
+
+import (
+	"time"
+)
+
+// Auswahl-Strategien (Werte in channels.json "selection").
+const (
+	SelectionOrdered           = "ordered"
+	SelectionRoundRobin        = "round_robin"
+	SelectionWeighted          = "weighted"
+	SelectionLeastRecentlyUsed = "least_recently_used"
+	SelectionLeastInFlight     = "least_in_flight"
+	SelectionFastest           = "fastest"
+)
+
+// validSelection prüft, ob strategy eine bekannte Auswahl-Strategie ist.
+func validSelection(strategy string) bool {
+	switch strategy {
+	case SelectionOrdered, SelectionRoundRobin, SelectionWeighted,
+		SelectionLeastRecentlyUsed, SelectionLeastInFlight, SelectionFastest:
+		return true
+	}
+	return false
+}
+
+// Selection returns the selection strategy of a provider ("ordered" if unset).
+func (r *ChannelRegistry) Selection(provider string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if strategy := r.selections[provider]; strategy != "" {
+		return strategy
+	}
+	return SelectionOrdered
+}
+
+// SetSelection sets the selection strategy of a provider and persists state.
+func (r *ChannelRegistry) SetSelection(provider, strategy string) error {
+	if !validSelection(strategy) {
+		return NewError(ErrConfigNotFound, "unknown channel selection strategy", nil,
+			map[string]interface{}{"provider": provider, "selection": strategy})
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if strategy == SelectionOrdered {
+		delete(r.selections, provider)
+	} else {
+		r.selections[provider] = strategy
+	}
+	return r.saveStateLocked()
+}
+
+// pick wählt per Strategie des Providers einen aktiven Kanal (nil wenn
+// keiner aktiv ist). Bei Gleichstand gewinnt die Kanal-Reihenfolge.
+// model (optional) bevorzugt bei "fastest" die Modell#Kanal-Statistik.
+func (m *ChannelManager) pick(provider, model string) *Channel {
+	var active []*Channel
+	for _, ch := range m.registry.Channels(provider) {
+		if ch.Active {
+			active = append(active, ch)
+		}
+	}
+	if len(active) == 0 {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var picked *Channel
+	switch m.registry.Selection(provider) {
+	case SelectionRoundRobin:
+		picked = active[m.rrNext[provider]%len(active)]
+		m.rrNext[provider] = (m.rrNext[provider] + 1) % len(active)
+
+	case SelectionWeighted:
+		// Smooth Weighted Round-Robin (wie nginx): jeder Kanal sammelt sein
+		// Gewicht, der höchste gewinnt und gibt die Summe ab. Verteilt
+		// gleichmäßig statt in Blöcken (Gewicht 3:1 → a a b a, nicht a a a b).
+		total := 0
+		for _, ch := range active {
+			weight := m.effectiveWeight(ch)
+			total += weight
+			m.wrrScore[ch.FullName()] += weight
+			if picked == nil || m.wrrScore[ch.FullName()] > m.wrrScore[picked.FullName()] {
+				picked = ch
+			}
+		}
+		m.wrrScore[picked.FullName()] -= total
+
+	case SelectionLeastRecentlyUsed:
+		for _, ch := range active {
+			if picked == nil || m.lastUsed[ch.FullName()].Before(m.lastUsed[picked.FullName()]) {
+				picked = ch
+			}
+		}
+
+	case SelectionLeastInFlight:
+		// Gleichstand (z.B. alle idle) → am längsten ungenutzter Kanal,
+		// damit sich auch geringe Last über die Keys verteilt.
+		for _, ch := range active {
+			if picked == nil {
+				picked = ch
+				continue
+			}
+			n, best := m.inFlight[ch.FullName()], m.inFlight[picked.FullName()]
+			if n < best || (n == best && m.lastUsed[ch.FullName()].Before(m.lastUsed[picked.FullName()])) {
+				picked = ch
+			}
+		}
+
+	case SelectionFastest:
+		var best float64
+		for _, ch := range active {
+			score := m.registry.RoutingScore(model, ch)
+			if picked == nil || score < best ||
+				(score == best && m.lastUsed[ch.FullName()].Before(m.lastUsed[picked.FullName()])) {
+				picked, best = ch, score
+			}
+		}
+
+	default:
+		picked = active[0]
+	}
+
+	// Auswahl zählt als Nutzung: parallele Requests vor dem ersten
+	// BeginRequest landen sonst alle auf demselben Kanal.
+	m.lastUsed[picked.FullName()] = time.Now()
+	return picked
+}
+
+// effectiveWeight liefert das Gewicht eines Kanals für "weighted"
+// (Weight, Default 1, in Hundertsteln), abgesenkt um die Fehlerquote im
+// Statistik-Fenster: ein Kanal mit 50% Fehlern bekommt nur noch die
+// Hälfte seines Anteils, fällt aber nie ganz heraus.
+func (m *ChannelManager) effectiveWeight(ch *Channel) int {
+	weight := 1
+	if ch.Weight > 0 {
+		weight = ch.Weight
+	}
+	effective := int(float64(weight*100) * (1 - m.registry.Stats(ch).ErrorRate))
+	if effective < 1 {
+		effective = 1
+	}
+	return effective
+}
+
+// BeginRequest marks an upstream request on the channel as running (for
+// least_in_flight and least_recently_used). The returned func ends it.
+func (m *ChannelManager) BeginRequest(ch *Channel) func() {
+	key := ch.FullName()
+	m.mu.Lock()
+	m.inFlight[key]++
+	m.lastUsed[key] = time.Now()
+	m.mu.Unlock()
+	return func() {
+		m.mu.Lock()
+		if m.inFlight[key] > 0 {
+			m.inFlight[key]--
+		}
+		m.mu.Unlock()
+	}
+}
+
+// InFlight returns the number of running upstream requests on the channel.
+func (m *ChannelManager) InFlight(ch *Channel) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.inFlight[ch.FullName()]
+}
@@ -0,0 +1,126 @@
+//**********************************************************************
+//      sigoengine/channel_selection_test.go
+//**********************************************************************
+
+package sigoengine
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// selectionTestManager baut einen Manager mit drei aktiven zai-Kanälen a, b, c.
+func selectionTestManager(t *testing.T, strategy string) *ChannelManager {
+	t.Helper()
+	reg := NewChannelRegistry("")
+	for i, name := range []string{"a", "b", "c"} {
+		reg.AddChannel(&Channel{Provider: "zai", Name: name, APIKey: "k-" + name, Active: true, Order: i})
+	}
+	reg.AddChannel(&Channel{Provider: "zai", Name: "off", Active: false, Order: 9})
+	if err := reg.SetSelection("zai", strategy); err != nil {
+		t.Fatalf("SetSelection: %v", err)
+	}
+	return NewChannelManager(reg)
+}
+
+// resolveNames löst n-mal ohne expliziten Kanal auf und liefert die Namen.
+func resolveNames(t *testing.T, mgr *ChannelManager, n int) string {
+	t.Helper()
+	var names []string
+	for i := 0; i < n; i++ {
+		ch, err := mgr.Resolve("zai", "")
+		if err != nil {
+			t.Fatalf("Resolve: %v", err)
+		}
+		names = append(names, ch.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestChannelSelection_Ordered(t *testing.T) {
+	mgr := selectionTestManager(t, SelectionOrdered)
+	if got := resolveNames(t, mgr, 3); got != "a a a" {
+		t.Errorf("ordered = %q", got)
+	}
+	chain := mgr.FailoverChain("zai", mgr.registry.channels["zai"][1])
+	if len(chain) != 2 || chain[0].Name != "b" || chain[1].Name != "c" {
+		t.Errorf("ordered failover should not wrap: %v", chain)
+	}
+}
+
+func TestChannelSelection_RoundRobin(t *testing.T) {
+	mgr := selectionTestManager(t, SelectionRoundRobin)
+	if got := resolveNames(t, mgr, 4); got != "a b c a" {
+		t.Errorf("round_robin = %q", got)
+	}
+	ch, _ := mgr.registry.GetChannel("zai", "b")
+	var names []string
+	for _, c := range mgr.FailoverChain("zai", ch) {
+		names = append(names, c.Name)
+	}
+	if strings.Join(names, " ") != "b c a" {
+		t.Errorf("failover chain should wrap: %v", names)
+	}
+}
+
+func TestChannelSelection_Weighted(t *testing.T) {
+	mgr := selectionTestManager(t, SelectionWeighted)
+	a, _ := mgr.registry.GetChannel("zai", "a")
+	a.Weight = 3
+	// a=3, b=1, c=1 → 5 Auswahlen: a dreimal, verteilt statt am Block
+	if got := resolveNames(t, mgr, 5); got != "a b a c a" {
+		t.Errorf("weighted = %q", got)
+	}
+}
+
+func TestChannelSelection_LeastRecentlyUsed(t *testing.T) {
+	mgr := selectionTestManager(t, SelectionLeastRecentlyUsed)
+	b, _ := mgr.registry.GetChannel("zai", "b")
+	mgr.BeginRequest(b)()
+	// a und c nie benutzt → a (Reihenfolge), dann c, dann b
+	if got := resolveNames(t, mgr, 3); got != "a c b" {
+		t.Errorf("least_recently_used = %q", got)
+	}
+}
+
+func TestChannelSelection_LeastInFlight(t *testing.T) {
+	mgr := selectionTestManager(t, SelectionLeastInFlight)
+	a, _ := mgr.registry.GetChannel("zai", "a")
+	b, _ := mgr.registry.GetChannel("zai", "b")
+	doneA := mgr.BeginRequest(a)
+	mgr.BeginRequest(a)
+	mgr.BeginRequest(b)
+	if got := resolveNames(t, mgr, 1); got != "c" {
+		t.Errorf("least_in_flight = %q, want c", got)
+	}
+	doneA()
+	if mgr.InFlight(a) != 1 {
+		t.Errorf("InFlight(a) = %d, want 1", mgr.InFlight(a))
+	}
+}
+
+func TestChannelSelection_Persisted(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "channels.json")
+	reg := NewChannelRegistry(statePath)
+	reg.AddChannel(&Channel{Provider: "zai", Name: "a", APIKey: "k", Active: true, Weight: 4})
+	if err := reg.SetSelection("zai", "random"); err == nil {
+		t.Error("expected error for unknown strategy")
+	}
+	if err := reg.SetSelection("zai", SelectionWeighted); err != nil {
+		t.Fatalf("SetSelection: %v", err)
+	}
+
+	reg2 := NewChannelRegistry(statePath)
+	reg2.AddChannel(&Channel{Provider: "zai", Name: "a", APIKey: "k"})
+	if err := reg2.LoadState(); err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	ch, _ := reg2.GetChannel("zai", "a")
+	if reg2.Selection("zai") != SelectionWeighted || ch.Weight != 4 {
+		t.Errorf("selection=%q weight=%d not restored", reg2.Selection("zai"), ch.Weight)
+	}
+	if reg2.Selection("mammouth") != SelectionOrdered {
+		t.Errorf("default selection = %q", reg2.Selection("mammouth"))
+	}
+}
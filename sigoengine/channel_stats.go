@@ -0,0 +1,205 @@
+//**********************************************************************
+//      sigoengine/channel_stats.go
+//**********************************************************************
+//  Beschreibung: Rollierende Latenz- und Fehlerstatistik pro Kanal und
+//  pro Modell#Kanal (die letzten channelStatsWindow Upstream-Calls,
+//  höchstens channelStatsMaxAge alt). Grundlage für die Strategie
+//  "fastest" und für /api/channels. Anders als Healthy/ConsecutiveErrors
+//  kippt die Fehlerquote nicht, sondern steigt und fällt mit dem Fenster.
+//**********************************************************************
+
+package sigoengine
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	channelStatsWindow = 100              // Samples pro Kanal bzw. Modell#Kanal
+	channelStatsMaxAge = 10 * time.Minute // ältere Samples zählen nicht mehr
+)
+
+// ChannelStats ist ein Snapshot der rollierenden Statistik eines Kanals.
+// Perzentile beziehen sich nur auf erfolgreiche Calls.
+type ChannelStats struct {
+	Requests  int     `json:"requests"`
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+	P50Ms     int64   `json:"p50_ms"`
+	P90Ms     int64   `json:"p90_ms"`
+	P99Ms     int64   `json:"p99_ms"`
+}
+
+// statSample ist ein einzelner Upstream-Call.
+type statSample struct {
+	at      time.Time
+	latency time.Duration
+	failed  bool
+}
+
+// statWindow ist ein Ringpuffer der letzten Samples.
+type statWindow struct {
+	samples []statSample
+	next    int
+}
+
+func (w *statWindow) add(s statSample) {
+	if len(w.samples) < channelStatsWindow {
+		w.samples = append(w.samples, s)
+		return
+	}
+	w.samples[w.next] = s
+	w.next = (w.next + 1) % channelStatsWindow
+}
+
+func (w *statWindow) snapshot(now time.Time) ChannelStats {
+	var st ChannelStats
+	var latencies []time.Duration
+	for _, s := range w.samples {
+		if now.Sub(s.at) > channelStatsMaxAge {
+			continue
+		}
+		st.Requests++
+		if s.failed {
+			st.Errors++
+			continue
+		}
+		latencies = append(latencies, s.latency)
+	}
+	if st.Requests == 0 {
+		return st
+	}
+	st.ErrorRate = float64(st.Errors) / float64(st.Requests)
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		st.P50Ms = percentile(latencies, 50).Milliseconds()
+		st.P90Ms = percentile(latencies, 90).Milliseconds()
+		st.P99Ms = percentile(latencies, 99).Milliseconds()
+	}
+	return st
+}
+
+// percentile liefert das p-Perzentil (nearest rank) einer sortierten Liste.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	idx := (len(sorted)*p + 99) / 100
+	if idx < 1 {
+		idx = 1
+	}
+	return sorted[idx-1]
+}
+
+// channelStatsStore hält die Fenster pro Kanal und pro Modell#Kanal.
+type channelStatsStore struct {
+	mu      sync.Mutex
+	windows map[string]*statWindow // FullName bzw. model#FullName → Fenster
+}
+
+func newChannelStatsStore() *channelStatsStore {
+	return &channelStatsStore{windows: make(map[string]*statWindow)}
+}
+
+func (s *channelStatsStore) record(key string, sample statSample) {
+	w := s.windows[key]
+	if w == nil {
+		w = &statWindow{}
+		s.windows[key] = w
+	}
+	w.add(sample)
+}
+
+// modelStatsKey ist der Key für Modell#Kanal (wie die Circuit Breaker).
+func modelStatsKey(model string, ch *Channel) string {
+	return model + "#" + ch.FullName()
+}
+
+// RecordCall erfasst einen Upstream-Call eines Kanals (model optional) mit
+// Latenz und Ergebnis. Client-Fehler sollte der Aufrufer nicht melden: sie
+// sagen nichts über den Kanal aus.
+func (r *ChannelRegistry) RecordCall(ch *Channel, model string, latency time.Duration, failed bool) {
+	sample := statSample{at: time.Now(), latency: latency, failed: failed}
+	r.stats.mu.Lock()
+	defer r.stats.mu.Unlock()
+	r.stats.record(ch.FullName(), sample)
+	if model != "" {
+		r.stats.record(modelStatsKey(model, ch), sample)
+	}
+}
+
+// Stats returns the rolling statistics of a channel.
+func (r *ChannelRegistry) Stats(ch *Channel) ChannelStats {
+	return r.statsFor(ch.FullName())
+}
+
+// ModelStats returns the rolling statistics of a model on a channel.
+func (r *ChannelRegistry) ModelStats(model string, ch *Channel) ChannelStats {
+	return r.statsFor(modelStatsKey(model, ch))
+}
+
+// ModelStatsByChannel returns the per-model statistics of a channel
+// (model → stats), only models with samples in the window.
+func (r *ChannelRegistry) ModelStatsByChannel(ch *Channel) map[string]ChannelStats {
+	suffix := "#" + ch.FullName()
+	now := time.Now()
+	r.stats.mu.Lock()
+	defer r.stats.mu.Unlock()
+	result := make(map[string]ChannelStats)
+	for key, w := range r.stats.windows {
+		model := strings.TrimSuffix(key, suffix)
+		if model == key || model == "" {
+			continue
+		}
+		if st := w.snapshot(now); st.Requests > 0 {
+			result[model] = st
+		}
+	}
+	return result
+}
+
+func (r *ChannelRegistry) statsFor(key string) ChannelStats {
+	r.stats.mu.Lock()
+	defer r.stats.mu.Unlock()
+	if w := r.stats.windows[key]; w != nil {
+		return w.snapshot(time.Now())
+	}
+	return ChannelStats{}
+}
+
+// Bewertung für die Strategie "fastest" (kleiner ist besser).
+const (
+	fastestNoSuccessMs   = 10000 // Latenz-Ersatz, wenn im Fenster kein Call gelang
+	fastestErrorPenalty  = 4.0   // Fehlerquote 25% → doppelte, 100% → fünffache Latenz
+	fastestUnhealthyMult = 10.0  // als unhealthy markierte Kanäle stark abwerten
+)
+
+// RoutingScore bewertet einen Kanal wie die Strategie "fastest" (kleiner
+// ist besser). model (optional) bevorzugt die Modell#Kanal-Statistik.
+func (r *ChannelRegistry) RoutingScore(model string, ch *Channel) float64 {
+	st := r.Stats(ch)
+	if model != "" {
+		if ms := r.ModelStats(model, ch); ms.Requests > 0 {
+			st = ms
+		}
+	}
+	return routingScore(st, ch.Healthy)
+}
+
+// routingScore bewertet einen Kanal nach Latenz (p50) und Fehlerquote.
+// Steigende Fehlerquoten werten den Kanal schrittweise ab statt ihn
+// abzuschalten. Kanäle ohne Samples bekommen den besten Wert, damit neue
+// (oder lange ungenutzte) Kanäle ausprobiert werden.
+func routingScore(st ChannelStats, healthy bool) float64 {
+	latency := float64(st.P50Ms)
+	if st.Requests == 0 {
+		latency = 0
+	} else if st.Errors == st.Requests {
+		latency = fastestNoSuccessMs
+	}
+	score := (latency + 1) * (1 + fastestErrorPenalty*st.ErrorRate)
+	if !healthy {
+		score *= fastestUnhealthyMult
+	}
+	return score
+}
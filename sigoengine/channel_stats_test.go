@@ -0,0 +1,119 @@
+//**********************************************************************
+//      sigoengine/channel_stats_test.go
+//**********************************************************************
+
+package sigoengine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChannelStats_Window(t *testing.T) {
+	reg := NewChannelRegistry("")
+	ch := &Channel{Provider: "zai", Name: "a", Active: true}
+	reg.AddChannel(ch)
+
+	if st := reg.Stats(ch); st.Requests != 0 {
+		t.Fatalf("empty stats = %+v", st)
+	}
+	for i := 1; i <= 10; i++ {
+		reg.RecordCall(ch, "glm-4.6", time.Duration(i*100)*time.Millisecond, false)
+	}
+	reg.RecordCall(ch, "glm-4.6", time.Millisecond, true)
+	reg.RecordCall(ch, "", time.Millisecond, true)
+
+	st := reg.Stats(ch)
+	if st.Requests != 12 || st.Errors != 2 {
+		t.Fatalf("stats = %+v", st)
+	}
+	if st.P50Ms != 500 || st.P90Ms != 900 || st.P99Ms != 1000 {
+		t.Errorf("percentiles = %d/%d/%d, want 500/900/1000", st.P50Ms, st.P90Ms, st.P99Ms)
+	}
+	if ms := reg.ModelStats("glm-4.6", ch); ms.Requests != 11 || ms.Errors != 1 {
+		t.Errorf("model stats = %+v", ms)
+	}
+	if by := reg.ModelStatsByChannel(ch); len(by) != 1 || by["glm-4.6"].Requests != 11 {
+		t.Errorf("ModelStatsByChannel = %+v", by)
+	}
+
+	// Ringpuffer: nach channelStatsWindow Erfolgen sind die Fehler verdrängt
+	for i := 0; i < channelStatsWindow; i++ {
+		reg.RecordCall(ch, "", 50*time.Millisecond, false)
+	}
+	if st := reg.Stats(ch); st.Requests != channelStatsWindow || st.Errors != 0 || st.P99Ms != 50 {
+		t.Errorf("after window = %+v", st)
+	}
+
+	// Samples älter als channelStatsMaxAge zählen nicht
+	w := reg.stats.windows[ch.FullName()]
+	for i := range w.samples {
+		w.samples[i].at = time.Now().Add(-2 * channelStatsMaxAge)
+	}
+	if st := reg.Stats(ch); st.Requests != 0 {
+		t.Errorf("expired samples counted: %+v", st)
+	}
+}
+
+func TestRoutingScore(t *testing.T) {
+	fast := ChannelStats{Requests: 10, P50Ms: 200}
+	if routingScore(ChannelStats{}, true) >= routingScore(fast, true) {
+		t.Error("unexplored channel should be tried first")
+	}
+	// Fehlerquote wertet schrittweise ab: 10% < 25% < 50%
+	prev := routingScore(fast, true)
+	for _, rate := range []float64{0.1, 0.25, 0.5} {
+		st := fast
+		st.ErrorRate = rate
+		score := routingScore(st, true)
+		if score <= prev {
+			t.Errorf("error rate %.2f: score %.0f not above %.0f", rate, score, prev)
+		}
+		prev = score
+	}
+	if routingScore(fast, false) <= routingScore(fast, true) {
+		t.Error("unhealthy channel should score worse")
+	}
+	failing := ChannelStats{Requests: 3, Errors: 3, ErrorRate: 1}
+	if routingScore(failing, true) <= routingScore(ChannelStats{Requests: 5, P50Ms: 3000}, true) {
+		t.Error("channel without successes should lose against a slow one")
+	}
+}
+
+func TestChannelSelection_Fastest(t *testing.T) {
+	mgr := selectionTestManager(t, SelectionFastest)
+	reg := mgr.Registry()
+	a, _ := reg.GetChannel("zai", "a")
+	b, _ := reg.GetChannel("zai", "b")
+	c, _ := reg.GetChannel("zai", "c")
+	for _, ch := range []*Channel{a, b, c} {
+		ch.Healthy = true
+	}
+	for i := 0; i < 10; i++ {
+		reg.RecordCall(a, "", 800*time.Millisecond, false)
+		reg.RecordCall(b, "", 200*time.Millisecond, false)
+		reg.RecordCall(c, "", 400*time.Millisecond, false)
+	}
+	if got := resolveNames(t, mgr, 2); got != "b b" {
+		t.Errorf("fastest = %q, want b b", got)
+	}
+
+	// b bekommt Fehler → schrittweise abgewertet, c übernimmt
+	for i := 0; i < 5; i++ {
+		reg.RecordCall(b, "", 0, true)
+	}
+	if got := resolveNames(t, mgr, 1); got != "c" {
+		t.Errorf("after errors on b = %q, want c", got)
+	}
+
+	// Modell-Statistik hat Vorrang: auf a ist glm-4.6 am schnellsten
+	for _, ch := range []*Channel{a, b, c} {
+		reg.RecordCall(ch, "glm-4.6", 900*time.Millisecond, false)
+	}
+	reg.RecordCall(a, "glm-4.6", 10*time.Millisecond, false)
+	reg.RecordCall(a, "glm-4.6", 10*time.Millisecond, false)
+	ch, err := mgr.ResolveModel("zai", "glm-4.6", "")
+	if err != nil || ch.Name != "a" {
+		t.Errorf("ResolveModel = %v, %v; want a", ch, err)
+	}
+}
@@ -107,4 +107,18 @@ func TestChannelRegistry_LoadSaveState(t *testing.T) {
 	if !ok || !ch.Active {
 		t.Errorf("expected channel 0 to be active after loading state")
 	}
+
+	// Token-Bucket-Limits werden mit dem Kanal persistiert
+	ch.RPM, ch.TPM, ch.Burst = 60, 90000, 10
+	if err := reg2.SaveState(); err != nil {
+		t.Fatalf("SaveState failed: %v", err)
+	}
+	reg3 := NewChannelRegistry(statePath)
+	reg3.DiscoverFromEnv()
+	if err := reg3.LoadState(); err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if ch, _ := reg3.GetChannel("mammouth", "0"); ch.RPM != 60 || ch.TPM != 90000 || ch.Burst != 10 {
+		t.Errorf("rpm/tpm/burst not restored: %+v", ch)
+	}
 }
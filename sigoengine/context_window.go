@@ -0,0 +1,115 @@
+//**********************************************************************
+//      sigoengine/context_window.go
+//**********************************************************************
+//  Beschreibung: Pre-Flight-Prüfung gegen das Kontextfenster.
+//  Zählt die Prompt-Tokens der fertig gebauten Messages (Memory,
+//  System-Prompt, Session, Request) mit dem Tokenizer des Modells und
+//  kürzt max_tokens, damit Prompt plus Output in MaxInputTokens passen.
+//  Passt schon der Prompt nicht, gibt es ErrContextLength, bevor ein
+//  Provider-Call läuft (bei genäherter Zählung erst jenseits einer
+//  Toleranz).
+//**********************************************************************
+
+package sigoengine
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ErrContextLength - Prompt passt nicht ins Kontextfenster des Modells
+const ErrContextLength = "CONTEXT_LENGTH_EXCEEDED"
+
+const (
+	messageTokenOverhead = 4   // Rolle und Trenner je Message
+	promptTokenOverhead  = 3   // Einleitung der Assistant-Antwort
+	imageTokenEstimate   = 765 // Pauschale je Bild (hohe Detailstufe)
+)
+
+// Spielraum der Näherung ohne Vokabular: erst wenn der geschätzte Prompt
+// das Fenster um mehr als approxTolerance übersteigt, gilt er sicher als
+// zu lang. Bis dahin bleiben mindestens approxMinOutput Tokens für die
+// Antwort (der Provider entscheidet über den echten Prompt).
+const (
+	approxTolerance = 0.25
+	approxMinOutput = 256
+)
+
+// EstimatePromptTokens zählt die Prompt-Tokens von Chat-Messages im
+// OpenAI-Format: Text aller Inhalte, Tool-Calls und Bild-Pauschalen plus
+// Overhead je Message.
+func EstimatePromptTokens(tok *Tokenizer, messages []map[string]interface{}) int {
+	tokens := promptTokenOverhead
+	for _, msg := range messages {
+		tokens += MessageTokens(tok, msg)
+	}
+	return tokens
+}
+
+// MessageTokens zählt eine Message inkl. Overhead.
+func MessageTokens(tok *Tokenizer, msg map[string]interface{}) int {
+	tokens := messageTokenOverhead + contentTokens(tok, msg["content"])
+	if calls, ok := msg["tool_calls"]; ok {
+		data, _ := json.Marshal(calls)
+		tokens += tok.Count(string(data))
+	}
+	return tokens
+}
+
+// contentTokens zählt einen Message-Inhalt (String oder Content-Parts).
+func contentTokens(tok *Tokenizer, content interface{}) int {
+	switch c := content.(type) {
+	case nil:
+		return 0
+	case string:
+		return tok.Count(c)
+	case []interface{}:
+		tokens := 0
+		for _, part := range c {
+			p, ok := part.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch p["type"] {
+			case "text":
+				text, _ := p["text"].(string)
+				tokens += tok.Count(text)
+			case "image_url", "image":
+				tokens += imageTokenEstimate
+			default:
+				data, _ := json.Marshal(p)
+				tokens += tok.Count(string(data))
+			}
+		}
+		return tokens
+	default:
+		data, _ := json.Marshal(c)
+		return tok.Count(string(data))
+	}
+}
+
+// FitMaxTokens kürzt maxTokens auf den Platz, den promptTokens im
+// Kontextfenster maxInput lassen. maxInput 0 (unbekannt) und maxTokens 0
+// (Provider-Default) bleiben unverändert. Bleibt kein Platz für Output,
+// kommt ein Fehler mit Code ErrContextLength (Fields prompt_tokens und
+// max_input_tokens). exact = false (Zählung per Näherung): innerhalb von
+// approxTolerance gilt als Platz das Fenster minus Schätzung, mindestens
+// approxMinOutput.
+func FitMaxTokens(promptTokens, maxInput, maxTokens int, exact bool) (int, error) {
+	if maxInput <= 0 {
+		return maxTokens, nil
+	}
+	room := maxInput - promptTokens
+	if !exact && float64(promptTokens) <= float64(maxInput)*(1+approxTolerance) {
+		room = max(room, approxMinOutput)
+	}
+	if room < 1 {
+		return 0, NewError(ErrContextLength,
+			fmt.Sprintf("Prompt (ca. %d Tokens) passt nicht ins Kontextfenster des Modells (%d Tokens)", promptTokens, maxInput),
+			nil, map[string]interface{}{"prompt_tokens": promptTokens, "max_input_tokens": maxInput})
+	}
+	if maxTokens > room {
+		return room, nil
+	}
+	return maxTokens, nil
+}
@@ -0,0 +1,86 @@
+package sigoengine
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEstimatePromptTokens(t *testing.T) {
+	var parts []interface{}
+	json.Unmarshal([]byte(`[{"type":"text","text":"`+strings.Repeat("a", 30)+`"},{"type":"image_url","image_url":{"url":"x"}}]`), &parts)
+	messages := []map[string]interface{}{
+		{"role": "system", "content": strings.Repeat("s", 60)},
+		{"role": "user", "content": parts},
+		{"role": "assistant", "content": nil},
+	}
+	tok := TokenizerForModel("")
+	want := promptTokenOverhead + 3*messageTokenOverhead + tok.Count(strings.Repeat("s", 60)) + tok.Count(strings.Repeat("a", 30)) + imageTokenEstimate
+	if got := EstimatePromptTokens(tok, messages); got != want {
+		t.Fatalf("EstimatePromptTokens = %d, want %d", got, want)
+	}
+}
+
+func TestFitMaxTokens(t *testing.T) {
+	tests := []struct {
+		prompt, maxInput, maxTokens, want int
+		approx, wantErr                   bool
+	}{
+		{prompt: 1000, maxInput: 0, maxTokens: 8192, want: 8192},      // Fenster unbekannt
+		{prompt: 1000, maxInput: 128000, maxTokens: 8192, want: 8192}, // passt
+		{prompt: 125000, maxInput: 128000, maxTokens: 8192, want: 3000},
+		{prompt: 125000, maxInput: 128000, maxTokens: 0, want: 0}, // Provider-Default
+		{prompt: 128000, maxInput: 128000, maxTokens: 8192, wantErr: true},
+		{prompt: 200000, maxInput: 128000, maxTokens: 0, wantErr: true},
+		// Näherung: innerhalb der Toleranz Rest des Fensters, mindestens approxMinOutput
+		{prompt: 125000, maxInput: 128000, maxTokens: 8192, approx: true, want: 3000},
+		{prompt: 127900, maxInput: 128000, maxTokens: 8192, approx: true, want: approxMinOutput},
+		{prompt: 150000, maxInput: 128000, maxTokens: 8192, approx: true, want: approxMinOutput},
+		{prompt: 150000, maxInput: 128000, maxTokens: 100, approx: true, want: 100},
+		{prompt: 150000, maxInput: 128000, maxTokens: 0, approx: true, want: 0},
+		{prompt: 170000, maxInput: 128000, maxTokens: 8192, approx: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := FitMaxTokens(tt.prompt, tt.maxInput, tt.maxTokens, !tt.approx)
+		if tt.wantErr {
+			sigoErr, ok := err.(*SigoError)
+			if !ok || sigoErr.Code != ErrContextLength {
+				t.Errorf("FitMaxTokens(%d, %d, %d): erwartet ErrContextLength, got %v", tt.prompt, tt.maxInput, tt.maxTokens, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("FitMaxTokens(%d, %d, %d) = %d, %v; want %d", tt.prompt, tt.maxInput, tt.maxTokens, got, err, tt.want)
+		}
+	}
+}
+
+// TestFitMaxTokensSweep: über die Fenstergrenze hinweg sinkt max_tokens
+// stetig bis zum Minimum und bleibt dort bis zur Toleranzgrenze; danach
+// (exakt: ab dem vollen Fenster) kommt ErrContextLength.
+func TestFitMaxTokensSweep(t *testing.T) {
+	const maxInput, maxTokens = 1000, 800
+	limit := int(maxInput * (1 + approxTolerance))
+	for _, exact := range []bool{true, false} {
+		prev := maxTokens
+		for prompt := 0; prompt <= limit+50; prompt++ {
+			got, err := FitMaxTokens(prompt, maxInput, maxTokens, exact)
+			room := maxInput - prompt
+			wantErr := room < 1
+			if !exact {
+				room = max(room, approxMinOutput)
+				wantErr = prompt > limit
+			}
+			if wantErr {
+				if sigoErr, ok := err.(*SigoError); !ok || sigoErr.Code != ErrContextLength {
+					t.Fatalf("exact=%v prompt=%d: erwartet ErrContextLength, got %d, %v", exact, prompt, got, err)
+				}
+				continue
+			}
+			if err != nil || got != min(maxTokens, room) || got > prev {
+				t.Fatalf("exact=%v prompt=%d: got %d, %v (vorher %d)", exact, prompt, got, err, prev)
+			}
+			prev = got
+		}
+	}
+}
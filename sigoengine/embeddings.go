@@ -0,0 +1,207 @@
+//**********************************************************************
+//      sigoengine/embeddings.go
+//**********************************************************************
+//  Beschreibung: Embeddings-Call-Pfad (OpenAI /v1/embeddings und
+//                Ollama /api/embed)
+//**********************************************************************
+
+package sigoengine
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"time"
+)
+
+// Modell-Arten in der Registry. Leer gilt als Chat (Rückwärtskompatibilität
+// mit models.csv/models.json ohne kind-Spalte).
+const (
+	ModelKindChat      = "chat"
+	ModelKindEmbedding = "embedding"
+)
+
+// IsEmbedding meldet, ob das Modell ein Embedding-Modell ist.
+func (m Model) IsEmbedding() bool {
+	return m.Kind == ModelKindEmbedding
+}
+
+// EmbeddingResult ist die provider-unabhängige Embeddings-Antwort.
+// Embeddings[i] gehört zu Input i und ist entweder ein JSON-Array von
+// Floats oder (encoding_format=base64) ein JSON-String.
+type EmbeddingResult struct {
+	Embeddings []json.RawMessage
+	Usage      *UsageData
+}
+
+// CallEmbeddings erzeugt Embeddings über den Provider aus cfg. request
+// liegt im OpenAI-Format vor (model, input, encoding_format, dimensions,
+// user). Für cfg.Type "ollama" wird nach /api/embed übersetzt; Provider
+// mit UpstreamModelProvider bekommen ihren Modellnamen (request selbst
+// bleibt unverändert).
+func CallEmbeddings(ctx context.Context, cfg *ProviderConfig, request map[string]interface{},
+	timeoutSec int) (*EmbeddingResult, error) {
+
+	start := time.Now()
+	logF := map[string]interface{}{"endpoint": cfg.Endpoint, "model": cfg.Model, "embeddings": true}
+	LogDebug("Making embeddings request", logF)
+
+	if timeoutSec > 0 {
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, time.Duration(timeoutSec)*time.Second)
+			defer cancel()
+		}
+	}
+
+	payload := request
+	if up, ok := providerForConfig(cfg).(UpstreamModelProvider); ok {
+		if model, ok := request["model"].(string); ok {
+			payload = make(map[string]interface{}, len(request))
+			for k, v := range request {
+				payload[k] = v
+			}
+			payload["model"] = up.UpstreamModel(model)
+		}
+	}
+	if cfg.Type == "ollama" {
+		payload = toOllamaEmbedRequest(payload)
+	}
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, NewError(ErrAPIFailed, "Failed to marshal request", err, logF)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", cfg.Endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, NewError(ErrAPIFailed, "Failed to create HTTP request", err, logF)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	providerForConfig(cfg).SetAuth(req, cfg.APIKey)
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := defaultHTTPClient.Do(req)
+	if err != nil {
+		LogError("HTTP request failed", err, logF)
+		return nil, NewError(ErrAPIFailed, "HTTP request failed", err, logF)
+	}
+	defer resp.Body.Close()
+	reportRateLimit(cfg, resp)
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		logF["status_code"] = resp.StatusCode
+		logF["body"] = string(body)
+		LogError("HTTP error", nil, logF)
+
+		apiErr := classifyHTTPError(resp.StatusCode, string(body), nil)
+		apiErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		return nil, apiErr
+	}
+
+	LogDebug("Embeddings response", map[string]interface{}{
+		"size_bytes":  len(body),
+		"duration_ms": time.Since(start).Milliseconds(),
+	})
+
+	if cfg.Type == "ollama" {
+		base64Out, _ := request["encoding_format"].(string)
+		return fromOllamaEmbedResponse(body, base64Out == "base64", logF)
+	}
+	return fromOpenAIEmbeddingResponse(body, logF)
+}
+
+// fromOpenAIEmbeddingResponse liest data[].embedding (nach index sortiert)
+// und usage aus einer OpenAI-kompatiblen Antwort.
+func fromOpenAIEmbeddingResponse(body []byte, logF map[string]interface{}) (*EmbeddingResult, error) {
+	var parsed struct {
+		Data []struct {
+			Index     int             `json:"index"`
+			Embedding json.RawMessage `json:"embedding"`
+		} `json:"data"`
+		Usage *struct {
+			PromptTokens int `json:"prompt_tokens"`
+			TotalTokens  int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		LogError("Failed to parse response", err, logF)
+		return nil, NewError(ErrAPIFailed, "Failed to parse JSON response", err, logF)
+	}
+
+	res := &EmbeddingResult{Embeddings: make([]json.RawMessage, len(parsed.Data))}
+	for i, d := range parsed.Data {
+		idx := d.Index
+		if idx < 0 || idx >= len(parsed.Data) || res.Embeddings[idx] != nil {
+			idx = i
+		}
+		res.Embeddings[idx] = d.Embedding
+	}
+	if parsed.Usage != nil {
+		total := parsed.Usage.TotalTokens
+		if total == 0 {
+			total = parsed.Usage.PromptTokens
+		}
+		res.Usage = &UsageData{InputTokens: parsed.Usage.PromptTokens, TotalTokens: total}
+	}
+	return res, nil
+}
+
+// toOllamaEmbedRequest übersetzt einen OpenAI-Embeddings-Request in das
+// Format von Ollama POST /api/embed ({model, input, dimensions}).
+func toOllamaEmbedRequest(request map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{
+		"model": request["model"],
+		"input": request["input"],
+	}
+	if dims, ok := request["dimensions"]; ok {
+		out["dimensions"] = dims
+	}
+	return out
+}
+
+// fromOllamaEmbedResponse liest {embeddings, prompt_eval_count} aus der
+// Ollama-Antwort. Ollama kennt kein base64; die Kodierung (float32
+// little-endian, wie bei OpenAI) übernimmt sigoengine.
+func fromOllamaEmbedResponse(body []byte, asBase64 bool, logF map[string]interface{}) (*EmbeddingResult, error) {
+	var parsed struct {
+		Embeddings      [][]float64 `json:"embeddings"`
+		PromptEvalCount int         `json:"prompt_eval_count"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		LogError("Failed to parse response", err, logF)
+		return nil, NewError(ErrAPIFailed, "Failed to parse JSON response", err, logF)
+	}
+
+	res := &EmbeddingResult{Embeddings: make([]json.RawMessage, 0, len(parsed.Embeddings))}
+	for _, vec := range parsed.Embeddings {
+		var raw []byte
+		if asBase64 {
+			raw, _ = json.Marshal(encodeEmbeddingBase64(vec))
+		} else {
+			raw, _ = json.Marshal(vec)
+		}
+		res.Embeddings = append(res.Embeddings, raw)
+	}
+	if parsed.PromptEvalCount > 0 {
+		res.Usage = &UsageData{InputTokens: parsed.PromptEvalCount, TotalTokens: parsed.PromptEvalCount}
+	}
+	return res, nil
+}
+
+// encodeEmbeddingBase64 kodiert einen Vektor wie die OpenAI-API bei
+// encoding_format=base64: float32 little-endian, Standard-Base64.
+func encodeEmbeddingBase64(vec []float64) string {
+	buf := make([]byte, 4*len(vec))
+	for i, v := range vec {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(float32(v)))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
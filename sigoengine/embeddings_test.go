@@ -0,0 +1,94 @@
+package sigoengine
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCallEmbeddingsOpenAI(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer k" {
+			t.Errorf("missing bearer auth: %v", r.Header)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		// Absichtlich vertauschte Reihenfolge: index ist maßgeblich
+		w.Write([]byte(`{"object":"list","data":[
+			{"object":"embedding","index":1,"embedding":[0.3,0.4]},
+			{"object":"embedding","index":0,"embedding":[0.1,0.2]}],
+			"usage":{"prompt_tokens":6,"total_tokens":6}}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &ProviderConfig{Endpoint: upstream.URL, Model: "embedding-3", APIKey: "k", Type: "mammoth"}
+	res, err := CallEmbeddings(context.Background(), cfg, map[string]interface{}{
+		"model": cfg.Model,
+		"input": []string{"a", "b"},
+	}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Embeddings) != 2 || string(res.Embeddings[0]) != "[0.1,0.2]" || string(res.Embeddings[1]) != "[0.3,0.4]" {
+		t.Fatalf("unexpected embeddings: %s", res.Embeddings)
+	}
+	if res.Usage == nil || res.Usage.InputTokens != 6 || res.Usage.TotalTokens != 6 {
+		t.Fatalf("unexpected usage: %+v", res.Usage)
+	}
+}
+
+func TestCallEmbeddingsOllama(t *testing.T) {
+	var got map[string]interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("unexpected auth header for ollama")
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"model":"nomic-embed-text","embeddings":[[0.5,-1]],"prompt_eval_count":4}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &ProviderConfig{Endpoint: upstream.URL + "/api/embed", Model: "nomic-embed-text:latest", Type: "ollama"}
+	res, err := CallEmbeddings(context.Background(), cfg, map[string]interface{}{
+		"model":           cfg.Model,
+		"input":           "Hallo",
+		"encoding_format": "base64",
+	}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["input"] != "Hallo" || got["encoding_format"] != nil {
+		t.Fatalf("unexpected ollama request: %v", got)
+	}
+	var b64 string
+	if err := json.Unmarshal(res.Embeddings[0], &b64); err != nil {
+		t.Fatalf("expected base64 string, got %s", res.Embeddings[0])
+	}
+	raw, _ := base64.StdEncoding.DecodeString(b64)
+	if len(raw) != 8 || math.Float32frombits(binary.LittleEndian.Uint32(raw[4:])) != -1 {
+		t.Fatalf("unexpected base64 payload: %v", raw)
+	}
+	if res.Usage == nil || res.Usage.InputTokens != 4 {
+		t.Fatalf("unexpected usage: %+v", res.Usage)
+	}
+}
+
+func TestCallEmbeddingsHTTPError(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer upstream.Close()
+
+	cfg := &ProviderConfig{Endpoint: upstream.URL, Model: "m", Type: "mammoth"}
+	_, err := CallEmbeddings(context.Background(), cfg, map[string]interface{}{"model": "m", "input": "x"}, 5)
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.Type != ErrRateLimit || apiErr.RetryAfter.Seconds() != 7 {
+		t.Fatalf("expected rate limit with Retry-After, got %v", err)
+	}
+}
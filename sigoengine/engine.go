@@ -21,7 +21,6 @@ import (
 	"io"
 	"net/http"
 	"os"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -391,133 +390,13 @@ type ProviderConfig struct {
 	Model    string            `json:"model"`
 	APIKey   string            `json:"api_key"`
 	Headers  map[string]string `json:"headers,omitempty"`
-	Type     string            `json:"type"` // "anthropic","openai","custom","ollama"
-}
-
-// **********************************************************************
-// Ollama Registry — wird zur Laufzeit via DiscoverOllamaModels befüllt
-
-// OllamaModelInfo beschreibt ein lokal installiertes Ollama-Modell
-type OllamaModelInfo struct {
-	Shortcode     string // z.B. "ollama-llama3"
-	OllamaName    string // z.B. "llama3:latest" (echter Ollama-Name)
-	Size          int64  `json:"size"`
-	ContextLength int    // aus /api/show, 0 wenn unbekannt
-}
-
-// fetchOllamaContextLength fragt POST /api/show für ein Modell ab und
-// gibt die Context-Length zurück (0 wenn nicht verfügbar).
-func fetchOllamaContextLength(endpoint, modelName string) int {
-	client := &http.Client{Timeout: 5 * time.Second}
-
-	type showReq struct {
-		Name string `json:"name"`
-	}
-	body, _ := json.Marshal(showReq{Name: modelName})
-
-	resp, err := client.Post(
-		endpoint+"/api/show",
-		"application/json",
-		bytes.NewReader(body),
-	)
-	if err != nil {
-		return 0
-	}
-	defer resp.Body.Close()
-
-	var result struct {
-		ModelInfo map[string]interface{} `json:"modelinfo"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return 0
-	}
-
-	// Suche nach context_length in modelinfo (Feldname variiert je nach Modell-Typ)
-	for k, v := range result.ModelInfo {
-		if strings.HasSuffix(k, ".context_length") || k == "context_length" {
-			switch val := v.(type) {
-			case float64:
-				return int(val)
-			}
-		}
-	}
-	return 0
-}
-
-var (
-	ollamaRegistry   = make(map[string]OllamaModelInfo) // shortcode → info
-	ollamaRegistryMu sync.RWMutex
-)
-
-// DiscoverOllamaModels fragt Ollama nach installierten Modellen.
-// endpoint z.B. "http://localhost:11434"
-// Gibt Anzahl gefundener Modelle zurück (0 wenn Ollama nicht läuft).
-func DiscoverOllamaModels(endpoint string) int {
-	type ollamaTag struct {
-		Name string `json:"name"`
-		Size int64  `json:"size"`
-	}
-	type ollamaTagsResponse struct {
-		Models []ollamaTag `json:"models"`
-	}
-
-	client := &http.Client{Timeout: 3 * time.Second}
-	resp, err := client.Get(endpoint + "/api/tags")
-	if err != nil {
-		LogInfo("Ollama nicht erreichbar", map[string]interface{}{"endpoint": endpoint})
-		return 0
-	}
-	defer resp.Body.Close()
-
-	var tags ollamaTagsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
-		LogWarn("Ollama /api/tags Parse-Fehler", map[string]interface{}{"error": err.Error()})
-		return 0
-	}
-
-	ollamaRegistryMu.Lock()
-	defer ollamaRegistryMu.Unlock()
-
-	// Alten Stand löschen (Modelle könnten entfernt worden sein)
-	ollamaRegistry = make(map[string]OllamaModelInfo)
+	Type     string            `json:"type"`               // "anthropic","openai","custom","ollama"
+	Provider string            `json:"provider,omitempty"` // Registry-Name, siehe provider.go
 
-	for _, m := range tags.Models {
-		// Shortcode: ":latest" weglassen, andere Tags als Suffix behalten
-		// gemma3:12b → ollama-gemma3-12b
-		// llama3.2-vision:latest → ollama-llama3.2-vision
-		name := m.Name
-		shortcode := "ollama-" + strings.ReplaceAll(name, ":", "-")
-		if strings.HasSuffix(shortcode, "-latest") {
-			shortcode = strings.TrimSuffix(shortcode, "-latest")
-		}
-
-		ctxLen := fetchOllamaContextLength(endpoint, m.Name)
-		ollamaRegistry[shortcode] = OllamaModelInfo{
-			Shortcode:     shortcode,
-			OllamaName:    name,
-			Size:          m.Size,
-			ContextLength: ctxLen,
-		}
-		LogDebug("Ollama-Modell registriert", map[string]interface{}{
-			"shortcode": shortcode, "model": name,
-		})
-	}
-
-	LogInfo("Ollama Discovery abgeschlossen", map[string]interface{}{
-		"endpoint": endpoint, "models": len(ollamaRegistry),
-	})
-	return len(ollamaRegistry)
-}
-
-// GetOllamaModels gibt eine Kopie der aktuellen Ollama-Registry zurück
-func GetOllamaModels() map[string]OllamaModelInfo {
-	ollamaRegistryMu.RLock()
-	defer ollamaRegistryMu.RUnlock()
-	result := make(map[string]OllamaModelInfo, len(ollamaRegistry))
-	for k, v := range ollamaRegistry {
-		result[k] = v
-	}
-	return result
+	// OnRateLimit erhält den Reset-Zeitpunkt, wenn der Provider den Kanal
+	// als erschöpft meldet (429 + Retry-After, remaining 0), siehe
+	// ratelimit_headers.go. nil → Header werden ignoriert.
+	OnRateLimit func(until time.Time) `json:"-"`
 }
 
 // **********************************************************************
@@ -573,9 +452,47 @@ type Response struct {
 
 // **********************************************************************
 // Message - eine Chat-Nachricht
+// ToolCalls/ToolCallID tragen OpenAI Function-Calling-Turns: Assistant-
+// Nachrichten mit tool_calls und role:"tool"-Antworten mit tool_call_id.
 type Message struct {
-	Role    string          `json:"role"`
-	Content json.RawMessage `json:"content"`
+	Role       string          `json:"role"`
+	Content    json.RawMessage `json:"content"`
+	ToolCalls  json.RawMessage `json:"tool_calls,omitempty"`
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+	Name       string          `json:"name,omitempty"`
+}
+
+// ToMap wandelt die Nachricht in das OpenAI-Request-Format um.
+// Leerer Content wird zu null (Assistant-Nachrichten mit reinen tool_calls).
+func (m Message) ToMap() map[string]interface{} {
+	var contentValue interface{}
+	if len(m.Content) > 0 {
+		if err := json.Unmarshal(m.Content, &contentValue); err != nil {
+			contentValue = string(m.Content)
+		}
+	}
+	msg := map[string]interface{}{
+		"role": m.Role, "content": contentValue,
+	}
+	if len(m.ToolCalls) > 0 && string(m.ToolCalls) != "null" {
+		msg["tool_calls"] = m.ToolCalls
+	}
+	if m.ToolCallID != "" {
+		msg["tool_call_id"] = m.ToolCallID
+	}
+	if m.Name != "" {
+		msg["name"] = m.Name
+	}
+	return msg
+}
+
+// IsToolTurn prüft ob die Nachricht Teil eines Function-Calling-Turns ist
+// (Assistant mit tool_calls oder role:"tool").
+func (m Message) IsToolTurn() bool {
+	if m.Role == "tool" {
+		return true
+	}
+	return m.Role == "assistant" && len(m.ToolCalls) > 0 && string(m.ToolCalls) != "null"
 }
 
 // **********************************************************************
@@ -642,26 +559,34 @@ func jsonEscapeString(s string) string {
 // Speichert nur Text (keine Vision-Arrays) in Session-Dateien
 func (s *Session) AddMessage(role, content string) {
 	raw := json.RawMessage(`"` + jsonEscapeString(content) + `"`)
-	s.History = append(s.History, Message{
+	s.AddRawMessage(Message{
 		Role:    role,
 		Content: raw,
 	})
+}
+
+// AddRawMessage fügt eine vollständige Nachricht (inkl. tool_calls bzw.
+// tool_call_id) zur Session hinzu (max. 20). Tool-Antworten, deren
+// Assistant-Aufruf beim Kürzen herausfällt, werden mit entfernt — Provider
+// lehnen role:"tool" ohne vorangehende tool_calls ab.
+func (s *Session) AddRawMessage(m Message) {
+	if m.Role == "assistant" && len(m.Content) == 0 {
+		m.Content = json.RawMessage("null")
+	}
+	s.History = append(s.History, m)
 	if len(s.History) > 20 {
 		s.History = s.History[len(s.History)-20:]
 	}
+	for len(s.History) > 0 && s.History[0].Role == "tool" {
+		s.History = s.History[1:]
+	}
 }
 
 // BuildMessages baut eine OpenAI-kompatible Messages-Liste auf
 func (s *Session) BuildMessages(newPrompt string) []map[string]interface{} {
 	var msgs []map[string]interface{}
 	for _, m := range s.History {
-		var contentValue interface{}
-		if err := json.Unmarshal(m.Content, &contentValue); err != nil {
-			contentValue = string(m.Content)
-		}
-		msgs = append(msgs, map[string]interface{}{
-			"role": m.Role, "content": contentValue,
-		})
+		msgs = append(msgs, m.ToMap())
 	}
 	msgs = append(msgs, map[string]interface{}{
 		"role": "user", "content": newPrompt,
@@ -1069,12 +994,25 @@ type ProviderHealth struct {
 //   - "auth_failed":  HTTP 401/403, Key ungültig (Provider aber erreichbar)
 //   - "unavailable":  Netzwerkfehler, Timeout, sonstiger HTTP-Fehler
 func ProbeProviderModelList(ctx context.Context, provider, apiKey string) ProviderHealth {
+	return ProbeChannel(ctx, &Channel{Provider: provider, APIKey: apiKey})
+}
+
+// ProbeChannel prüft einen Kanal wie ProbeProviderModelList; Kanäle mit
+// BaseURL (Ollama-Hosts) werden auf ihrem eigenen Host geprüft.
+func ProbeChannel(ctx context.Context, ch *Channel) ProviderHealth {
 	start := time.Now()
 	health := ProviderHealth{
 		LastChecked: start,
 	}
+	provider, apiKey := ch.Provider, ch.APIKey
 
-	endpoint, needsAuth := modelsEndpointForProvider(provider)
+	var endpoint string
+	var needsAuth bool
+	p, known := GetProvider(provider)
+	if known {
+		endpoint, needsAuth = p.ProbeEndpoint()
+		endpoint = ChannelEndpoint(ch, endpoint)
+	}
 	if endpoint == "" {
 		health.Status = "unavailable"
 		health.Error = "no models endpoint known for provider: " + provider
@@ -1091,7 +1029,7 @@ func ProbeProviderModelList(ctx context.Context, provider, apiKey string) Provid
 		return health
 	}
 	if needsAuth && apiKey != "" {
-		req.Header.Set("Authorization", "Bearer "+apiKey)
+		p.SetAuth(req, apiKey)
 	}
 
 	resp, err := defaultHTTPClient.Do(req)
@@ -1118,21 +1056,6 @@ func ProbeProviderModelList(ctx context.Context, provider, apiKey string) Provid
 	return health
 }
 
-// modelsEndpointForProvider liefert den GET /models-Endpoint eines Providers
-// und ob dieser Bearer-Auth benötigt. Mammouth ist key-less.
-func modelsEndpointForProvider(provider string) (string, bool) {
-	switch provider {
-	case "mammouth":
-		return mammouthModelsEndpoint, false
-	case "moonshot":
-		return moonshotModelsEndpoint, true
-	case "zai":
-		return zaiModelsEndpoint, true
-	default:
-		return "", false
-	}
-}
-
 // **********************************************************************
 // isContextLimitError prüft ob der Fehler ein Context-Limit-Überschreitung ist
 func isContextLimitError(errText string) bool {
@@ -1158,9 +1081,38 @@ func isContextLimitError(errText string) bool {
 var defaultHTTPClient = &http.Client{}
 
 // **********************************************************************
-// CallAPI führt einen HTTP-Call zu einem AI-Provider durch
+// ChatResult - vollständige Assistant-Antwort eines Providers.
+// ToolCalls enthält das OpenAI tool_calls-Array unverändert (nil wenn keine).
+type ChatResult struct {
+	Content      string
+	ToolCalls    json.RawMessage
+	Usage        *UsageData
+	FinishReason string
+}
+
+// HasToolCalls prüft ob die Antwort Tool-Aufrufe enthält
+func (r *ChatResult) HasToolCalls() bool {
+	return len(r.ToolCalls) > 0 && string(r.ToolCalls) != "null" && string(r.ToolCalls) != "[]"
+}
+
+// CallAPI führt einen HTTP-Call zu einem AI-Provider durch und liefert nur
+// den Text der Antwort (Kompatibilitäts-Wrapper um CallAPIResult).
 func CallAPI(ctx context.Context, cfg *ProviderConfig, request map[string]interface{},
 	timeoutSec int) (string, *UsageData, string, error) {
+	res, err := CallAPIResult(ctx, cfg, request, timeoutSec)
+	if err != nil {
+		if res != nil {
+			return res.Content, res.Usage, res.FinishReason, err
+		}
+		return "", nil, "", err
+	}
+	return res.Content, res.Usage, res.FinishReason, nil
+}
+
+// CallAPIResult führt einen HTTP-Call zu einem AI-Provider durch und liefert
+// die vollständige Antwort inkl. tool_calls.
+func CallAPIResult(ctx context.Context, cfg *ProviderConfig, request map[string]interface{},
+	timeoutSec int) (*ChatResult, error) {
 
 	start := time.Now()
 	logF := map[string]interface{}{"endpoint": cfg.Endpoint, "model": cfg.Model}
@@ -1176,21 +1128,23 @@ func CallAPI(ctx context.Context, cfg *ProviderConfig, request map[string]interf
 		}
 	}
 
+	// OpenAI-Request ins Provider-Format übersetzen (z.B. Anthropic Messages)
+	provider := providerForConfig(cfg)
+	request, err := provider.BuildChatRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
 	jsonData, _ := json.Marshal(request)
 
-	req, err := http.NewRequestWithContext(ctx, "POST", cfg.Endpoint, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", provider.ChatEndpoint(cfg.Endpoint, false), bytes.NewBuffer(jsonData))
 	if err != nil {
 		LogError("Failed to create request", err, logF)
-		return "", nil, "", NewError(ErrAPIFailed, "Failed to create HTTP request", err, logF)
+		return nil, NewError(ErrAPIFailed, "Failed to create HTTP request", err, logF)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	if cfg.Type == "anthropic" {
-		req.Header.Set("x-api-key", cfg.APIKey)
-		req.Header.Set("anthropic-version", "2023-06-01")
-	} else if cfg.APIKey != "" {
-		req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
-	}
+	provider.SetAuth(req, cfg.APIKey)
 	for k, v := range cfg.Headers {
 		req.Header.Set(k, v)
 	}
@@ -1198,9 +1152,10 @@ func CallAPI(ctx context.Context, cfg *ProviderConfig, request map[string]interf
 	resp, err := defaultHTTPClient.Do(req)
 	if err != nil {
 		LogError("HTTP request failed", err, logF)
-		return "", nil, "", NewError(ErrAPIFailed, "HTTP request failed", err, logF)
+		return nil, NewError(ErrAPIFailed, "HTTP request failed", err, logF)
 	}
 	defer resp.Body.Close()
+	reportRateLimit(cfg, resp)
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
@@ -1208,18 +1163,10 @@ func CallAPI(ctx context.Context, cfg *ProviderConfig, request map[string]interf
 		logF["body"] = string(body)
 		LogError("HTTP error", nil, logF)
 
-		// Retry-After Header parsen
-		var retryAfter time.Duration
-		if retryHeader := resp.Header.Get("Retry-After"); retryHeader != "" {
-			if seconds, err := strconv.Atoi(retryHeader); err == nil {
-				retryAfter = time.Duration(seconds) * time.Second
-			}
-		}
-
-		// APIError mit Status-Code erstellen
-		apiErr := classifyHTTPError(resp.StatusCode, string(body), nil)
-		apiErr.RetryAfter = retryAfter
-		return "", nil, "", apiErr
+		// APIError mit Status-Code und Retry-After erstellen
+		apiErr := provider.MapHTTPError(resp.StatusCode, string(body))
+		apiErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		return nil, apiErr
 	}
 
 	body, _ := io.ReadAll(resp.Body)
@@ -1231,7 +1178,7 @@ func CallAPI(ctx context.Context, cfg *ProviderConfig, request map[string]interf
 	var result map[string]interface{}
 	if err := json.Unmarshal(body, &result); err != nil {
 		LogError("Failed to parse response", err, logF)
-		return "", nil, "", NewError(ErrAPIFailed, "Failed to parse JSON response", err, logF)
+		return nil, NewError(ErrAPIFailed, "Failed to parse JSON response", err, logF)
 	}
 
 	// Fehler in der API-Antwort
@@ -1241,90 +1188,53 @@ func CallAPI(ctx context.Context, cfg *ProviderConfig, request map[string]interf
 
 		// Prüfe auf Context-Limit-Fehler -> client_error
 		if isContextLimitError(errText) {
-			return "", nil, "", &APIError{
+			return nil, &APIError{
 				Type:       ErrClientError,
 				StatusCode: 400,
 				Message:    errText,
 			}
 		}
 
-		return "", nil, "", NewError(ErrAPIFailed, errText, nil, logF)
-	}
-
-	// Usage-Daten extrahieren (beide Formate)
-	usage := extractUsage(result, cfg.Type)
-
-	// finish_reason extrahieren
-	finishReason := ""
-	if choices, ok := result["choices"].([]interface{}); ok && len(choices) > 0 {
-		if choice, ok := choices[0].(map[string]interface{}); ok {
-			if fr, ok := choice["finish_reason"].(string); ok {
-				finishReason = fr
-			}
-		}
-	}
-	// Anthropic fallback: stop_reason
-	if finishReason == "" && cfg.Type == "anthropic" {
-		if sr, ok := result["stop_reason"].(string); ok {
-			finishReason = sr
-		}
-	}
-
-	// Anthropic-Format: content[0].text
-	if cfg.Type == "anthropic" {
-		if content, ok := result["content"].([]interface{}); ok && len(content) > 0 {
-			if text, ok := content[0].(map[string]interface{})["text"].(string); ok {
-				return text, usage, finishReason, nil
-			}
-		}
+		return nil, NewError(ErrAPIFailed, errText, nil, logF)
 	}
 
-	// OpenAI-Format: choices[0].message.content
-	if choices, ok := result["choices"].([]interface{}); ok && len(choices) > 0 {
-		if msg, ok := choices[0].(map[string]interface{})["message"].(map[string]interface{}); ok {
-			if content, ok := msg["content"].(string); ok {
-				return content, usage, finishReason, nil
-			}
-			// content:null → finish_reason:length (max_tokens:0 Fallback)
-			if msg["content"] == nil {
-				return "", usage, finishReason, NewError(ErrClientError,
-					"leere Antwort: max_tokens zu niedrig oder Modell-Limit erreicht", nil, logF)
-			}
-		}
+	// Provider-Format → ChatResult (OpenAI: choices[0].message, Anthropic: content-Blöcke)
+	res, err := provider.ParseChatResponse(result)
+	if err != nil {
+		LogError("Failed to interpret response", err, logF)
 	}
-
-	LogError("Unexpected response format", nil, logF)
-	return "", nil, "", NewError(ErrUnexpectedFormat, "Unexpected response format", nil, logF)
+	return res, err
 }
 
 // **********************************************************************
-// CallAPIStream führt einen Streaming-HTTP-Call zu einem OpenAI-kompatiblen
-// Provider durch. Der zurückgegebene io.ReadCloser muss vom Aufrufer
-// geschlossen werden. Timeout/Deadline kommen aus ctx.
+// CallAPIStream führt einen Streaming-HTTP-Call zu einem Provider durch.
+// Der zurückgegebene io.ReadCloser muss vom Aufrufer geschlossen werden.
+// Timeout/Deadline kommen aus ctx. Der Stream ist immer OpenAI-SSE; native
+// Formate übersetzt ein StreamTranslator (siehe stream_translate.go).
 func CallAPIStream(ctx context.Context, cfg *ProviderConfig, request map[string]interface{}) (io.ReadCloser, error) {
 	logF := map[string]interface{}{"endpoint": cfg.Endpoint, "model": cfg.Model, "stream": true}
 	LogDebug("Making streaming API request", logF)
 
 	request["stream"] = true
+	provider := providerForConfig(cfg)
+	request, err := provider.BuildChatRequest(request)
+	if err != nil {
+		return nil, err
+	}
 
 	jsonData, err := json.Marshal(request)
 	if err != nil {
 		return nil, NewError(ErrAPIFailed, "Failed to marshal request", err, logF)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", cfg.Endpoint, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", provider.ChatEndpoint(cfg.Endpoint, true), bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, NewError(ErrAPIFailed, "Failed to create HTTP request", err, logF)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "text/event-stream")
-	if cfg.Type == "anthropic" {
-		req.Header.Set("x-api-key", cfg.APIKey)
-		req.Header.Set("anthropic-version", "2023-06-01")
-	} else if cfg.APIKey != "" {
-		req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
-	}
+	provider.SetAuth(req, cfg.APIKey)
 	for k, v := range cfg.Headers {
 		req.Header.Set(k, v)
 	}
@@ -1333,6 +1243,7 @@ func CallAPIStream(ctx context.Context, cfg *ProviderConfig, request map[string]
 	if err != nil {
 		return nil, NewError(ErrAPIFailed, "HTTP request failed", err, logF)
 	}
+	reportRateLimit(cfg, resp)
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
@@ -1340,10 +1251,15 @@ func CallAPIStream(ctx context.Context, cfg *ProviderConfig, request map[string]
 		logF["status_code"] = resp.StatusCode
 		logF["body"] = string(body)
 		LogError("HTTP error", nil, logF)
-		apiErr := classifyHTTPError(resp.StatusCode, string(body), nil)
+		apiErr := provider.MapHTTPError(resp.StatusCode, string(body))
+		apiErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
 		return nil, apiErr
 	}
 
+	// Native Stream-Formate (Anthropic) in OpenAI-SSE übersetzen
+	if tr := provider.NewStreamTranslator(cfg.Model); tr != nil {
+		return TranslateStream(resp.Body, tr), nil
+	}
 	return resp.Body, nil
 }
 
@@ -1366,7 +1282,9 @@ func extractUsage(result map[string]interface{}, providerType string) *UsageData
 
 	// Versuche provider-spezifische Feldnamen
 	if providerType == "anthropic" {
-		usage.InputTokens = toInt(u["input_tokens"])
+		// Cache-Tokens zählen bei Anthropic nicht in input_tokens mit
+		usage.InputTokens = toInt(u["input_tokens"]) +
+			toInt(u["cache_creation_input_tokens"]) + toInt(u["cache_read_input_tokens"])
 		usage.OutputTokens = toInt(u["output_tokens"])
 	} else {
 		usage.InputTokens = toInt(u["prompt_tokens"])
@@ -1391,14 +1309,19 @@ func extractUsage(result map[string]interface{}, providerType string) *UsageData
 	return usage
 }
 
-// EstimateUsage schatzt Token-Verbrauch heuristisch
-// Konservative Naherung: 3 Zeichen ~ 1 Token (westliche Sprachen)
+// EstimateUsage schatzt Token-Verbrauch mit dem Default-Tokenizer (cl100k_base)
 func EstimateUsage(inputText, outputText string) *UsageData {
-	inputTokens := len([]rune(inputText)) / 3
+	return TokenizerForModel("").EstimateUsage(inputText, outputText)
+}
+
+// EstimateUsage zahlt Token-Verbrauch lokal mit diesem Tokenizer
+// (Fallback, wenn der Provider keine Usage liefert)
+func (t *Tokenizer) EstimateUsage(inputText, outputText string) *UsageData {
+	inputTokens := t.Count(inputText)
 	if inputTokens < 1 {
 		inputTokens = 1
 	}
-	outputTokens := len([]rune(outputText)) / 3
+	outputTokens := t.Count(outputText)
 	if outputTokens < 1 {
 		outputTokens = 1
 	}
@@ -0,0 +1,598 @@
+//**********************************************************************
+//      sigoengine/gemini.go
+//**********************************************************************
+//  Beschreibung: Adapter für die native Google Gemini API
+//  (generateContent / streamGenerateContent). Übersetzt OpenAI-Chat-
+//  Requests (Rollen, systemInstruction, Bild-Parts, tool_calls/tool-
+//  Ergebnisse) ins Gemini-Format, Antworten inkl. finishReason
+//  (Safety-Blocks → content_filter) und usageMetadata zurück, sowie
+//  Gemini-SSE in OpenAI chat.completion.chunk-Events.
+//**********************************************************************
+
+package sigoengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// Direkte Gemini-Modelle tragen dieses ID-Präfix, damit sie nicht mit
+// den gleichnamigen Mammouth-Modellen (z.B. gemini-2.5-pro) kollidieren.
+const geminiModelPrefix = "gemini/"
+
+// GeminiModelName liefert den Modellnamen, den die Gemini API erwartet
+// (ohne "gemini/"-Präfix der Registry).
+func GeminiModelName(id string) string {
+	return strings.TrimPrefix(id, geminiModelPrefix)
+}
+
+// GeminiChatEndpoint liefert den generateContent-Endpoint eines Modells;
+// Gemini kodiert das Modell in der URL statt im Request-Body.
+func GeminiChatEndpoint(model string) string {
+	return geminiAPIBase + "/models/" + GeminiModelName(model) + ":generateContent"
+}
+
+// geminiProvider: generateContent statt /chat/completions, x-goog-api-key-
+// Auth, eigener Streaming-Endpoint und eigenes SSE-Format.
+type geminiProvider struct {
+	OpenAICompatProvider
+}
+
+func init() {
+	RegisterProvider(&geminiProvider{OpenAICompatProvider{
+		ProviderName:   "gemini",
+		KeyEnvPrefix:   "GEMINI",
+		Hosts:          []string{"generativelanguage.googleapis.com"},
+		ModelsURL:      geminiModelsEndpoint,
+		ModelsNeedAuth: true,
+	}})
+}
+
+func (p *geminiProvider) Type() string { return "gemini" }
+
+// ClaimsModel: nur IDs mit "gemini/"-Präfix; "gemini-2.5-pro" ohne Präfix
+// bleibt beim Default-Provider (Mammouth).
+func (p *geminiProvider) ClaimsModel(modelID string) bool {
+	return strings.HasPrefix(modelID, geminiModelPrefix)
+}
+
+// FetchModels lädt Modelle nur mit GEMINI_API_KEY (IDs mit "gemini/"-Präfix).
+func (p *geminiProvider) FetchModels() ([]Model, error) {
+	if GetEnvWithFile("GEMINI_API_KEY") == "" {
+		return nil, nil
+	}
+	return FetchGeminiModels()
+}
+
+// SetAuth: Gemini nutzt x-goog-api-key statt Bearer.
+func (p *geminiProvider) SetAuth(req *http.Request, apiKey string) {
+	if apiKey != "" {
+		req.Header.Set("x-goog-api-key", apiKey)
+	}
+}
+
+// ChatEndpoint: Streaming läuft über streamGenerateContent mit alt=sse.
+func (p *geminiProvider) ChatEndpoint(endpoint string, stream bool) string {
+	if stream {
+		return strings.Replace(endpoint, ":generateContent", ":streamGenerateContent?alt=sse", 1)
+	}
+	return endpoint
+}
+
+func (p *geminiProvider) BuildChatRequest(request map[string]interface{}) (map[string]interface{}, error) {
+	return ToGeminiRequest(request)
+}
+
+func (p *geminiProvider) ParseChatResponse(result map[string]interface{}) (*ChatResult, error) {
+	return FromGeminiResponse(result)
+}
+
+func (p *geminiProvider) NewStreamTranslator(model string) StreamTranslator {
+	return NewGeminiStreamTranslator(model)
+}
+
+// **********************************************************************
+// OpenAI → Gemini Request
+
+// geminiChatRequest ergänzt openAIChatRequest um die Felder, die nur
+// Gemini in generationConfig abbildet.
+type geminiChatRequest struct {
+	openAIChatRequest
+	Seed             *int     `json:"seed"`
+	PresencePenalty  *float64 `json:"presence_penalty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty"`
+	ResponseFormat   *struct {
+		Type       string `json:"type"`
+		JSONSchema struct {
+			Schema json.RawMessage `json:"schema"`
+		} `json:"json_schema"`
+	} `json:"response_format"`
+}
+
+// ToGeminiRequest wandelt einen OpenAI-Chat-Request in einen Gemini
+// generateContent-Request um:
+//   - role:"system"/"developer" wird zu systemInstruction
+//   - "assistant" wird zu "model", alle anderen Rollen zu "user"
+//   - image_url-Parts werden zu inlineData (data:-URI) oder fileData (URL)
+//   - Assistant-tool_calls werden zu functionCall-, role:"tool" zu
+//     functionResponse-Parts (Name über die tool_call_id)
+//   - aufeinanderfolgende Nachrichten gleicher Rolle werden zusammengeführt
+//
+// Das Modell steht in der URL (GeminiChatEndpoint), nicht im Request.
+func ToGeminiRequest(request map[string]interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, NewError(ErrInvalidInput, "Request nicht serialisierbar", err, nil)
+	}
+	var in geminiChatRequest
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, NewError(ErrInvalidInput, "Request nicht im OpenAI-Format", err, nil)
+	}
+
+	var systemParts []map[string]interface{}
+	var contents []map[string]interface{}
+	appendParts := func(role string, parts []map[string]interface{}) {
+		if len(parts) == 0 {
+			return
+		}
+		if n := len(contents); n > 0 && contents[n-1]["role"] == role {
+			prev := contents[n-1]["parts"].([]map[string]interface{})
+			contents[n-1]["parts"] = append(prev, parts...)
+			return
+		}
+		contents = append(contents, map[string]interface{}{"role": role, "parts": parts})
+	}
+
+	// functionResponse braucht den Funktionsnamen, OpenAI liefert nur die ID
+	toolNames := make(map[string]string)
+	for _, m := range in.Messages {
+		switch m.Role {
+		case "system", "developer":
+			if text := ExtractTextFromContent(m.Content); text != "" && string(m.Content) != "null" {
+				systemParts = append(systemParts, map[string]interface{}{"text": text})
+			}
+		case "tool":
+			appendParts("user", []map[string]interface{}{{
+				"functionResponse": map[string]interface{}{
+					"name":     toolNames[m.ToolCallID],
+					"response": geminiFunctionResponse(ExtractTextFromContent(m.Content)),
+				},
+			}})
+		case "assistant":
+			parts := geminiParts(m.Content)
+			for _, tc := range m.ToolCalls {
+				toolNames[tc.ID] = tc.Function.Name
+				args := json.RawMessage(tc.Function.Arguments)
+				if len(strings.TrimSpace(tc.Function.Arguments)) == 0 || !json.Valid(args) {
+					args = json.RawMessage("{}")
+				}
+				parts = append(parts, map[string]interface{}{
+					"functionCall": map[string]interface{}{"name": tc.Function.Name, "args": args},
+				})
+			}
+			appendParts("model", parts)
+		default:
+			appendParts("user", geminiParts(m.Content))
+		}
+	}
+
+	out := map[string]interface{}{"contents": contents}
+	if len(systemParts) > 0 {
+		out["systemInstruction"] = map[string]interface{}{"parts": systemParts}
+	}
+
+	gen := map[string]interface{}{}
+	maxTokens := in.MaxTokens
+	if in.MaxCompletionTokens > 0 {
+		maxTokens = in.MaxCompletionTokens
+	}
+	if maxTokens > 0 {
+		gen["maxOutputTokens"] = maxTokens
+	}
+	if in.Temperature != nil {
+		gen["temperature"] = *in.Temperature
+	}
+	if in.TopP != nil {
+		gen["topP"] = *in.TopP
+	}
+	if in.TopK != nil {
+		gen["topK"] = *in.TopK
+	}
+	if in.Seed != nil {
+		gen["seed"] = *in.Seed
+	}
+	if in.PresencePenalty != nil {
+		gen["presencePenalty"] = *in.PresencePenalty
+	}
+	if in.FrequencyPenalty != nil {
+		gen["frequencyPenalty"] = *in.FrequencyPenalty
+	}
+	if stops := anthropicStopSequences(in.Stop); len(stops) > 0 {
+		gen["stopSequences"] = stops
+	}
+	if rf := in.ResponseFormat; rf != nil && (rf.Type == "json_object" || rf.Type == "json_schema") {
+		gen["responseMimeType"] = "application/json"
+		if schema := rf.JSONSchema.Schema; len(schema) > 0 && string(schema) != "null" {
+			gen["responseJsonSchema"] = schema
+		}
+	}
+	if len(gen) > 0 {
+		out["generationConfig"] = gen
+	}
+
+	if len(in.Tools) > 0 {
+		decls := make([]map[string]interface{}, 0, len(in.Tools))
+		for _, t := range in.Tools {
+			decl := map[string]interface{}{"name": t.Function.Name}
+			if t.Function.Description != "" {
+				decl["description"] = t.Function.Description
+			}
+			// parametersJsonSchema akzeptiert JSON Schema wie OpenAI
+			// (parameters nur eine OpenAPI-Teilmenge)
+			if schema := t.Function.Parameters; len(schema) > 0 && string(schema) != "null" {
+				decl["parametersJsonSchema"] = schema
+			}
+			decls = append(decls, decl)
+		}
+		out["tools"] = []map[string]interface{}{{"functionDeclarations": decls}}
+		if cfg := geminiToolConfig(in.ToolChoice); cfg != nil {
+			out["toolConfig"] = map[string]interface{}{"functionCallingConfig": cfg}
+		}
+	}
+
+	return out, nil
+}
+
+// geminiParts wandelt OpenAI-Content (String oder Vision-Array) in
+// Gemini-Parts um.
+func geminiParts(raw json.RawMessage) []map[string]interface{} {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		if s == "" {
+			return nil
+		}
+		return []map[string]interface{}{{"text": s}}
+	}
+	var parts []openAIContentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return []map[string]interface{}{{"text": string(raw)}}
+	}
+	var out []map[string]interface{}
+	for _, p := range parts {
+		switch p.Type {
+		case "text":
+			if p.Text != "" {
+				out = append(out, map[string]interface{}{"text": p.Text})
+			}
+		case "image_url":
+			if part := geminiImagePart(p.ImageURL.URL); part != nil {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
+
+// geminiImagePart wandelt eine image_url in einen Gemini-Part um:
+// data:-URIs werden zu inlineData, URLs zu fileData (MIME-Typ aus der
+// Dateiendung, Default image/jpeg).
+func geminiImagePart(url string) map[string]interface{} {
+	if strings.HasPrefix(url, "data:") {
+		// data:image/png;base64,<daten>
+		meta, payload, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+		if !ok {
+			return nil
+		}
+		return map[string]interface{}{"inlineData": map[string]interface{}{
+			"mimeType": strings.TrimSuffix(meta, ";base64"),
+			"data":     payload,
+		}}
+	}
+	if url == "" {
+		return nil
+	}
+	mimeType := mime.TypeByExtension(strings.ToLower(path.Ext(strings.SplitN(url, "?", 2)[0])))
+	if !strings.HasPrefix(mimeType, "image/") {
+		mimeType = "image/jpeg"
+	}
+	return map[string]interface{}{"fileData": map[string]interface{}{
+		"mimeType": mimeType,
+		"fileUri":  url,
+	}}
+}
+
+// geminiFunctionResponse: Gemini erwartet ein JSON-Objekt. Tool-Ergebnisse,
+// die bereits ein Objekt sind, gehen unverändert durch, alles andere
+// wird als {"content": ...} verpackt.
+func geminiFunctionResponse(text string) interface{} {
+	var obj map[string]interface{}
+	if json.Unmarshal([]byte(text), &obj) == nil && obj != nil {
+		return obj
+	}
+	return map[string]interface{}{"content": text}
+}
+
+// geminiToolConfig übersetzt OpenAI tool_choice ("auto", "none",
+// "required" oder {"type":"function","function":{"name":...}}) in
+// functionCallingConfig.
+func geminiToolConfig(raw json.RawMessage) map[string]interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	var mode string
+	if json.Unmarshal(raw, &mode) == nil {
+		switch mode {
+		case "auto":
+			return map[string]interface{}{"mode": "AUTO"}
+		case "none":
+			return map[string]interface{}{"mode": "NONE"}
+		case "required":
+			return map[string]interface{}{"mode": "ANY"}
+		}
+		return nil
+	}
+	var named struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if json.Unmarshal(raw, &named) == nil && named.Function.Name != "" {
+		return map[string]interface{}{"mode": "ANY", "allowedFunctionNames": []string{named.Function.Name}}
+	}
+	return nil
+}
+
+// **********************************************************************
+// Gemini → OpenAI Antwort
+
+// geminiFinishReason bildet Gemini finishReason auf OpenAI finish_reason ab.
+// Safety-/Policy-Abbrüche werden zu content_filter.
+func geminiFinishReason(reason string) string {
+	switch reason {
+	case "", "FINISH_REASON_UNSPECIFIED":
+		return ""
+	case "STOP":
+		return "stop"
+	case "MAX_TOKENS":
+		return "length"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII",
+		"IMAGE_SAFETY", "IMAGE_PROHIBITED_CONTENT":
+		return "content_filter"
+	default:
+		return strings.ToLower(reason)
+	}
+}
+
+// geminiUsage liest usageMetadata. Thinking-Tokens werden als Output
+// abgerechnet, zählen aber nicht in candidatesTokenCount.
+func geminiUsage(result map[string]interface{}) *UsageData {
+	meta, ok := result["usageMetadata"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	usage := extractUsage(map[string]interface{}{"usage": meta}, "gemini")
+	if thoughts := intField(meta, "thoughtsTokenCount"); thoughts > 0 {
+		usage.OutputTokens += thoughts
+		usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+	}
+	return usage
+}
+
+// geminiPromptBlocked meldet promptFeedback.blockReason (Prompt selbst
+// blockiert, die Antwort enthält dann keine candidates).
+func geminiPromptBlocked(result map[string]interface{}) string {
+	feedback, _ := result["promptFeedback"].(map[string]interface{})
+	reason, _ := feedback["blockReason"].(string)
+	return reason
+}
+
+// geminiCandidate liefert candidates[0] (nil wenn keiner vorhanden).
+func geminiCandidate(result map[string]interface{}) map[string]interface{} {
+	candidates, _ := result["candidates"].([]interface{})
+	if len(candidates) == 0 {
+		return nil
+	}
+	cand, _ := candidates[0].(map[string]interface{})
+	return cand
+}
+
+// geminiCandidateParts liefert die Parts eines Kandidaten ohne Thinking-Parts.
+func geminiCandidateParts(cand map[string]interface{}) []map[string]interface{} {
+	content, _ := cand["content"].(map[string]interface{})
+	raw, _ := content["parts"].([]interface{})
+	parts := make([]map[string]interface{}, 0, len(raw))
+	for _, r := range raw {
+		if part, ok := r.(map[string]interface{}); ok && part["thought"] != true {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// geminiToolCall wandelt einen functionCall-Part in einen OpenAI tool_call.
+// Gemini vergibt meist keine IDs; dann wird "call_<index>" genutzt.
+func geminiToolCall(fc map[string]interface{}, index int) map[string]interface{} {
+	args, _ := json.Marshal(fc["args"])
+	if fc["args"] == nil {
+		args = []byte("{}")
+	}
+	id, _ := fc["id"].(string)
+	if id == "" {
+		id = fmt.Sprintf("call_%d", index)
+	}
+	return map[string]interface{}{
+		"id":   id,
+		"type": "function",
+		"function": map[string]interface{}{
+			"name":      fc["name"],
+			"arguments": string(args),
+		},
+	}
+}
+
+// FromGeminiResponse wandelt eine generateContent-Antwort in ein ChatResult
+// um: Text-Parts von candidates[0] werden verkettet, functionCall-Parts zu
+// OpenAI tool_calls. Ein blockierter Prompt liefert eine leere Antwort mit
+// finish_reason "content_filter".
+func FromGeminiResponse(result map[string]interface{}) (*ChatResult, error) {
+	res := &ChatResult{Usage: geminiUsage(result)}
+	cand := geminiCandidate(result)
+	if cand == nil {
+		if reason := geminiPromptBlocked(result); reason != "" {
+			LogWarn("Gemini-Prompt blockiert", map[string]interface{}{"block_reason": reason})
+			res.FinishReason = "content_filter"
+			return res, nil
+		}
+		return nil, NewError(ErrUnexpectedFormat, "Gemini-Antwort ohne candidates", nil, nil)
+	}
+	if fr, ok := cand["finishReason"].(string); ok {
+		res.FinishReason = geminiFinishReason(fr)
+	}
+
+	var text strings.Builder
+	var calls []map[string]interface{}
+	for _, part := range geminiCandidateParts(cand) {
+		if t, ok := part["text"].(string); ok {
+			text.WriteString(t)
+		}
+		if fc, ok := part["functionCall"].(map[string]interface{}); ok {
+			calls = append(calls, geminiToolCall(fc, len(calls)))
+		}
+	}
+	res.Content = text.String()
+	if len(calls) > 0 {
+		res.ToolCalls, _ = json.Marshal(calls)
+		// Gemini meldet bei Funktionsaufrufen STOP
+		if res.FinishReason == "stop" {
+			res.FinishReason = "tool_calls"
+		}
+	}
+	return res, nil
+}
+
+// **********************************************************************
+// Gemini SSE → OpenAI chat.completion.chunk
+
+// GeminiStreamTranslator übersetzt streamGenerateContent-Events (jedes
+// data: ist eine vollständige GenerateContentResponse mit dem nächsten
+// Textstück) in OpenAI-Chunks. Gemini sendet kein Stream-Ende-Event;
+// [DONE] folgt auf den Chunk mit finishReason.
+// Nicht thread-safe; ein Translator pro Stream.
+type GeminiStreamTranslator struct {
+	model    string
+	id       string
+	created  int64
+	started  bool
+	nextTool int
+	usage    *UsageData
+}
+
+// NewGeminiStreamTranslator erzeugt einen Translator; model erscheint
+// im "model"-Feld der erzeugten Chunks.
+func NewGeminiStreamTranslator(model string) *GeminiStreamTranslator {
+	return &GeminiStreamTranslator{
+		model:   model,
+		id:      fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano()),
+		created: time.Now().Unix(),
+	}
+}
+
+// Translate verarbeitet den Inhalt einer "data: "-Zeile. Ein Gemini-
+// error-Objekt wird als APIError zurückgegeben.
+func (t *GeminiStreamTranslator) Translate(data string) ([]string, error) {
+	var ev map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &ev); err != nil {
+		return nil, nil
+	}
+	if errObj, ok := ev["error"].(map[string]interface{}); ok {
+		msg, _ := errObj["message"].(string)
+		code := intField(errObj, "code")
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		return nil, classifyHTTPError(code, msg, nil)
+	}
+
+	var out []string
+	if !t.started {
+		t.started = true
+		if id, ok := ev["responseId"].(string); ok && id != "" {
+			t.id = id
+		}
+		out = append(out, t.chunk(map[string]interface{}{"role": "assistant", "content": ""}, nil, nil))
+	}
+	if u := geminiUsage(ev); u != nil {
+		t.usage = u
+	}
+
+	cand := geminiCandidate(ev)
+	if cand == nil {
+		if geminiPromptBlocked(ev) == "" {
+			return out, nil
+		}
+		finish := "content_filter"
+		return append(out, t.chunk(map[string]interface{}{}, &finish, t.usageMap()), "[DONE]"), nil
+	}
+
+	for _, part := range geminiCandidateParts(cand) {
+		if text, ok := part["text"].(string); ok && text != "" {
+			out = append(out, t.chunk(map[string]interface{}{"content": text}, nil, nil))
+		}
+		if fc, ok := part["functionCall"].(map[string]interface{}); ok {
+			call := geminiToolCall(fc, t.nextTool)
+			call["index"] = t.nextTool
+			t.nextTool++
+			out = append(out, t.chunk(map[string]interface{}{"tool_calls": []map[string]interface{}{call}}, nil, nil))
+		}
+	}
+
+	fr, _ := cand["finishReason"].(string)
+	finish := geminiFinishReason(fr)
+	if finish == "" {
+		return out, nil
+	}
+	if finish == "stop" && t.nextTool > 0 {
+		finish = "tool_calls"
+	}
+	return append(out, t.chunk(map[string]interface{}{}, &finish, t.usageMap()), "[DONE]"), nil
+}
+
+// usageMap liefert die letzte usageMetadata im OpenAI-usage-Format.
+func (t *GeminiStreamTranslator) usageMap() map[string]interface{} {
+	if t.usage == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"prompt_tokens":     t.usage.InputTokens,
+		"completion_tokens": t.usage.OutputTokens,
+		"total_tokens":      t.usage.TotalTokens,
+	}
+}
+
+// chunk baut ein chat.completion.chunk-Payload.
+func (t *GeminiStreamTranslator) chunk(delta map[string]interface{}, finish *string, usage map[string]interface{}) string {
+	choice := map[string]interface{}{"index": 0, "delta": delta, "finish_reason": nil}
+	if finish != nil {
+		choice["finish_reason"] = *finish
+	}
+	c := map[string]interface{}{
+		"id":      t.id,
+		"object":  "chat.completion.chunk",
+		"created": t.created,
+		"model":   t.model,
+		"choices": []interface{}{choice},
+	}
+	if usage != nil {
+		c["usage"] = usage
+	}
+	data, _ := json.Marshal(c)
+	return string(data)
+}
@@ -0,0 +1,282 @@
+package sigoengine
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestToGeminiRequest(t *testing.T) {
+	request := map[string]interface{}{
+		"model": "gemini/gemini-2.5-flash",
+		"messages": []map[string]interface{}{
+			{"role": "system", "content": "Memory"},
+			{"role": "user", "content": []interface{}{
+				map[string]interface{}{"type": "text", "text": "Was ist das?"},
+				map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "data:image/png;base64,iVBORw0"}},
+				map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "https://example.com/katze.webp?x=1"}},
+			}},
+			Message{Role: "assistant", ToolCalls: json.RawMessage(`[{"id":"c1","type":"function","function":{"name":"wetter","arguments":"{\"ort\":\"Berlin\"}"}}]`)}.ToMap(),
+			{"role": "tool", "tool_call_id": "c1", "content": "sonnig"},
+			{"role": "user", "content": "Und morgen?"},
+		},
+		"temperature":     0.5,
+		"max_tokens":      100,
+		"stop":            []string{"ENDE"},
+		"seed":            7,
+		"response_format": map[string]interface{}{"type": "json_schema", "json_schema": map[string]interface{}{"schema": map[string]interface{}{"type": "object"}}},
+		"tools":           json.RawMessage(`[{"type":"function","function":{"name":"wetter","description":"Wetter","parameters":{"type":"object"}}}]`),
+		"tool_choice":     json.RawMessage(`{"type":"function","function":{"name":"wetter"}}`),
+	}
+	out, err := ToGeminiRequest(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(out)
+	var got struct {
+		Model             string `json:"model"`
+		SystemInstruction struct {
+			Parts []map[string]interface{} `json:"parts"`
+		} `json:"systemInstruction"`
+		Contents []struct {
+			Role  string                   `json:"role"`
+			Parts []map[string]interface{} `json:"parts"`
+		} `json:"contents"`
+		GenerationConfig map[string]interface{} `json:"generationConfig"`
+		Tools            []struct {
+			FunctionDeclarations []map[string]interface{} `json:"functionDeclarations"`
+		} `json:"tools"`
+		ToolConfig struct {
+			FunctionCallingConfig map[string]interface{} `json:"functionCallingConfig"`
+		} `json:"toolConfig"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Model != "" {
+		t.Errorf("model gehört in die URL, nicht in den Body: %q", got.Model)
+	}
+	if len(got.SystemInstruction.Parts) != 1 || got.SystemInstruction.Parts[0]["text"] != "Memory" {
+		t.Errorf("systemInstruction falsch: %s", data)
+	}
+	// user, model, user (functionResponse + Text zusammengeführt)
+	if len(got.Contents) != 3 || got.Contents[1].Role != "model" || got.Contents[2].Role != "user" {
+		t.Fatalf("Rollen falsch: %s", data)
+	}
+	user := got.Contents[0].Parts
+	inline, _ := user[1]["inlineData"].(map[string]interface{})
+	file, _ := user[2]["fileData"].(map[string]interface{})
+	if inline["mimeType"] != "image/png" || inline["data"] != "iVBORw0" {
+		t.Errorf("inlineData falsch: %v", user[1])
+	}
+	if file["mimeType"] != "image/webp" || file["fileUri"] != "https://example.com/katze.webp?x=1" {
+		t.Errorf("fileData falsch: %v", user[2])
+	}
+	call, _ := got.Contents[1].Parts[0]["functionCall"].(map[string]interface{})
+	if args, _ := call["args"].(map[string]interface{}); call["name"] != "wetter" || args["ort"] != "Berlin" {
+		t.Errorf("functionCall falsch: %v", got.Contents[1].Parts)
+	}
+	last := got.Contents[2].Parts
+	resp, _ := last[0]["functionResponse"].(map[string]interface{})
+	if len(last) != 2 || resp["name"] != "wetter" || last[1]["text"] != "Und morgen?" {
+		t.Errorf("functionResponse falsch: %v", last)
+	}
+	if inner, _ := resp["response"].(map[string]interface{}); inner["content"] != "sonnig" {
+		t.Errorf("functionResponse.response falsch: %v", resp)
+	}
+	gen := got.GenerationConfig
+	if gen["temperature"] != 0.5 || gen["maxOutputTokens"] != float64(100) || gen["seed"] != float64(7) ||
+		gen["responseMimeType"] != "application/json" || gen["responseJsonSchema"] == nil {
+		t.Errorf("generationConfig falsch: %v", gen)
+	}
+	if stops, _ := gen["stopSequences"].([]interface{}); len(stops) != 1 || stops[0] != "ENDE" {
+		t.Errorf("stopSequences falsch: %v", gen["stopSequences"])
+	}
+	if len(got.Tools) != 1 || got.Tools[0].FunctionDeclarations[0]["parametersJsonSchema"] == nil {
+		t.Errorf("tools falsch: %s", data)
+	}
+	fcc := got.ToolConfig.FunctionCallingConfig
+	if names, _ := fcc["allowedFunctionNames"].([]interface{}); fcc["mode"] != "ANY" || len(names) != 1 {
+		t.Errorf("toolConfig falsch: %v", fcc)
+	}
+}
+
+func TestFromGeminiResponse(t *testing.T) {
+	body := `{
+		"candidates": [{
+			"content": {"role": "model", "parts": [
+				{"text": "nachdenken", "thought": true},
+				{"text": "Ich schaue nach."},
+				{"functionCall": {"name": "wetter", "args": {"ort": "Berlin"}}}
+			]},
+			"finishReason": "STOP"
+		}],
+		"usageMetadata": {"promptTokenCount": 10, "candidatesTokenCount": 7, "thoughtsTokenCount": 3, "totalTokenCount": 20}
+	}`
+	var result map[string]interface{}
+	json.Unmarshal([]byte(body), &result)
+
+	res, err := FromGeminiResponse(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Content != "Ich schaue nach." || res.FinishReason != "tool_calls" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if res.Usage == nil || res.Usage.InputTokens != 10 || res.Usage.OutputTokens != 10 || res.Usage.TotalTokens != 20 {
+		t.Fatalf("unexpected usage: %+v", res.Usage)
+	}
+	var calls []openAIToolCall
+	if err := json.Unmarshal(res.ToolCalls, &calls); err != nil || len(calls) != 1 {
+		t.Fatalf("invalid tool_calls: %s", res.ToolCalls)
+	}
+	if calls[0].ID != "call_0" || calls[0].Function.Name != "wetter" || calls[0].Function.Arguments != `{"ort":"Berlin"}` {
+		t.Fatalf("unexpected tool call: %+v", calls[0])
+	}
+}
+
+func TestFromGeminiResponseBlocked(t *testing.T) {
+	for _, body := range []string{
+		`{"promptFeedback":{"blockReason":"SAFETY"},"usageMetadata":{"promptTokenCount":4}}`,
+		`{"candidates":[{"finishReason":"SAFETY","safetyRatings":[]}]}`,
+		`{"candidates":[{"content":{"parts":[{"text":"..."}]},"finishReason":"RECITATION"}]}`,
+	} {
+		var result map[string]interface{}
+		json.Unmarshal([]byte(body), &result)
+		res, err := FromGeminiResponse(result)
+		if err != nil || res.FinishReason != "content_filter" {
+			t.Errorf("%s: %+v, %v", body, res, err)
+		}
+	}
+	if _, err := FromGeminiResponse(map[string]interface{}{}); err == nil {
+		t.Error("Antwort ohne candidates: Fehler erwartet")
+	}
+}
+
+func TestGeminiStreamTranslator(t *testing.T) {
+	events := []string{
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Hal"}]}}],"usageMetadata":{"promptTokenCount":12},"responseId":"r1"}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"lo"}]}}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"wetter","args":{"ort":"Berlin"}}}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":12,"candidatesTokenCount":20,"totalTokenCount":32}}`,
+	}
+	tr := NewGeminiStreamTranslator("g-gem25f")
+	acc := NewStreamAccumulator()
+	var last []string
+	for _, ev := range events {
+		out, err := tr.Translate(ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, data := range out {
+			acc.AddData(data)
+		}
+		last = out
+	}
+	if n := len(last); n == 0 || last[n-1] != "[DONE]" {
+		t.Fatalf("expected [DONE] at end, got %v", last)
+	}
+	res := acc.Result()
+	if res.Content != "Hallo" || res.FinishReason != "tool_calls" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if res.Usage == nil || res.Usage.InputTokens != 12 || res.Usage.OutputTokens != 20 {
+		t.Fatalf("unexpected usage: %+v", res.Usage)
+	}
+	var calls []openAIToolCall
+	if err := json.Unmarshal(res.ToolCalls, &calls); err != nil || len(calls) != 1 {
+		t.Fatalf("invalid tool_calls: %s", res.ToolCalls)
+	}
+	if calls[0].Function.Name != "wetter" || calls[0].Function.Arguments != `{"ort":"Berlin"}` {
+		t.Fatalf("unexpected tool call: %+v", calls[0])
+	}
+}
+
+func TestGeminiStreamTranslatorBlockedAndError(t *testing.T) {
+	tr := NewGeminiStreamTranslator("m")
+	out, err := tr.Translate(`{"candidates":[{"content":{"parts":[{"text":"x"}]},"finishReason":"SAFETY"}]}`)
+	if err != nil || len(out) == 0 || out[len(out)-1] != "[DONE]" || !strings.Contains(out[len(out)-2], `"content_filter"`) {
+		t.Fatalf("safety block: %v, %v", out, err)
+	}
+
+	_, err = NewGeminiStreamTranslator("m").Translate(`{"error":{"code":503,"message":"overloaded","status":"UNAVAILABLE"}}`)
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.Type != ErrServerError || !apiErr.IsRetryable() {
+		t.Fatalf("expected retryable server error, got %v", err)
+	}
+}
+
+func TestCallAPIGemini(t *testing.T) {
+	var paths []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path+"?"+r.URL.RawQuery)
+		if r.Header.Get("x-goog-api-key") != "g-key" || r.Header.Get("Authorization") != "" {
+			t.Errorf("unexpected auth headers: %v", r.Header)
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["systemInstruction"] == nil || body["stream"] != nil {
+			t.Errorf("unexpected request body: %v", body)
+		}
+		if strings.Contains(r.URL.Path, ":streamGenerateContent") {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte(`data: {"candidates":[{"content":{"parts":[{"text":"Hal"}]}}]}` + "\r\n\r\n" +
+				`data: {"candidates":[{"content":{"parts":[{"text":"lo"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":3,"candidatesTokenCount":2}}` + "\r\n\r\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"Hallo"}]},"finishReason":"MAX_TOKENS"}],"usageMetadata":{"promptTokenCount":3,"candidatesTokenCount":2}}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &ProviderConfig{
+		Endpoint: upstream.URL + "/v1beta/models/gemini-2.5-flash:generateContent",
+		Model:    "gemini/gemini-2.5-flash", APIKey: "g-key", Type: "gemini", Provider: "gemini",
+	}
+	request := func() map[string]interface{} {
+		return map[string]interface{}{
+			"model": cfg.Model,
+			"messages": []map[string]interface{}{
+				{"role": "system", "content": "Sei knapp."},
+				{"role": "user", "content": "Hi"},
+			},
+		}
+	}
+	res, err := CallAPIResult(context.Background(), cfg, request(), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Content != "Hallo" || res.FinishReason != "length" || res.Usage.TotalTokens != 5 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	stream, err := CallAPIStream(context.Background(), cfg, request())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	raw, _ := io.ReadAll(stream)
+	acc := NewStreamAccumulator()
+	for _, line := range strings.Split(string(raw), "\n") {
+		if strings.HasPrefix(line, "data: ") {
+			acc.AddData(strings.TrimPrefix(line, "data: "))
+		}
+	}
+	if sr := acc.Result(); sr.Content != "Hallo" || sr.FinishReason != "stop" || !strings.HasSuffix(strings.TrimSpace(string(raw)), "[DONE]") {
+		t.Fatalf("unexpected translated stream: %+v\n%s", sr, raw)
+	}
+
+	want := []string{
+		"/v1beta/models/gemini-2.5-flash:generateContent?",
+		"/v1beta/models/gemini-2.5-flash:streamGenerateContent?alt=sse",
+	}
+	if len(paths) != 2 || paths[0] != want[0] || paths[1] != want[1] {
+		t.Fatalf("unexpected upstream paths: %v", paths)
+	}
+	if got := ProviderForModel("", "GEMINI_API_KEY", cfg.Model); got != "gemini" {
+		t.Errorf("ProviderForModel = %q, want gemini", got)
+	}
+}
@@ -10,19 +10,30 @@ package sigoengine
 // Verwendung eines bestimmten Kanals. Wenn ch nil ist, wird der Default-
 // Kanal verwendet (Rückwärtskompatibilität).
 func LoadConfigWithChannel(model string, ch *Channel) (*ProviderConfig, error) {
-	// Zuerst Ollama-Registry prüfen (shortcode direkt, kein Resolve nötig)
+	// Zuerst Ollama-Registry prüfen (shortcode direkt, kein Resolve nötig).
+	// Mit Kanal: Host des Kanals, das Modell muss dort installiert sein.
 	ollamaRegistryMu.RLock()
 	ollamaInfo, isOllama := ollamaRegistry[model]
 	ollamaRegistryMu.RUnlock()
 
 	if isOllama {
-		return &ProviderConfig{
-			Endpoint: "http://localhost:11434/v1/chat/completions",
+		cfg := &ProviderConfig{
+			Endpoint: ollamaInfo.Host + "/v1/chat/completions",
 			Model:    ollamaInfo.OllamaName,
-			APIKey:   "", // Ollama braucht keinen Key
+			APIKey:   "", // Ollama braucht keinen Key (außer hinter Proxy)
 			Type:     "ollama",
+			Provider: ollama.ProviderName,
 			Headers:  make(map[string]string),
-		}, nil
+		}
+		if ch != nil && ch.Provider == ollama.ProviderName {
+			if !ollamaInfo.onChannel(ch.Name) {
+				return nil, NewError(ErrConfigNotFound, "Modell auf diesem Ollama-Host nicht installiert", nil,
+					map[string]interface{}{"model": model, "channel": ch.FullName()})
+			}
+			cfg.Endpoint = ChannelEndpoint(ch, cfg.Endpoint)
+			cfg.APIKey = ch.APIKey
+		}
+		return cfg, nil
 	}
 
 	// Neue typisierte Registry nutzen
@@ -34,9 +45,10 @@ func LoadConfigWithChannel(model string, ch *Channel) (*ProviderConfig, error) {
 		// aus dem Kanal gebaut; der Endpoint wird vom Aufrufer (main.go) über das
 		// dynamische modelInfo gesetzt. Der Modellname wird 1:1 durchgereicht, damit
 		// Casing und Form zum Provider passen (Z.ai erwartet z.B. lowercase "glm-4.5").
-		// Type "mammoth" = OpenAI-kompatibles Bearer-Auth; alle aktuellen Provider
-		// (mammouth/moonshot/zai) proxen im OpenAI-Style, siehe CallAPI.
-		if ch != nil && ch.APIKey != "" {
+		// Type "mammoth" = OpenAI-kompatibles Bearer-Auth (mammouth/moonshot/zai),
+		// Type "anthropic" = native Messages API, siehe anthropic.go.
+		// Provider ohne Auth (providers.json, auth "none") brauchen keinen Key.
+		if ch != nil && (ch.APIKey != "" || keyOptional(ch.Provider)) {
 			LogDebug("Registry-Miss, nutze Channel-Only Config", map[string]interface{}{
 				"model":    model,
 				"provider": ch.Provider,
@@ -46,7 +58,8 @@ func LoadConfigWithChannel(model string, ch *Channel) (*ProviderConfig, error) {
 				Endpoint: "", // main.go überschreibt mit modelInfo.Endpoint
 				Model:    model,
 				APIKey:   ch.APIKey,
-				Type:     "mammoth",
+				Type:     configType(ch.Provider),
+				Provider: ch.Provider,
 				Headers:  make(map[string]string),
 			}, nil
 		}
@@ -63,11 +76,16 @@ func LoadConfigWithChannel(model string, ch *Channel) (*ProviderConfig, error) {
 			map[string]interface{}{"env_var": m.APIKeyEnv, "model": fullName})
 	}
 
+	provider := ProviderForModel(m.Endpoint, m.APIKeyEnv, m.ID)
+	if ch != nil {
+		provider = ch.Provider
+	}
 	return &ProviderConfig{
 		Endpoint: m.Endpoint,
 		Model:    fullName,
 		APIKey:   apiKey,
-		Type:     "mammoth",
+		Type:     configType(provider),
+		Provider: provider,
 		Headers:  make(map[string]string),
 	}, nil
 }
@@ -10,17 +10,20 @@ package sigoengine
 
 // Model repräsentiert eine AI-Modell-Konfiguration
 type Model struct {
-	ID                       string  // Vollständiger Modellname (z.B. "gpt-4.1")
-	Shortcode                string  // Kurzbezeichnung (z.B. "gpt41")
-	Endpoint                 string  // API URL
-	APIKeyEnv                string  // Environment-Variable für API Key
-	MaxInputTokens           int     // Maximale Input-Tokens (Kontextfenster)
-	MaxOutputTokens          int     // Maximale Output-Tokens
-	InputCost                float64 // Kosten pro 1M Input-Tokens ($)
-	OutputCost               float64 // Kosten pro 1M Output-Tokens ($)
-	MinTemperature           float64 // Minimale Temperatur
-	MaxTemperature           float64 // Maximale Temperatur
-	RequiresCompletionTokens bool    // Nutzt max_completion_tokens statt max_tokens (GPT-5)
+	ID                       string   // Vollständiger Modellname (z.B. "gpt-4.1")
+	Shortcode                string   // Kurzbezeichnung (z.B. "gpt41")
+	Endpoint                 string   // API URL
+	APIKeyEnv                string   // Environment-Variable für API Key
+	MaxInputTokens           int      // Maximale Input-Tokens (Kontextfenster)
+	MaxOutputTokens          int      // Maximale Output-Tokens
+	InputCost                float64  // Kosten pro 1M Input-Tokens ($)
+	OutputCost               float64  // Kosten pro 1M Output-Tokens ($)
+	MinTemperature           float64  // Minimale Temperatur
+	MaxTemperature           float64  // Maximale Temperatur
+	RequiresCompletionTokens bool     // Nutzt max_completion_tokens statt max_tokens (GPT-5)
+	Kind                     string   // "chat" (Default, leer) oder "embedding"
+	Tokenizer                string   // BPE-Encoding (leer = nach Modellfamilie)
+	Capabilities             []string // Features (vision, tools, ...), nil = unbekannt
 }
 
 // CoreModels enthält das Minimal-Set eingebetteter Modelle (Fallback)
@@ -160,7 +160,7 @@ func loadModelsFromCSV(path string) ([]Model, error) {
 }
 
 // parseCSVRecord parst einen CSV-Record zu einem Model
-// Format: id;shortcode;endpoint;apikey;max_input;max_output;input_cost;output_cost;min_temp;max_temp;requires_completion_tokens
+// Format: id;shortcode;endpoint;apikey;max_input;max_output;input_cost;output_cost;min_temp;max_temp;requires_completion_tokens;kind;tokenizer;capabilities
 func parseCSVRecord(record []string) (Model, error) {
 	// Trimme Whitespace von allen Feldern
 	for i := range record {
@@ -218,6 +218,23 @@ func parseCSVRecord(record []string) (Model, error) {
 		m.RequiresCompletionTokens = strings.ToLower(record[10]) == "true"
 	}
 
+	if len(record) > 11 && record[11] != "" {
+		m.Kind = strings.ToLower(record[11])
+	}
+
+	if len(record) > 12 && record[12] != "" {
+		m.Tokenizer = strings.ToLower(record[12])
+	}
+
+	// Capabilities: "vision,tools,streaming" oder "none"; leer = unbekannt
+	if len(record) > 13 && record[13] != "" {
+		caps, unknown := ParseCapabilities(record[13])
+		if len(unknown) > 0 {
+			LogWarn("Unbekannte Capabilities ignoriert", map[string]interface{}{"model": m.ID, "capabilities": unknown})
+		}
+		m.Capabilities = caps
+	}
+
 	return m, nil
 }
 
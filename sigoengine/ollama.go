@@ -0,0 +1,339 @@
+//**********************************************************************
+//      sigoengine/ollama.go
+//**********************************************************************
+//  Beschreibung: Ollama als Provider. Jeder konfigurierte Ollama-Host
+//  ist ein Kanal (eigener Health-Status, Rate-Limiter, Failover); die
+//  Modell-Registry wird periodisch neu von allen Hosts geladen, damit
+//  "ollama pull" ohne Neustart sichtbar wird.
+//**********************************************************************
+
+package sigoengine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultOllamaHost wird genutzt, wenn keine Hosts konfiguriert sind.
+const DefaultOllamaHost = "http://localhost:11434"
+
+// ollamaProvider: OpenAI-kompatibel über /v1/chat/completions, Typ
+// "ollama" (Embeddings über /api/embed). Kanäle kommen nicht aus
+// API-Key-Variablen, sondern aus den Hosts (AddOllamaHosts).
+type ollamaProvider struct {
+	OpenAICompatProvider
+	mu sync.RWMutex // schützt Hosts/ModelsURL (AddOllamaHosts)
+}
+
+var ollama = &ollamaProvider{OpenAICompatProvider: OpenAICompatProvider{
+	ProviderName:   "ollama",
+	Hosts:          []string{DefaultOllamaHost},
+	ModelsURL:      DefaultOllamaHost + "/api/tags",
+	ModelsNeedAuth: true, // nur wenn OLLAMA_API_KEY gesetzt (Reverse-Proxy)
+}}
+
+func init() {
+	RegisterProvider(ollama)
+}
+
+func (p *ollamaProvider) Type() string { return "ollama" }
+
+func (p *ollamaProvider) Matches(endpoint string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.OpenAICompatProvider.Matches(endpoint)
+}
+
+func (p *ollamaProvider) ProbeEndpoint() (string, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.OpenAICompatProvider.ProbeEndpoint()
+}
+
+// NormalizeOllamaHost ergänzt das Schema ("gpu01:11434" → "http://gpu01:11434")
+// und entfernt abschließende Slashes.
+func NormalizeOllamaHost(host string) string {
+	host = strings.TrimRight(strings.TrimSpace(host), "/")
+	if host != "" && !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	return host
+}
+
+// AddOllamaHosts legt je Host einen aktiven Kanal des Providers "ollama" an:
+// der erste heißt "default", weitere "0", "1", ... (wie indizierte API-Keys).
+// OLLAMA_API_KEY (optional, z.B. hinter einem Reverse-Proxy) gilt für alle Hosts.
+func (r *ChannelRegistry) AddOllamaHosts(hosts []string) {
+	apiKey := GetEnvWithFile("OLLAMA_API_KEY")
+	var normalized []string
+	for _, h := range hosts {
+		if h = NormalizeOllamaHost(h); h != "" {
+			normalized = append(normalized, h)
+		}
+	}
+	for i, host := range normalized {
+		name := "default"
+		if i > 0 {
+			name = fmt.Sprintf("%d", i-1)
+		}
+		r.AddChannel(&Channel{
+			Provider: ollama.ProviderName,
+			Name:     name,
+			APIKey:   apiKey,
+			BaseURL:  host,
+			Active:   true,
+			Order:    i,
+			Healthy:  true,
+		})
+	}
+	if len(normalized) > 0 {
+		ollama.mu.Lock()
+		ollama.Hosts = normalized
+		ollama.ModelsURL = normalized[0] + "/api/tags"
+		ollama.mu.Unlock()
+	}
+}
+
+// ChannelEndpoint setzt Schema und Host von endpoint auf die BaseURL des
+// Kanals (Ollama-Hosts); Kanäle ohne BaseURL nutzen endpoint unverändert.
+func ChannelEndpoint(ch *Channel, endpoint string) string {
+	if ch == nil || ch.BaseURL == "" {
+		return endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	path := u.Path
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return strings.TrimRight(ch.BaseURL, "/") + path
+}
+
+// ChannelServesModel meldet, ob ein Kanal das Modell bedienen kann. Nur für
+// Ollama-Hosts relevant (Modelle sind pro Host installiert); alle anderen
+// Kanäle bedienen jedes Modell ihres Providers.
+func ChannelServesModel(ch *Channel, model string) bool {
+	if ch == nil || ch.Provider != ollama.ProviderName {
+		return true
+	}
+	ollamaRegistryMu.RLock()
+	info, ok := ollamaRegistry[model]
+	ollamaRegistryMu.RUnlock()
+	return !ok || info.onChannel(ch.Name)
+}
+
+// **********************************************************************
+// Ollama Registry — wird zur Laufzeit via DiscoverOllamaModels befüllt
+
+// OllamaModelInfo beschreibt ein lokal installiertes Ollama-Modell
+type OllamaModelInfo struct {
+	Shortcode     string   // z.B. "ollama-llama3"
+	OllamaName    string   // z.B. "llama3:latest" (echter Ollama-Name)
+	Size          int64    `json:"size"`
+	ContextLength int      // aus /api/show, 0 wenn unbekannt
+	Kind          string   // ModelKindChat oder ModelKindEmbedding
+	Host          string   // erster Host mit dem Modell (Basis-URL)
+	Channels      []string // Kanal-Namen der Hosts, auf denen das Modell liegt
+}
+
+func (info OllamaModelInfo) onChannel(name string) bool {
+	for _, c := range info.Channels {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchOllamaShow fragt POST /api/show für ein Modell ab und gibt die
+// Context-Length (0 wenn nicht verfügbar) und die Modell-Art zurück.
+// Die Art kommt aus "capabilities" (ab Ollama 0.6); ältere Versionen
+// liefern das Feld nicht, dann entscheidet der Name ("embed").
+func fetchOllamaShow(endpoint, apiKey, modelName string) (int, string) {
+	kind := ModelKindChat
+	if strings.Contains(strings.ToLower(modelName), "embed") {
+		kind = ModelKindEmbedding
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	type showReq struct {
+		Name string `json:"name"`
+	}
+	body, _ := json.Marshal(showReq{Name: modelName})
+
+	req, err := http.NewRequest(http.MethodPost, endpoint+"/api/show", bytes.NewReader(body))
+	if err != nil {
+		return 0, kind
+	}
+	req.Header.Set("Content-Type", "application/json")
+	ollama.SetAuth(req, apiKey)
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, kind
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		ModelInfo    map[string]interface{} `json:"modelinfo"`
+		Capabilities []string               `json:"capabilities"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, kind
+	}
+	if len(result.Capabilities) > 0 {
+		kind = ModelKindChat
+		for _, c := range result.Capabilities {
+			if c == "embedding" {
+				kind = ModelKindEmbedding
+			}
+		}
+	}
+
+	// Suche nach context_length in modelinfo (Feldname variiert je nach Modell-Typ)
+	for k, v := range result.ModelInfo {
+		if strings.HasSuffix(k, ".context_length") || k == "context_length" {
+			switch val := v.(type) {
+			case float64:
+				return int(val), kind
+			}
+		}
+	}
+	return 0, kind
+}
+
+var (
+	ollamaRegistry   = make(map[string]OllamaModelInfo) // shortcode → info
+	ollamaRegistryMu sync.RWMutex
+)
+
+// ollamaTag ist ein Eintrag aus GET /api/tags.
+type ollamaTag struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// fetchOllamaTags lädt die installierten Modelle eines Hosts.
+func fetchOllamaTags(host, apiKey string) ([]ollamaTag, error) {
+	client := &http.Client{Timeout: 3 * time.Second}
+	req, err := http.NewRequest(http.MethodGet, host+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	ollama.SetAuth(req, apiKey)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("/api/tags returned HTTP %d", resp.StatusCode)
+	}
+
+	var tags struct {
+		Models []ollamaTag `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("/api/tags Parse-Fehler: %w", err)
+	}
+	return tags.Models, nil
+}
+
+// ollamaShortcode: ":latest" weglassen, andere Tags als Suffix behalten
+// gemma3:12b → ollama-gemma3-12b
+// llama3.2-vision:latest → ollama-llama3.2-vision
+func ollamaShortcode(name string) string {
+	shortcode := "ollama-" + strings.ReplaceAll(name, ":", "-")
+	return strings.TrimSuffix(shortcode, "-latest")
+}
+
+// DiscoverOllamaModels fragt alle Ollama-Hosts (Kanäle des Providers
+// "ollama") nach installierten Modellen und ersetzt die Registry.
+// Erreichbarkeit setzt den Health-Status des Kanals; Modelle eines
+// nicht erreichbaren Hosts bleiben aus dem letzten Lauf erhalten, damit
+// Requests per Failover auf andere Hosts ausweichen statt "nicht gefunden".
+// Gibt die Anzahl der Modelle zurück (0 wenn kein Host läuft).
+func DiscoverOllamaModels(registry *ChannelRegistry) int {
+	ollamaRegistryMu.RLock()
+	previous := ollamaRegistry
+	ollamaRegistryMu.RUnlock()
+
+	next := make(map[string]OllamaModelInfo)
+	addTo := func(sc string, info OllamaModelInfo, channel string) {
+		if existing, ok := next[sc]; ok {
+			if !existing.onChannel(channel) {
+				existing.Channels = append(existing.Channels, channel)
+			}
+			next[sc] = existing
+			return
+		}
+		info.Channels = []string{channel}
+		next[sc] = info
+	}
+
+	for _, ch := range registry.Channels(ollama.ProviderName) {
+		host := ch.BaseURL
+		if host == "" {
+			host = DefaultOllamaHost
+		}
+		tags, err := fetchOllamaTags(host, ch.APIKey)
+		if err != nil {
+			LogInfo("Ollama nicht erreichbar", map[string]interface{}{"endpoint": host, "channel": ch.FullName()})
+			registry.MarkChannelHealth(ch.Provider, ch.Name, false, err.Error())
+			for sc, info := range previous {
+				if info.onChannel(ch.Name) {
+					addTo(sc, info, ch.Name)
+				}
+			}
+			continue
+		}
+		registry.MarkChannelHealth(ch.Provider, ch.Name, true, "")
+
+		for _, m := range tags {
+			sc := ollamaShortcode(m.Name)
+			if _, known := next[sc]; known {
+				addTo(sc, OllamaModelInfo{}, ch.Name)
+				continue
+			}
+			ctxLen, kind := fetchOllamaShow(host, ch.APIKey, m.Name)
+			addTo(sc, OllamaModelInfo{
+				Shortcode:     sc,
+				OllamaName:    m.Name,
+				Size:          m.Size,
+				ContextLength: ctxLen,
+				Kind:          kind,
+				Host:          host,
+			}, ch.Name)
+			LogDebug("Ollama-Modell registriert", map[string]interface{}{
+				"shortcode": sc, "model": m.Name, "kind": kind, "host": host,
+			})
+		}
+	}
+	ollamaRegistryMu.Lock()
+	ollamaRegistry = next
+	ollamaRegistryMu.Unlock()
+
+	LogInfo("Ollama Discovery abgeschlossen", map[string]interface{}{
+		"hosts": len(registry.Channels(ollama.ProviderName)), "models": len(next),
+	})
+	return len(next)
+}
+
+// GetOllamaModels gibt eine Kopie der aktuellen Ollama-Registry zurück
+func GetOllamaModels() map[string]OllamaModelInfo {
+	ollamaRegistryMu.RLock()
+	defer ollamaRegistryMu.RUnlock()
+	result := make(map[string]OllamaModelInfo, len(ollamaRegistry))
+	for k, v := range ollamaRegistry {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,105 @@
+package sigoengine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newOllamaHost simuliert einen Ollama-Host mit den angegebenen Modellen.
+func newOllamaHost(t *testing.T, models ...string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/tags":
+			var tags []map[string]interface{}
+			for _, m := range models {
+				tags = append(tags, map[string]interface{}{"name": m, "size": 1})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"models": tags})
+		case "/api/show":
+			w.Write([]byte(`{"modelinfo":{"llama.context_length":8192},"capabilities":["completion"]}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// resetOllama stellt Registry und Provider-Hosts nach einem Test wieder her.
+func resetOllama(t *testing.T) {
+	t.Cleanup(func() {
+		ollamaRegistryMu.Lock()
+		ollamaRegistry = make(map[string]OllamaModelInfo)
+		ollamaRegistryMu.Unlock()
+		ollama.mu.Lock()
+		ollama.Hosts = []string{DefaultOllamaHost}
+		ollama.ModelsURL = DefaultOllamaHost + "/api/tags"
+		ollama.mu.Unlock()
+	})
+}
+
+func TestOllamaHostsAsChannels(t *testing.T) {
+	resetOllama(t)
+	hostA := newOllamaHost(t, "llama3:latest", "qwen3:8b")
+	hostB := newOllamaHost(t, "llama3:latest")
+
+	r := NewChannelRegistry("")
+	r.AddOllamaHosts([]string{hostA.URL + "/", hostB.URL})
+	chA, okA := r.GetChannel("ollama", "default")
+	chB, okB := r.GetChannel("ollama", "0")
+	if !okA || !okB || chA.BaseURL != hostA.URL || !chB.Active {
+		t.Fatalf("Kanäle falsch: %+v %+v", chA, chB)
+	}
+
+	if n := DiscoverOllamaModels(r); n != 2 {
+		t.Fatalf("DiscoverOllamaModels = %d, want 2", n)
+	}
+	models := GetOllamaModels()
+	llama := models["ollama-llama3"]
+	if len(llama.Channels) != 2 || llama.ContextLength != 8192 || llama.Host != hostA.URL {
+		t.Errorf("llama3 falsch: %+v", llama)
+	}
+	if got := ProviderForModel(llama.Host+"/v1/chat/completions", "", "ollama-llama3"); got != "ollama" {
+		t.Errorf("ProviderForModel = %q, want ollama", got)
+	}
+
+	// qwen3 liegt nur auf Host A
+	if ChannelServesModel(chB, "ollama-qwen3-8b") || !ChannelServesModel(chA, "ollama-qwen3-8b") {
+		t.Error("ChannelServesModel falsch für qwen3")
+	}
+	if _, err := LoadConfigWithChannel("ollama-qwen3-8b", chB); err == nil {
+		t.Error("LoadConfigWithChannel auf Host ohne Modell: Fehler erwartet")
+	}
+	cfg, err := LoadConfigWithChannel("ollama-llama3", chB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Endpoint != hostB.URL+"/v1/chat/completions" || cfg.Model != "llama3:latest" || cfg.Type != "ollama" {
+		t.Errorf("Config falsch: %+v", cfg)
+	}
+
+	// Host B fällt aus: Modelle bleiben, Kanal wird unhealthy
+	hostB.Close()
+	DiscoverOllamaModels(r)
+	if len(GetOllamaModels()["ollama-llama3"].Channels) != 2 {
+		t.Error("Modelle eines nicht erreichbaren Hosts sollten erhalten bleiben")
+	}
+	if ch, _ := r.GetChannel("ollama", "0"); ch.Healthy {
+		t.Error("nicht erreichbarer Host sollte unhealthy sein")
+	}
+}
+
+func TestChannelEndpoint(t *testing.T) {
+	ch := &Channel{BaseURL: "http://gpu02:11434"}
+	if got := ChannelEndpoint(ch, "http://localhost:11434/api/embed"); got != "http://gpu02:11434/api/embed" {
+		t.Errorf("ChannelEndpoint = %q", got)
+	}
+	if got := ChannelEndpoint(&Channel{}, "https://api.mammouth.ai/v1/chat/completions"); got != "https://api.mammouth.ai/v1/chat/completions" {
+		t.Errorf("ChannelEndpoint ohne BaseURL = %q", got)
+	}
+	if got := NormalizeOllamaHost(" gpu01:11434/ "); got != "http://gpu01:11434" {
+		t.Errorf("NormalizeOllamaHost = %q", got)
+	}
+}
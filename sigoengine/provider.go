@@ -0,0 +1,294 @@
+//**********************************************************************
+//      sigoengine/provider.go
+//**********************************************************************
+//  Beschreibung: Provider-Interface und Registry. Bündelt das Wissen
+//  über einen Provider (Name, API-Key-Env, Auth, Request-/Response-
+//  Format, Stream-Übersetzung, Modell-Liste, Health-Probe, Fehler-
+//  Mapping). Kanal-Discovery, Engine, Server und CLI lösen Provider
+//  nur noch über die Registry auf; ein neuer Provider ist eine Datei
+//  mit einem RegisterProvider-Aufruf in init().
+//**********************************************************************
+
+package sigoengine
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultProvider wird genutzt, wenn kein Provider ein Modell beansprucht.
+const DefaultProvider = "mammouth"
+
+// Provider beschreibt einen Chat-Provider. Den HTTP-Transport (Timeouts,
+// Retry-After, Fehlerklassifizierung) übernehmen CallAPIResult und
+// CallAPIStream; der Provider liefert nur die formatabhängigen Teile.
+type Provider interface {
+	// Name ist der Kanal-Provider-Name (z.B. "mammouth").
+	Name() string
+	// EnvPrefix ist das Präfix der API-Key-Variablen: "MAMMOUTH" →
+	// MAMMOUTH_API_KEY (default-Kanal) und MAMMOUTH_API_KEY_<n>.
+	// Leer: Kanäle kommen aus einer eigenen Quelle (Ollama-Hosts).
+	EnvPrefix() string
+	// Type ist das Wire-Format (ProviderConfig.Type): "mammoth" für
+	// OpenAI-kompatible Provider, sonst ein eigenes (z.B. "anthropic").
+	Type() string
+
+	// Matches prüft ob ein Chat-Endpoint zu diesem Provider gehört.
+	Matches(endpoint string) bool
+	// ClaimsModel prüft ob ein Modellname (ohne bekannten Endpoint)
+	// typisch für diesen Provider ist (z.B. "kimi" → moonshot).
+	ClaimsModel(modelID string) bool
+
+	// FetchModels lädt die Modell-Liste. (nil, nil) wenn der Provider
+	// nicht konfiguriert ist (z.B. API-Key fehlt und ist Pflicht).
+	FetchModels() ([]Model, error)
+	// ProbeEndpoint liefert den kostenlosen GET-Endpoint für Health-Checks
+	// und ob er Auth braucht ("" = kein Probe möglich).
+	ProbeEndpoint() (endpoint string, needsAuth bool)
+
+	// SetAuth setzt die Auth-Header für einen Request.
+	SetAuth(req *http.Request, apiKey string)
+	// ChatEndpoint liefert die URL für einen Chat-Call; Provider mit
+	// eigenem Streaming-Endpoint (Gemini) tauschen sie bei stream=true.
+	ChatEndpoint(endpoint string, stream bool) string
+	// BuildChatRequest übersetzt einen OpenAI-Chat-Request ins Provider-Format.
+	BuildChatRequest(request map[string]interface{}) (map[string]interface{}, error)
+	// ParseChatResponse übersetzt die Provider-Antwort (JSON, ohne
+	// error-Objekt) in ein ChatResult.
+	ParseChatResponse(result map[string]interface{}) (*ChatResult, error)
+	// NewStreamTranslator liefert den Übersetzer für native Streams;
+	// nil wenn der Provider bereits OpenAI-SSE sendet.
+	NewStreamTranslator(model string) StreamTranslator
+	// MapHTTPError klassifiziert eine Fehlerantwort (Status != 200).
+	MapHTTPError(statusCode int, body string) *APIError
+}
+
+// KeylessProvider ist optional: KeyOptional()=true → DiscoverFromEnv legt
+// den default-Kanal auch ohne API-Key an (lokale Server ohne Auth).
+type KeylessProvider interface {
+	KeyOptional() bool
+}
+
+// RateLimitProvider ist optional: Default-Rate-Limit für die Kanäle des
+// Providers (ok=false → Server-Default). Kanal-Werte haben Vorrang.
+type RateLimitProvider interface {
+	DefaultRateLimits() (minInterval, maxWait time.Duration, ok bool)
+}
+
+// UpstreamModelProvider ist optional: übersetzt die Registry-ID eines
+// Modells in den Namen beim Provider (providers.json: ohne "<name>/").
+// Genutzt von Calls ohne BuildChatRequest (Embeddings).
+type UpstreamModelProvider interface {
+	UpstreamModel(modelID string) string
+}
+
+// keyOptional meldet, ob der Provider ohne API-Key nutzbar ist.
+func keyOptional(provider string) bool {
+	p, ok := GetProvider(provider)
+	if !ok {
+		return false
+	}
+	k, ok := p.(KeylessProvider)
+	return ok && k.KeyOptional()
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]Provider)
+)
+
+// RegisterProvider fügt einen Provider hinzu (oder ersetzt ihn gleichen Namens).
+func RegisterProvider(p Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[p.Name()] = p
+}
+
+// GetProvider sucht einen Provider nach Namen.
+func GetProvider(name string) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	p, ok := providers[name]
+	return p, ok
+}
+
+// Providers liefert alle registrierten Provider, nach Namen sortiert
+// (deterministische Reihenfolge für Discovery und Modell-Laden).
+func Providers() []Provider {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	result := make([]Provider, 0, len(providers))
+	for _, p := range providers {
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name() < result[j].Name() })
+	return result
+}
+
+// ProviderForModel bestimmt den Provider eines Modells. Reihenfolge:
+// API-Key-Env der Registry (z.B. MOONSHOT_API_KEY), Endpoint, Modellname,
+// sonst DefaultProvider. Alle Angaben dürfen leer sein.
+func ProviderForModel(endpoint, apiKeyEnv, modelID string) string {
+	all := Providers()
+	if apiKeyEnv != "" {
+		for _, p := range all {
+			if p.EnvPrefix() == "" {
+				continue
+			}
+			envVar := p.EnvPrefix() + "_API_KEY"
+			if apiKeyEnv == envVar || strings.HasPrefix(apiKeyEnv, envVar+"_") {
+				return p.Name()
+			}
+		}
+	}
+	if endpoint != "" {
+		for _, p := range all {
+			if p.Matches(endpoint) {
+				return p.Name()
+			}
+		}
+	}
+	for _, p := range all {
+		if p.ClaimsModel(modelID) {
+			return p.Name()
+		}
+	}
+	return DefaultProvider
+}
+
+// providerForConfig liefert den Provider einer ProviderConfig. Ohne
+// Provider-Namen entscheidet der Typ; OpenAI-kompatible Typen ("mammoth",
+// "ollama") ohne registrierten Provider nutzen das OpenAI-Verhalten.
+func providerForConfig(cfg *ProviderConfig) Provider {
+	if p, ok := GetProvider(cfg.Provider); ok {
+		return p
+	}
+	if p, ok := GetProvider(cfg.Type); ok {
+		return p
+	}
+	return openAIDefault
+}
+
+// configType liefert den ProviderConfig.Type eines Providers (Default:
+// "mammoth" = OpenAI-kompatibel mit Bearer-Auth).
+func configType(provider string) string {
+	if p, ok := GetProvider(provider); ok {
+		return p.Type()
+	}
+	return "mammoth"
+}
+
+// **********************************************************************
+// OpenAICompatProvider — OpenAI-kompatible Provider (Bearer-Auth,
+// /chat/completions, SSE mit chat.completion.chunk).
+
+// OpenAICompatProvider implementiert Provider für OpenAI-kompatible APIs.
+// Andere Adapter betten ihn ein und überschreiben die abweichenden Teile.
+type OpenAICompatProvider struct {
+	ProviderName   string
+	KeyEnvPrefix   string
+	Hosts          []string                // Endpoint-Teilstrings, z.B. "api.moonshot.ai"
+	ModelHints     []string                // Modellnamen-Teilstrings, z.B. "kimi"
+	ModelsURL      string                  // GET /models für Health-Probes
+	ModelsNeedAuth bool                    // Probe mit API-Key
+	Fetch          func() ([]Model, error) // Modell-Liste (nil = keine)
+}
+
+func (p *OpenAICompatProvider) Name() string      { return p.ProviderName }
+func (p *OpenAICompatProvider) EnvPrefix() string { return p.KeyEnvPrefix }
+func (p *OpenAICompatProvider) Type() string      { return "mammoth" }
+
+func (p *OpenAICompatProvider) Matches(endpoint string) bool {
+	for _, h := range p.Hosts {
+		if strings.Contains(endpoint, h) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *OpenAICompatProvider) ClaimsModel(modelID string) bool {
+	lower := strings.ToLower(modelID)
+	for _, h := range p.ModelHints {
+		if strings.Contains(lower, h) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *OpenAICompatProvider) FetchModels() ([]Model, error) {
+	if p.Fetch == nil {
+		return nil, nil
+	}
+	return p.Fetch()
+}
+
+func (p *OpenAICompatProvider) ProbeEndpoint() (string, bool) {
+	return p.ModelsURL, p.ModelsNeedAuth
+}
+
+func (p *OpenAICompatProvider) SetAuth(req *http.Request, apiKey string) {
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+}
+
+func (p *OpenAICompatProvider) ChatEndpoint(endpoint string, stream bool) string {
+	return endpoint
+}
+
+func (p *OpenAICompatProvider) BuildChatRequest(request map[string]interface{}) (map[string]interface{}, error) {
+	return request, nil
+}
+
+// ParseChatResponse liest choices[0].message (content, tool_calls),
+// finish_reason und usage.
+func (p *OpenAICompatProvider) ParseChatResponse(result map[string]interface{}) (*ChatResult, error) {
+	usage := extractUsage(result, "")
+
+	choices, ok := result["choices"].([]interface{})
+	if !ok || len(choices) == 0 {
+		return nil, NewError(ErrUnexpectedFormat, "Unexpected response format", nil, nil)
+	}
+	choice, _ := choices[0].(map[string]interface{})
+	finishReason, _ := choice["finish_reason"].(string)
+	msg, ok := choice["message"].(map[string]interface{})
+	if !ok {
+		return nil, NewError(ErrUnexpectedFormat, "Unexpected response format", nil, nil)
+	}
+
+	res := &ChatResult{Usage: usage, FinishReason: finishReason}
+	if tc, ok := msg["tool_calls"].([]interface{}); ok && len(tc) > 0 {
+		res.ToolCalls, _ = json.Marshal(tc)
+	}
+	if content, ok := msg["content"].(string); ok {
+		res.Content = content
+		return res, nil
+	}
+	// content:null mit tool_calls ist eine gültige Antwort
+	if res.HasToolCalls() {
+		return res, nil
+	}
+	// content:null → finish_reason:length (max_tokens:0 Fallback)
+	if msg["content"] == nil {
+		return res, NewError(ErrClientError,
+			"leere Antwort: max_tokens zu niedrig oder Modell-Limit erreicht", nil, nil)
+	}
+	return nil, NewError(ErrUnexpectedFormat, "Unexpected response format", nil, nil)
+}
+
+func (p *OpenAICompatProvider) NewStreamTranslator(model string) StreamTranslator {
+	return nil
+}
+
+func (p *OpenAICompatProvider) MapHTTPError(statusCode int, body string) *APIError {
+	return classifyHTTPError(statusCode, body, nil)
+}
+
+// openAIDefault: Verhalten für Configs ohne registrierten Provider (Ollama, Tests).
+var openAIDefault Provider = &OpenAICompatProvider{ProviderName: "openai"}
@@ -0,0 +1,285 @@
+//**********************************************************************
+//      sigoengine/provider_config.go
+//**********************************************************************
+//  Beschreibung: OpenAI-kompatible Provider aus providers.json (vLLM,
+//  llama.cpp server, LM Studio, interne Proxies). Jeder Eintrag wird
+//  als Provider registriert und erhält damit Kanäle (DiscoverFromEnv),
+//  Modell-Discovery, Health-Probes und Failover wie die eingebauten.
+//**********************************************************************
+
+package sigoengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Auth-Stile in providers.json.
+const (
+	ProviderAuthBearer = "bearer" // Authorization: Bearer <key> (Default)
+	ProviderAuthHeader = "header" // <auth_header>: <key>, z.B. X-API-Key
+	ProviderAuthNone   = "none"   // kein Key; default-Kanal ohne Key
+)
+
+// ProviderSpec ist ein Eintrag in providers.json.
+type ProviderSpec struct {
+	Name           string `json:"name"`
+	BaseURL        string `json:"base_url"`                  // z.B. http://gpu01:8000/v1
+	Auth           string `json:"auth,omitempty"`            // bearer | header | none
+	AuthHeader     string `json:"auth_header,omitempty"`     // Header-Name bei auth "header"
+	APIKeyEnv      string `json:"api_key_env,omitempty"`     // Präfix, Default: NAME in Großbuchstaben
+	ModelsEndpoint string `json:"models_endpoint,omitempty"` // Default: <base_url>/models
+	// Modell-Defaults, falls /models keine Limits liefert.
+	MaxInputTokens  int `json:"max_input_tokens,omitempty"`
+	MaxOutputTokens int `json:"max_output_tokens,omitempty"`
+	// Default-Rate-Limit der Kanäle (Kanal-Config in channels.json hat Vorrang).
+	RateLimit *struct {
+		MinInterval int `json:"min_interval_ms"` // 0 = kein Rate-Limit
+		MaxWait     int `json:"max_wait_ms"`     // 0 = Server-Default
+	} `json:"rate_limit,omitempty"`
+}
+
+// providersFile ist das Format von providers.json.
+type providersFile struct {
+	Providers []ProviderSpec `json:"providers"`
+}
+
+var providerNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_]*$`)
+
+// LoadProviderConfig liest providers.json und registriert alle Einträge.
+// Fehlt die Datei, passiert nichts. Ein Eintrag mit dem Namen eines
+// eingebauten Providers ersetzt diesen.
+func LoadProviderConfig(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("providers.json lesen: %w", err)
+	}
+	var file providersFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return 0, fmt.Errorf("providers.json parsen: %w", err)
+	}
+
+	providers := make([]Provider, 0, len(file.Providers))
+	for i, spec := range file.Providers {
+		p, err := NewConfiguredProvider(spec)
+		if err != nil {
+			return 0, fmt.Errorf("providers.json Eintrag %d: %w", i, err)
+		}
+		providers = append(providers, p)
+	}
+	for _, p := range providers {
+		RegisterProvider(p)
+		LogInfo("Provider aus Konfiguration registriert", map[string]interface{}{
+			"provider": p.Name(),
+			"base_url": p.(*configuredProvider).baseURL,
+		})
+	}
+	return len(providers), nil
+}
+
+// configuredProvider ist ein OpenAI-kompatibler Provider aus providers.json.
+// Modell-IDs tragen das Präfix "<name>/" (wie "anthropic/"), damit sie nicht
+// mit gleichnamigen Modellen anderer Provider kollidieren.
+type configuredProvider struct {
+	OpenAICompatProvider
+	baseURL    string
+	auth       string
+	authHeader string
+	maxInput   int
+	maxOutput  int
+	rateSet    bool
+	minInt     time.Duration
+	maxWait    time.Duration
+}
+
+// NewConfiguredProvider prüft einen providers.json-Eintrag und baut den Provider.
+func NewConfiguredProvider(spec ProviderSpec) (Provider, error) {
+	if !providerNamePattern.MatchString(spec.Name) {
+		return nil, fmt.Errorf("ungültiger Provider-Name %q (erlaubt: a-z, 0-9, _)", spec.Name)
+	}
+	base := strings.TrimRight(spec.BaseURL, "/")
+	u, err := url.Parse(base)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("%s: ungültige base_url %q", spec.Name, spec.BaseURL)
+	}
+
+	auth := strings.ToLower(spec.Auth)
+	switch auth {
+	case "":
+		auth = ProviderAuthBearer
+	case ProviderAuthBearer, ProviderAuthNone:
+	case ProviderAuthHeader:
+		if spec.AuthHeader == "" {
+			return nil, fmt.Errorf("%s: auth \"header\" braucht auth_header", spec.Name)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unbekannter auth-Stil %q", spec.Name, spec.Auth)
+	}
+
+	prefix := spec.APIKeyEnv
+	if prefix == "" {
+		prefix = strings.ToUpper(spec.Name)
+	}
+	prefix = strings.TrimSuffix(prefix, "_API_KEY")
+
+	modelsURL := spec.ModelsEndpoint
+	switch {
+	case modelsURL == "":
+		modelsURL = base + "/models"
+	case strings.HasPrefix(modelsURL, "/"):
+		modelsURL = base + modelsURL
+	}
+
+	p := &configuredProvider{
+		OpenAICompatProvider: OpenAICompatProvider{
+			ProviderName:   spec.Name,
+			KeyEnvPrefix:   prefix,
+			Hosts:          []string{base},
+			ModelsURL:      modelsURL,
+			ModelsNeedAuth: auth != ProviderAuthNone,
+		},
+		baseURL:    base,
+		auth:       auth,
+		authHeader: spec.AuthHeader,
+		maxInput:   spec.MaxInputTokens,
+		maxOutput:  spec.MaxOutputTokens,
+	}
+	if spec.RateLimit != nil {
+		p.rateSet = true
+		p.minInt = time.Duration(spec.RateLimit.MinInterval) * time.Millisecond
+		p.maxWait = time.Duration(spec.RateLimit.MaxWait) * time.Millisecond
+	}
+	p.Fetch = p.fetchModels
+	return p, nil
+}
+
+// SetAuth setzt den Key im konfigurierten Stil.
+func (p *configuredProvider) SetAuth(req *http.Request, apiKey string) {
+	if apiKey == "" {
+		return
+	}
+	switch p.auth {
+	case ProviderAuthHeader:
+		req.Header.Set(p.authHeader, apiKey)
+	case ProviderAuthBearer:
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+}
+
+// BuildChatRequest entfernt das "<name>/"-Präfix der Registry-ID. Der
+// Request des Aufrufers bleibt unverändert (Retries und Hedge-Calls
+// teilen ihn), geändert wird eine flache Kopie.
+func (p *configuredProvider) BuildChatRequest(request map[string]interface{}) (map[string]interface{}, error) {
+	model, ok := request["model"].(string)
+	if !ok || !strings.HasPrefix(model, p.ProviderName+"/") {
+		return request, nil
+	}
+	out := make(map[string]interface{}, len(request))
+	for k, v := range request {
+		out[k] = v
+	}
+	out["model"] = p.UpstreamModel(model)
+	return out, nil
+}
+
+// UpstreamModel entfernt das "<name>/"-Präfix der Registry-ID.
+func (p *configuredProvider) UpstreamModel(modelID string) string {
+	return strings.TrimPrefix(modelID, p.ProviderName+"/")
+}
+
+// KeyOptional: auth "none" → default-Kanal ohne API-Key.
+func (p *configuredProvider) KeyOptional() bool {
+	return p.auth == ProviderAuthNone
+}
+
+// DefaultRateLimits liefert das rate_limit aus providers.json.
+func (p *configuredProvider) DefaultRateLimits() (time.Duration, time.Duration, bool) {
+	return p.minInt, p.maxWait, p.rateSet
+}
+
+// fetchModels lädt GET <models_endpoint> (OpenAI-Format {"data":[{"id"}]}).
+// Kontextlängen werden übernommen, wenn der Server sie liefert (vLLM:
+// max_model_len, LM Studio/llama.cpp: context_length).
+func (p *configuredProvider) fetchModels() ([]Model, error) {
+	envVar := p.KeyEnvPrefix + "_API_KEY"
+	apiKey := GetEnvWithFile(envVar)
+	if apiKey == "" && p.auth != ProviderAuthNone {
+		return nil, nil
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	req, err := http.NewRequest(http.MethodGet, p.ModelsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: Request-Erstellung fehlgeschlagen: %w", p.ProviderName, err)
+	}
+	p.SetAuth(req, apiKey)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: GET %s: %w", p.ProviderName, p.ModelsURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s returned HTTP %d", p.ProviderName, p.ModelsURL, resp.StatusCode)
+	}
+
+	var listResp struct {
+		Data []struct {
+			ID            string `json:"id"`
+			MaxModelLen   int    `json:"max_model_len"`
+			ContextLength int    `json:"context_length"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&listResp); err != nil {
+		return nil, fmt.Errorf("%s: invalid JSON: %w", p.ProviderName, err)
+	}
+
+	maxOutput := p.maxOutput
+	if maxOutput == 0 {
+		maxOutput = 4096
+	}
+	used := make(map[string]bool)
+	var result []Model
+	for _, item := range listResp.Data {
+		if item.ID == "" {
+			continue
+		}
+		maxInput := p.maxInput
+		if item.MaxModelLen > 0 {
+			maxInput = item.MaxModelLen
+		} else if item.ContextLength > 0 {
+			maxInput = item.ContextLength
+		}
+		if maxInput == 0 {
+			maxInput = 32768
+		}
+		sc := generateProviderShortcode(item.ID, used)
+		used[sc] = true
+		result = append(result, Model{
+			ID:              p.ProviderName + "/" + item.ID,
+			Shortcode:       p.ProviderName + "-" + sc,
+			Endpoint:        p.baseURL + "/chat/completions",
+			APIKeyEnv:       envVar,
+			MaxInputTokens:  maxInput,
+			MaxOutputTokens: maxOutput,
+			MinTemperature:  0.0,
+			MaxTemperature:  2.0,
+		})
+	}
+
+	LogInfo("Provider-Modelle geladen (konfiguriert)", map[string]interface{}{
+		"provider": p.ProviderName,
+		"count":    len(result),
+	})
+	return result, nil
+}
@@ -0,0 +1,124 @@
+package sigoengine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func unregisterProvider(name string) {
+	providersMu.Lock()
+	delete(providers, name)
+	providersMu.Unlock()
+}
+
+func TestLoadProviderConfig(t *testing.T) {
+	var chatReq map[string]interface{}
+	var chatKey string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/models":
+			if r.Header.Get("X-API-Key") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"data":[{"id":"Qwen/Qwen3-8B","max_model_len":40960},{"id":"llama-3.1-8b"}]}`))
+		case "/v1/chat/completions":
+			chatKey = r.Header.Get("X-API-Key")
+			json.NewDecoder(r.Body).Decode(&chatReq)
+			w.Write([]byte(`{"choices":[{"finish_reason":"stop","message":{"role":"assistant","content":"ok"}}]}`))
+		}
+	}))
+	defer upstream.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "providers.json")
+	os.WriteFile(path, []byte(`{"providers":[
+		{"name":"vllm","base_url":"`+upstream.URL+`/v1/","auth":"header","auth_header":"X-API-Key",
+		 "api_key_env":"GPU_VLLM","max_input_tokens":8192,"rate_limit":{"min_interval_ms":0,"max_wait_ms":2000}},
+		{"name":"llamacpp","base_url":"http://127.0.0.1:1/v1","auth":"none"}]}`), 0644)
+	t.Setenv("GPU_VLLM_API_KEY", "secret")
+
+	n, err := LoadProviderConfig(path)
+	defer unregisterProvider("vllm")
+	defer unregisterProvider("llamacpp")
+	if err != nil || n != 2 {
+		t.Fatalf("LoadProviderConfig = %d, %v", n, err)
+	}
+
+	p, ok := GetProvider("vllm")
+	if !ok {
+		t.Fatal("vllm nicht registriert")
+	}
+	models, err := p.FetchModels()
+	if err != nil || len(models) != 2 {
+		t.Fatalf("FetchModels = %v, %v", models, err)
+	}
+	if models[0].ID != "vllm/Qwen/Qwen3-8B" || models[0].MaxInputTokens != 40960 || models[1].MaxInputTokens != 8192 {
+		t.Errorf("Modelle falsch: %+v", models)
+	}
+	if models[0].Endpoint != upstream.URL+"/v1/chat/completions" || models[0].APIKeyEnv != "GPU_VLLM_API_KEY" {
+		t.Errorf("Endpoint/Env falsch: %+v", models[0])
+	}
+	if got := ProviderForModel(models[0].Endpoint, models[0].APIKeyEnv, models[0].ID); got != "vllm" {
+		t.Errorf("ProviderForModel = %q, want vllm", got)
+	}
+	if minInt, maxWait, set := p.(RateLimitProvider).DefaultRateLimits(); !set || minInt != 0 || maxWait != 2*time.Second {
+		t.Errorf("DefaultRateLimits = %v, %v, %v", minInt, maxWait, set)
+	}
+
+	// Kanäle: vllm über GPU_VLLM_API_KEY, llamacpp ohne Key (auth "none")
+	r := NewChannelRegistry("")
+	r.DiscoverFromEnv()
+	ch, ok := r.GetChannel("vllm", "default")
+	if !ok || ch.APIKey != "secret" {
+		t.Fatalf("vllm default-Kanal fehlt: %+v", ch)
+	}
+	if keyless, ok := r.GetChannel("llamacpp", "default"); !ok || keyless.APIKey != "" {
+		t.Errorf("llamacpp default-Kanal ohne Key fehlt: %+v", keyless)
+	}
+
+	// Chat: Präfix wird entfernt, Key im konfigurierten Header
+	cfg, err := LoadConfigWithChannel(models[0].ID, ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Endpoint = models[0].Endpoint
+	request := map[string]interface{}{
+		"model":    cfg.Model,
+		"messages": []map[string]interface{}{{"role": "user", "content": "hi"}},
+	}
+	res, err := CallAPIResult(context.Background(), cfg, request, 5)
+	if err != nil || res.Content != "ok" {
+		t.Fatalf("CallAPIResult = %+v, %v", res, err)
+	}
+	if chatReq["model"] != "Qwen/Qwen3-8B" || chatKey != "secret" {
+		t.Errorf("Upstream-Request falsch: model=%v key=%q", chatReq["model"], chatKey)
+	}
+	// Der Request des Aufrufers bleibt unverändert (Retries, Hedge)
+	if request["model"] != "vllm/Qwen/Qwen3-8B" {
+		t.Errorf("Request des Aufrufers verändert: model=%v", request["model"])
+	}
+}
+
+func TestLoadProviderConfigErrors(t *testing.T) {
+	if n, err := LoadProviderConfig(filepath.Join(t.TempDir(), "fehlt.json")); n != 0 || err != nil {
+		t.Errorf("fehlende Datei: %d, %v", n, err)
+	}
+	for _, spec := range []ProviderSpec{
+		{Name: "Bad Name", BaseURL: "http://x/v1"},
+		{Name: "x", BaseURL: "ftp://x"},
+		{Name: "x", BaseURL: "http://x/v1", Auth: "header"},
+		{Name: "x", BaseURL: "http://x/v1", Auth: "oauth"},
+	} {
+		if _, err := NewConfiguredProvider(spec); err == nil {
+			t.Errorf("NewConfiguredProvider(%+v): Fehler erwartet", spec)
+		}
+	}
+}
@@ -1,7 +1,8 @@
 //**********************************************************************
 //      sigoengine/provider_fetchers.go
 //**********************************************************************
-// Beschreibung: Dynamischer Modellabruf von Mammouth, Moonshot und ZAI.
+// Beschreibung: Dynamischer Modellabruf von Mammouth, Moonshot, ZAI,
+//               Anthropic und Gemini.
 //               Fetcher lesen API-Keys direkt aus ENV.
 //               Gibt []Model zurück; bei Fehler leerer Slice + Fehler.
 //**********************************************************************
@@ -13,29 +14,69 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 )
 
 const (
-	mammouthChatEndpoint = "https://api.mammouth.ai/v1/chat/completions"
-	moonshotChatEndpoint = "https://api.moonshot.ai/v1/chat/completions"
-	zaiChatEndpoint      = "https://api.z.ai/api/paas/v4/chat/completions"
+	mammouthChatEndpoint  = "https://api.mammouth.ai/v1/chat/completions"
+	moonshotChatEndpoint  = "https://api.moonshot.ai/v1/chat/completions"
+	zaiChatEndpoint       = "https://api.z.ai/api/paas/v4/chat/completions"
+	anthropicChatEndpoint = "https://api.anthropic.com/v1/messages"
+	geminiAPIBase         = "https://generativelanguage.googleapis.com/v1beta" // + /models/<id>:generateContent
+
+	zaiEmbeddingsEndpoint = "https://api.z.ai/api/paas/v4/embeddings"
 )
 
 // Provider-Model-Listen-Endpoints (GET, kostenlos — keine Token-Billing).
 // Genutzt von ProbeProviderModelList für Health-Checks, statt eines
 // Chat-Completion-"ping"-Requests der Input-Token kosten verursacht.
 const (
-	mammouthModelsEndpoint = "https://api.mammouth.ai/public/models" // key-less
-	moonshotModelsEndpoint = "https://api.moonshot.ai/v1/models"     // Bearer
-	zaiModelsEndpoint      = "https://api.z.ai/api/paas/v4/models"   // Bearer
+	mammouthModelsEndpoint  = "https://api.mammouth.ai/public/models" // key-less
+	moonshotModelsEndpoint  = "https://api.moonshot.ai/v1/models"     // Bearer
+	zaiModelsEndpoint       = "https://api.z.ai/api/paas/v4/models"   // Bearer
+	anthropicModelsEndpoint = "https://api.anthropic.com/v1/models"   // x-api-key
+	geminiModelsEndpoint    = geminiAPIBase + "/models"               // x-goog-api-key
 )
 
+// OpenAI-kompatible Provider in der Registry (Anthropic: anthropic.go,
+// Gemini: gemini.go).
+func init() {
+	RegisterProvider(&OpenAICompatProvider{
+		ProviderName: "mammouth",
+		KeyEnvPrefix: "MAMMOUTH",
+		Hosts:        []string{"mammouth"},
+		ModelsURL:    mammouthModelsEndpoint,
+		Fetch:        FetchMammouthModels,
+	})
+	RegisterProvider(&OpenAICompatProvider{
+		ProviderName:   "moonshot",
+		KeyEnvPrefix:   "MOONSHOT",
+		Hosts:          []string{"moonshot"},
+		ModelHints:     []string{"kimi"},
+		ModelsURL:      moonshotModelsEndpoint,
+		ModelsNeedAuth: true,
+		Fetch:          FetchMoonshotModels,
+	})
+	RegisterProvider(&OpenAICompatProvider{
+		ProviderName:   "zai",
+		KeyEnvPrefix:   "ZAI",
+		Hosts:          []string{"z.ai"},
+		ModelHints:     []string{"glm"},
+		ModelsURL:      zaiModelsEndpoint,
+		ModelsNeedAuth: true,
+		Fetch:          FetchZAIModels,
+	})
+}
+
 // **********************************************************************
 // Moonshot — statische Parameter-Tabelle
 // Die Moonshot /v1/models API liefert nur Model-IDs, keine Preise/Limits.
 // Bekannte Modelle werden angereichert; unbekannte erhalten sichere Defaults.
 // ACHTUNG: Preise in USD/1M tokens, Moonshot rechnet in CNY — bitte verifizieren.
+// Capabilities: moonshot-v1 ohne Bilder und Reasoning, kimi-k2.5 multimodal mit Thinking.
+var moonshotV1Caps = []string{CapTools, CapJSONMode, CapStreaming}
+
 var moonshotKnownModels = map[string]Model{
 	"moonshot-v1-8k": {
 		ID: "moonshot-v1-8k", Shortcode: "moon8k",
@@ -43,6 +84,7 @@ var moonshotKnownModels = map[string]Model{
 		MaxInputTokens: 8000, MaxOutputTokens: 4096,
 		InputCost: 12.0, OutputCost: 12.0,
 		MinTemperature: 0.0, MaxTemperature: 2.0,
+		Capabilities: moonshotV1Caps,
 	},
 	"moonshot-v1-32k": {
 		ID: "moonshot-v1-32k", Shortcode: "moon32k",
@@ -50,6 +92,7 @@ var moonshotKnownModels = map[string]Model{
 		MaxInputTokens: 32000, MaxOutputTokens: 4096,
 		InputCost: 24.0, OutputCost: 24.0,
 		MinTemperature: 0.0, MaxTemperature: 2.0,
+		Capabilities: moonshotV1Caps,
 	},
 	"moonshot-v1-128k": {
 		ID: "moonshot-v1-128k", Shortcode: "moon128k",
@@ -57,6 +100,7 @@ var moonshotKnownModels = map[string]Model{
 		MaxInputTokens: 128000, MaxOutputTokens: 4096,
 		InputCost: 60.0, OutputCost: 60.0,
 		MinTemperature: 0.0, MaxTemperature: 2.0,
+		Capabilities: moonshotV1Caps,
 	},
 	"kimi-k2.5": {
 		ID: "kimi-k2.5", Shortcode: "kimi",
@@ -66,6 +110,7 @@ var moonshotKnownModels = map[string]Model{
 		// Thinking-Modell: Moonshot akzeptiert nur temperature=1.
 		// Min==Max signalisiert "fixed temperature" → main.go erzwingt den Wert.
 		MinTemperature: 1.0, MaxTemperature: 1.0,
+		Capabilities: []string{CapVision, CapTools, CapJSONMode, CapReasoning, CapStreaming},
 	},
 }
 
@@ -73,20 +118,63 @@ var moonshotKnownModels = map[string]Model{
 // ZAI — statische Fallback-Liste (13 Modelle, Quelle: Mastra, Stand 2026-04)
 // Wird verwendet wenn GET https://api.z.ai/api/paas/v4/models keinen
 // verwertbaren Response liefert.
+// Capabilities: GLM-*v mit Bildern, alle mit Tools, JSON-Mode und Thinking.
+var (
+	zaiTextCaps   = []string{CapTools, CapJSONMode, CapReasoning, CapStreaming}
+	zaiVisionCaps = []string{CapVision, CapTools, CapJSONMode, CapReasoning, CapStreaming}
+)
+
 var zaiStaticModels = []Model{
-	{ID: "glm-4.5",        Shortcode: "glm45",   Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 131072, MaxOutputTokens: 4096, InputCost: 0.60, OutputCost: 2.00, MinTemperature: 0.0, MaxTemperature: 2.0},
-	{ID: "glm-4.5-air",    Shortcode: "glm45a",  Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 131072, MaxOutputTokens: 4096, InputCost: 0.20, OutputCost: 1.00, MinTemperature: 0.0, MaxTemperature: 2.0},
-	{ID: "glm-4.5-flash",  Shortcode: "glm45f",  Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 131072, MaxOutputTokens: 4096, InputCost: 0.00, OutputCost: 0.00, MinTemperature: 0.0, MaxTemperature: 2.0},
-	{ID: "glm-4.5v",       Shortcode: "glm45v",  Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 65536,  MaxOutputTokens: 4096, InputCost: 0.60, OutputCost: 2.00, MinTemperature: 0.0, MaxTemperature: 2.0},
-	{ID: "glm-4.6",        Shortcode: "glm46",   Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 204800, MaxOutputTokens: 4096, InputCost: 0.60, OutputCost: 2.00, MinTemperature: 0.0, MaxTemperature: 2.0},
-	{ID: "glm-4.6v",       Shortcode: "glm46v",  Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 131072, MaxOutputTokens: 4096, InputCost: 0.30, OutputCost: 0.90, MinTemperature: 0.0, MaxTemperature: 2.0},
-	{ID: "glm-4.7",        Shortcode: "glm47",   Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 204800, MaxOutputTokens: 4096, InputCost: 0.60, OutputCost: 2.00, MinTemperature: 0.0, MaxTemperature: 2.0},
-	{ID: "glm-4.7-flash",  Shortcode: "glm47f",  Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 204800, MaxOutputTokens: 4096, InputCost: 0.00, OutputCost: 0.00, MinTemperature: 0.0, MaxTemperature: 2.0},
-	{ID: "glm-4.7-flashx", Shortcode: "glm47fx", Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 204800, MaxOutputTokens: 4096, InputCost: 0.07, OutputCost: 0.40, MinTemperature: 0.0, MaxTemperature: 2.0},
-	{ID: "glm-5",          Shortcode: "glm5",    Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 204800, MaxOutputTokens: 4096, InputCost: 1.00, OutputCost: 3.00, MinTemperature: 0.0, MaxTemperature: 2.0},
-	{ID: "glm-5-turbo",    Shortcode: "glm5t",   Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 204800, MaxOutputTokens: 4096, InputCost: 1.00, OutputCost: 4.00, MinTemperature: 0.0, MaxTemperature: 2.0},
-	{ID: "glm-5.1",        Shortcode: "glm51",   Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 204800, MaxOutputTokens: 4096, InputCost: 1.00, OutputCost: 4.00, MinTemperature: 0.0, MaxTemperature: 2.0},
-	{ID: "glm-5v-turbo",   Shortcode: "glm5vt",  Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 204800, MaxOutputTokens: 4096, InputCost: 1.00, OutputCost: 4.00, MinTemperature: 0.0, MaxTemperature: 2.0},
+	{ID: "glm-4.5",        Shortcode: "glm45",   Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 131072, MaxOutputTokens: 4096, InputCost: 0.60, OutputCost: 2.00, MinTemperature: 0.0, MaxTemperature: 2.0, Capabilities: zaiTextCaps},
+	{ID: "glm-4.5-air",    Shortcode: "glm45a",  Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 131072, MaxOutputTokens: 4096, InputCost: 0.20, OutputCost: 1.00, MinTemperature: 0.0, MaxTemperature: 2.0, Capabilities: zaiTextCaps},
+	{ID: "glm-4.5-flash",  Shortcode: "glm45f",  Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 131072, MaxOutputTokens: 4096, InputCost: 0.00, OutputCost: 0.00, MinTemperature: 0.0, MaxTemperature: 2.0, Capabilities: zaiTextCaps},
+	{ID: "glm-4.5v",       Shortcode: "glm45v",  Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 65536,  MaxOutputTokens: 4096, InputCost: 0.60, OutputCost: 2.00, MinTemperature: 0.0, MaxTemperature: 2.0, Capabilities: zaiVisionCaps},
+	{ID: "glm-4.6",        Shortcode: "glm46",   Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 204800, MaxOutputTokens: 4096, InputCost: 0.60, OutputCost: 2.00, MinTemperature: 0.0, MaxTemperature: 2.0, Capabilities: zaiTextCaps},
+	{ID: "glm-4.6v",       Shortcode: "glm46v",  Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 131072, MaxOutputTokens: 4096, InputCost: 0.30, OutputCost: 0.90, MinTemperature: 0.0, MaxTemperature: 2.0, Capabilities: zaiVisionCaps},
+	{ID: "glm-4.7",        Shortcode: "glm47",   Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 204800, MaxOutputTokens: 4096, InputCost: 0.60, OutputCost: 2.00, MinTemperature: 0.0, MaxTemperature: 2.0, Capabilities: zaiTextCaps},
+	{ID: "glm-4.7-flash",  Shortcode: "glm47f",  Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 204800, MaxOutputTokens: 4096, InputCost: 0.00, OutputCost: 0.00, MinTemperature: 0.0, MaxTemperature: 2.0, Capabilities: zaiTextCaps},
+	{ID: "glm-4.7-flashx", Shortcode: "glm47fx", Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 204800, MaxOutputTokens: 4096, InputCost: 0.07, OutputCost: 0.40, MinTemperature: 0.0, MaxTemperature: 2.0, Capabilities: zaiTextCaps},
+	{ID: "glm-5",          Shortcode: "glm5",    Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 204800, MaxOutputTokens: 4096, InputCost: 1.00, OutputCost: 3.00, MinTemperature: 0.0, MaxTemperature: 2.0, Capabilities: zaiTextCaps},
+	{ID: "glm-5-turbo",    Shortcode: "glm5t",   Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 204800, MaxOutputTokens: 4096, InputCost: 1.00, OutputCost: 4.00, MinTemperature: 0.0, MaxTemperature: 2.0, Capabilities: zaiTextCaps},
+	{ID: "glm-5.1",        Shortcode: "glm51",   Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 204800, MaxOutputTokens: 4096, InputCost: 1.00, OutputCost: 4.00, MinTemperature: 0.0, MaxTemperature: 2.0, Capabilities: zaiTextCaps},
+	{ID: "glm-5v-turbo",   Shortcode: "glm5vt",  Endpoint: zaiChatEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 204800, MaxOutputTokens: 4096, InputCost: 1.00, OutputCost: 4.00, MinTemperature: 0.0, MaxTemperature: 2.0, Capabilities: zaiVisionCaps},
+}
+
+// **********************************************************************
+// Embedding-Modelle der Provider (statisch, die /models-Listen enthalten
+// sie nicht). MaxOutputTokens = 0: Embeddings erzeugen keinen Text.
+var providerEmbeddingModels = []Model{
+	{ID: "embedding-3", Shortcode: "zemb3", Endpoint: zaiEmbeddingsEndpoint, APIKeyEnv: "ZAI_API_KEY", MaxInputTokens: 8192, Kind: ModelKindEmbedding},
+}
+
+// FetchEmbeddingModels liefert die Embedding-Modelle der Provider plus alle
+// Registry-Einträge (models.csv/models.json) mit kind "embedding".
+func FetchEmbeddingModels() []Model {
+	result := append([]Model{}, providerEmbeddingModels...)
+	seen := make(map[string]bool, len(result))
+	for _, m := range result {
+		seen[m.ID] = true
+	}
+	for _, m := range GetAllModels() {
+		if m.IsEmbedding() && !seen[m.ID] {
+			result = append(result, m)
+			seen[m.ID] = true
+		}
+	}
+	return result
+}
+
+// **********************************************************************
+// Anthropic — statische Parameter-Tabelle (Preise USD/1M tokens)
+// Die Anthropic /v1/models API liefert nur ID und Anzeigename.
+// IDs ohne "anthropic/"-Präfix; das Präfix setzt FetchAnthropicModels.
+// MaxOutputTokens konservativ wie in CoreModels (Non-Streaming-Calls).
+var anthropicKnownModels = map[string]Model{
+	"claude-opus-4-6":   {MaxInputTokens: 200000, MaxOutputTokens: 8192, InputCost: 15.0, OutputCost: 75.0},
+	"claude-sonnet-4-6": {MaxInputTokens: 200000, MaxOutputTokens: 8192, InputCost: 3.0, OutputCost: 15.0},
+	"claude-sonnet-4-5": {MaxInputTokens: 200000, MaxOutputTokens: 8192, InputCost: 3.0, OutputCost: 15.0},
+	"claude-haiku-4-5":  {MaxInputTokens: 200000, MaxOutputTokens: 8192, InputCost: 1.0, OutputCost: 5.0},
+	"claude-opus-4-1":   {MaxInputTokens: 200000, MaxOutputTokens: 8192, InputCost: 15.0, OutputCost: 75.0},
 }
 
 // **********************************************************************
@@ -139,6 +227,42 @@ type mammouthModel struct {
 	OutputPricePerMillion float64 `json:"output_price_per_million"`
 	InputCost             float64 `json:"input_cost"`
 	OutputCost            float64 `json:"output_cost"`
+	// Capabilities (mögliche Feldnamen): Liste, Objekt oder supports_*-Flags
+	Capabilities            json.RawMessage `json:"capabilities"`
+	SupportsVision          *bool           `json:"supports_vision"`
+	SupportsTools           *bool           `json:"supports_tools"`
+	SupportsFunctionCalling *bool           `json:"supports_function_calling"`
+	SupportsJSONMode        *bool           `json:"supports_json_mode"`
+	SupportsResponseSchema  *bool           `json:"supports_response_schema"`
+	SupportsReasoning       *bool           `json:"supports_reasoning"`
+	SupportsStreaming       *bool           `json:"supports_streaming"`
+	InputModalities         []string        `json:"input_modalities"`
+}
+
+// capabilities wertet die Capability-Angaben aus (nil = keine Angaben).
+func (m mammouthModel) capabilities() []string {
+	flags := make(map[string]bool)
+	parseCapabilityValue(m.Capabilities, flags)
+	for c, v := range map[string]*bool{
+		CapVision:    m.SupportsVision,
+		CapTools:     m.SupportsTools,
+		CapJSONMode:  m.SupportsJSONMode,
+		CapReasoning: m.SupportsReasoning,
+		CapStreaming: m.SupportsStreaming,
+	} {
+		if v != nil {
+			flags[c] = flags[c] || *v
+		}
+	}
+	for c, v := range map[string]*bool{CapTools: m.SupportsFunctionCalling, CapJSONMode: m.SupportsResponseSchema} {
+		if v != nil {
+			flags[c] = flags[c] || *v
+		}
+	}
+	for _, modality := range m.InputModalities {
+		flags[CapVision] = flags[CapVision] || strings.EqualFold(modality, "image")
+	}
+	return capabilitiesFromFlags(flags)
 }
 
 func parseMammouthResponse(raw json.RawMessage) ([]Model, error) {
@@ -185,6 +309,7 @@ func convertMammouthModels(items []mammouthModel) []Model {
 			OutputCost:      outCost,
 			MinTemperature:  0.0,
 			MaxTemperature:  2.0,
+			Capabilities:    m.capabilities(),
 		})
 	}
 	return result
@@ -359,3 +484,211 @@ func FetchZAIModels() ([]Model, error) {
 	LogInfo("ZAI-Modelle geladen (dynamisch)", map[string]interface{}{"count": len(result)})
 	return result, nil
 }
+
+// **********************************************************************
+// FetchAnthropicModels ruft https://api.anthropic.com/v1/models ab.
+// API-Key aus ENV: ANTHROPIC_API_KEY (x-api-key Header).
+// Modelle werden als "anthropic/<id>" registriert (Shortcode "a-<sc>"),
+// damit sie neben den gleichnamigen Mammouth-Modellen bestehen.
+func FetchAnthropicModels() ([]Model, error) {
+	apiKey := GetEnvWithFile("ANTHROPIC_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("anthropic: ANTHROPIC_API_KEY nicht gesetzt")
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	req, err := http.NewRequest(http.MethodGet, anthropicModelsEndpoint+"?limit=100", nil)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic: Request-Erstellung fehlgeschlagen: %w", err)
+	}
+	(&anthropicProvider{}).SetAuth(req, apiKey)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic: GET /v1/models: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("anthropic: /v1/models returned HTTP %d", resp.StatusCode)
+	}
+
+	var listResp struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&listResp); err != nil {
+		return nil, fmt.Errorf("anthropic: invalid JSON: %w", err)
+	}
+
+	ids := make([]string, 0, len(listResp.Data))
+	for _, item := range listResp.Data {
+		if item.ID != "" {
+			ids = append(ids, item.ID)
+		}
+	}
+	// Fallback: API liefert keine Modelle → statische bekannte Liste
+	if len(ids) == 0 {
+		LogWarn("Anthropic /v1/models leer, verwende statische Liste")
+		for id := range anthropicKnownModels {
+			ids = append(ids, id)
+		}
+	}
+
+	used := make(map[string]bool)
+	var result []Model
+	for _, id := range ids {
+		sc := generateProviderShortcode(id, used)
+		used[sc] = true
+		m := Model{
+			ID:              anthropicModelPrefix + id,
+			Shortcode:       "a-" + sc,
+			Endpoint:        anthropicChatEndpoint,
+			APIKeyEnv:       "ANTHROPIC_API_KEY",
+			MaxInputTokens:  200000,
+			MaxOutputTokens: 8192,
+			MinTemperature:  0.0,
+			MaxTemperature:  1.0,
+		}
+		if known, ok := anthropicKnownModels[id]; ok {
+			m.MaxInputTokens = known.MaxInputTokens
+			m.MaxOutputTokens = known.MaxOutputTokens
+			m.InputCost = known.InputCost
+			m.OutputCost = known.OutputCost
+		}
+		result = append(result, m)
+	}
+
+	LogInfo("Anthropic-Modelle geladen", map[string]interface{}{"count": len(result)})
+	return result, nil
+}
+
+// **********************************************************************
+// Gemini — statische Preis-Tabelle (USD/1M tokens, Prompts bis 200k).
+// Die Gemini /models API liefert Limits, aber keine Preise.
+var geminiKnownModels = map[string]Model{
+	"gemini-2.5-pro":        {MaxInputTokens: 1048576, MaxOutputTokens: 65536, InputCost: 1.25, OutputCost: 10.0},
+	"gemini-2.5-flash":      {MaxInputTokens: 1048576, MaxOutputTokens: 65536, InputCost: 0.30, OutputCost: 2.50},
+	"gemini-2.5-flash-lite": {MaxInputTokens: 1048576, MaxOutputTokens: 65536, InputCost: 0.10, OutputCost: 0.40},
+	"gemini-2.0-flash":      {MaxInputTokens: 1048576, MaxOutputTokens: 8192, InputCost: 0.10, OutputCost: 0.40},
+}
+
+// geminiModel ist ein Eintrag aus GET /v1beta/models.
+type geminiModel struct {
+	Name                       string   `json:"name"` // "models/gemini-2.5-flash"
+	InputTokenLimit            int      `json:"inputTokenLimit"`
+	OutputTokenLimit           int      `json:"outputTokenLimit"`
+	MaxTemperature             float64  `json:"maxTemperature"`
+	SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+}
+
+// **********************************************************************
+// FetchGeminiModels ruft https://generativelanguage.googleapis.com/v1beta/models
+// ab (seitenweise). API-Key aus ENV: GEMINI_API_KEY (x-goog-api-key Header).
+// Übernommen werden nur Modelle mit generateContent, als "gemini/<id>"
+// (Shortcode "g-<sc>"), damit sie neben den Mammouth-Modellen bestehen.
+func FetchGeminiModels() ([]Model, error) {
+	apiKey := GetEnvWithFile("GEMINI_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("gemini: GEMINI_API_KEY nicht gesetzt")
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	var items []geminiModel
+	pageToken := ""
+	for {
+		url := geminiModelsEndpoint + "?pageSize=1000"
+		if pageToken != "" {
+			url += "&pageToken=" + pageToken
+		}
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("gemini: Request-Erstellung fehlgeschlagen: %w", err)
+		}
+		(&geminiProvider{}).SetAuth(req, apiKey)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("gemini: GET /v1beta/models: %w", err)
+		}
+		var listResp struct {
+			Models        []geminiModel `json:"models"`
+			NextPageToken string        `json:"nextPageToken"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("gemini: /v1beta/models returned HTTP %d", resp.StatusCode)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&listResp)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("gemini: invalid JSON: %w", err)
+		}
+		items = append(items, listResp.Models...)
+		if listResp.NextPageToken == "" {
+			break
+		}
+		pageToken = listResp.NextPageToken
+	}
+
+	models := convertGeminiModels(items)
+	// Fallback: API liefert keine Modelle → statische bekannte Liste
+	if len(models) == 0 {
+		LogWarn("Gemini /v1beta/models leer, verwende statische Liste")
+		for id, known := range geminiKnownModels {
+			items = append(items, geminiModel{
+				Name:                       "models/" + id,
+				InputTokenLimit:            known.MaxInputTokens,
+				OutputTokenLimit:           known.MaxOutputTokens,
+				SupportedGenerationMethods: []string{"generateContent"},
+			})
+		}
+		models = convertGeminiModels(items)
+	}
+
+	LogInfo("Gemini-Modelle geladen", map[string]interface{}{"count": len(models)})
+	return models, nil
+}
+
+func convertGeminiModels(items []geminiModel) []Model {
+	used := make(map[string]bool)
+	var result []Model
+	for _, item := range items {
+		id := strings.TrimPrefix(item.Name, "models/")
+		chat := false
+		for _, method := range item.SupportedGenerationMethods {
+			chat = chat || method == "generateContent"
+		}
+		if id == "" || !chat {
+			continue
+		}
+		sc := generateProviderShortcode(id, used)
+		used[sc] = true
+		m := Model{
+			ID:              geminiModelPrefix + id,
+			Shortcode:       "g-" + sc,
+			Endpoint:        GeminiChatEndpoint(id),
+			APIKeyEnv:       "GEMINI_API_KEY",
+			MaxInputTokens:  item.InputTokenLimit,
+			MaxOutputTokens: item.OutputTokenLimit,
+			MinTemperature:  0.0,
+			MaxTemperature:  item.MaxTemperature,
+		}
+		if m.MaxInputTokens == 0 {
+			m.MaxInputTokens = 32768
+		}
+		if m.MaxOutputTokens == 0 {
+			m.MaxOutputTokens = 8192
+		}
+		if m.MaxTemperature == 0 {
+			m.MaxTemperature = 2.0
+		}
+		if known, ok := geminiKnownModels[id]; ok {
+			m.InputCost = known.InputCost
+			m.OutputCost = known.OutputCost
+		}
+		result = append(result, m)
+	}
+	return result
+}
@@ -0,0 +1,100 @@
+package sigoengine
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestBuiltinProvidersRegistered(t *testing.T) {
+	want := []string{"anthropic", "gemini", "mammouth", "moonshot", "zai"}
+	var got []string
+	for _, p := range Providers() {
+		got = append(got, p.Name())
+	}
+	if len(got) < len(want) {
+		t.Fatalf("Providers() = %v, want mindestens %v", got, want)
+	}
+	for _, name := range want {
+		p, ok := GetProvider(name)
+		if !ok {
+			t.Fatalf("Provider %q nicht registriert", name)
+		}
+		if endpoint, _ := p.ProbeEndpoint(); endpoint == "" {
+			t.Errorf("%s: kein Probe-Endpoint", name)
+		}
+	}
+	if configType("anthropic") != "anthropic" || configType("moonshot") != "mammoth" || configType("unbekannt") != "mammoth" {
+		t.Error("configType liefert falschen Typ")
+	}
+}
+
+func TestProviderForModel(t *testing.T) {
+	tests := []struct {
+		endpoint, apiKeyEnv, model, want string
+	}{
+		{"", "MOONSHOT_API_KEY", "x", "moonshot"},
+		{"", "ANTHROPIC_API_KEY", "anthropic/claude-sonnet-4-6", "anthropic"},
+		{"https://api.z.ai/api/paas/v4/chat/completions", "", "x", "zai"},
+		{"https://api.anthropic.com/v1/messages", "", "x", "anthropic"},
+		{"https://api.mammouth.ai/v1/chat/completions", "", "kimi-k2", "mammouth"},
+		{"", "", "Kimi-K2", "moonshot"},
+		{"", "", "glm-4.6", "zai"},
+		{"", "GEMINI_API_KEY_0", "x", "gemini"},
+		{"", "", "gemini/gemini-2.5-pro", "gemini"},
+		{"", "", "gemini-2.5-pro", DefaultProvider},
+		{"", "", "gpt-4.1", DefaultProvider},
+	}
+	for _, tt := range tests {
+		if got := ProviderForModel(tt.endpoint, tt.apiKeyEnv, tt.model); got != tt.want {
+			t.Errorf("ProviderForModel(%q, %q, %q) = %q, want %q", tt.endpoint, tt.apiKeyEnv, tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestProviderSetAuth(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "http://example", nil)
+	providerForConfig(&ProviderConfig{Type: "anthropic"}).SetAuth(req, "sk-ant")
+	if req.Header.Get("x-api-key") != "sk-ant" || req.Header.Get("anthropic-version") == "" || req.Header.Get("Authorization") != "" {
+		t.Errorf("Anthropic-Auth falsch: %v", req.Header)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, "http://example", nil)
+	providerForConfig(&ProviderConfig{Type: "ollama"}).SetAuth(req, "")
+	if req.Header.Get("Authorization") != "" {
+		t.Errorf("Ollama ohne Key darf keinen Authorization-Header setzen: %v", req.Header)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, "http://example", nil)
+	providerForConfig(&ProviderConfig{Type: "mammoth", Provider: "zai"}).SetAuth(req, "k")
+	if req.Header.Get("Authorization") != "Bearer k" {
+		t.Errorf("Bearer-Auth falsch: %v", req.Header)
+	}
+}
+
+// testProvider prüft, dass ein neuer Provider nur RegisterProvider braucht.
+type testProvider struct {
+	OpenAICompatProvider
+}
+
+func TestRegisterProviderDiscovery(t *testing.T) {
+	RegisterProvider(&testProvider{OpenAICompatProvider{ProviderName: "testprov", KeyEnvPrefix: "TESTPROV", Hosts: []string{"testprov.example"}}})
+	defer func() {
+		providersMu.Lock()
+		delete(providers, "testprov")
+		providersMu.Unlock()
+	}()
+
+	t.Setenv("TESTPROV_API_KEY", "k1")
+	t.Setenv("TESTPROV_API_KEY_0", "k2")
+	r := NewChannelRegistry("")
+	r.DiscoverFromEnv()
+	if _, ok := r.GetChannel("testprov", "default"); !ok {
+		t.Error("default-Kanal für testprov fehlt")
+	}
+	if _, ok := r.GetChannel("testprov", "0"); !ok {
+		t.Error("Kanal 0 für testprov fehlt")
+	}
+	if got := ProviderForModel("https://testprov.example/v1/chat/completions", "", "m"); got != "testprov" {
+		t.Errorf("ProviderForModel = %q, want testprov", got)
+	}
+}
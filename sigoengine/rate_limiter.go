@@ -3,7 +3,12 @@
 //**********************************************************************
 //  Beschreibung: Pro-Kanal Rate-Limiter (hybrid).
 //  Acquire blockiert bis minInterval seit letztem Call vergangen,
-//  spätestens nach maxWait → ErrRateLimited (→ HTTP 429).
+//  (max_concurrent) ein Slot frei ist und die Token-Buckets (rpm, tpm)
+//  reichen, spätestens nach maxWait → ErrRateLimited (→ HTTP 429).
+//  Release gibt den Slot nach dem Call frei, CorrectTokens bucht die
+//  TPM-Schätzung auf die echte Usage um. Bench pausiert einen Kanal, den
+//  der Provider als erschöpft meldet, bis zum Reset; liegt der hinter
+//  maxWait, gibt es sofort ErrRateLimited (Failover ohne Wartezeit).
 //**********************************************************************
 
 package sigoengine
@@ -19,77 +24,224 @@ import (
 // Vom Server als HTTP 429 + Retry-After gemappt.
 var ErrRateLimited = errors.New("rate_limit_exceeded")
 
-// RateLimiter verwaltet pro Kanal-Schlüssel den letzten Call-Zeitstempel.
+// RateLimiter verwaltet pro Kanal-Schlüssel den letzten Call-Zeitstempel
+// und die Zahl laufender Calls (Bulkhead für max_concurrent).
 // Runtime-State (nicht in channels.json persistiert).
 type RateLimiter struct {
 	mu       sync.Mutex
 	lastCall map[string]time.Time
+	inFlight map[string]int
+	released map[string]chan struct{} // wird beim nächsten Release geschlossen
+	requests map[string]*tokenBucket  // RPM-Bucket pro Kanal
+	tokens   map[string]*tokenBucket  // TPM-Bucket pro Kanal
+	benched  map[string]time.Time     // Kanal laut Provider erschöpft bis
+}
+
+// Limits sind die Limits eines Kanals für AcquireLimits.
+type Limits struct {
+	MinInterval   time.Duration // Mindest-Abstand zwischen Calls (0 = keiner)
+	MaxWait       time.Duration // max Wartezeit bis ErrRateLimited
+	MaxConcurrent int           // max parallele Calls (0 = unbegrenzt)
+	RPM           int           // Requests pro Minute (0 = unbegrenzt)
+	TPM           int           // Tokens pro Minute (0 = unbegrenzt)
+	Burst         int           // Kapazität des RPM-Buckets (0 → RPM)
+	Tokens        int           // geschätzte Tokens dieses Calls (für TPM)
+}
+
+// requestCapacity liefert die Kapazität des RPM-Buckets.
+func (l Limits) requestCapacity() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.RPM
+}
+
+// tokenCost liefert die TPM-Kosten des Calls, höchstens die Kapazität
+// (sonst könnte ein großer Call nie starten).
+func (l Limits) tokenCost() float64 {
+	if l.Tokens > l.TPM {
+		return float64(l.TPM)
+	}
+	return float64(l.Tokens)
 }
 
 // NewRateLimiter erzeugt einen leeren Limiter.
 func NewRateLimiter() *RateLimiter {
-	return &RateLimiter{lastCall: make(map[string]time.Time)}
+	return &RateLimiter{
+		lastCall: make(map[string]time.Time),
+		inFlight: make(map[string]int),
+		released: make(map[string]chan struct{}),
+		requests: make(map[string]*tokenBucket),
+		tokens:   make(map[string]*tokenBucket),
+		benched:  make(map[string]time.Time),
+	}
 }
 
 // Acquire wartet bis minInterval seit letztem Call vergangen ist.
 // Schläft höchstens maxWait; danach ErrRateLimited.
 // ctx-Abbruch bricht Wartezeit ab (→ ctx.Err()).
 // Bei Erfolg wird lastCall sofort gesetzt, damit parallele Acquires
-// sich serialisieren.
+// sich serialisieren. Jedes erfolgreiche Acquire braucht ein Release.
 func (rl *RateLimiter) Acquire(ctx context.Context, channelKey string, minInterval, maxWait time.Duration) error {
-	deadline := time.Now().Add(maxWait)
+	_, err := rl.AcquireLimits(ctx, channelKey, Limits{MinInterval: minInterval, MaxWait: maxWait})
+	return err
+}
+
+// AcquireLimits wartet, bis der Kanal frei ist: MinInterval seit dem
+// letzten Call vergangen, weniger als MaxConcurrent Calls laufend,
+// genug im RPM- bzw. TPM-Bucket (Tokens wird vorab abgebucht) und keine
+// Pause per Bench (Reset hinter MaxWait → sofort ErrRateLimited).
+// Ein voller Kanal wartet auf ein Release, höchstens MaxWait; danach
+// ErrRateLimited wie beim Intervall (→ Failover bzw. HTTP 429).
+// charged ist die abgebuchte TPM-Menge (höchstens TPM, ohne TPM 0); sie
+// ist die Basis für CorrectTokens.
+func (rl *RateLimiter) AcquireLimits(ctx context.Context, channelKey string, limits Limits) (charged int, err error) {
+	deadline := time.Now().Add(limits.MaxWait)
 	for {
 		// Context schon vorbei?
 		if err := ctx.Err(); err != nil {
-			return err
+			return 0, err
 		}
 
 		rl.mu.Lock()
 		now := time.Now()
-		last := rl.lastCall[channelKey]
-		wait := minInterval - now.Sub(last)
-		if wait <= 0 {
+		wait := limits.MinInterval - now.Sub(rl.lastCall[channelKey])
+		if until, ok := rl.benched[channelKey]; ok {
+			if !until.After(now) {
+				delete(rl.benched, channelKey)
+			} else if until.After(deadline) {
+				// Reset liegt hinter maxWait: Warten lohnt nicht
+				rl.mu.Unlock()
+				return 0, ErrRateLimited
+			} else if w := until.Sub(now); w > wait {
+				wait = w
+			}
+		}
+		full := limits.MaxConcurrent > 0 && rl.inFlight[channelKey] >= limits.MaxConcurrent
+		var reqBucket, tokBucket *tokenBucket
+		if limits.RPM > 0 {
+			reqBucket = rl.bucket(rl.requests, channelKey, limits.requestCapacity(), now)
+			reqBucket.refill(now, limits.requestCapacity(), limits.RPM)
+			if w := reqBucket.waitFor(1, limits.RPM); w > wait {
+				wait = w
+			}
+		}
+		if limits.TPM > 0 {
+			tokBucket = rl.bucket(rl.tokens, channelKey, limits.TPM, now)
+			tokBucket.refill(now, limits.TPM, limits.TPM)
+			if w := tokBucket.waitFor(limits.tokenCost(), limits.TPM); w > wait {
+				wait = w
+			}
+		}
+		if wait <= 0 && !full {
 			rl.lastCall[channelKey] = now
+			rl.inFlight[channelKey]++
+			if reqBucket != nil {
+				reqBucket.level--
+			}
+			if tokBucket != nil {
+				tokBucket.level -= limits.tokenCost()
+				charged = int(limits.tokenCost())
+			}
 			rl.mu.Unlock()
-			return nil
+			return charged, nil
+		}
+		released := rl.released[channelKey]
+		if released == nil {
+			released = make(chan struct{})
+			rl.released[channelKey] = released
 		}
 		rl.mu.Unlock()
 
-		// Wartezeit würde deadline überschreiten → bis deadline schlafen,
-		// dann endgültig prüfen.
-		if now.Add(wait).After(deadline) {
-			sleep := time.Until(deadline)
-			if sleep > 0 {
-				select {
-				case <-ctx.Done():
-					return ctx.Err()
-				case <-time.After(sleep):
-				}
-			}
-			// Letzter Versuch nach deadline.
-			rl.mu.Lock()
-			now = time.Now()
-			last = rl.lastCall[channelKey]
-			if minInterval-now.Sub(last) <= 0 {
-				rl.lastCall[channelKey] = now
-				rl.mu.Unlock()
-				return nil
-			}
-			rl.mu.Unlock()
-			return ErrRateLimited
+		// Deadline erreicht: der Versuch oben war der letzte.
+		remaining := deadline.Sub(now)
+		if remaining <= 0 {
+			return 0, ErrRateLimited
 		}
 
-		// Innerhalb deadline: voll warten, dann Loop erneut
-		// (lastCall könnte von anderer goroutine aktualisiert worden sein).
+		// Bis zum Intervall-Ende bzw. zur Deadline schlafen; ein voller
+		// Kanal wacht zusätzlich beim nächsten Release auf. Danach Loop
+		// erneut (lastCall könnte von anderer goroutine aktualisiert sein).
+		sleep := remaining
+		if !full && wait < sleep {
+			sleep = wait
+		}
+		timer := time.NewTimer(sleep)
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(wait):
+			timer.Stop()
+			return 0, ctx.Err()
+		case <-timer.C:
+		case <-released:
+			timer.Stop()
 		}
 	}
 }
 
-// Release ist aktuell ein Noop — lastCall wird in Acquire bei Erfolg
-// gesetzt. Existiert als symmetrisches Gegenstück für künftige
-// Semaphor-/Waiter-Zählung und explizite Aufrufstelle im Handler.
-func (rl *RateLimiter) Release(channelKey string) {}
+// Release gibt den Slot eines erfolgreichen Acquire frei (nach dem Call)
+// und weckt Requests, die auf einen vollen Kanal warten.
+func (rl *RateLimiter) Release(channelKey string) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.inFlight[channelKey] > 0 {
+		rl.inFlight[channelKey]--
+	}
+	if released := rl.released[channelKey]; released != nil {
+		close(released)
+		delete(rl.released, channelKey)
+	}
+}
+
+// CorrectTokens bucht die Abbuchung eines Calls (charged aus
+// AcquireLimits) auf die echte Usage um (actual > charged belastet den
+// Bucket nach, sonst Gutschrift).
+func (rl *RateLimiter) CorrectTokens(channelKey string, charged, actual int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if b := rl.tokens[channelKey]; b != nil {
+		b.level -= float64(actual - charged)
+	}
+}
+
+// Bench pausiert einen Kanal bis until (Reset laut Provider). Eine
+// bestehende längere Pause bleibt; meldet true, wenn sich die Pause
+// verlängert hat.
+func (rl *RateLimiter) Bench(channelKey string, until time.Time) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if !until.After(rl.benched[channelKey]) {
+		return false
+	}
+	rl.benched[channelKey] = until
+	return true
+}
+
+// BenchedUntil liefert das Ende der Pause eines Kanals (Zero-Time, wenn
+// der Kanal nicht pausiert).
+func (rl *RateLimiter) BenchedUntil(channelKey string) time.Time {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	until := rl.benched[channelKey]
+	if !until.After(time.Now()) {
+		return time.Time{}
+	}
+	return until
+}
+
+// bucket liefert den Bucket eines Kanals, neu angelegt voll.
+// Aufrufer muss rl.mu halten.
+func (rl *RateLimiter) bucket(buckets map[string]*tokenBucket, channelKey string, capacity int, now time.Time) *tokenBucket {
+	b := buckets[channelKey]
+	if b == nil {
+		b = newTokenBucket(capacity, now)
+		buckets[channelKey] = b
+	}
+	return b
+}
+
+// InFlight liefert die Zahl der belegten Slots eines Kanals.
+func (rl *RateLimiter) InFlight(channelKey string) int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.inFlight[channelKey]
+}
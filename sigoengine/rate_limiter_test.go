@@ -146,3 +146,155 @@ func TestRateLimiterConcurrentSerializes(t *testing.T) {
 		}
 	}
 }
+
+// TestRateLimiterMaxConcurrent: voller Kanal wartet auf Release, sonst ErrRateLimited.
+func TestRateLimiterMaxConcurrent(t *testing.T) {
+	rl := NewRateLimiter()
+	ctx := context.Background()
+	limits := Limits{MaxWait: 50 * time.Millisecond, MaxConcurrent: 2}
+
+	for i := 0; i < 2; i++ {
+		if _, err := rl.AcquireLimits(ctx, "k1", limits); err != nil {
+			t.Fatalf("Acquire %d: %v", i, err)
+		}
+	}
+	if n := rl.InFlight("k1"); n != 2 {
+		t.Fatalf("InFlight = %d, want 2", n)
+	}
+
+	// Kanal voll, kein Release innerhalb maxWait → ErrRateLimited
+	start := time.Now()
+	if _, err := rl.AcquireLimits(ctx, "k1", limits); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("erwartet ErrRateLimited, got %v", err)
+	}
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Fatalf("voller Kanal sollte bis maxWait warten, war %v", d)
+	}
+
+	// Release während der Wartezeit weckt den Wartenden sofort
+	limits.MaxWait = time.Second
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		rl.Release("k1")
+	}()
+	start = time.Now()
+	if _, err := rl.AcquireLimits(ctx, "k1", limits); err != nil {
+		t.Fatalf("Acquire nach Release: %v", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Fatalf("Release sollte den Wartenden wecken, wartete %v", d)
+	}
+
+	// Andere Keys sind unabhängig
+	if _, err := rl.AcquireLimits(ctx, "k2", limits); err != nil {
+		t.Fatalf("k2: %v", err)
+	}
+}
+
+// TestRateLimiterRPMBurst: der RPM-Bucket erlaubt burst Calls sofort, danach
+// erst nach Nachfüllen (rpm/60 pro Sekunde).
+func TestRateLimiterRPMBurst(t *testing.T) {
+	rl := NewRateLimiter()
+	ctx := context.Background()
+	limits := Limits{MaxWait: 20 * time.Millisecond, RPM: 60, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		if _, err := rl.AcquireLimits(ctx, "k1", limits); err != nil {
+			t.Fatalf("Burst-Call %d: %v", i, err)
+		}
+		rl.Release("k1")
+	}
+	// Bucket leer, nächster Token erst nach ~1s > maxWait
+	if _, err := rl.AcquireLimits(ctx, "k1", limits); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("erwartet ErrRateLimited nach Burst, got %v", err)
+	}
+
+	// Mit rpm=6000 kommt alle 10ms ein Token nach → Wartezeit statt Fehler
+	limits = Limits{MaxWait: time.Second, RPM: 6000, Burst: 1}
+	if _, err := rl.AcquireLimits(ctx, "k2", limits); err != nil {
+		t.Fatalf("k2 erster Call: %v", err)
+	}
+	start := time.Now()
+	if _, err := rl.AcquireLimits(ctx, "k2", limits); err != nil {
+		t.Fatalf("k2 zweiter Call: %v", err)
+	}
+	if d := time.Since(start); d < 5*time.Millisecond {
+		t.Fatalf("zweiter Call sollte auf Nachfüllen warten, war %v", d)
+	}
+}
+
+// TestRateLimiterTPMCorrect: die TPM-Schätzung wird vorab abgebucht und per
+// CorrectTokens auf die echte Usage umgebucht.
+func TestRateLimiterTPMCorrect(t *testing.T) {
+	rl := NewRateLimiter()
+	ctx := context.Background()
+	limits := Limits{MaxWait: 20 * time.Millisecond, TPM: 1000, Tokens: 600}
+
+	if _, err := rl.AcquireLimits(ctx, "k1", limits); err != nil {
+		t.Fatalf("erster Call: %v", err)
+	}
+	rl.Release("k1")
+	// 400 übrig, Schätzung 600 passt nicht
+	if _, err := rl.AcquireLimits(ctx, "k1", limits); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("erwartet ErrRateLimited, got %v", err)
+	}
+
+	// Echte Usage war nur 100 → 500 gutgeschrieben, 900 verfügbar
+	rl.CorrectTokens("k1", 600, 100)
+	if _, err := rl.AcquireLimits(ctx, "k1", limits); err != nil {
+		t.Fatalf("Call nach Korrektur: %v", err)
+	}
+	rl.Release("k1")
+
+	// Größer als das Limit: Kosten auf die Kapazität gedeckelt, sonst nie möglich
+	big := Limits{MaxWait: 20 * time.Millisecond, TPM: 1000, Tokens: 5000}
+	charged, err := rl.AcquireLimits(ctx, "k2", big)
+	if err != nil || charged != 1000 {
+		t.Fatalf("Call über TPM: charged=%d, %v", charged, err)
+	}
+	rl.Release("k2")
+
+	// Korrektur gegen die Abbuchung (1000), nicht die Schätzung (5000):
+	// echte Usage 300 → 700 gutgeschrieben, ein voller Call passt nicht
+	rl.CorrectTokens("k2", charged, 300)
+	if _, err := rl.AcquireLimits(ctx, "k2", Limits{MaxWait: 20 * time.Millisecond, TPM: 1000, Tokens: 1000}); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("erwartet ErrRateLimited nach Korrektur, got %v", err)
+	}
+	if _, err := rl.AcquireLimits(ctx, "k2", Limits{MaxWait: 20 * time.Millisecond, TPM: 1000, Tokens: 600}); err != nil {
+		t.Fatalf("Call innerhalb der Gutschrift: %v", err)
+	}
+}
+
+// TestRateLimiterBench: ein pausierter Kanal liefert sofort ErrRateLimited,
+// wenn der Reset hinter maxWait liegt, sonst wartet Acquire bis zum Reset.
+func TestRateLimiterBench(t *testing.T) {
+	rl := NewRateLimiter()
+	ctx := context.Background()
+
+	if !rl.Bench("k1", time.Now().Add(time.Minute)) {
+		t.Fatal("Bench sollte die Pause setzen")
+	}
+	if rl.Bench("k1", time.Now().Add(time.Second)) {
+		t.Fatal("kürzere Pause darf die längere nicht ersetzen")
+	}
+	start := time.Now()
+	_, err := rl.AcquireLimits(ctx, "k1", Limits{MaxWait: time.Second})
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("erwartet ErrRateLimited, got %v", err)
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Fatalf("Reset hinter maxWait: sofortiger Fehler erwartet, wartete %v", d)
+	}
+
+	rl.Bench("k2", time.Now().Add(50*time.Millisecond))
+	start = time.Now()
+	if _, err := rl.AcquireLimits(ctx, "k2", Limits{MaxWait: time.Second}); err != nil {
+		t.Fatalf("k2: %v", err)
+	}
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Fatalf("Acquire sollte bis zum Reset warten, war %v", d)
+	}
+	if !rl.BenchedUntil("k2").IsZero() {
+		t.Fatal("Pause nach Reset nicht beendet")
+	}
+}
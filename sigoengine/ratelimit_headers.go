@@ -0,0 +1,112 @@
+//**********************************************************************
+//      sigoengine/ratelimit_headers.go
+//**********************************************************************
+//  Beschreibung: Rate-Limit-Auskunft aus Provider-Antworten.
+//  Retry-After (429) und x-ratelimit-remaining-*/x-ratelimit-reset-*
+//  (bzw. anthropic-ratelimit-*) ergeben den Zeitpunkt, bis zu dem ein
+//  Kanal erschöpft ist. ProviderConfig.OnRateLimit meldet ihn an den
+//  Aufrufer (sigoREST: RateLimiter.Bench → sofortiger Failover).
+//**********************************************************************
+
+package sigoengine
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRateLimitBench begrenzt die Pause aus Provider-Headern (Schutz vor
+// Tages-Quoten und unsinnigen Reset-Werten).
+const maxRateLimitBench = time.Hour
+
+// rateLimitHeaderPairs sind die bekannten Remaining/Reset-Header.
+var rateLimitHeaderPairs = [][2]string{
+	{"x-ratelimit-remaining-requests", "x-ratelimit-reset-requests"},
+	{"x-ratelimit-remaining-tokens", "x-ratelimit-reset-tokens"},
+	{"x-ratelimit-remaining", "x-ratelimit-reset"},
+	{"anthropic-ratelimit-requests-remaining", "anthropic-ratelimit-requests-reset"},
+	{"anthropic-ratelimit-tokens-remaining", "anthropic-ratelimit-tokens-reset"},
+}
+
+// RateLimitReset liefert, bis wann ein Kanal laut Antwort erschöpft ist:
+// bei 429 aus Retry-After, sonst (und bei 429 ohne Retry-After) aus einem
+// Remaining-Header mit 0 und dem zugehörigen Reset. Zero-Time, wenn die
+// Antwort nichts dergleichen meldet.
+func RateLimitReset(h http.Header, statusCode int, now time.Time) time.Time {
+	var until time.Time
+	if statusCode == http.StatusTooManyRequests {
+		if d := parseRetryAfter(h.Get("Retry-After"), now); d > 0 {
+			until = now.Add(d)
+		}
+	}
+	for _, pair := range rateLimitHeaderPairs {
+		remaining, err := strconv.ParseFloat(strings.TrimSpace(h.Get(pair[0])), 64)
+		if err != nil || remaining > 0 {
+			continue
+		}
+		if d := parseResetHeader(h.Get(pair[1]), now); d > 0 && now.Add(d).After(until) {
+			until = now.Add(d)
+		}
+	}
+	if until.After(now.Add(maxRateLimitBench)) {
+		until = now.Add(maxRateLimitBench)
+	}
+	return until
+}
+
+// parseRetryAfter liest Retry-After in Sekunden oder als HTTP-Datum.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// parseResetHeader liest einen Reset-Header. Formate je Provider:
+// Go-Duration ("6m0s", "20ms", OpenAI), Sekunden ("1.5"), Unix-Zeit in
+// Sekunden oder Millisekunden, RFC 3339 (Anthropic).
+func parseResetHeader(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		switch {
+		case n > 1e12: // Unix-Zeit in ms
+			return time.UnixMilli(int64(n)).Sub(now)
+		case n > 1e9: // Unix-Zeit in s
+			sec, frac := math.Modf(n)
+			return time.Unix(int64(sec), int64(frac*1e9)).Sub(now)
+		default:
+			return time.Duration(n * float64(time.Second))
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return d
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// reportRateLimit meldet eine erschöpfte Rate-Limit-Auskunft der Antwort
+// an cfg.OnRateLimit (falls gesetzt).
+func reportRateLimit(cfg *ProviderConfig, resp *http.Response) {
+	if cfg.OnRateLimit == nil {
+		return
+	}
+	if until := RateLimitReset(resp.Header, resp.StatusCode, time.Now()); !until.IsZero() {
+		cfg.OnRateLimit(until)
+	}
+}
@@ -0,0 +1,51 @@
+package sigoengine
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimitReset(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	header := func(kv ...string) http.Header {
+		h := http.Header{}
+		for i := 0; i < len(kv); i += 2 {
+			h.Set(kv[i], kv[i+1])
+		}
+		return h
+	}
+
+	tests := []struct {
+		name   string
+		h      http.Header
+		status int
+		want   time.Duration // 0 → keine Pause
+	}{
+		{"429 Retry-After Sekunden", header("Retry-After", "7"), 429, 7 * time.Second},
+		{"429 Retry-After HTTP-Datum", header("Retry-After", now.Add(time.Minute).Format(http.TimeFormat)), 429, time.Minute},
+		{"Retry-After ohne 429", header("Retry-After", "7"), 200, 0},
+		{"OpenAI remaining 0", header("x-ratelimit-remaining-requests", "0", "x-ratelimit-reset-requests", "6m0s"), 200, 6 * time.Minute},
+		{"remaining > 0", header("x-ratelimit-remaining-tokens", "15", "x-ratelimit-reset-tokens", "20ms"), 200, 0},
+		{"Reset in Sekunden", header("x-ratelimit-remaining", "0", "x-ratelimit-reset", "1.5"), 200, 1500 * time.Millisecond},
+		{"Reset als Unix-Zeit", header("x-ratelimit-remaining", "0", "x-ratelimit-reset", "1767323105"), 200, 60 * time.Second},
+		{"Anthropic RFC 3339", header("anthropic-ratelimit-requests-remaining", "0",
+			"anthropic-ratelimit-requests-reset", now.Add(30*time.Second).Format(time.RFC3339)), 200, 30 * time.Second},
+		{"längster Reset gewinnt", header("Retry-After", "2",
+			"x-ratelimit-remaining-tokens", "0", "x-ratelimit-reset-tokens", "10s"), 429, 10 * time.Second},
+		{"gedeckelt", header("Retry-After", "86400"), 429, maxRateLimitBench},
+		{"keine Header", header(), 200, 0},
+	}
+	for _, tt := range tests {
+		got := RateLimitReset(tt.h, tt.status, now)
+		if tt.want == 0 {
+			if !got.IsZero() {
+				t.Errorf("%s: erwartet keine Pause, got %v", tt.name, got)
+			}
+			continue
+		}
+		if d := got.Sub(now); d != tt.want {
+			t.Errorf("%s: Pause %v, want %v", tt.name, d, tt.want)
+		}
+	}
+}
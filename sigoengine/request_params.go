@@ -0,0 +1,109 @@
+//**********************************************************************
+//      sigoengine/request_params.go
+//**********************************************************************
+//  Beschreibung: Provider-Policy für durchgereichte OpenAI-Parameter.
+//  sigoREST reicht alle Request-Felder, die es nicht selbst auswertet
+//  (top_p, stop, seed, response_format, ...), an den Provider weiter.
+//  Pro Provider kann eine Allow- oder Deny-Liste Felder entfernen, die
+//  der Provider mit HTTP 400 ablehnen würde.
+//**********************************************************************
+
+package sigoengine
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// ParamPolicy beschreibt, welche durchgereichten Parameter ein Provider
+// akzeptiert. Ist Allow gesetzt, gehen nur diese Felder durch; Deny
+// entfernt einzelne Felder. Deny hat Vorrang vor Allow.
+type ParamPolicy struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+var (
+	paramPoliciesMu sync.RWMutex
+	// paramPolicies: Provider → Policy. Provider ohne Eintrag bekommen alle Felder.
+	paramPolicies = map[string]ParamPolicy{
+		// Moonshot (Kimi): OpenAI-kompatibel, aber ohne Logprobs/Logit-Bias
+		// und ohne die OpenAI-spezifischen Plattform-Felder.
+		"moonshot": {Deny: []string{
+			"logit_bias", "logprobs", "top_logprobs", "parallel_tool_calls",
+			"service_tier", "store", "metadata", "prediction", "reasoning_effort",
+		}},
+		// Z.ai (GLM): eigener Parametersatz, unbekannte Felder → 400.
+		// stream_options setzt nur sigoREST (Usage im Stream).
+		"zai": {Allow: []string{
+			"top_p", "stop", "tools", "tool_choice", "response_format",
+			"user_id", "request_id", "do_sample", "thinking", "stream_options",
+		}},
+		// Anthropic (Messages API): der Adapter übersetzt nur diese Felder.
+		"anthropic": {Allow: []string{
+			"top_p", "top_k", "stop", "tools", "tool_choice", "parallel_tool_calls",
+			"user", "max_completion_tokens",
+		}},
+		// Gemini (generateContent): der Adapter übersetzt nur diese Felder.
+		"gemini": {Allow: []string{
+			"top_p", "top_k", "stop", "seed", "presence_penalty", "frequency_penalty",
+			"response_format", "tools", "tool_choice", "max_completion_tokens",
+		}},
+		// Ollama (/v1-Kompatibilität): kein Logit-Bias, keine Logprobs, n=1.
+		"ollama": {Deny: []string{
+			"logit_bias", "logprobs", "top_logprobs", "n",
+			"service_tier", "store", "metadata", "prediction",
+		}},
+	}
+)
+
+// SetParamPolicy setzt (oder ersetzt) die Policy eines Providers.
+func SetParamPolicy(provider string, policy ParamPolicy) {
+	paramPoliciesMu.Lock()
+	defer paramPoliciesMu.Unlock()
+	paramPolicies[provider] = policy
+}
+
+// GetParamPolicy liefert die Policy eines Providers (leer wenn keine gesetzt).
+func GetParamPolicy(provider string) ParamPolicy {
+	paramPoliciesMu.RLock()
+	defer paramPoliciesMu.RUnlock()
+	return paramPolicies[provider]
+}
+
+// ParamAllowed prüft ob ein Parameter für den Provider durchgereicht werden darf.
+func ParamAllowed(provider, name string) bool {
+	policy := GetParamPolicy(provider)
+	for _, d := range policy.Deny {
+		if d == name {
+			return false
+		}
+	}
+	if len(policy.Allow) == 0 {
+		return true
+	}
+	for _, a := range policy.Allow {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterPassthroughParams wendet die Provider-Policy auf die durchzureichenden
+// Felder an. Liefert die erlaubten Felder und die Namen der entfernten
+// (sortiert, für Logging).
+func FilterPassthroughParams(provider string, params map[string]json.RawMessage) (map[string]json.RawMessage, []string) {
+	kept := make(map[string]json.RawMessage, len(params))
+	var dropped []string
+	for name, value := range params {
+		if ParamAllowed(provider, name) {
+			kept[name] = value
+		} else {
+			dropped = append(dropped, name)
+		}
+	}
+	sort.Strings(dropped)
+	return kept, dropped
+}
@@ -0,0 +1,58 @@
+package sigoengine
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFilterPassthroughParamsDeny(t *testing.T) {
+	params := map[string]json.RawMessage{
+		"top_p":      json.RawMessage(`0.9`),
+		"logit_bias": json.RawMessage(`{"50256":-100}`),
+		"seed":       json.RawMessage(`42`),
+	}
+	kept, dropped := FilterPassthroughParams("moonshot", params)
+	if _, ok := kept["logit_bias"]; ok {
+		t.Fatal("logit_bias should be dropped for moonshot")
+	}
+	if _, ok := kept["top_p"]; !ok {
+		t.Fatal("top_p should be kept for moonshot")
+	}
+	if len(dropped) != 1 || dropped[0] != "logit_bias" {
+		t.Fatalf("unexpected dropped list: %v", dropped)
+	}
+}
+
+func TestFilterPassthroughParamsAllow(t *testing.T) {
+	params := map[string]json.RawMessage{
+		"top_p":            json.RawMessage(`0.9`),
+		"presence_penalty": json.RawMessage(`0.5`),
+	}
+	kept, dropped := FilterPassthroughParams("zai", params)
+	if _, ok := kept["top_p"]; !ok || len(kept) != 1 {
+		t.Fatalf("expected only top_p for zai, got %v", kept)
+	}
+	if len(dropped) != 1 || dropped[0] != "presence_penalty" {
+		t.Fatalf("unexpected dropped list: %v", dropped)
+	}
+}
+
+// stream_options setzt sigoREST bei Streaming selbst; Provider mit
+// Allow-Liste, die es verstehen, müssen es aufführen.
+func TestParamAllowedStreamOptions(t *testing.T) {
+	for provider, want := range map[string]bool{
+		"zai": true, "moonshot": true, "mammouth": true, "anthropic": false, "gemini": false,
+	} {
+		if got := ParamAllowed(provider, "stream_options"); got != want {
+			t.Errorf("ParamAllowed(%q, stream_options) = %v, want %v", provider, got, want)
+		}
+	}
+}
+
+func TestFilterPassthroughParamsUnknownProvider(t *testing.T) {
+	params := map[string]json.RawMessage{"logit_bias": json.RawMessage(`{}`)}
+	kept, dropped := FilterPassthroughParams("mammouth", params)
+	if len(kept) != 1 || len(dropped) != 0 {
+		t.Fatalf("mammouth has no policy, expected passthrough: kept=%v dropped=%v", kept, dropped)
+	}
+}
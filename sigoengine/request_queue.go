@@ -0,0 +1,339 @@
+//**********************************************************************
+//      sigoengine/request_queue.go
+//**********************************************************************
+//  Beschreibung: Scheduler vor den Kanälen eines Providers.
+//  Pro Provider laufen höchstens capacity Requests gleichzeitig (Summe
+//  der max_concurrent der aktiven Kanäle, 0 = unbegrenzt); weitere warten
+//  in einer begrenzten Queue. Freie Plätze gehen an die höchste
+//  Prioritätsklasse, innerhalb der Klasse reihum an die wartenden Clients
+//  (ein Batch-Job mit 500 Requests blockiert interaktive Nutzer nicht).
+//  Volle Queue → ErrQueueFull, zu lange gewartet → ErrRateLimited.
+//**********************************************************************
+
+package sigoengine
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull signalisiert: die Queue des Providers ist voll.
+// Vom Server als HTTP 429 gemappt.
+var ErrQueueFull = errors.New("queue_full")
+
+// Priority ist die Prioritätsklasse eines Requests.
+type Priority int
+
+const (
+	PriorityHigh Priority = iota
+	PriorityNormal
+	PriorityLow
+	numPriorities
+)
+
+// priorityNames sind die Namen der Klassen (X-Sigo-Priority, clients.json).
+var priorityNames = [numPriorities]string{"high", "normal", "low"}
+
+// String liefert den Namen der Klasse.
+func (p Priority) String() string {
+	if p < 0 || p >= numPriorities {
+		return "normal"
+	}
+	return priorityNames[p]
+}
+
+// ParsePriority liest einen Klassennamen (case-insensitiv).
+func ParsePriority(s string) (Priority, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for i, name := range priorityNames {
+		if s == name {
+			return Priority(i), true
+		}
+	}
+	return PriorityNormal, false
+}
+
+// queueEntry ist ein wartender Request.
+type queueEntry struct {
+	client   string
+	ready    chan struct{} // geschlossen, sobald der Request dran ist
+	admitted bool
+}
+
+// fairClass ist eine Prioritätsklasse: FIFO je Client, Clients reihum.
+type fairClass struct {
+	clients []string // Round-Robin-Reihenfolge der wartenden Clients
+	waiting map[string][]*queueEntry
+}
+
+// providerQueue ist der Scheduler-Zustand eines Providers.
+type providerQueue struct {
+	capacity int
+	running  int
+	waiting  int
+	classes  [numPriorities]fairClass
+
+	admitted  int64
+	rejected  int64
+	timedOut  int64
+	waitTotal time.Duration
+	waitMax   time.Duration
+}
+
+// RequestQueue verteilt die Plätze aller Provider.
+// Runtime-State (nicht persistiert).
+type RequestQueue struct {
+	mu       sync.Mutex
+	maxDepth int           // max wartende Requests pro Provider
+	maxWait  time.Duration // max Wartezeit bis ErrRateLimited
+	queues   map[string]*providerQueue
+}
+
+// QueueStats ist der Zustand einer Provider-Queue für /api/health.
+type QueueStats struct {
+	Provider  string         `json:"provider"`
+	Capacity  int            `json:"capacity"` // 0 = unbegrenzt
+	Running   int            `json:"running"`
+	Depth     int            `json:"depth"`
+	ByClass   map[string]int `json:"depth_by_priority"`
+	Clients   int            `json:"waiting_clients"`
+	Admitted  int64          `json:"admitted"`
+	Rejected  int64          `json:"rejected"`
+	TimedOut  int64          `json:"timed_out"`
+	AvgWaitMs float64        `json:"avg_wait_ms"`
+	MaxWaitMs int64          `json:"max_wait_ms"`
+}
+
+// NewRequestQueue erzeugt einen Scheduler mit maxDepth wartenden Requests
+// pro Provider und maxWait Wartezeit.
+func NewRequestQueue(maxDepth int, maxWait time.Duration) *RequestQueue {
+	return &RequestQueue{
+		maxDepth: maxDepth,
+		maxWait:  maxWait,
+		queues:   make(map[string]*providerQueue),
+	}
+}
+
+// Enter reiht einen Request von client in die Queue des Providers ein und
+// wartet, bis er laufen darf. capacity ist die aktuelle Zahl paralleler
+// Requests des Providers (0 = unbegrenzt). leave gibt den Platz nach dem
+// Request frei und muss genau einmal aufgerufen werden.
+func (q *RequestQueue) Enter(ctx context.Context, provider, client string, prio Priority, capacity int) (leave func(), err error) {
+	if prio < 0 || prio >= numPriorities {
+		prio = PriorityNormal
+	}
+	start := time.Now()
+
+	q.mu.Lock()
+	pq := q.queue(provider)
+	pq.capacity = capacity
+	pq.dispatch() // Kapazität kann gewachsen sein (Kanal aktiviert)
+	if pq.waiting == 0 && pq.hasRoom() {
+		pq.running++
+		pq.admitted++
+		q.mu.Unlock()
+		return q.leaveFunc(pq), nil
+	}
+	if q.maxDepth > 0 && pq.waiting >= q.maxDepth {
+		pq.rejected++
+		depth := pq.waiting
+		q.mu.Unlock()
+		LogWarn("Queue voll, Request abgewiesen", map[string]interface{}{
+			"provider": provider,
+			"client":   client,
+			"priority": prio.String(),
+			"depth":    depth,
+		})
+		return nil, ErrQueueFull
+	}
+	entry := &queueEntry{client: client, ready: make(chan struct{})}
+	pq.push(prio, entry)
+	q.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if q.maxWait > 0 {
+		timer := time.NewTimer(q.maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-entry.ready:
+		q.mu.Lock()
+		waited := time.Since(start)
+		pq.waitTotal += waited
+		if waited > pq.waitMax {
+			pq.waitMax = waited
+		}
+		q.mu.Unlock()
+		return q.leaveFunc(pq), nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timeout:
+		err = ErrRateLimited
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if entry.admitted {
+		// Gleichzeitig dran gekommen: Platz sofort weitergeben
+		pq.running--
+		pq.dispatch()
+	} else {
+		pq.remove(prio, entry)
+	}
+	if err == ErrRateLimited {
+		pq.timedOut++
+	}
+	return nil, err
+}
+
+// TryEnter belegt einen Platz ohne zu warten (zweiter Call eines Hedge).
+// ok ist false, wenn der Provider voll ist oder Requests warten; wartende
+// Requests haben Vorrang vor einem Hedge.
+func (q *RequestQueue) TryEnter(provider string, capacity int) (leave func(), ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	pq := q.queue(provider)
+	pq.capacity = capacity
+	pq.dispatch()
+	if pq.waiting > 0 || !pq.hasRoom() {
+		return nil, false
+	}
+	pq.running++
+	pq.admitted++
+	return q.leaveFunc(pq), true
+}
+
+// leaveFunc gibt den Platz eines Requests frei (idempotent).
+func (q *RequestQueue) leaveFunc(pq *providerQueue) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			pq.running--
+			pq.dispatch()
+		})
+	}
+}
+
+// Stats liefert den Zustand aller Provider-Queues (nach Provider sortiert).
+func (q *RequestQueue) Stats() []QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	result := make([]QueueStats, 0, len(q.queues))
+	for provider, pq := range q.queues {
+		st := QueueStats{
+			Provider:  provider,
+			Capacity:  pq.capacity,
+			Running:   pq.running,
+			Depth:     pq.waiting,
+			ByClass:   make(map[string]int, numPriorities),
+			Admitted:  pq.admitted,
+			Rejected:  pq.rejected,
+			TimedOut:  pq.timedOut,
+			MaxWaitMs: pq.waitMax.Milliseconds(),
+		}
+		for p := range pq.classes {
+			c := &pq.classes[p]
+			n := 0
+			for _, entries := range c.waiting {
+				n += len(entries)
+			}
+			st.ByClass[Priority(p).String()] = n
+			st.Clients += len(c.clients)
+		}
+		if pq.admitted > 0 {
+			st.AvgWaitMs = float64(pq.waitTotal.Milliseconds()) / float64(pq.admitted)
+		}
+		result = append(result, st)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Provider < result[j].Provider })
+	return result
+}
+
+// queue liefert die Queue eines Providers. Aufrufer muss q.mu halten.
+func (q *RequestQueue) queue(provider string) *providerQueue {
+	pq := q.queues[provider]
+	if pq == nil {
+		pq = &providerQueue{}
+		for p := range pq.classes {
+			pq.classes[p].waiting = make(map[string][]*queueEntry)
+		}
+		q.queues[provider] = pq
+	}
+	return pq
+}
+
+// hasRoom meldet einen freien Platz.
+func (pq *providerQueue) hasRoom() bool {
+	return pq.capacity <= 0 || pq.running < pq.capacity
+}
+
+// push hängt entry an die Warteschlange seines Clients.
+func (pq *providerQueue) push(prio Priority, entry *queueEntry) {
+	c := &pq.classes[prio]
+	if len(c.waiting[entry.client]) == 0 {
+		c.clients = append(c.clients, entry.client)
+	}
+	c.waiting[entry.client] = append(c.waiting[entry.client], entry)
+	pq.waiting++
+}
+
+// remove nimmt einen abgebrochenen Request aus der Queue.
+func (pq *providerQueue) remove(prio Priority, entry *queueEntry) {
+	c := &pq.classes[prio]
+	entries := c.waiting[entry.client]
+	for i, e := range entries {
+		if e == entry {
+			entries = append(entries[:i], entries[i+1:]...)
+			pq.waiting--
+			break
+		}
+	}
+	if len(entries) > 0 {
+		c.waiting[entry.client] = entries
+		return
+	}
+	delete(c.waiting, entry.client)
+	for i, client := range c.clients {
+		if client == entry.client {
+			c.clients = append(c.clients[:i], c.clients[i+1:]...)
+			break
+		}
+	}
+}
+
+// dispatch vergibt freie Plätze: höchste Klasse zuerst, darin reihum je
+// Client der älteste Request. Aufrufer muss q.mu halten.
+func (pq *providerQueue) dispatch() {
+	for pq.waiting > 0 && pq.hasRoom() {
+		for p := range pq.classes {
+			c := &pq.classes[p]
+			if len(c.clients) == 0 {
+				continue
+			}
+			client := c.clients[0]
+			entries := c.waiting[client]
+			entry := entries[0]
+			c.clients = c.clients[1:]
+			if len(entries) > 1 {
+				c.waiting[client] = entries[1:]
+				c.clients = append(c.clients, client)
+			} else {
+				delete(c.waiting, client)
+			}
+			pq.waiting--
+			pq.running++
+			pq.admitted++
+			entry.admitted = true
+			close(entry.ready)
+			break
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package sigoengine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// enterAsync reiht einen Request im Hintergrund ein und meldet die
+// Reihenfolge, in der die Requests dran kommen.
+func enterAsync(t *testing.T, q *RequestQueue, client string, prio Priority, order chan<- string, wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		leave, err := q.Enter(context.Background(), "p", client, prio, 1)
+		if err != nil {
+			t.Errorf("%s: %v", client, err)
+			return
+		}
+		order <- client
+		leave()
+	}()
+}
+
+// waitDepth wartet, bis n Requests in der Queue stehen.
+func waitDepth(t *testing.T, q *RequestQueue, n int) {
+	deadline := time.Now().Add(time.Second)
+	for {
+		if st := q.Stats(); len(st) == 1 && st[0].Depth == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Queue-Tiefe %d nicht erreicht: %+v", n, q.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestRequestQueueFairAndPriority: höhere Klasse zuerst, innerhalb der
+// Klasse reihum je Client statt in Ankunftsreihenfolge.
+func TestRequestQueueFairAndPriority(t *testing.T) {
+	q := NewRequestQueue(0, 0)
+	leave, err := q.Enter(context.Background(), "p", "holder", PriorityNormal, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan string, 10)
+	var wg sync.WaitGroup
+	// Batch-Client reiht drei Requests vor dem interaktiven Client ein
+	for i, c := range []string{"batch", "batch", "batch", "user"} {
+		enterAsync(t, q, c, PriorityNormal, order, &wg)
+		waitDepth(t, q, i+1)
+	}
+	enterAsync(t, q, "batch-low", PriorityLow, order, &wg)
+	waitDepth(t, q, 5)
+	enterAsync(t, q, "admin", PriorityHigh, order, &wg)
+	waitDepth(t, q, 6)
+
+	leave()
+	wg.Wait()
+	close(order)
+	var got []string
+	for c := range order {
+		got = append(got, c)
+	}
+	want := []string{"admin", "batch", "user", "batch", "batch", "batch-low"}
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			t.Fatalf("Reihenfolge %v, want %v", got, want)
+		}
+	}
+
+	st := q.Stats()[0]
+	if st.Running != 0 || st.Depth != 0 || st.Admitted != 7 {
+		t.Errorf("Stats nach Durchlauf: %+v", st)
+	}
+}
+
+// TestRequestQueueFullAndTimeout: volle Queue → ErrQueueFull, zu lange
+// gewartet → ErrRateLimited; ohne Kapazität gibt es keine Wartezeit.
+func TestRequestQueueFullAndTimeout(t *testing.T) {
+	q := NewRequestQueue(1, 30*time.Millisecond)
+	ctx := context.Background()
+	leave, err := q.Enter(ctx, "p", "a", PriorityNormal, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer leave()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := q.Enter(ctx, "p", "b", PriorityNormal, 1)
+		done <- err
+	}()
+	waitDepth(t, q, 1)
+	if _, err := q.Enter(ctx, "p", "c", PriorityHigh, 1); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("erwartet ErrQueueFull, got %v", err)
+	}
+	if err := <-done; !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("erwartet ErrRateLimited nach maxWait, got %v", err)
+	}
+	st := q.Stats()[0]
+	if st.Depth != 0 || st.Rejected != 1 || st.TimedOut != 1 {
+		t.Errorf("Stats: %+v", st)
+	}
+
+	// Kapazität 0 = unbegrenzt
+	for i := 0; i < 5; i++ {
+		if _, err := q.Enter(ctx, "q", "a", PriorityLow, 0); err != nil {
+			t.Fatalf("unbegrenzter Provider: %v", err)
+		}
+	}
+}
+
+// TestRequestQueueTryEnter: Hedge-Platz nur bei freier Kapazität und
+// leerer Queue, ohne zu warten.
+func TestRequestQueueTryEnter(t *testing.T) {
+	q := NewRequestQueue(0, 0)
+	leave, err := q.Enter(context.Background(), "p", "a", PriorityNormal, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hedge, ok := q.TryEnter("p", 2)
+	if !ok {
+		t.Fatal("freier Platz nicht vergeben")
+	}
+	if _, ok := q.TryEnter("p", 2); ok {
+		t.Fatal("Platz über die Kapazität vergeben")
+	}
+
+	order := make(chan string, 1)
+	var wg sync.WaitGroup
+	enterAsync(t, q, "b", PriorityNormal, order, &wg)
+	waitDepth(t, q, 1)
+	hedge()
+	// Wartender Request hat Vorrang vor einem Hedge
+	if _, ok := q.TryEnter("p", 2); ok {
+		t.Fatal("Hedge vor wartendem Request zugelassen")
+	}
+	if got := <-order; got != "b" {
+		t.Fatalf("erwartet b, got %s", got)
+	}
+	wg.Wait()
+	if st := q.Stats()[0]; st.Running != 1 || st.Admitted != 3 {
+		t.Errorf("Stats: %+v", st)
+	}
+	leave()
+}
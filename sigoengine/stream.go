@@ -0,0 +1,194 @@
+//**********************************************************************
+//      sigoengine/stream.go
+//**********************************************************************
+//  Beschreibung: Akkumulation von OpenAI chat.completion.chunk-Events.
+//  Sammelt Text und tool_calls-Deltas zu einer vollständigen Antwort
+//  (für Sessions) sowie finish_reason und den Usage-Chunk, den Provider
+//  bei stream_options.include_usage am Ende senden.
+//**********************************************************************
+
+package sigoengine
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// streamToolCall sammelt die Fragmente eines einzelnen Tool-Aufrufs.
+type streamToolCall struct {
+	ID        string
+	Type      string
+	Name      string
+	Arguments strings.Builder
+}
+
+// StreamAccumulator baut aus Stream-Deltas eine ChatResult-Antwort auf.
+// Nicht thread-safe; ein Accumulator pro Stream.
+type StreamAccumulator struct {
+	text         strings.Builder
+	toolCalls    map[int]*streamToolCall
+	finishReason string
+	usage        *UsageData
+}
+
+// NewStreamAccumulator erzeugt einen leeren Accumulator.
+func NewStreamAccumulator() *StreamAccumulator {
+	return &StreamAccumulator{toolCalls: make(map[int]*streamToolCall)}
+}
+
+// AddData verarbeitet den Inhalt einer "data: "-Zeile (ohne Präfix).
+// Ungültiges JSON und [DONE] werden ignoriert.
+func (a *StreamAccumulator) AddData(data string) {
+	if data == "" || data == "[DONE]" {
+		return
+	}
+	var chunk map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		return
+	}
+	a.AddChunk(chunk)
+}
+
+// AddChunk verarbeitet ein bereits geparstes chat.completion.chunk-Objekt.
+// Der Usage-Chunk (include_usage) hat leere choices und wird hier erkannt.
+func (a *StreamAccumulator) AddChunk(chunk map[string]interface{}) {
+	if u := extractUsage(chunk, ""); u != nil {
+		a.usage = u
+	}
+	choices, ok := chunk["choices"].([]interface{})
+	if !ok || len(choices) == 0 {
+		return
+	}
+	choice, ok := choices[0].(map[string]interface{})
+	if !ok {
+		return
+	}
+	if fr, ok := choice["finish_reason"].(string); ok && fr != "" {
+		a.finishReason = fr
+	}
+	// Moonshot liefert usage im letzten Choice statt auf Top-Level
+	if u := extractUsage(choice, ""); u != nil && a.usage == nil {
+		a.usage = u
+	}
+	delta, ok := choice["delta"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	if content, ok := delta["content"].(string); ok {
+		a.text.WriteString(content)
+	}
+	if tcs, ok := delta["tool_calls"].([]interface{}); ok {
+		for i, raw := range tcs {
+			tc, ok := raw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			// index fehlt bei manchen Providern → Position im Array
+			idx := i
+			if f, ok := tc["index"].(float64); ok {
+				idx = int(f)
+			}
+			entry, ok := a.toolCalls[idx]
+			if !ok {
+				entry = &streamToolCall{}
+				a.toolCalls[idx] = entry
+			}
+			if id, ok := tc["id"].(string); ok && id != "" {
+				entry.ID = id
+			}
+			if typ, ok := tc["type"].(string); ok && typ != "" {
+				entry.Type = typ
+			}
+			if fn, ok := tc["function"].(map[string]interface{}); ok {
+				if name, ok := fn["name"].(string); ok && name != "" {
+					entry.Name = name
+				}
+				if args, ok := fn["arguments"].(string); ok {
+					entry.Arguments.WriteString(args)
+				}
+			}
+		}
+	}
+}
+
+// Text liefert den bisher gesammelten Assistant-Text.
+func (a *StreamAccumulator) Text() string {
+	return a.text.String()
+}
+
+// HasOutput prüft ob bereits Text oder Tool-Aufrufe empfangen wurden.
+func (a *StreamAccumulator) HasOutput() bool {
+	return a.text.Len() > 0 || len(a.toolCalls) > 0
+}
+
+// Result liefert die vollständige Antwort; tool_calls im OpenAI-Format.
+// Usage ist nil, wenn der Provider keine gesendet hat.
+func (a *StreamAccumulator) Result() *ChatResult {
+	res := &ChatResult{Content: a.text.String(), FinishReason: a.finishReason, Usage: a.usage}
+	if len(a.toolCalls) == 0 {
+		return res
+	}
+	indices := make([]int, 0, len(a.toolCalls))
+	for idx := range a.toolCalls {
+		indices = append(indices, idx)
+	}
+	sort.Ints(indices)
+
+	calls := make([]map[string]interface{}, 0, len(indices))
+	for _, idx := range indices {
+		tc := a.toolCalls[idx]
+		typ := tc.Type
+		if typ == "" {
+			typ = "function"
+		}
+		calls = append(calls, map[string]interface{}{
+			"id":   tc.ID,
+			"type": typ,
+			"function": map[string]interface{}{
+				"name":      tc.Name,
+				"arguments": tc.Arguments.String(),
+			},
+		})
+	}
+	res.ToolCalls, _ = json.Marshal(calls)
+	return res
+}
+
+// StreamChunkError erkennt ein Fehler-Objekt im Stream ({"error":{...}}),
+// das manche Provider statt eines HTTP-Status mitten im SSE senden.
+// Liefert nil für normale Chunks. Fehler gelten als Server-Fehler
+// (retryable), außer der Provider nennt einen 4xx-Code.
+func StreamChunkError(data string) error {
+	if !strings.Contains(data, `"error"`) {
+		return nil
+	}
+	var chunk struct {
+		Error *struct {
+			Message string      `json:"message"`
+			Type    string      `json:"type"`
+			Code    interface{} `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil || chunk.Error == nil {
+		return nil
+	}
+	status := 500
+	switch c := chunk.Error.Code.(type) {
+	case float64:
+		status = int(c)
+	case string:
+		if n, err := strconv.Atoi(c); err == nil {
+			status = n
+		}
+	}
+	if status < 400 || status > 599 {
+		status = 500
+	}
+	msg := chunk.Error.Message
+	if msg == "" {
+		msg = chunk.Error.Type
+	}
+	return classifyHTTPError(status, msg, nil)
+}
@@ -0,0 +1,129 @@
+package sigoengine
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestStreamAccumulatorText(t *testing.T) {
+	acc := NewStreamAccumulator()
+	acc.AddData(`{"choices":[{"index":0,"delta":{"role":"assistant","content":"Hal"}}]}`)
+	acc.AddData(`{"choices":[{"index":0,"delta":{"content":"lo"}}]}`)
+	acc.AddData("[DONE]")
+
+	res := acc.Result()
+	if res.Content != "Hallo" {
+		t.Fatalf("expected Hallo, got %q", res.Content)
+	}
+	if res.HasToolCalls() {
+		t.Fatalf("expected no tool calls, got %s", res.ToolCalls)
+	}
+}
+
+func TestStreamAccumulatorUsageAndFinishReason(t *testing.T) {
+	acc := NewStreamAccumulator()
+	acc.AddData(`{"choices":[{"index":0,"delta":{"content":"Hi"},"finish_reason":null}],"usage":null}`)
+	acc.AddData(`{"choices":[{"index":0,"delta":{},"finish_reason":"length"}],"usage":null}`)
+	acc.AddData(`{"choices":[],"usage":{"prompt_tokens":11,"completion_tokens":4,"total_tokens":15}}`)
+
+	res := acc.Result()
+	if res.FinishReason != "length" {
+		t.Fatalf("expected finish_reason length, got %q", res.FinishReason)
+	}
+	if res.Usage == nil || res.Usage.InputTokens != 11 || res.Usage.OutputTokens != 4 || res.Usage.TotalTokens != 15 {
+		t.Fatalf("unexpected usage: %+v", res.Usage)
+	}
+
+	// Moonshot: usage im letzten Choice
+	acc = NewStreamAccumulator()
+	acc.AddData(`{"choices":[{"index":0,"delta":{},"finish_reason":"stop","usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}]}`)
+	if res := acc.Result(); res.Usage == nil || res.Usage.TotalTokens != 5 {
+		t.Fatalf("choice-level usage not captured: %+v", res.Usage)
+	}
+
+	// Ohne Usage-Chunk bleibt Usage nil (Aufrufer schätzt)
+	acc = NewStreamAccumulator()
+	acc.AddData(`{"choices":[{"index":0,"delta":{"content":"x"}}]}`)
+	if acc.Result().Usage != nil {
+		t.Fatal("expected nil usage without usage chunk")
+	}
+}
+
+func TestStreamAccumulatorToolCalls(t *testing.T) {
+	acc := NewStreamAccumulator()
+	acc.AddData(`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`)
+	acc.AddData(`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`)
+	acc.AddData(`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Berlin\"}"}}]}}]}`)
+	acc.AddData(`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_2","function":{"name":"get_time","arguments":"{}"}}]}}]}`)
+
+	res := acc.Result()
+	if !res.HasToolCalls() {
+		t.Fatal("expected tool calls")
+	}
+	var calls []struct {
+		ID       string `json:"id"`
+		Type     string `json:"type"`
+		Function struct {
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(res.ToolCalls, &calls); err != nil {
+		t.Fatalf("invalid tool_calls JSON: %v", err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 tool calls, got %d", len(calls))
+	}
+	if calls[0].ID != "call_1" || calls[0].Function.Name != "get_weather" || calls[0].Function.Arguments != `{"city":"Berlin"}` {
+		t.Fatalf("unexpected first call: %+v", calls[0])
+	}
+	if calls[1].Type != "function" || calls[1].Function.Name != "get_time" {
+		t.Fatalf("unexpected second call: %+v", calls[1])
+	}
+}
+
+func TestSessionAddRawMessageDropsOrphanToolResults(t *testing.T) {
+	s := &Session{}
+	s.AddRawMessage(Message{Role: "assistant", ToolCalls: json.RawMessage(`[{"id":"c1"}]`)})
+	s.AddRawMessage(Message{Role: "tool", ToolCallID: "c1", Content: json.RawMessage(`"42"`)})
+	for i := 0; i < 19; i++ {
+		s.AddMessage("user", "x")
+	}
+	// Assistant mit tool_calls ist herausgefallen → Tool-Ergebnis muss folgen
+	if len(s.History) == 0 || s.History[0].Role == "tool" {
+		t.Fatalf("orphan tool message kept at head: %+v", s.History[0])
+	}
+	if len(s.History) != 19 {
+		t.Fatalf("expected 19 messages, got %d", len(s.History))
+	}
+}
+
+func TestMessageToMapToolFields(t *testing.T) {
+	m := Message{Role: "assistant", ToolCalls: json.RawMessage(`[{"id":"c1"}]`)}
+	out := m.ToMap()
+	if out["content"] != nil {
+		t.Fatalf("expected null content, got %v", out["content"])
+	}
+	if _, ok := out["tool_calls"]; !ok {
+		t.Fatal("expected tool_calls in map")
+	}
+
+	tool := Message{Role: "tool", ToolCallID: "c1", Content: json.RawMessage(`"ok"`)}.ToMap()
+	if tool["tool_call_id"] != "c1" || tool["content"] != "ok" {
+		t.Fatalf("unexpected tool message map: %v", tool)
+	}
+}
+
+func TestStreamChunkError(t *testing.T) {
+	if err := StreamChunkError(`{"choices":[{"index":0,"delta":{"content":"error"}}]}`); err != nil {
+		t.Fatalf("normal chunk classified as error: %v", err)
+	}
+	err := StreamChunkError(`{"error":{"message":"overloaded","type":"server_error"}}`)
+	if apiErr, ok := err.(*APIError); !ok || apiErr.Type != ErrServerError || !apiErr.IsRetryable() {
+		t.Fatalf("expected retryable server error, got %v", err)
+	}
+	err = StreamChunkError(`{"error":{"message":"bad","code":"400"}}`)
+	if apiErr, ok := err.(*APIError); !ok || apiErr.Type != ErrClientError {
+		t.Fatalf("expected client error, got %v", err)
+	}
+}
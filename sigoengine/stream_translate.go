@@ -0,0 +1,99 @@
+//**********************************************************************
+//      sigoengine/stream_translate.go
+//**********************************************************************
+//  Beschreibung: Übersetzung nativer Provider-Streams in OpenAI-SSE.
+//  CallAPIStream liefert für jeden Provider-Typ einen Stream aus
+//  "data: <chat.completion.chunk>"-Zeilen; Provider mit eigenem
+//  Stream-Format (Anthropic) liefern über Provider.NewStreamTranslator
+//  einen StreamTranslator. Fehler-Events werden zu {"error":{...}}-
+//  Chunks, die StreamChunkError wieder als APIError erkennt.
+//**********************************************************************
+
+package sigoengine
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// StreamTranslator übersetzt die data:-Payloads eines nativen Provider-
+// Streams in OpenAI-data-Payloads (chunk-JSON oder "[DONE]").
+type StreamTranslator interface {
+	Translate(data string) ([]string, error)
+}
+
+// translatingStream liest den nativen SSE-Stream zeilenweise und gibt die
+// übersetzten Payloads als "data: ...\n\n"-Frames aus.
+type translatingStream struct {
+	src     io.ReadCloser
+	scanner *bufio.Scanner
+	tr      StreamTranslator
+	out     bytes.Buffer
+	done    bool
+}
+
+// TranslateStream umhüllt einen nativen Provider-Stream, sodass der Leser
+// OpenAI-SSE sieht. Close schließt den Quell-Stream.
+func TranslateStream(src io.ReadCloser, tr StreamTranslator) io.ReadCloser {
+	scanner := bufio.NewScanner(src)
+	scanner.Buffer(make([]byte, 4096), 1024*1024)
+	return &translatingStream{src: src, scanner: scanner, tr: tr}
+}
+
+func (s *translatingStream) Read(p []byte) (int, error) {
+	for s.out.Len() == 0 && !s.done {
+		s.next()
+	}
+	if s.out.Len() == 0 {
+		return 0, io.EOF
+	}
+	return s.out.Read(p)
+}
+
+// next verarbeitet eine Zeile des Quell-Streams.
+func (s *translatingStream) next() {
+	if !s.scanner.Scan() {
+		if err := s.scanner.Err(); err != nil {
+			s.writeError(ClassifyError(err))
+		}
+		s.done = true
+		return
+	}
+	line := s.scanner.Text()
+	// event:-Zeilen sind redundant: der Typ steht auch im data:-JSON
+	if !strings.HasPrefix(line, "data:") {
+		return
+	}
+	payloads, err := s.tr.Translate(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+	for _, data := range payloads {
+		s.out.WriteString("data: " + data + "\n\n")
+	}
+	if err != nil {
+		s.writeError(ClassifyError(err))
+		s.done = true
+	}
+}
+
+// writeError gibt einen Fehler als OpenAI-Fehler-Chunk aus; der HTTP-Status
+// steht in "code", damit StreamChunkError die Fehlerklasse rekonstruiert.
+func (s *translatingStream) writeError(apiErr *APIError) {
+	code := apiErr.StatusCode
+	if code == 0 {
+		code = 502
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": apiErr.Message,
+			"type":    apiErr.Type,
+			"code":    code,
+		},
+	})
+	s.out.WriteString("data: " + string(data) + "\n\n")
+}
+
+func (s *translatingStream) Close() error {
+	return s.src.Close()
+}
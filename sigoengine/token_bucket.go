@@ -0,0 +1,47 @@
+//**********************************************************************
+//      sigoengine/token_bucket.go
+//**********************************************************************
+//  Beschreibung: Token-Bucket für die RPM/TPM-Limits des RateLimiters.
+//  Der Bucket füllt sich kontinuierlich mit perMinute/60 pro Sekunde bis
+//  zur Kapazität; ein neuer Bucket startet voll. Nachträgliche
+//  Korrekturen (echte statt geschätzter Tokens) dürfen ihn ins Minus
+//  ziehen, der nächste Call wartet dann entsprechend länger.
+//**********************************************************************
+
+package sigoengine
+
+import (
+	"time"
+)
+
+// tokenBucket ist der Füllstand eines Buckets; Kapazität und Rate kommen
+// bei jedem Zugriff aus den aktuellen Limits.
+type tokenBucket struct {
+	level float64
+	last  time.Time
+}
+
+// newTokenBucket erzeugt einen vollen Bucket.
+func newTokenBucket(capacity int, now time.Time) *tokenBucket {
+	return &tokenBucket{level: float64(capacity), last: now}
+}
+
+// refill füllt den Bucket für die seit dem letzten Zugriff vergangene Zeit auf.
+func (b *tokenBucket) refill(now time.Time, capacity, perMinute int) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.level += elapsed.Minutes() * float64(perMinute)
+		b.last = now
+	}
+	if b.level > float64(capacity) {
+		b.level = float64(capacity)
+	}
+}
+
+// waitFor liefert die Zeit, bis need Tokens verfügbar sind (0 = sofort).
+func (b *tokenBucket) waitFor(need float64, perMinute int) time.Duration {
+	missing := need - b.level
+	if missing <= 0 {
+		return 0
+	}
+	return time.Duration(missing / float64(perMinute) * float64(time.Minute))
+}
@@ -0,0 +1,322 @@
+//**********************************************************************
+//      sigoengine/tokenizer.go
+//**********************************************************************
+//  Beschreibung: Lokaler BPE-Tokenizer für Token-Zählungen ohne
+//  Provider-Call (EstimateUsage, Kontextfenster-Pre-Flight, Kosten).
+//  Encodings cl100k_base und o200k_base: Pre-Tokenizer nach den
+//  tiktoken-Split-Mustern, danach Byte-Pair-Merge über die Ranks aus
+//  <name>.tiktoken (per LoadTokenizerVocab aus <data-dir>/tokenizers;
+//  die Vokabulare liegen nicht im Repository). Ohne Vokabular zählt eine
+//  kalibrierte Näherung je Pre-Token (Exact() == false).
+//  Zuordnung Modell → Encoding: Model.Tokenizer aus der Registry, sonst
+//  nach Modellfamilie (tokenizerFamilies).
+//**********************************************************************
+
+package sigoengine
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Namen der unterstützten Encodings.
+const (
+	TokenizerCL100K = "cl100k_base"
+	TokenizerO200K  = "o200k_base"
+)
+
+// maxBPEPiece begrenzt die Länge eines Pre-Tokens für den Byte-Pair-Merge
+// (quadratischer Aufwand); längere Stücke werden abschnittsweise gezählt.
+const maxBPEPiece = 1024
+
+// approxProfile kalibriert die Näherung eines Encodings ohne Vokabular.
+type approxProfile struct {
+	asciiPerToken float64 // ASCII-Buchstaben pro Token
+	latinExtra    float64 // Zuschlag je Nicht-ASCII-Buchstabe lateinischer Schrift
+	cjkPerChar    float64 // Tokens je CJK-Zeichen
+	otherPerToken float64 // Buchstaben anderer Schriften pro Token
+	symbolTokens  float64 // Tokens je Nicht-ASCII-Symbol (Emoji, ...)
+}
+
+// Tokenizer zählt Tokens nach einem Encoding.
+type Tokenizer struct {
+	name    string
+	split   func(string) []string
+	profile approxProfile
+
+	mu    sync.RWMutex
+	ranks map[string]int // nil = Näherung
+}
+
+// tokenizers sind die unterstützten Encodings.
+var tokenizers = map[string]*Tokenizer{
+	TokenizerCL100K: {
+		name:    TokenizerCL100K,
+		split:   splitCL100K,
+		profile: approxProfile{asciiPerToken: 6, latinExtra: 0.6, cjkPerChar: 1.2, otherPerToken: 2.5, symbolTokens: 2},
+	},
+	TokenizerO200K: {
+		name:    TokenizerO200K,
+		split:   splitO200K,
+		profile: approxProfile{asciiPerToken: 6.5, latinExtra: 0.3, cjkPerChar: 0.75, otherPerToken: 3.5, symbolTokens: 1.5},
+	},
+}
+
+// tokenizerFamilies ordnet Modellfamilien (Präfix der Modell-ID ohne
+// Provider) ihrem Encoding zu. Erster Treffer gewinnt; Modelle ohne
+// eigenes öffentliches Vokabular (Qwen, GLM, Kimi, DeepSeek) nutzen das
+// Encoding mit der ähnlichsten Abdeckung. Default: cl100k_base.
+var tokenizerFamilies = []struct {
+	prefix string
+	name   string
+}{
+	{"gpt-4o", TokenizerO200K},
+	{"chatgpt-4o", TokenizerO200K},
+	{"gpt-4.1", TokenizerO200K},
+	{"gpt-4.5", TokenizerO200K},
+	{"gpt-5", TokenizerO200K},
+	{"gpt-oss", TokenizerO200K},
+	{"o1", TokenizerO200K},
+	{"o3", TokenizerO200K},
+	{"o4", TokenizerO200K},
+	{"qwen", TokenizerO200K},
+	{"glm", TokenizerO200K},
+	{"kimi", TokenizerO200K},
+	{"moonshot", TokenizerO200K},
+	{"deepseek", TokenizerO200K},
+	{"gpt-4", TokenizerCL100K},
+	{"gpt-3.5", TokenizerCL100K},
+	{"text-embedding", TokenizerCL100K},
+}
+
+// GetTokenizer liefert ein Encoding nach Namen.
+func GetTokenizer(name string) (*Tokenizer, bool) {
+	t, ok := tokenizers[name]
+	return t, ok
+}
+
+// TokenizerForModel liefert das Encoding eines Modells (ID oder Shortcode):
+// Model.Tokenizer aus der Registry, sonst nach Modellfamilie.
+func TokenizerForModel(model string) *Tokenizer {
+	if m, ok := GetModelByID(ResolveModelName(model)); ok && m.Tokenizer != "" {
+		if t, ok := tokenizers[m.Tokenizer]; ok {
+			return t
+		}
+	}
+	return tokenizers[tokenizerFamily(model)]
+}
+
+// tokenizerFamily bestimmt das Encoding aus dem Modellnamen.
+func tokenizerFamily(model string) string {
+	name := strings.ToLower(ResolveModelName(model))
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	for _, f := range tokenizerFamilies {
+		if strings.HasPrefix(name, f.prefix) {
+			return f.name
+		}
+	}
+	return TokenizerCL100K
+}
+
+// Name liefert den Namen des Encodings.
+func (t *Tokenizer) Name() string {
+	return t.name
+}
+
+// Exact meldet, ob ein Vokabular geladen ist (sonst Näherung).
+func (t *Tokenizer) Exact() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.ranks != nil
+}
+
+// Count zählt die Tokens eines Textes (ohne Spezial-Tokens).
+func (t *Tokenizer) Count(text string) int {
+	if text == "" {
+		return 0
+	}
+	t.mu.RLock()
+	ranks := t.ranks
+	t.mu.RUnlock()
+
+	tokens := 0
+	for _, piece := range t.split(text) {
+		if ranks == nil {
+			tokens += t.approxPiece(piece)
+			continue
+		}
+		for len(piece) > maxBPEPiece {
+			cut := maxBPEPiece
+			for cut > 0 && !utf8.RuneStart(piece[cut]) {
+				cut--
+			}
+			tokens += bytePairCount([]byte(piece[:cut]), ranks)
+			piece = piece[cut:]
+		}
+		tokens += bytePairCount([]byte(piece), ranks)
+	}
+	return tokens
+}
+
+// setRanks setzt das Vokabular des Encodings.
+func (t *Tokenizer) setRanks(ranks map[string]int) {
+	t.mu.Lock()
+	t.ranks = ranks
+	t.mu.Unlock()
+}
+
+// bytePairCount führt den Byte-Pair-Merge von tiktoken aus: solange
+// benachbarte Teile als Paar im Vokabular stehen, wird das Paar mit dem
+// kleinsten Rank verschmolzen. Ergebnis ist die Zahl der Teile.
+func bytePairCount(piece []byte, ranks map[string]int) int {
+	if len(piece) <= 1 {
+		return len(piece)
+	}
+	if _, ok := ranks[string(piece)]; ok {
+		return 1
+	}
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		best, bestIdx := math.MaxInt, -1
+		for i := 0; i+2 < len(bounds); i++ {
+			if r, ok := ranks[string(piece[bounds[i]:bounds[i+2]])]; ok && r < best {
+				best, bestIdx = r, i
+			}
+		}
+		if bestIdx < 0 {
+			break
+		}
+		bounds = append(bounds[:bestIdx+1], bounds[bestIdx+2:]...)
+	}
+	return len(bounds) - 1
+}
+
+// approxPiece schätzt die Tokens eines Pre-Tokens ohne Vokabular.
+// Häufige Wörter sind ein Token (inkl. führendem Leerzeichen), lange
+// Wörter zerfallen in Stücke von ca. asciiPerToken Buchstaben; Umlaute,
+// CJK und andere Schriften kosten mehr.
+func (t *Tokenizer) approxPiece(piece string) int {
+	var ascii, latin, cjk, other, digits, punct, symbols, space, upperRuns int
+	prevUpper := false
+	for _, r := range piece {
+		switch {
+		case unicode.IsSpace(r):
+			space++
+		case r < utf8.RuneSelf && unicode.IsLetter(r):
+			ascii++
+			upper := unicode.IsUpper(r)
+			if upper && !prevUpper {
+				upperRuns++
+			}
+			prevUpper = upper
+		case unicode.IsNumber(r):
+			digits++
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			cjk++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		case unicode.IsLetter(r) || unicode.IsMark(r):
+			other++
+		case r < utf8.RuneSelf:
+			punct++
+		default:
+			symbols++
+		}
+	}
+	p := t.profile
+	var tokens float64
+	switch {
+	case ascii+latin+cjk+other > 0:
+		tokens = float64(ascii+latin)/p.asciiPerToken +
+			float64(latin)*p.latinExtra +
+			float64(cjk)*p.cjkPerChar +
+			float64(other)/p.otherPerToken
+		if n := float64(upperRuns - 1); n > tokens {
+			tokens = n // CamelCase trennt meist an Großbuchstaben
+		}
+	case digits > 0:
+		tokens = math.Ceil(float64(digits) / 3)
+	case punct+symbols > 0:
+		tokens = float64(punct)/3 + float64(symbols)*p.symbolTokens
+	default:
+		tokens = float64(space) / 16
+	}
+	n := int(math.Ceil(tokens))
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// LoadTokenizerVocab lädt Vokabulare aus dir (<name>.tiktoken, Format von
+// tiktoken: "<base64-Token> <Rank>" je Zeile). Fehlende Dateien werden
+// übersprungen.
+func LoadTokenizerVocab(dir string) ([]string, error) {
+	var loaded []string
+	for name, t := range tokenizers {
+		path := filepath.Join(dir, name+".tiktoken")
+		f, err := os.Open(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return loaded, fmt.Errorf("%s lesen: %w", path, err)
+		}
+		ranks, err := parseTiktoken(f)
+		f.Close()
+		if err != nil {
+			return loaded, fmt.Errorf("%s: %w", path, err)
+		}
+		t.setRanks(ranks)
+		loaded = append(loaded, name)
+	}
+	return loaded, nil
+}
+
+// parseTiktoken liest ein Vokabular im tiktoken-Format.
+func parseTiktoken(r io.Reader) (map[string]int, error) {
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("Zeile %d: erwartet \"<base64> <rank>\"", line)
+		}
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("Zeile %d: %w", line, err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("Zeile %d: %w", line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("leeres Vokabular")
+	}
+	return ranks, nil
+}
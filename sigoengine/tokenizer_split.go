@@ -0,0 +1,236 @@
+//**********************************************************************
+//      sigoengine/tokenizer_split.go
+//**********************************************************************
+//  Beschreibung: Pre-Tokenizer der BPE-Encodings. Nachbau der
+//  tiktoken-Split-Muster als Scanner, da RE2 (regexp) keine Lookaheads
+//  kennt. cl100k_base:
+//    's|'t|'re|'ve|'m|'ll|'d | [^\r\n\p{L}\p{N}]?\p{L}+ | \p{N}{1,3}
+//    | ?[^\s\p{L}\p{N}]+[\r\n]* | \s*[\r\n]+ | \s+(?!\S) | \s+
+//  o200k_base trennt Wörter zusätzlich an Groß-/Kleinschreibung, hängt
+//  Kontraktionen an das Wort und erlaubt "/" nach Satzzeichen.
+//**********************************************************************
+
+package sigoengine
+
+import (
+	"unicode"
+)
+
+// contractions sind die Kontraktionen der Split-Muster (case-insensitiv).
+var contractions = []string{"s", "t", "re", "ve", "m", "ll", "d"}
+
+// splitCL100K zerlegt Text nach dem Muster von cl100k_base.
+func splitCL100K(text string) []string {
+	r := []rune(text)
+	var pieces []string
+	for i := 0; i < len(r); {
+		end := matchContraction(r, i)
+		if end < 0 {
+			end = matchPrefixedLetters(r, i)
+		}
+		if end < 0 {
+			end = matchNumber(r, i)
+		}
+		if end < 0 {
+			end = matchPunct(r, i, false)
+		}
+		if end < 0 {
+			end = matchSpace(r, i)
+		}
+		if end <= i {
+			end = i + 1
+		}
+		pieces = append(pieces, string(r[i:end]))
+		i = end
+	}
+	return pieces
+}
+
+// splitO200K zerlegt Text nach dem Muster von o200k_base.
+func splitO200K(text string) []string {
+	r := []rune(text)
+	var pieces []string
+	for i := 0; i < len(r); {
+		end := matchCasedWord(r, i, true)
+		if end < 0 {
+			end = matchCasedWord(r, i, false)
+		}
+		if end >= 0 {
+			if c := matchContraction(r, end); c > 0 {
+				end = c
+			}
+		}
+		if end < 0 {
+			end = matchNumber(r, i)
+		}
+		if end < 0 {
+			end = matchPunct(r, i, true)
+		}
+		if end < 0 {
+			end = matchSpace(r, i)
+		}
+		if end <= i {
+			end = i + 1
+		}
+		pieces = append(pieces, string(r[i:end]))
+		i = end
+	}
+	return pieces
+}
+
+// isNewline: [\r\n]
+func isNewline(c rune) bool {
+	return c == '\r' || c == '\n'
+}
+
+// isWordPrefix: [^\r\n\p{L}\p{N}]
+func isWordPrefix(c rune) bool {
+	return !isNewline(c) && !unicode.IsLetter(c) && !unicode.IsNumber(c)
+}
+
+// isPunct: [^\s\p{L}\p{N}]
+func isPunct(c rune) bool {
+	return !unicode.IsSpace(c) && !unicode.IsLetter(c) && !unicode.IsNumber(c)
+}
+
+// isUpperish: [\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]
+func isUpperish(c rune) bool {
+	return unicode.In(c, unicode.Lu, unicode.Lt, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+// isLowerish: [\p{Ll}\p{Lm}\p{Lo}\p{M}]
+func isLowerish(c rune) bool {
+	return unicode.In(c, unicode.Ll, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+// matchContraction: 's|'t|'re|'ve|'m|'ll|'d (Ende oder -1).
+func matchContraction(r []rune, i int) int {
+	if i >= len(r) || r[i] != '\'' {
+		return -1
+	}
+	for _, c := range contractions {
+		end := i + 1 + len(c)
+		if end > len(r) {
+			continue
+		}
+		ok := true
+		for k, ch := range c {
+			if unicode.ToLower(r[i+1+k]) != ch {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return end
+		}
+	}
+	return -1
+}
+
+// matchPrefixedLetters: [^\r\n\p{L}\p{N}]?\p{L}+
+func matchPrefixedLetters(r []rune, i int) int {
+	start := i
+	if !unicode.IsLetter(r[i]) {
+		if !isWordPrefix(r[i]) || i+1 >= len(r) || !unicode.IsLetter(r[i+1]) {
+			return -1
+		}
+		start = i + 1
+	}
+	end := start
+	for end < len(r) && unicode.IsLetter(r[end]) {
+		end++
+	}
+	return end
+}
+
+// matchCasedWord: Wort-Alternativen von o200k_base (ohne Kontraktion).
+// lowerFirst: [^\r\n\p{L}\p{N}]?[Upperish]*[Lowerish]+
+// sonst:      [^\r\n\p{L}\p{N}]?[Upperish]+[Lowerish]*
+func matchCasedWord(r []rune, i int, lowerFirst bool) int {
+	try := func(start int) int {
+		k := start
+		for k < len(r) && isUpperish(r[k]) {
+			k++
+		}
+		if !lowerFirst {
+			if k == start {
+				return -1
+			}
+			for k < len(r) && isLowerish(r[k]) {
+				k++
+			}
+			return k
+		}
+		// Upperish* gibt Zeichen zurück, bis Lowerish+ passt (Backtracking)
+		for m := k; m >= start; m-- {
+			if m < len(r) && isLowerish(r[m]) {
+				end := m
+				for end < len(r) && isLowerish(r[end]) {
+					end++
+				}
+				return end
+			}
+		}
+		return -1
+	}
+	if isWordPrefix(r[i]) && i+1 < len(r) {
+		if end := try(i + 1); end >= 0 {
+			return end
+		}
+	}
+	return try(i)
+}
+
+// matchNumber: \p{N}{1,3}
+func matchNumber(r []rune, i int) int {
+	end := i
+	for end < len(r) && end-i < 3 && unicode.IsNumber(r[end]) {
+		end++
+	}
+	if end == i {
+		return -1
+	}
+	return end
+}
+
+// matchPunct: ' ?[^\s\p{L}\p{N}]+[\r\n]*' (o200k: [\r\n/]*)
+func matchPunct(r []rune, i int, slash bool) int {
+	start := i
+	if r[i] == ' ' {
+		start++
+	}
+	end := start
+	for end < len(r) && isPunct(r[end]) {
+		end++
+	}
+	if end == start {
+		return -1
+	}
+	for end < len(r) && (isNewline(r[end]) || (slash && r[end] == '/')) {
+		end++
+	}
+	return end
+}
+
+// matchSpace: \s*[\r\n]+ | \s+(?!\S) | \s+
+func matchSpace(r []rune, i int) int {
+	end := i
+	lastNewline := -1
+	for end < len(r) && unicode.IsSpace(r[end]) {
+		if isNewline(r[end]) {
+			lastNewline = end
+		}
+		end++
+	}
+	switch {
+	case end == i:
+		return -1
+	case lastNewline >= 0:
+		return lastNewline + 1
+	case end == len(r) || end-i == 1:
+		return end
+	default:
+		// Das letzte Leerzeichen gehört zum folgenden Wort
+		return end - 1
+	}
+}
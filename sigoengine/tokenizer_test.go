@@ -0,0 +1,155 @@
+package sigoengine
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitCL100K(t *testing.T) {
+	got := splitCL100K("Hello world's 12345 foo  bar\n\n  x")
+	want := []string{"Hello", " world", "'s", " ", "123", "45", " foo", " ", " bar", "\n\n", " ", " x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("splitCL100K = %q, want %q", got, want)
+	}
+	if joined := strings.Join(splitCL100K("a\tb  (x+y);\r\n"), ""); joined != "a\tb  (x+y);\r\n" {
+		t.Fatalf("Pieces ergeben nicht den Text: %q", joined)
+	}
+}
+
+func TestSplitO200K(t *testing.T) {
+	got := splitO200K("getUserName HTTPServer don't a/b")
+	want := []string{"get", "User", "Name", " HTTPServer", " don't", " a", "/b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("splitO200K = %q, want %q", got, want)
+	}
+}
+
+func TestTokenizerBytePairMerge(t *testing.T) {
+	tok := &Tokenizer{name: "test", split: splitCL100K}
+	tok.setRanks(map[string]int{"a": 0, "b": 1, "c": 2, " ": 3, "ab": 4, "abc": 5})
+	if !tok.Exact() {
+		t.Fatal("Exact = false mit Vokabular")
+	}
+	// "abcab": ab|c|ab → abc|ab; " ab": " "|ab
+	if got := tok.Count("abcab"); got != 2 {
+		t.Errorf("Count(abcab) = %d, want 2", got)
+	}
+	if got := tok.Count("abc ab"); got != 3 {
+		t.Errorf("Count(abc ab) = %d, want 3", got)
+	}
+}
+
+// approxTokenizer liefert ein Encoding ohne Vokabular (Näherung), auch
+// wenn für das globale eines geladen ist.
+func approxTokenizer(name string) *Tokenizer {
+	t := tokenizers[name]
+	return &Tokenizer{name: t.name, split: t.split, profile: t.profile}
+}
+
+func TestTokenizerApprox(t *testing.T) {
+	cl, o200 := approxTokenizer(TokenizerCL100K), approxTokenizer(TokenizerO200K)
+	if cl.Exact() || o200.Exact() {
+		t.Fatal("Exact = true ohne Vokabular")
+	}
+	// Häufige englische Wörter: ein Token je Wort (wie cl100k_base)
+	if got := cl.Count("The quick brown fox jumps over the lazy dog."); got != 10 {
+		t.Errorf("Count(englisch) = %d, want 10", got)
+	}
+	if cl.Count("") != 0 {
+		t.Error("Count(\"\") != 0")
+	}
+	// o200k kodiert CJK deutlich dichter als cl100k
+	cjk := "你好世界，今天天气很好。"
+	if o200.Count(cjk) >= cl.Count(cjk) {
+		t.Errorf("CJK: o200k %d >= cl100k %d", o200.Count(cjk), cl.Count(cjk))
+	}
+	// Lange Wörter und Umlaute kosten mehr als kurze
+	if cl.Count(" Donaudampfschifffahrtsgesellschaft") <= cl.Count(" Donau") {
+		t.Error("langes Wort nicht teurer als kurzes")
+	}
+}
+
+// TestTokenizerVocabCounts prüft die Zählung mit den echten Vokabularen
+// gegen bekannte tiktoken-Ergebnisse. Die Dateien liegen nicht im
+// Repository: SIGO_TOKENIZER_DIR=<verzeichnis mit *.tiktoken> go test
+func TestTokenizerVocabCounts(t *testing.T) {
+	dir := os.Getenv("SIGO_TOKENIZER_DIR")
+	if dir == "" {
+		t.Skip("SIGO_TOKENIZER_DIR nicht gesetzt: keine tiktoken-Vokabulare, exakte Zählung nicht geprüft")
+	}
+	for _, name := range []string{TokenizerCL100K, TokenizerO200K} {
+		tok := tokenizers[name]
+		prev := tok.ranks
+		t.Cleanup(func() { tok.setRanks(prev) })
+	}
+	loaded, err := LoadTokenizerVocab(dir)
+	if err != nil || len(loaded) != 2 {
+		t.Fatalf("LoadTokenizerVocab(%s) = %v, %v", dir, loaded, err)
+	}
+
+	tests := []struct {
+		text          string
+		cl100k, o200k int
+	}{
+		{"tiktoken is great!", 6, 6},
+		{"antidisestablishmentarianism", 6, 5},
+		{"2 + 2 = 4", 7, 7},
+		{"お誕生日おめでとう", 9, 8},
+	}
+	cl, _ := GetTokenizer(TokenizerCL100K)
+	o200, _ := GetTokenizer(TokenizerO200K)
+	for _, tt := range tests {
+		if got := cl.Count(tt.text); got != tt.cl100k {
+			t.Errorf("cl100k_base Count(%q) = %d, want %d", tt.text, got, tt.cl100k)
+		}
+		if got := o200.Count(tt.text); got != tt.o200k {
+			t.Errorf("o200k_base Count(%q) = %d, want %d", tt.text, got, tt.o200k)
+		}
+	}
+}
+
+func TestTokenizerForModel(t *testing.T) {
+	tests := map[string]string{
+		"gpt-4o-mini":          TokenizerO200K,
+		"openai/gpt-5":         TokenizerO200K,
+		"o3-mini":              TokenizerO200K,
+		"gpt-4":                TokenizerCL100K,
+		"claude-sonnet-4-5":    TokenizerCL100K,
+		"llama3.1:8b":          TokenizerCL100K,
+		"text-embedding-3-big": TokenizerCL100K,
+	}
+	for model, want := range tests {
+		if got := TokenizerForModel(model).Name(); got != want {
+			t.Errorf("TokenizerForModel(%q) = %s, want %s", model, got, want)
+		}
+	}
+}
+
+func TestLoadTokenizerVocab(t *testing.T) {
+	dir := t.TempDir()
+	if loaded, err := LoadTokenizerVocab(dir); err != nil || len(loaded) != 0 {
+		t.Fatalf("leeres Verzeichnis: %v, %v", loaded, err)
+	}
+
+	tok, _ := GetTokenizer(TokenizerCL100K)
+	prev := tok.ranks
+	t.Cleanup(func() { tok.setRanks(prev) })
+
+	// "a"=YQ==, "b"=Yg==, "ab"=YWI=
+	os.WriteFile(filepath.Join(dir, "cl100k_base.tiktoken"), []byte("YQ== 0\nYg== 1\nYWI= 2\n"), 0644)
+	loaded, err := LoadTokenizerVocab(dir)
+	if err != nil || !reflect.DeepEqual(loaded, []string{TokenizerCL100K}) {
+		t.Fatalf("LoadTokenizerVocab = %v, %v", loaded, err)
+	}
+	if !tok.Exact() || tok.Count("abab") != 2 {
+		t.Fatalf("Vokabular nicht aktiv: exact=%v count=%d", tok.Exact(), tok.Count("abab"))
+	}
+
+	os.WriteFile(filepath.Join(dir, "cl100k_base.tiktoken"), []byte("kein-base64\n"), 0644)
+	if _, err := LoadTokenizerVocab(dir); err == nil {
+		t.Error("expected error for invalid vocab")
+	}
+}